@@ -0,0 +1,47 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+)
+
+// 標準のアセット（extra assetsは含まない）
+//
+//go:embed assets/*.png assets/*.mp4 assets/combo assets/score assets/judge
+var embeddedAssets embed.FS
+
+// 使うアセットのディレクトリを決め、足りないファイルを同梱のアセットから書き出す
+//
+// overrideが空の場合は実行ファイルの隣のassetsを使い、そこに書き込めない場合はキャッシュディレクトリを使う
+func resolveAssetsDir(override string) (string, error) {
+	assets, err := fs.Sub(embeddedAssets, "assets")
+	if err != nil {
+		return "", err
+	}
+	if override != "" {
+		dir, err := filepath.Abs(override)
+		if err != nil {
+			return "", err
+		}
+		return dir, pjsekaioverlay.ExtractAssets(assets, dir)
+	}
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(filepath.Dir(executablePath), "assets")
+	if err := pjsekaioverlay.ExtractAssets(assets, dir); err == nil {
+		return dir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(cacheDir, "pjsekai-overlay", "assets-"+pjsekaioverlay.Version)
+	return dir, pjsekaioverlay.ExtractAssets(assets, dir)
+}
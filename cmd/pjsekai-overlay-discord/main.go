@@ -0,0 +1,35 @@
+// Discordボットのサーバーの例。環境変数で設定を渡して起動し、
+// 表示されたアドレスをDiscordのInteractions Endpoint URLに指定する。
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/discord"
+)
+
+func main() {
+	bot, err := discord.NewBot(os.Getenv("DISCORD_PUBLIC_KEY"), os.Getenv("DISCORD_APPLICATION_ID"), os.Getenv("DISCORD_BOT_TOKEN"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bot.Assets = os.Getenv("PJSEKAI_OVERLAY_ASSETS")
+
+	if err := bot.RegisterCommand(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	addr := os.Getenv("DISCORD_LISTEN_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	fmt.Printf("Listening on %s\n", addr)
+	if err := http.ListenAndServe(addr, bot); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
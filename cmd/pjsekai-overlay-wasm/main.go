@@ -0,0 +1,89 @@
+//go:build js && wasm
+
+// ブラウザで譜面のタイムライン（JSON）とジャケット・背景の画像を生成する。
+//
+//	GOOS=js GOARCH=wasm go build -o main.wasm ./cmd/pjsekai-overlay-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// main.wasm、wasm_exec.js、index.htmlを同じ場所に置いて開く。譜面サーバーがCORSを許可している必要がある。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+)
+
+type generateRequest struct {
+	ChartId         string `json:"chartId"`
+	TeamPower       int    `json:"teamPower"`
+	BackgroundStyle string `json:"backgroundStyle"`
+}
+
+func generate(requestJson string, progress func(step string)) (map[string]any, error) {
+	var request generateRequest
+	if err := json.Unmarshal([]byte(requestJson), &request); err != nil {
+		return nil, err
+	}
+	options := pjsekaioverlay.DefaultGenerateOptions
+	options.ChartId = request.ChartId
+	if request.TeamPower > 0 {
+		options.TeamPower = request.TeamPower
+	}
+	if request.BackgroundStyle != "" {
+		options.BackgroundStyle = request.BackgroundStyle
+	}
+	if options.ChartId == "" {
+		return nil, errors.New("譜面IDを入力して下さい。(Please enter the chart ID.)")
+	}
+
+	output := pjsekaioverlay.NewMemoryOutput()
+	timeline, err := pjsekaioverlay.New().GenerateTimeline(options, output, progress)
+	if err != nil {
+		return nil, err
+	}
+	timelineJson, err := json.Marshal(timeline)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]any{}
+	for _, name := range output.Names() {
+		data := output.File(name)
+		array := js.Global().Get("Uint8Array").New(len(data))
+		js.CopyBytesToJS(array, data)
+		files[name] = array
+	}
+	return map[string]any{"timeline": string(timelineJson), "files": files}, nil
+}
+
+func main() {
+	// pjsekaiOverlayGenerate(JSON文字列, 進捗のコールバック) はPromiseを返す
+	js.Global().Set("pjsekaiOverlayGenerate", js.FuncOf(func(this js.Value, args []js.Value) any {
+		requestJson := args[0].String()
+		callback := js.Undefined()
+		if len(args) > 1 {
+			callback = args[1]
+		}
+		return js.Global().Get("Promise").New(js.FuncOf(func(this js.Value, promiseArgs []js.Value) any {
+			resolve, reject := promiseArgs[0], promiseArgs[1]
+			// 通信はブロックするので、別のgoroutineで行う
+			go func() {
+				result, err := generate(requestJson, func(step string) {
+					if callback.Type() == js.TypeFunction {
+						callback.Invoke(step)
+					}
+				})
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New(err.Error()))
+					return
+				}
+				resolve.Invoke(result)
+			}()
+			return nil
+		}))
+	}))
+	select {}
+}
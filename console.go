@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"image"
+	"os"
 	"strings"
 
 	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
@@ -46,3 +48,38 @@ func AnsiColorEscape(color int) string {
 func ResetEscape() string {
 	return "\033[0m"
 }
+
+func RgbBackgroundEscape(rgb int) string {
+	return fmt.Sprintf("\033[48;2;%d;%d;%dm", (rgb>>16)&0xff, (rgb>>8)&0xff, rgb&0xff)
+}
+
+// 24bitカラーを表示できる端末か（Windows Terminalなど）
+func SupportsTrueColor() bool {
+	colorTerm := os.Getenv("COLORTERM")
+	return colorTerm == "truecolor" || colorTerm == "24bit" || os.Getenv("WT_SESSION") != ""
+}
+
+// 譜面の情報を、取得したサーバーの色で囲んで表示する
+func SourceHeader(source pjsekaioverlay.Source, title string, subtitle string) string {
+	bar := RgbColorEscape(source.Color) + "┃" + ResetEscape()
+	return fmt.Sprintf("  %s %s\n  %s %s\n  %s %s%s%s (%s)\n", bar, title, bar, subtitle, bar, RgbColorEscape(source.Color), source.Name, ResetEscape(), source.Host)
+}
+
+// 画像を上下2ピクセルずつ「▀」1文字に描き、端末に表示できる文字列にする
+func ImagePreview(img image.Image, width int) string {
+	bounds := img.Bounds()
+	height := width * bounds.Dy() / bounds.Dx()
+	pixel := func(x int, y int) int {
+		r, g, b, _ := img.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height).RGBA()
+		return int(r>>8)<<16 | int(g>>8)<<8 | int(b>>8)
+	}
+	var builder strings.Builder
+	for y := 0; y+1 < height; y += 2 {
+		builder.WriteString("  ")
+		for x := 0; x < width; x++ {
+			builder.WriteString(RgbColorEscape(pixel(x, y)) + RgbBackgroundEscape(pixel(x, y+1)) + "▀")
+		}
+		builder.WriteString(ResetEscape() + "\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+	"golang.org/x/sys/windows"
+)
+
+// 診断情報に残すログの上限（古いものから捨てる）
+const diagnosticsLogLimit = 256 * 1024
+
+// 失敗した時に診断情報を保存するか（ask, always, never。空の場合はdefaultDiagnosticsModeで決める）
+var diagnosticsMode = ""
+
+// 確認できるのは、引数なしで端末から起動した場合だけ（それ以外は保存しない）
+func defaultDiagnosticsMode(isOptionSpecified bool) string {
+	var mode uint32
+	if !isOptionSpecified && windows.GetConsoleMode(windows.Handle(os.Stdin.Fd()), &mode) == nil {
+		return "ask"
+	}
+	return "never"
+}
+
+// 診断情報に含める譜面の情報（取得できた分だけ）
+var diagnosticsChart struct {
+	id     string
+	source string
+}
+
+var escapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// 画面に出力した内容を、診断情報用に保持する
+type diagnosticsLog struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (l *diagnosticsLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	if len(l.buf) > diagnosticsLogLimit {
+		l.buf = l.buf[len(l.buf)-diagnosticsLogLimit:]
+	}
+	return len(p), nil
+}
+
+// 色などのエスケープシーケンスを除いたログ
+func (l *diagnosticsLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return escapePattern.ReplaceAllString(string(l.buf), "")
+}
+
+// 標準出力をログにも書き込むようにし、元に戻す関数を返す
+func startDiagnosticsLog(log *diagnosticsLog) func() {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(stdout, log), reader)
+		close(done)
+	}()
+	return func() {
+		os.Stdout = stdout
+		writer.Close()
+		<-done
+		reader.Close()
+	}
+}
+
+// runを実行し、パニックした場合やFAILを表示した場合に、Issueに添付できる診断情報のzipを書き出せるようにする
+func runWithDiagnostics(defaultMode string, run func()) {
+	log := &diagnosticsLog{}
+	stop := startDiagnosticsLog(log)
+	var panicValue any
+	var stack []byte
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicValue = r
+				stack = debug.Stack()
+			}
+		}()
+		run()
+	}()
+	stop()
+
+	// --diagnosticsはrunの中で読み込まれる
+	mode := diagnosticsMode
+	if mode == "" {
+		mode = defaultMode
+	}
+
+	if panicValue != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:予期しないエラーが発生しました。(An unexpected error occurred.) [%v]", panicValue)))
+	} else if !strings.Contains(log.String(), "FAIL") {
+		return
+	}
+
+	switch mode {
+	case "never":
+		return
+	case "always":
+	default:
+		fmt.Print(color.YellowString("\n診断情報をzipファイルに保存しますか？ (Save diagnostics to a zip file for bug reports?) [y/N] "))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			return
+		}
+	}
+
+	path, err := writeDiagnostics(log.String(), panicValue, stack)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	fmt.Println(color.GreenString("診断情報を保存しました。(Diagnostics saved.) -> %s", path))
+	fmt.Println("個人情報が含まれていないか確認してから、GitHubのIssueに添付して下さい。(Check it for personal information, then attach it to your GitHub issue.)")
+}
+
+func writeDiagnostics(log string, panicValue any, stack []byte) (string, error) {
+	path, err := filepath.Abs(fmt.Sprintf("pjsekai-overlay-diagnostics-%s.zip", time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+
+	var report strings.Builder
+	osVersion := windows.RtlGetVersion()
+	fmt.Fprintf(&report, "version: %s\n", pjsekaioverlay.Version)
+	fmt.Fprintf(&report, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "windows: %d.%d.%d\n", osVersion.MajorVersion, osVersion.MinorVersion, osVersion.BuildNumber)
+	fmt.Fprintf(&report, "time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&report, "args: %q\n", os.Args[1:])
+	fmt.Fprintf(&report, "chart: %s\n", diagnosticsChart.id)
+	fmt.Fprintf(&report, "source: %s\n", diagnosticsChart.source)
+	if panicValue != nil {
+		fmt.Fprintf(&report, "\npanic: %v\n\n%s", panicValue, stack)
+	}
+
+	zipWriter := zip.NewWriter(file)
+	for _, entry := range []struct{ name, content string }{
+		{"report.txt", report.String()},
+		{"log.txt", log},
+	} {
+		writer, err := zipWriter.Create(entry.name)
+		if err == nil {
+			_, err = io.WriteString(writer, entry.content)
+		}
+		if err != nil {
+			return "", fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return "", fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+// 処理中のジョブのファイルの接尾辞。中断した場合は起動時に待機中に戻す
+const daemonRunningSuffix = ".running"
+
+// 出力先に書き込むジョブの状態（serveの/jobs/{id}と同じ形式）
+const daemonStatusFileName = "status.json"
+
+type daemon struct {
+	client  *pjsekaioverlay.Client
+	assets  string
+	jobsDir string
+	workDir string
+	// 空の場合はアップロードしない
+	upload string
+}
+
+// 待機中のジョブ（jobsDirの*.json）を名前順に返す
+func (d *daemon) pendingJobs() ([]string, error) {
+	entries, err := os.ReadDir(d.jobsDir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *daemon) writeStatus(job serveJob) {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return
+	}
+	// 読み込む側が書きかけのファイルを読まないよう、名前を変えて置き換える
+	path := filepath.Join(job.outDir, daemonStatusFileName)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+	}
+}
+
+// ジョブを1つ処理し、ジョブのファイルをdoneかfailedに移す
+func (d *daemon) run(name string) {
+	id := strings.TrimSuffix(name, ".json")
+	runningPath := filepath.Join(d.jobsDir, name+daemonRunningSuffix)
+	job := serveJob{Id: id, Status: "running", outDir: filepath.Join(d.workDir, id)}
+
+	err := func() error {
+		if err := os.MkdirAll(job.outDir, 0755); err != nil {
+			return err
+		}
+		d.writeStatus(job)
+		data, err := os.ReadFile(runningPath)
+		if err != nil {
+			return err
+		}
+		request := defaultServeRequest()
+		if err := json.Unmarshal(data, &request); err != nil {
+			return fmt.Errorf("ジョブの読み込みに失敗しました。(Loading the job failed.) [%s]", err)
+		}
+		if err := request.Validate(); err != nil {
+			return err
+		}
+		options := request.generateOptions(d.assets, job.outDir)
+		pipeline, err := d.client.NewPipeline(options, pjsekaioverlay.DirOutput(options.OutDir), filepath.Join(options.OutDir, pjsekaioverlay.PipelineStateFileName))
+		if err != nil {
+			return err
+		}
+		defer func() { job.Timings = pipeline.Timings.List() }()
+		err = pipeline.Run(func(step string) {
+			job.Step = step
+			d.writeStatus(job)
+		})
+		if err != nil || d.upload == "" {
+			return err
+		}
+		job.Step = "upload"
+		d.writeStatus(job)
+		output, err := d.client.OpenOutput(strings.TrimSuffix(d.upload, "/") + "/" + id)
+		if err != nil {
+			return err
+		}
+		return pjsekaioverlay.UploadDir(job.outDir, output)
+	}()
+
+	result := "done"
+	if err != nil {
+		result = "failed"
+		job.Error = err.Error()
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s: %s", id, err.Error())))
+	} else {
+		fmt.Println(color.GreenString(fmt.Sprintf("OK:%s", id)))
+	}
+	job.Status = result
+	job.Step = ""
+	if job.outDir != "" {
+		d.writeStatus(job)
+	}
+	if err := os.MkdirAll(filepath.Join(d.jobsDir, result), 0755); err == nil {
+		err = os.Rename(runningPath, filepath.Join(d.jobsDir, result, name))
+	}
+	if err != nil {
+		fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+	}
+}
+
+// ジョブのディレクトリを監視し、置かれたジョブ（serveの/generateと同じJSON）を順に生成する常駐プロセスを起動する
+//
+// 結果は出力先の<ジョブ名>/に、状態はその中のstatus.jsonに書き込み、ジョブのファイルはdone/かfailed/に移す
+func daemonMain(args []string) {
+	Title()
+
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	var jobsDir string
+	flags.StringVar(&jobsDir, "jobs-dir", "./dist/jobs", "ジョブのファイル（*.json）を置くディレクトリを指定します。\nEnter the directory to watch for job files. (*.json)")
+	var workDir string
+	flags.StringVar(&workDir, "work-dir", "./dist/daemon", "生成したファイルの保存先を指定します。(Enter the directory to store generated files.)")
+	var cacheDir string
+	flags.StringVar(&cacheDir, "cache-dir", "./dist/cache", "譜面データやジャケットをキャッシュするディレクトリを指定します。（空で無効）\nEnter the directory to cache chart data and images. (empty to disable)")
+	var workers int
+	flags.IntVar(&workers, "workers", 1, "同時に処理するジョブの数を指定します。(Enter the number of jobs processed at the same time.)")
+	var requestInterval time.Duration
+	flags.DurationVar(&requestInterval, "request-interval", pjsekaioverlay.DefaultRequestInterval, "同じサーバーへのリクエストの最小の間隔を指定します。(例：500ms)\nEnter the minimum interval between requests to the same server. (e.g. 500ms)")
+	var poll time.Duration
+	flags.DurationVar(&poll, "poll", 2*time.Second, "ジョブのディレクトリを確認する間隔を指定します。\nEnter how often to check the jobs directory.")
+	var assetsDir string
+	flags.StringVar(&assetsDir, "assets-dir", "", "アセットのディレクトリを指定します。（空で実行ファイルの隣のassets）\nEnter the assets directory. (empty for assets next to the executable)")
+	var upload string
+	flags.StringVar(&upload, "upload", "", "生成したファイルを<ジョブ名>/にアップロードする先を指定します。（--uploadと同じ形式）\nEnter a destination to upload each job's files to under <job name>/. (same format as --upload)")
+	flags.Parse(args)
+
+	if workers <= 0 || poll <= 0 {
+		fmt.Println(color.RedString("FAIL:--workersと--pollには0より大きい値を指定して下さい。(--workers and --poll must be greater than 0.)"))
+		return
+	}
+	assets, err := resolveAssetsDir(assetsDir)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	// 前回中断したジョブは、途中経過から再開する
+	if running, err := filepath.Glob(filepath.Join(jobsDir, "*.json"+daemonRunningSuffix)); err == nil {
+		for _, path := range running {
+			os.Rename(path, strings.TrimSuffix(path, daemonRunningSuffix))
+		}
+	}
+
+	d := &daemon{
+		client: pjsekaioverlay.New(
+			pjsekaioverlay.WithCacheDir(cacheDir),
+			pjsekaioverlay.WithRequestInterval(requestInterval),
+		),
+		assets:  assets,
+		jobsDir: jobsDir,
+		workDir: workDir,
+		upload:  upload,
+	}
+
+	if upload != "" {
+		if _, err := d.client.OpenOutput(upload); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	queue := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				d.run(name)
+			}
+		}()
+	}
+
+	fmt.Printf("- ジョブの監視を開始しました (Watching for jobs): %s\n", color.CyanString(jobsDir))
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+loop:
+	for {
+		names, err := d.pendingJobs()
+		if err != nil {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		}
+		for _, name := range names {
+			// 名前を変えられたものだけを処理するので、複数のプロセスで同じディレクトリを監視しても重複しない
+			if err := os.Rename(filepath.Join(jobsDir, name), filepath.Join(jobsDir, name+daemonRunningSuffix)); err != nil {
+				continue
+			}
+			select {
+			case queue <- name:
+			case <-ctx.Done():
+				os.Rename(filepath.Join(jobsDir, name+daemonRunningSuffix), filepath.Join(jobsDir, name))
+				break loop
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	// 処理中のジョブは最後まで処理する
+	fmt.Println("- 処理中のジョブの完了を待っています (Waiting for running jobs)...")
+	close(queue)
+	wg.Wait()
+}
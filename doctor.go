@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+type doctorStatus int
+
+const (
+	doctorOk doctorStatus = iota
+	// 動作はするが、確認した方がよいもの
+	doctorWarn
+	doctorFail
+)
+
+type doctorResult struct {
+	status doctorStatus
+	detail string
+	// 問題があった場合の対処方法
+	fix string
+}
+
+type doctorCheck struct {
+	name string
+	run  func() doctorResult
+}
+
+// 動作環境を確認し、問題があれば対処方法を表示する
+func doctorMain(args []string) {
+	Title()
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	executableDir := filepath.Dir(executablePath)
+
+	checks := []doctorCheck{}
+	for _, source := range pjsekaioverlay.DefaultSources {
+		checks = append(checks, doctorCheck{source.Name + " (" + source.Host + ")", func() doctorResult {
+			return checkDoctorHost("https://"+source.Host+"/sonolus/info", "ファイアウォールやプロキシの設定を確認するか、--ip-version 4を試して下さい。(Check your firewall or proxy settings, or try --ip-version 4.)")
+		}})
+	}
+	checks = append(checks,
+		doctorCheck{"GitHub (api.github.com)", func() doctorResult {
+			return checkDoctorHost("https://api.github.com", "更新の確認とupdate-assetsが使えません。(Update checks and update-assets won't work.)")
+		}},
+		doctorCheck{"AviUtl", checkDoctorAviUtl},
+		doctorCheck{"ffmpeg", func() doctorResult {
+			path, err := exec.LookPath("ffmpeg")
+			if err != nil {
+				return doctorResult{doctorWarn, "見つかりません (Not found)", "動画を変換する場合は、ffmpegをインストールしてPATHに追加して下さい。(To convert videos, install ffmpeg and add it to PATH.)"}
+			}
+			return doctorResult{doctorOk, path, ""}
+		}},
+		doctorCheck{"フォント (Font)", checkDoctorFont},
+		doctorCheck{"アセット (Assets)", func() doctorResult {
+			return checkDoctorAssets(filepath.Join(executableDir, "assets"))
+		}},
+		doctorCheck{"書き込み権限 (Write permission)", func() doctorResult {
+			return checkDoctorWritable(executableDir, "./dist")
+		}},
+	)
+
+	failed := false
+	for _, check := range checks {
+		result := check.run()
+		switch result.status {
+		case doctorOk:
+			fmt.Println(color.GreenString("[OK]   %s: %s", check.name, result.detail))
+		case doctorWarn:
+			fmt.Println(color.YellowString("[WARN] %s: %s", check.name, result.detail))
+		case doctorFail:
+			failed = true
+			fmt.Println(color.RedString("[FAIL] %s: %s", check.name, result.detail))
+		}
+		if result.fix != "" {
+			fmt.Printf("       -> %s\n", result.fix)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func checkDoctorHost(url string, fix string) doctorResult {
+	client := http.Client{Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return doctorResult{doctorFail, err.Error(), fix}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return doctorResult{doctorFail, resp.Status, "サーバーに障害が発生している可能性があります。時間をおいて試して下さい。(The server may be down. Try again later.)"}
+	}
+	return doctorResult{doctorOk, time.Since(start).Round(time.Millisecond).String(), ""}
+}
+
+func checkDoctorAviUtl() doctorResult {
+	exeditRoot := pjsekaioverlay.FindExeditRoot()
+	if exeditRoot == "" {
+		return doctorResult{doctorWarn, "起動中の拡張編集が見つかりません (No running AviUtl with exedit.auf)", "AviUtlを起動した状態で実行すると、オブジェクトを自動でインストールします。(Run with AviUtl open to install the object automatically.)"}
+	}
+	if _, err := os.Stat(filepath.Join(exeditRoot, "script", "@pjsekai-overlay.obj")); err != nil {
+		return doctorResult{doctorFail, "オブジェクトがインストールされていません (The object is not installed)", "AviUtlを起動した状態で、--no-aviutl-installを付けずに実行して下さい。(Run without --no-aviutl-install while AviUtl is open.)"}
+	}
+	return doctorResult{doctorOk, exeditRoot, ""}
+}
+
+// スクリプトの標準のフォント（メイリオ）
+func checkDoctorFont() doctorResult {
+	path := filepath.Join(os.Getenv("WINDIR"), "Fonts", "meiryo.ttc")
+	if _, err := os.Stat(path); err != nil {
+		return doctorResult{doctorWarn, "メイリオが見つかりません (Meiryo not found)", "メイリオをインストールするか、--fontと--counter-fontでフォントファイルを指定して下さい。(Install Meiryo, or specify font files with --font and --counter-font.)"}
+	}
+	return doctorResult{doctorOk, path, ""}
+}
+
+func checkDoctorAssets(dir string) doctorResult {
+	assets, err := fs.Sub(embeddedAssets, "assets")
+	if err != nil {
+		return doctorResult{doctorFail, err.Error(), ""}
+	}
+	missing, changed, err := pjsekaioverlay.VerifyAssets(assets, dir)
+	if err != nil {
+		return doctorResult{doctorFail, err.Error(), ""}
+	}
+	if len(missing) > 0 {
+		return doctorResult{doctorWarn, fmt.Sprintf("%d個のファイルがありません (%d files missing): %s", len(missing), len(missing), strings.Join(missing, ", ")), "生成時に同梱のアセットから書き出されます。(They will be extracted from the bundled assets when generating.)"}
+	}
+	if len(changed) > 0 && pjsekaioverlay.InstalledAssetPackVersion(dir) == "" {
+		return doctorResult{doctorWarn, fmt.Sprintf("%d個のファイルが変更されています (%d files modified)", len(changed), len(changed)), "意図しない変更の場合は、ファイルを削除すると同梱のものに戻ります。(If unintended, delete the files to restore the bundled ones.)"}
+	}
+	return doctorResult{doctorOk, dir, ""}
+}
+
+func checkDoctorWritable(dirs ...string) doctorResult {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return doctorResult{doctorFail, fmt.Sprintf("%s: %s", dir, err), "書き込めるフォルダーに移動するか、--out-dirを指定して下さい。(Move to a writable folder or specify --out-dir.)"}
+		}
+		file, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			return doctorResult{doctorFail, fmt.Sprintf("%s: %s", dir, err), "書き込めるフォルダーに移動するか、--out-dirを指定して下さい。(Move to a writable folder or specify --out-dir.)"}
+		}
+		file.Close()
+		os.Remove(file.Name())
+	}
+	return doctorResult{doctorOk, strings.Join(dirs, ", "), ""}
+}
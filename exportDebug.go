@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"github.com/fatih/color"
+)
+
+// export-debugは生成と同じ引数を受け取り、生成の代わりに不具合報告用のzipを書き出す
+func exportDebugArgs(args []string) []string {
+	return append([]string{"-export-debug=debug.zip"}, args...)
+}
+
+func writeDebugBundle(client *pjsekaioverlay.Client, source pjsekaioverlay.Source, chart sonolus.LevelInfo, levelFile string, mapping pjsekaioverlay.ArchetypeMapping, teamPower int, path string) error {
+	fmt.Print("- 不具合報告用のファイルを生成中 (Generating debug bundle)... ")
+	var levelData sonolus.LevelData
+	var err error
+	if levelFile != "" {
+		levelData, err = pjsekaioverlay.LoadLevelFile(levelFile, mapping)
+	} else {
+		levelData, err = client.FetchLevelData(source, chart)
+	}
+	if err != nil {
+		return err
+	}
+
+	options := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "export-debug" {
+			options[f.Name] = f.Value.String()
+		}
+	})
+	bundle := pjsekaioverlay.NewDebugBundle(source, chart, pjsekaioverlay.CalculateScore(chart, levelData, teamPower), options)
+	if err := pjsekaioverlay.WriteDebugBundle(path, bundle, levelData); err != nil {
+		return err
+	}
+	fmt.Println(color.GreenString("OK"))
+	fmt.Println(color.CyanString(fmt.Sprintf("曲名や譜面IDは含まれていません。Issueに添付して下さい。(The title and chart ID are not included. Please attach it to the issue.) -> %s", path)))
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/overlaygrpc"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/overlaypb"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+	"google.golang.org/grpc"
+)
+
+// 生成処理をgRPCのサービスとして提供する（proto/overlay.proto）
+func grpcMain(args []string) {
+	Title()
+
+	flags := flag.NewFlagSet("grpc", flag.ExitOnError)
+	var addr string
+	flags.StringVar(&addr, "addr", "127.0.0.1:50051", "待ち受けるアドレスを指定します。(Enter the address to listen on.)")
+	var workDir string
+	flags.StringVar(&workDir, "work-dir", "./dist/grpc", "生成中のファイルの保存先を指定します。(Enter the directory to store files while generating.)")
+	var cacheDir string
+	flags.StringVar(&cacheDir, "cache-dir", "./dist/cache", "譜面データやジャケットをキャッシュするディレクトリを指定します。（空で無効）\nEnter the directory to cache chart data and images. (empty to disable)")
+	var assetsDir string
+	flags.StringVar(&assetsDir, "assets-dir", "", "アセットのディレクトリを指定します。（空で実行ファイルの隣のassets）\nEnter the assets directory. (empty for assets next to the executable)")
+	flags.Parse(args)
+
+	assets, err := resolveAssetsDir(assetsDir)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	grpcServer := grpc.NewServer()
+	overlaypb.RegisterOverlayServer(grpcServer, &overlaygrpc.Server{
+		Client:  pjsekaioverlay.New(pjsekaioverlay.WithCacheDir(cacheDir)),
+		Assets:  assets,
+		WorkDir: workDir,
+	})
+
+	fmt.Printf("- gRPCサーバーを起動しました (gRPC server started): %s\n", color.CyanString(addr))
+	if err := grpcServer.Serve(listener); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+	}
+}
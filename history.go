@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+// exeと同じディレクトリの履歴ファイル
+func historyPath() (string, error) {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(executablePath), pjsekaioverlay.HistoryFileName), nil
+}
+
+// 日付（YYYY-MM-DD）または現在からの期間（例：24h）
+func parseHistorySince(value string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日付または期間が不正です。(Invalid date or duration.) [%s]", value)
+	}
+	return time.Now().Add(-duration), nil
+}
+
+// 生成の履歴を表示する
+func historyMain(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	var chartId string
+	flags.StringVar(&chartId, "chart", "", "譜面IDで絞り込みます。（前方一致）\nFilter by chart ID. (prefix match)")
+	var since string
+	flags.StringVar(&since, "since", "", "指定した日付（YYYY-MM-DD）または期間（例：24h）以降の履歴を表示します。\nShow entries since the date (YYYY-MM-DD) or duration (e.g. 24h).")
+	var limit int
+	flags.IntVar(&limit, "limit", 0, "新しいものから表示する件数を指定します。（0で全て）\nEnter the number of latest entries to show. (0 for all)")
+	var showOptions bool
+	flags.BoolVar(&showOptions, "options", false, "生成に使ったオプションも表示します。(Show the options used for generation.)")
+	var asJson bool
+	flags.BoolVar(&asJson, "json", false, "1行に1件ずつJSONで出力します。(Output one JSON object per line.)")
+	flags.Parse(args)
+
+	var sinceTime time.Time
+	if since != "" {
+		var err error
+		sinceTime, err = parseHistorySince(since)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			os.Exit(1)
+		}
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	entries, err := pjsekaioverlay.LoadHistory(path)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	entries = slices.DeleteFunc(entries, func(entry pjsekaioverlay.HistoryEntry) bool {
+		return !strings.HasPrefix(entry.ChartId, chartId) || entry.Time.Before(sinceTime)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			encoder.Encode(entry)
+		}
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("履歴がありません。(No history.)")
+		return
+	}
+	for _, entry := range entries {
+		fmt.Printf("%s  %s  %s (Lv. %d)  %s  %s\n",
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			color.GreenString(entry.ChartId),
+			color.CyanString(entry.Title),
+			entry.Rating,
+			time.Duration(entry.DurationMs)*time.Millisecond,
+			entry.OutDir,
+		)
+		if showOptions && len(entry.Options) > 0 {
+			names := make([]string, 0, len(entry.Options))
+			for name := range entry.Options {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("    --%s=%s\n", name, entry.Options[name])
+			}
+		}
+	}
+}
+
+func appendHistory(entry pjsekaioverlay.HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	return pjsekaioverlay.AppendHistory(path, entry)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// フックに環境変数として渡す情報
+type hookEnv struct {
+	// assets（ジャケットと背景のダウンロード後）またはgenerate（生成の完了後）
+	stage   string
+	chartId string
+	source  string
+	level   sonolus.LevelInfo
+	// 最終的な出力先
+	outDir string
+	// ファイルが今ある場所（assetsの時点では、生成後にoutDirへ移動する一時ディレクトリ）
+	workDir string
+	// assetsの時点ではまだ決まっていない
+	assets  string
+	archive string
+}
+
+func (env hookEnv) environ() []string {
+	vars := []string{
+		"PJSEKAI_OVERLAY_STAGE=" + env.stage,
+		"PJSEKAI_OVERLAY_CHART_ID=" + env.chartId,
+		"PJSEKAI_OVERLAY_SOURCE=" + env.source,
+		"PJSEKAI_OVERLAY_TITLE=" + env.level.Title,
+		"PJSEKAI_OVERLAY_ARTISTS=" + env.level.Artists,
+		"PJSEKAI_OVERLAY_AUTHOR=" + env.level.Author,
+		"PJSEKAI_OVERLAY_RATING=" + strconv.Itoa(env.level.Rating),
+		"PJSEKAI_OVERLAY_OUT_DIR=" + env.outDir,
+		"PJSEKAI_OVERLAY_WORK_DIR=" + env.workDir,
+		"PJSEKAI_OVERLAY_ASSETS_DIR=" + env.assets,
+		"PJSEKAI_OVERLAY_ARCHIVE=" + env.archive,
+	}
+	// 今あるファイルの一覧（;区切り）
+	files := []string{}
+	filepath.WalkDir(env.workDir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return append(vars, "PJSEKAI_OVERLAY_FILES="+strings.Join(files, string(os.PathListSeparator)))
+}
+
+// コマンドプロンプトでフックを実行する（空の場合は何もしない）
+func runHook(command string, env hookEnv) error {
+	if command == "" {
+		return nil
+	}
+	fmt.Printf("- フックを実行中 (Running hook): %s\n", command)
+	shell := os.Getenv("COMSPEC")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := exec.Command(shell)
+	// cmd.exeは引数の引用符を独自に解釈するので、コマンドラインをそのまま渡す
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: "cmd.exe /S /C \"" + command + "\""}
+	cmd.Dir = env.workDir
+	cmd.Env = append(os.Environ(), env.environ()...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("フックの実行に失敗しました。(Hook failed.) [%s: %s]", env.stage, err)
+	}
+	return nil
+}
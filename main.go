@@ -3,10 +3,13 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -66,95 +69,1447 @@ func checkUpdate() {
 	fmt.Printf(color.HiCyanString("ダウンロード (Download Here) -> %s\n"), release.GetHTMLURL())
 }
 
+func installFont(fontPath string) (string, error) {
+	name, err := pjsekaioverlay.LoadFontName(fontPath)
+	if err != nil {
+		return "", err
+	}
+	if err := pjsekaioverlay.InstallFont(fontPath, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// --outで指定したファイルにexoを書き出す
+func writeExoOut(path string, variant string, assets string, destDir string, title string, description string, options pjsekaioverlay.ExoOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+	return pjsekaioverlay.WriteExo(file, variant, assets, destDir, title, description, options)
+}
+
+// --out -でexoを書き出す標準出力（進行状況の表示先とは別）
+var exoStdout = os.Stdout
+
+// 引数から--outの値を探す（フラグを読み込む前に、進行状況の表示先を決めるため）
+func exoOutArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "out" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// 標準出力にexoを書き出す場合、進行状況は標準エラー出力に表示する（診断情報のログより先に決める）
+func selectProgressOutput(args []string) {
+	exoStdout = os.Stdout
+	if exoOutArg(args) == "-" {
+		os.Stdout = os.Stderr
+	}
+}
+
+// 同じオプションで別の譜面を生成する
+func runForChart(chartId string) error {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// 診断情報は親のプロセスでまとめて保存する
+	args := []string{"-no-aviutl-install", "-diagnostics=never"}
+	flag.Visit(func(f *flag.Flag) {
+		// 開くのは最初の譜面だけにする
+		if f.Name != "difficulty" && f.Name != "no-aviutl-install" && f.Name != "diagnostics" && f.Name != "open" && f.Name != "launch" {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	args = append(args, chartId)
+	cmd := exec.Command(executablePath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 func origMain(isOptionSpecified bool) {
 	Title()
 
+	// exeと同じディレクトリのbackendsにある外部の出力形式を使えるようにする
+	if executablePath, err := os.Executable(); err == nil {
+		if err := pjsekaioverlay.LoadExecBackends(filepath.Join(filepath.Dir(executablePath), "backends")); err != nil {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		}
+	}
+
 	var skipAviutlInstall bool
 	flag.BoolVar(&skipAviutlInstall, "no-aviutl-install", false, "AviUtlオブジェクトのインストールをスキップします。(AviUtl object installation is skipped.)")
 
-	var outDir string
-	flag.StringVar(&outDir, "out-dir", "./dist/_chartId_", "出力先ディレクトリを指定します。_chartId_ は譜面IDに置き換えられます。\nEnter the output path. _chartId_ will be replaced with the chart ID.")
+	var outDir string
+	flag.StringVar(&outDir, "out-dir", "./dist/_chartId_", "出力先ディレクトリを指定します。_chartId_（{id}）、{title}、{artist}、{author}、{rating} は譜面の情報に置き換えられます。\nEnter the output path. _chartId_ ({id}), {title}, {artist}, {author} and {rating} will be replaced with the chart's information.")
+
+	var teamPower int
+	flag.IntVar(&teamPower, "team-power", 250000, "総合力を指定します。(Enter the team's power.)")
+
+	var apCombo bool
+	flag.BoolVar(&apCombo, "ap-combo", true, "コンボのAP表示を有効にします。(Enable AP display for combo.)")
+
+	var backgroundStyle string
+	flag.StringVar(&backgroundStyle, "background-style", "v3", "背景がない譜面で生成する背景のスタイルを指定します。(v1, v3, blur)\nEnter the style of the background generated for charts without one. (v1, v3, blur)")
+
+	var backgroundVideo string
+	flag.StringVar(&backgroundVideo, "background-video", "auto", "背景の画像の代わりにループ再生する動画ファイルを指定します。autoの場合はサーバーが提供している場合のみ使います。（noneで無効）\nEnter a video file looped in place of the background image. With auto, it is only used when the server provides one. (none to disable)")
+
+	var coverFormat string
+	flag.StringVar(&coverFormat, "cover-format", string(pjsekaioverlay.DefaultCoverOptions.Format), "ジャケットの出力形式を指定します。(png, webp)\nEnter the output format of the jacket. (png, webp)")
+
+	var coverSize int
+	flag.IntVar(&coverSize, "cover-size", pjsekaioverlay.DefaultCoverOptions.Size, "ジャケットの出力サイズを指定します。(Enter the output size of the jacket.)")
+
+	var coverFit string
+	flag.StringVar(&coverFit, "cover-fit", string(pjsekaioverlay.DefaultCoverOptions.Fit), "ジャケットのリサイズ方法を指定します。(crop, letterbox, stretch)\nEnter how the jacket is resized. (crop, letterbox, stretch)")
+
+	var coverOriginal bool
+	flag.BoolVar(&coverOriginal, "cover-original", false, "縮小前のジャケットも保存します。(Also save the jacket in its original resolution.)")
+
+	var coverUpscale string
+	flag.StringVar(&coverUpscale, "cover-upscale", "", "低解像度のジャケットを拡大するコマンドを指定します。{input}と{output}はPNGファイルのパスに置き換えられます。（空で無効）\nEnter a command to upscale low-resolution jackets. {input} and {output} are replaced with PNG file paths. (empty to disable)\n例 (e.g.): realesrgan-ncnn-vulkan.exe -i {input} -o {output} -s 4")
+
+	var coverUpscaleMin int
+	flag.IntVar(&coverUpscaleMin, "cover-upscale-min", 1024, "幅と高さの短い方がこれより小さいジャケットを拡大します。(Upscale jackets whose shorter side is smaller than this.)")
+
+	var imageFilterFile string
+	flag.StringVar(&imageFilterFile, "image-filters", "", "ジャケットと背景にかける加工（ぼかし・明るさ・周辺減光・角丸）の設定（JSON）を指定します。\nEnter a config file (JSON) of filters applied to the jacket and background. (blur, brightness, vignette, rounded corners)")
+
+	var animatedCover bool
+	flag.BoolVar(&animatedCover, "animated-cover", false, "ジャケットがGIF・APNG・WebPのアニメーションの場合、アニメーションジャケットとして再生します。\nPlay the jacket as an animated jacket if it is an animated GIF, APNG or WebP.")
+
+	var comboMilestone bool
+	flag.BoolVar(&comboMilestone, "combo-milestone", true, "100コンボごとの演出を有効にします。(Enable the effect at every 100 combo.)")
+
+	var finale string
+	flag.StringVar(&finale, "finale", "auto", "曲終わりの演出を指定します。(auto, ap, fc)\nEnter the effect shown at the end of the chart. (auto, ap, fc)")
+
+	var leadIn float64
+	flag.Float64Var(&leadIn, "lead-in", -1, "再生開始から最初のノーツまでの秒数を指定します。（負の値でテンプレートのまま）\nEnter the seconds from the start of play to the first note. (negative to keep the template)")
+
+	var tail float64
+	flag.Float64Var(&tail, "tail", 0, "最後のノーツから曲終わりの演出までの、最終スコアを表示する秒数を指定します。（0で既定の1秒）\nEnter the seconds the final score is held between the last note and the finale. (0 for the default 1 second)")
+
+	var syncRecording string
+	flag.StringVar(&syncRecording, "sync-recording", "", "録画（またはクラップ音の音声）の音から、最初のノーツの位置を自動で合わせます。録画は再生開始の位置に置く前提です。WAV以外はffmpegが必要です。\nAlign the first notes automatically from the audio of a recording (or a clap track), assuming it is placed at the start of play. Files other than WAV require ffmpeg.")
+
+	var rankEffect bool
+	flag.BoolVar(&rankEffect, "rank-effect", true, "ランクが上がった時の演出を有効にします。(Enable the effect when the rank goes up.)")
+
+	var judgmentCounter bool
+	flag.BoolVar(&judgmentCounter, "judgment-counter", false, "判定数の表示を追加します。(Add a display of the judgment counts.)")
+
+	var judgmentText bool
+	flag.BoolVar(&judgmentText, "judgment-text", false, "ノーツごとの判定（PERFECT、GREATなど）をコンボ数の近くに浮かび上がらせます。\nShow a floating judgment (PERFECT, GREAT, etc.) near the combo counter at each note.")
+
+	var autoBadge bool
+	flag.BoolVar(&autoBadge, "auto-badge", true, "オートプレイ中の「AUTO」の表示を有効にします。(Enable the \"AUTO\" badge shown during autoplay.)")
+
+	var accessible bool
+	flag.BoolVar(&accessible, "accessible", false, "見やすさ重視の表示にします。スコアとコンボを拡大し、コントラストを上げて縁取りを付けます。\nUse the high-contrast preset: enlarge the score and combo, increase contrast and add outlines.")
+
+	var accessibleScale float64
+	flag.Float64Var(&accessibleScale, "accessible-scale", 1.5, "見やすさ重視の表示での、スコアとコンボの拡大率を指定します。(Enter how much the score and combo are enlarged with --accessible.)")
+
+	var noScore bool
+	flag.BoolVar(&noScore, "no-score", false, "スコアを非表示にします。(Hide the score.)")
+
+	var noCombo bool
+	flag.BoolVar(&noCombo, "no-combo", false, "コンボを非表示にします。(Hide the combo.)")
+
+	var jacketPreview bool
+	flag.BoolVar(&jacketPreview, "jacket-preview", true, "対応した端末では、ダウンロードしたジャケットを文字で表示します。(Show the downloaded jacket as text in supporting terminals.)")
+
+	var noJacket bool
+	flag.BoolVar(&noJacket, "no-jacket", false, "ジャケットを非表示にします。(Hide the jacket.)")
+
+	var noBackground bool
+	flag.BoolVar(&noBackground, "no-background", false, "背景を非表示にします。(Hide the background.)")
+
+	var chromaKey bool
+	flag.BoolVar(&chromaKey, "chroma-key", false, "背景の代わりに単色のキー色を敷きます。クロマキーで切り抜く場合に使います。\nFill the background with a solid key color, for keying in your editor.")
+
+	var chromaKeyColor string
+	flag.StringVar(&chromaKeyColor, "chroma-key-color", "00ff00", "キー色を16進数で指定します。(Enter the key color in hex.)")
+
+	var chromaKeyAntialias bool
+	flag.BoolVar(&chromaKeyAntialias, "chroma-key-antialias", true, "キー色を敷く場合に、テキストのアンチエイリアスを有効にします。無効にすると縁にキー色が混ざりません。\nEnable text anti-aliasing with a key color. Disabling it keeps the key color out of the edges.")
+
+	var comboAnimation pjsekaioverlay.ComboAnimation
+	flag.StringVar(&comboAnimation.Easing, "combo-easing", pjsekaioverlay.DefaultComboAnimation.Easing, "コンボのアニメーションのイージングを指定します。(linear, ease_in, ease_out, ease_in_out, back)\nEnter the easing of the combo animation. (linear, ease_in, ease_out, ease_in_out, back)")
+	flag.Float64Var(&comboAnimation.Duration, "combo-duration", pjsekaioverlay.DefaultComboAnimation.Duration, "コンボのアニメーションの長さ（フレーム）を指定します。(Enter the duration of the combo animation in frames.)")
+	flag.Float64Var(&comboAnimation.Scale, "combo-scale", pjsekaioverlay.DefaultComboAnimation.Scale, "コンボのアニメーションの拡大の大きさを指定します。(Enter the scale amount of the combo animation.)")
+
+	var scoreAnimation pjsekaioverlay.ScoreAnimation
+	flag.StringVar((*string)(&scoreAnimation.Mode), "score-animation", string(pjsekaioverlay.DefaultScoreAnimation.Mode), "スコアの数字の変わり方を指定します。(instant, linear, game)\nEnter how the score counter changes. (instant, linear, game)")
+	flag.Float64Var(&scoreAnimation.Duration, "score-duration", pjsekaioverlay.DefaultScoreAnimation.Duration, "スコアの数え上げの長さ（フレーム）を指定します。(Enter the duration of the score roll-up in frames.)")
+
+	var comboCounter pjsekaioverlay.ComboCounter
+	flag.IntVar(&comboCounter.Start, "combo-start", 0, "コンボ数の開始値を指定します。（分割した動画の続きから数える場合など）\nEnter the starting combo count. (e.g. to continue from the previous part of a video)")
+	flag.IntVar(&comboCounter.Cap, "combo-cap", 0, "表示するコンボ数の上限を指定します。（0で上限なし）\nEnter the maximum displayed combo count. (0 for no cap)")
+	flag.BoolVar(&comboCounter.Loop, "combo-loop", false, "コンボ数が上限を超えたら1から数え直します。(Restart the combo count from 1 after reaching the cap.)")
+
+	var playbackSpeed float64
+	flag.Float64Var(&playbackSpeed, "speed", 1, "録画の再生速度を指定します。（0.5、1.25など）\nEnter the playback speed of the gameplay recording. (0.5, 1.25, etc.)")
+
+	var timeMapFile string
+	flag.StringVar(&timeMapFile, "time-map", "", "可変フレームレートの録画の各フレームの時刻（秒）を1行に1つ書いたファイルを指定します。（ffprobeの出力など）\nEnter a file with the timestamp (seconds) of each frame of a variable frame rate recording, one per line. (e.g. ffprobe output)")
+
+	pauses := []pjsekaioverlay.Pause{}
+	flag.Func("pause", "録画が一時停止していた区間を「開始+長さ」の形式（1:30+5など）で指定します。（複数指定可）\nEnter a segment where the recording was paused in the form start+duration (e.g. 1:30+5). (can be repeated)", func(value string) error {
+		pause, err := pjsekaioverlay.ParsePause(value)
+		if err != nil {
+			return err
+		}
+		pauses = append(pauses, pause)
+		return nil
+	})
+
+	var exoOut string
+	flag.StringVar(&exoOut, "out", "", "exoファイルの代わりに、1種類のexoを指定したファイルに出力します。（-で標準出力）\nWrite a single exo to the given file instead of the exo files. (- for stdout)")
+
+	var exoOutVariant string
+	flag.StringVar(&exoOutVariant, "out-variant", "jp_16-9", "--outで出力するexoの種類を指定します。(jp_16-9, jp_4-3, en_16-9, en_4-3)\nEnter the exo variant written by --out. (jp_16-9, jp_4-3, en_16-9, en_4-3)")
+
+	var openOutDir bool
+	flag.BoolVar(&openOutDir, "open", false, "生成後に出力先ディレクトリを開きます。\nOpen the output directory after generation.")
+
+	var launchAviUtl string
+	flag.StringVar(&launchAviUtl, "launch", "", "生成後に、指定したaviutl.exeで--out-variantのexoを開きます。\nAfter generation, open the --out-variant exo with the given aviutl.exe.")
+
+	var assetsDir string
+	flag.StringVar(&assetsDir, "assets-dir", "", "アセットのディレクトリを指定します。足りないファイルは同梱のアセットから書き出します。（空で実行ファイルの隣のassets）\nEnter the assets directory. Missing files are extracted from the bundled assets. (empty for assets next to the executable)")
+
+	var lyricsFile string
+	flag.StringVar(&lyricsFile, "lyrics", "", "歌詞ファイル（LRC/SRT）を指定します。MVのような歌詞のテキストオブジェクトを追加します。\nEnter a lyrics file (LRC/SRT). Adds MV-style lyric text objects.")
+
+	var cutIns bool
+	flag.BoolVar(&cutIns, "cut-ins", false, "セクションの境目に、キャラクターのカットインを入れる目印を追加します。(Add placeholders for character cut-ins at section boundaries.)")
+
+	var archetypeMapFile string
+	flag.StringVar(&archetypeMapFile, "archetype-map", "", "pjsekai以外のエンジンの譜面用に、アーキタイプの対応表（JSON）を指定します。\nEnter an archetype mapping file (JSON) for charts made for engines other than pjsekai.")
+
+	var skipEntities string
+	flag.StringVar(&skipEntities, "skip-entities", "", "ノーツ数とコンボから除外するエンティティを、ルール名（sim-lines、hidden-ticks、ignored-ticks、attached-ticks）またはアーキタイプ名のカンマ区切りで指定します。\nEnter comma-separated rule names (sim-lines, hidden-ticks, ignored-ticks, attached-ticks) or archetype names of entities to exclude from the note count and combo.")
+
+	var scoreRulesFile string
+	flag.StringVar(&scoreRulesFile, "score-rules", "", "ゲームのバージョンごとのスコアのルール（JSONの配列）を追加するファイルを指定します。\nEnter a file (JSON array) that adds score rules for game versions.")
+
+	var unknownArchetypes string
+	flag.StringVar(&unknownArchetypes, "unknown-archetypes", "ignore", "スコアの計算が対応していないアーキタイプのノーツの扱いを指定します。（ignore：コンボに含めない、combo：通常のタップノーツとして数える）\nSpecify how to treat notes of archetypes the score calculation doesn't support. (ignore: exclude from the combo, combo: count as normal tap notes)")
+
+	var gameVersion string
+	flag.StringVar(&gameVersion, "game-version", "", "スコアの計算に使うルールを、ルール名または日付（YYYY-MM-DD）で指定します。（空で標準のルール）\nEnter the score rules by name or date (YYYY-MM-DD) to match the game at that time. (empty for the default rules)")
+
+	var liveModeName string
+	flag.StringVar(&liveModeName, "mode", "normal", "ライブの種類を指定します。(normal, challenge, cheerful)\nEnter the live mode. (normal, challenge, cheerful)")
+
+	var teamColor string
+	flag.StringVar(&teamColor, "team-color", "", "チアフルカーニバルのチームカラーを16進数で指定します。（ff5a78など）\nEnter the Cheerful Carnival team color in hex. (e.g. ff5a78)")
+
+	var tintColor string
+	flag.StringVar(&tintColor, "tint", "", "UIの色を変えます。sourceの場合は譜面サーバーの色、それ以外は16進数の色です。（空で無効）\nTint the UI. source uses the chart server's color, otherwise enter a hex color. (empty to disable)")
+
+	var tagPresetFile string
+	flag.StringVar(&tagPresetFile, "tag-presets", "", "譜面のタグ（「APPEND」「April Fools」など）に合わせて色と難易度の表示を変えるプリセット（JSON）を追加します。noneの場合はタグで変えません。\nEnter a file (JSON) of presets that change the colors and difficulty label by chart tag (e.g. \"APPEND\", \"April Fools\"), added to the built-in ones. none to ignore tags.")
+
+	var tintElements string
+	flag.StringVar(&tintElements, "tint-elements", "combo,bar,frame", "色を変える要素をカンマ区切りで指定します。(combo, bar, frame)\nEnter the tinted elements, separated by commas. (combo, bar, frame)")
+
+	var scoreGauge string
+	flag.StringVar(&scoreGauge, "score-gauge", string(pjsekaioverlay.ScoreGaugeGame), "スコアバーの目盛りの置き方を指定します。gameはゲームと同じ固定の位置、proportionalは難易度ごとのボーダーに対する割合の位置です。(game, proportional)\nEnter how the score gauge markers are placed. game uses the fixed in-game positions, proportional places them by the chart's rank borders. (game, proportional)")
+
+	var targetScore string
+	flag.StringVar(&targetScore, "target-score", "", "目標スコア（ライバルのスコアなど）を「スコア」または「名前:スコア」の形式で指定します。ノーツごとに目標スコアへ進むゴーストと、現在のスコアとの差をバーで表示します。\nEnter a target score (such as a rival's score) as \"score\" or \"name:score\". Shows a bar with a ghost that advances to the target score note by note, and how far ahead or behind the current score is.")
+
+	var missTimes string
+	flag.StringVar(&missTimes, "miss-times", "", "ミスにするノーツの時間（譜面の秒数）をカンマ区切りで指定します。その時間以降の最初のノーツをMISSとしてスコア・コンボを計算し、画面の端の赤い点滅とコンボが切れる揺れを表示します。\nEnter the times (in chart seconds) of notes to miss, separated by commas. The first note at or after each time is judged MISS for the score and combo, with a red flash on the screen edges and a shake when the combo breaks.")
+
+	var compareChartId string
+	flag.StringVar(&compareChartId, "compare", "", "比較する譜面（更新前の譜面など）のIDを指定します。同じ総合力で計算したスコア・コンボ数と、片方にしかないノーツを並べて表示します。\nEnter the ID of a chart to compare with (such as the chart before an update). Shows its score and combo calculated with the same team power, and the notes only in one of the charts.")
+
+	var multiPlayers string
+	flag.StringVar(&multiPlayers, "multi-players", "", "マルチライブの参加者を「名前:総合力」のカンマ区切りで指定します。（最大5人）\nEnter the multi-live players as comma-separated \"name:talent\". (up to 5 players)")
+
+	var multiLiveOptions pjsekaioverlay.MultiLiveOptions
+	flag.Float64Var(&multiLiveOptions.Accuracy, "multi-accuracy", 0.97, "マルチライブの参加者がPERFECTを出す確率を指定します。（それ以外はGREAT）\nEnter the chance of a PERFECT for multi-live players. (GREAT otherwise)")
+
+	var seed string
+	flag.StringVar(&seed, "seed", "", "シミュレーションに使う乱数のシードを指定します。同じシードでは同じ内容のファイルを出力します。（空でランダム）\nEnter the random seed for simulations. The same seed produces byte-identical files. (empty for random)")
+
+	var rangeFrom, rangeTo string
+	flag.StringVar(&rangeFrom, "from", "", "生成する区間の始まりを秒（90、1:30）または小節番号（m12）で指定します。\nEnter the start of the segment to generate in seconds (90, 1:30) or as a measure number (m12).")
+	flag.StringVar(&rangeTo, "to", "", "生成する区間の終わりを秒（90、1:30）または小節番号（m12）で指定します。\nEnter the end of the segment to generate in seconds (90, 1:30) or as a measure number (m12).")
+
+	var scoreLocale string
+	flag.StringVar(&scoreLocale, "score-locale", "none", "スコアの3桁区切りに使うロケールを指定します。（none、en、de、frなど）\nEnter the locale used for the score digit grouping. (none, en, de, fr, etc.)")
+
+	var scoreFormat pjsekaioverlay.ScoreFormat
+	flag.IntVar(&scoreFormat.Digits, "score-digits", pjsekaioverlay.DefaultScoreFormat.Digits, "スコアを0埋めする桁数を指定します。（0で0埋めしない）\nEnter the number of digits to zero-pad the score to. (0 to disable)")
+	flag.BoolVar(&scoreFormat.Monospace, "score-monospace", pjsekaioverlay.DefaultScoreFormat.Monospace, "スコアの区切り文字を数字と同じ幅で並べます。(Align score separators to the same width as digits.)")
+
+	var textFont string
+	flag.StringVar(&textFont, "font", "", "テキストに使うフォントファイル（TTF/OTF）を指定します。\nEnter the font file (TTF/OTF) used for texts.")
+
+	var labels string
+	flag.StringVar(&labels, "labels", string(pjsekaioverlay.LabelLanguageJa), "説明文やコンボの見出しなどの文言の言語を指定します。(ja, en, zh-hans, zh-hant, ko)\nEnter the language of the overlay labels such as the description and the combo heading. (ja, en, zh-hans, zh-hant, ko)")
+
+	var comboImages bool
+	flag.BoolVar(&comboImages, "combo-images", false, "コンボ数を数字の画像から組み立てた連番画像として書き出し、表示に使います。フォントや環境によらず同じ見た目になります。\nPre-render the combo counter from the digit images as an image sequence and use it for display. Looks identical on every machine regardless of fonts.")
+
+	var counterFont string
+	flag.StringVar(&counterFont, "counter-font", "", "スコア・コンボの数字に使うフォントファイル（TTF/OTF）を指定します。\nEnter the font file (TTF/OTF) used for the score and combo digits.")
+
+	var exoEncoding string
+	flag.StringVar(&exoEncoding, "exo-encoding", string(pjsekaioverlay.DefaultExoFormat.Encoding), "exoファイルの文字コードを指定します。(sjis, utf8)\nEnter the encoding of the exo file. (sjis, utf8)")
+
+	var exoLineEnding string
+	flag.StringVar(&exoLineEnding, "exo-line-ending", string(pjsekaioverlay.DefaultExoFormat.LineEnding), "exoファイルの改行コードを指定します。(crlf, lf)\nEnter the line ending of the exo file. (crlf, lf)")
+
+	var aviutl2 bool
+	flag.BoolVar(&aviutl2, "aviutl2", false, "AviUtl2用のオブジェクトファイルも出力します。(Also output object files for AviUtl2.)")
+
+	var ymm4 bool
+	flag.BoolVar(&ymm4, "ymm4", false, "YMM4（ゆっくりMovieMaker4）用のプロジェクトも出力します。(Also output a YMM4 project.)")
+
+	var backendNames string
+	flag.StringVar(&backendNames, "backend", "", "追加で出力する形式をカンマ区切りで指定します。（--list-backendsで一覧を表示）\nEnter additional output backends, separated by commas. (see --list-backends)")
+
+	backendOptions := map[string]map[string]string{}
+	flag.Func("backend-opt", "出力形式のオプションを backend.key=value の形式で指定します。（複数指定可）\nEnter a backend option in the form backend.key=value. (can be repeated)", func(value string) error {
+		return pjsekaioverlay.ParseBackendOption(value, backendOptions)
+	})
+
+	var listBackends bool
+	flag.BoolVar(&listBackends, "list-backends", false, "使える出力形式とオプションを表示します。(List the available output backends and their options.)")
+
+	var splitExo bool
+	flag.BoolVar(&splitExo, "split-exo", false, "要素（スコア、コンボ、ジャケット、背景）ごとに分けたexoファイルも出力します。\nAlso output separate exo files for each element (score, combo, jacket, background).")
+
+	var markers bool
+	flag.BoolVar(&markers, "markers", false, "BPM変化などのマーカー（markers.json、chapters.txt）を出力します。\nOutput markers such as BPM changes. (markers.json, chapters.txt)")
+
+	var beatGrid int
+	flag.IntVar(&beatGrid, "beat-grid", 0, "拍に合わせて点滅する要素を追加します。小節の拍数を指定します。（0で無効）\nAdd an element that flashes on every beat. Specify the number of beats per measure. (0 to disable)")
+
+	var measureCounter bool
+	flag.BoolVar(&measureCounter, "measure-counter", false, "現在の小節と拍（「12:3」など）を表示する要素を追加します。譜面に拍子の変化がない場合は--beat-gridの拍子（既定は4拍子）で数えます。\nAdd an element showing the current measure and beat (e.g. \"12:3\"). Counts in the --beat-grid time signature (4 by default) if the chart has no time signature changes.")
+
+	var npsWindow float64
+	flag.Float64Var(&npsWindow, "nps-meter", 0, "拍ごとに、直前の指定した秒数のノーツ密度（NPS）を表示する要素を追加します。（0で無効）\nAdd an element showing the notes per second over the given number of preceding seconds, updated every beat. (0 to disable)")
+
+	var guides string
+	flag.StringVar(&guides, "guides", "", "編集時の確認用に、セーフエリアや動画サイトのUIで隠れる範囲を表示する要素を追加します。カンマ区切りで指定します（safe、youtube、tiktok）。書き出す前に非表示にしてください。\nAdd an element showing the safe areas and where video platform UI covers the screen, for checking while editing. Specify as a comma-separated list. (safe, youtube, tiktok) Hide it before rendering.")
+
+	var stats bool
+	flag.BoolVar(&stats, "stats", false, "ノーツ数などの統計（stats.json、stats.md）を出力します。\nOutput chart statistics such as note counts. (stats.json, stats.md)")
+
+	var densityGraph bool
+	flag.BoolVar(&densityGraph, "density-graph", false, "ノーツ密度のグラフ（density.png）を出力します。\nOutput a note density graph. (density.png)")
+
+	var densityOverlay bool
+	flag.BoolVar(&densityOverlay, "density-overlay", false, "ノーツ密度のグラフを再生位置付きで表示する要素を追加します。\nAdd an element that shows the note density graph with a playhead.")
+
+	var waveformMode string
+	flag.StringVar(&waveformMode, "waveform", "none", "曲に合わせて動くビジュアライザーを、背景とスコア・コンボの間に追加します。(none, wave, spectrum)\nAdd a visualizer that moves with the song between the background and the counters. (none, wave, spectrum)")
+
+	var waveformAudio string
+	flag.StringVar(&waveformAudio, "waveform-audio", "", "ビジュアライザーに使う音声ファイルを指定します。（空の場合はサーバーの曲をダウンロード）\nEnter the audio file used for the visualizer. (downloads the song from the server if empty)")
+
+	var force bool
+	flag.BoolVar(&force, "force", false, "前回と同じ設定でも、全てのファイルを生成し直します。\nRegenerate all files even if the chart and options have not changed.")
+
+	var levelFile string
+	flag.StringVar(&levelFile, "level-file", "", "サーバーの譜面データの代わりに、ローカルの譜面データ（LevelData）を使います。\nUse a local level data file instead of the one on the server.")
+
+	var watch bool
+	flag.BoolVar(&watch, "watch", false, "--level-fileで指定したファイルが保存されるたびに、pedファイルとexoファイルを生成し直します。\nRegenerate the ped and exo files whenever the file specified by --level-file is saved.")
+
+	var dryRun bool
+	flag.BoolVar(&dryRun, "dry-run", false, "ファイルを書き込まずに、生成されるファイルを表示します。\nShow the files that would be generated without writing anything.")
+
+	var sourceId string
+	flag.StringVar(&sourceId, "source", "", "複数のサーバーで譜面が見つかった場合に使うサーバーを指定します。(potato_leaves, chart_cyanvas)\nEnter the source to use when the chart is found on multiple sources. (potato_leaves, chart_cyanvas)")
+
+	var offline bool
+	flag.BoolVar(&offline, "offline", false, "通信せず、前回出力先に保存した譜面データから生成し直します。\nRegenerate from the chart data saved in the output directory without connecting to the server.")
+
+	var scp bool
+	flag.BoolVar(&scp, "scp", false, "譜面をデータ・ジャケット・曲・背景・エンジンごと、Sonolusのコレクションパッケージ（譜面ID.scp）として出力先に保存します。\nSave the chart with its data, cover, BGM, background and engine as a Sonolus collection package (chart ID.scp) in the output directory.")
+
+	var shortenPath bool
+	flag.BoolVar(&shortenPath, "shorten-path", false, "出力するファイルのパスがWindowsの上限（260文字）を超える場合、出力先ディレクトリの名前を短くします。\nShorten the output directory name if the output file paths would exceed the Windows limit (260 characters).")
+
+	var archive bool
+	flag.BoolVar(&archive, "archive", false, "出力先ディレクトリをmanifest.json付きのzipファイルにまとめます。\nPack the output directory into a zip file with manifest.json.")
+
+	var difficulty string
+	flag.StringVar(&difficulty, "difficulty", "", "同じ曲の別の難易度の譜面を、譜面IDかレベルで指定します。allで全ての難易度を生成します。\nSelect another difficulty of the same song by chart ID or level. Use all to generate every difficulty.")
+
+	var requireHash bool
+	flag.BoolVar(&requireHash, "require-hash", false, "前回の生成後に譜面データが変更されていた場合、警告ではなくエラーにします。\nFail instead of warning when the chart data has changed since the last generation.")
+
+	var uploadTarget string
+	flag.StringVar(&uploadTarget, "upload", "", "生成したファイルをアップロードする先を指定します。（s3://bucket/prefix、webdav(s)://host/path、HTTPのPUTを受け付けるURL）S3の認証情報はAWS_ACCESS_KEY_IDなどの環境変数で指定します。\nEnter a destination to upload the generated files to. (s3://bucket/prefix, webdav(s)://host/path, or a URL accepting HTTP PUT) S3 credentials are read from AWS_ACCESS_KEY_ID and the other AWS environment variables.")
+
+	var allowMissingAssets bool
+	flag.BoolVar(&allowMissingAssets, "allow-missing-assets", false, "ジャケットや背景がない譜面でも、曲名を書いた仮のジャケットとジャケットから生成した背景で続けます。\nContinue with a placeholder jacket showing the title and a background generated from it when the chart has no jacket or background.")
+	var strict bool
+	flag.BoolVar(&strict, "strict", false, "キャッシュや履歴の書き込みなど、失敗しても処理を続けていたエラーで停止します。無人で一括生成する場合向けです。\nStop on errors that are otherwise ignored, such as failing to write the cache or history. For unattended batch generation.")
+
+	var requestInterval time.Duration
+	flag.DurationVar(&requestInterval, "request-interval", pjsekaioverlay.DefaultRequestInterval, "サーバーへのリクエストの最小の間隔を指定します。(例：500ms)\nEnter the minimum interval between requests to the server. (e.g. 500ms)")
+
+	var rateLimit int64
+	flag.Func("limit-rate", "全ての通信の速度の上限を1秒あたりのバイト数で指定します。(例：500k、2M)\nEnter the maximum transfer rate for all connections in bytes per second. (e.g. 500k, 2M)", func(value string) error {
+		var err error
+		rateLimit, err = pjsekaioverlay.ParseRateLimit(value)
+		return err
+	})
+
+	var cacheDir string
+	flag.StringVar(&cacheDir, "cache-dir", "", "譜面データやジャケットをキャッシュするディレクトリを指定します。（空で無効）\nEnter the directory to cache chart data and images. (empty to disable)")
+
+	resolve := map[string]string{}
+	flag.Func("resolve", "ホスト名の代わりに使うIPアドレスを host:ip の形式で指定します。（複数指定可）\nEnter the IP address to use for a host in the form host:ip. (can be repeated)", func(value string) error {
+		host, ip, err := pjsekaioverlay.ParseResolve(value)
+		if err != nil {
+			return err
+		}
+		resolve[host] = ip
+		return nil
+	})
+
+	var hookAfterAssets string
+	flag.StringVar(&hookAfterAssets, "hook-after-assets", "", "ジャケットと背景のダウンロード後に実行するコマンドを指定します。（出力先の情報はPJSEKAI_OVERLAY_で始まる環境変数で渡されます）\nEnter a command to run after downloading the jacket and background. (Output info is passed in PJSEKAI_OVERLAY_* environment variables)")
+
+	var hookAfterGenerate string
+	flag.StringVar(&hookAfterGenerate, "hook-after-generate", "", "生成の完了後に実行するコマンドを指定します。（出力先の情報はPJSEKAI_OVERLAY_で始まる環境変数で渡されます）\nEnter a command to run after generation. (Output info is passed in PJSEKAI_OVERLAY_* environment variables)")
+
+	var noHistory bool
+	flag.BoolVar(&noHistory, "no-history", false, "生成の履歴（history.jsonl）に記録しません。(Don't record the generation in history.jsonl.)")
+
+	flag.StringVar(&diagnosticsMode, "diagnostics", "", "失敗した時に診断情報のzipファイルを保存するかを指定します。（省略した場合は、引数なしで起動した時だけ確認します）(ask, always, never)\nEnter whether to save a diagnostics zip file when generation fails. (by default, asks only when started without arguments) (ask, always, never)")
+
+	var exportDebug string
+	flag.StringVar(&exportDebug, "export-debug", "", "生成する代わりに、曲名などを除いた譜面データとオプションを不具合報告用のzipファイルに書き出します。\nInstead of generating, write the chart data and options without identifying metadata to a zip file for bug reports.")
+
+	var ipVersion string
+	flag.StringVar(&ipVersion, "ip-version", "auto", "接続に使うIPのバージョンを指定します。(auto, 4, 6)\nEnter the IP version used for connections. (auto, 4, 6)")
+
+	flag.Usage = func() {
+		fmt.Println("Usage: pjsekai-overlay [譜面ID] [オプション]")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	startTime := time.Now()
+
+	if listBackends {
+		for _, backend := range pjsekaioverlay.Backends() {
+			fmt.Printf("%s: %s\n", color.GreenString(backend.Name()), backend.Description())
+			for _, option := range backend.Options() {
+				fmt.Printf("    %s.%s: %s\n", backend.Name(), option.Name, option.Description)
+			}
+		}
+		return
+	}
+
+	// --aviutl2と--ymm4は--backendと同じ扱いにする
+	selectedNames := []string{}
+	if aviutl2 {
+		selectedNames = append(selectedNames, "aviutl2")
+	}
+	if ymm4 {
+		selectedNames = append(selectedNames, "ymm4")
+	}
+	for _, name := range strings.Split(backendNames, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(selectedNames, name) {
+			selectedNames = append(selectedNames, name)
+		}
+	}
+	selectedBackends := []pjsekaioverlay.OutputBackend{}
+	for _, name := range selectedNames {
+		backend, err := pjsekaioverlay.GetBackend(name)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		selectedBackends = append(selectedBackends, backend)
+	}
+
+	if watch && levelFile == "" {
+		fmt.Println(color.RedString("FAIL:--watchには--level-fileの指定が必要です。(--watch requires --level-file.)"))
+		return
+	}
+
+	if !offline && shouldCheckUpdate() {
+		checkUpdate()
+	}
+
+	if !skipAviutlInstall && !dryRun && exportDebug == "" {
+		success := pjsekaioverlay.TryInstallObject()
+		if success {
+			fmt.Println(color.GreenString("AviUtlオブジェクトのインストールに成功しました。(AviUtl object successfully installed.)"))
+		}
+	}
+
+	var chartId string
+	if flag.Arg(0) != "" {
+		chartId = flag.Arg(0)
+		fmt.Printf("譜面ID (Chart ID): %s\n", color.GreenString(chartId))
+	} else {
+		fmt.Print("譜面IDをプレフィックス込みで入力して下さい。\nEnter the chart ID including the prefix.\n\n'chcy-': Chart Cyanvas (cc.sevenc7c.com)\n'ptlv-': Potato Leaves (ptlv.sevenc7c.com)\n> ")
+		fmt.Scanln(&chartId)
+		fmt.Printf("\033[A\033[2K\r> %s\n", color.GreenString(chartId))
+	}
+	diagnosticsChart.id = chartId
+
+	// 譜面情報などを出力先の.chartに保存し、--offlineではそれを使って生成し直す
+	snapshotDir := filepath.Join(pjsekaioverlay.OutDirRoot(outDir), ".chart", pjsekaioverlay.SanitizeFileName(chartId))
+	if offline {
+		if err := pjsekaioverlay.HasSnapshot(snapshotDir); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+	network, err := pjsekaioverlay.ParseNetwork(ipVersion)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	var archetypeMapping pjsekaioverlay.ArchetypeMapping
+	if archetypeMapFile != "" {
+		archetypeMapping, err = pjsekaioverlay.LoadArchetypeMapping(archetypeMapFile)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+	clientOptions := []pjsekaioverlay.Option{
+		pjsekaioverlay.WithRequestInterval(requestInterval),
+		pjsekaioverlay.WithRateLimit(rateLimit),
+		pjsekaioverlay.WithCacheDir(cacheDir),
+		pjsekaioverlay.WithDialOptions(pjsekaioverlay.DialOptions{Resolve: resolve, Network: network}),
+		pjsekaioverlay.WithArchetypeMapping(archetypeMapping),
+		pjsekaioverlay.WithStrict(strict),
+		pjsekaioverlay.WithAllowMissingAssets(allowMissingAssets),
+	}
+	if !dryRun && exportDebug == "" {
+		clientOptions = append(clientOptions, pjsekaioverlay.WithSnapshotDir(snapshotDir, offline))
+	}
+	client := pjsekaioverlay.New(clientOptions...)
+
+	// 生成してから失敗しないよう、先に出力先を確認する
+	var uploadOutput pjsekaioverlay.Output
+	if uploadTarget != "" {
+		uploadOutput, err = client.OpenOutput(uploadTarget)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+
+	// 入力を待つ時間は含めない
+	timings := pjsekaioverlay.StageTimings{}
+	stopTiming := timings.Start("fetch")
+	fmt.Print("- 譜面を取得中 (Getting chart)... ")
+	matches, err := client.FindChart(chartId)
+	if err != nil {
+		if _, detectErr := client.DetectChartSource(chartId); detectErr != nil {
+			fmt.Println(color.RedString("\n譜面のサーバーを判別できませんでした。プレフィックスも込め、正しい譜面IDを入力して下さい。\nThe specified chart doesn't exist. Please enter the correct chart ID including the prefix."))
+			return
+		}
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL: %s", err.Error())))
+		return
+	}
+	match := matches[0]
+	if sourceId != "" {
+		index := slices.IndexFunc(matches, func(m pjsekaioverlay.ChartMatch) bool { return m.Source.Id == sourceId })
+		if index < 0 {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:指定したサーバーに譜面が見つかりませんでした。(Chart not found on the specified source.) [%s]", sourceId)))
+			return
+		}
+		match = matches[index]
+	} else if len(matches) > 1 {
+		// 複数のサーバーで見つかった場合は選んでもらう
+		fmt.Println(color.YellowString("\n  複数のサーバーで譜面が見つかりました。(The chart was found on multiple sources.)"))
+		for i, m := range matches {
+			fmt.Printf("  %d: %s%s%s (%s) - %s\n", i+1, RgbColorEscape(m.Source.Color), m.Source.Name, ResetEscape(), m.Source.Id, m.Level.Title)
+		}
+		if isOptionSpecified {
+			fmt.Println(color.RedString("FAIL:--sourceでサーバーを指定して下さい。(Please specify the source with --source.)"))
+			return
+		}
+		stopTiming()
+		fmt.Print("> ")
+		var tmpIndex string
+		fmt.Scanln(&tmpIndex)
+		index, err := strconv.Atoi(tmpIndex)
+		if err != nil || index < 1 || index > len(matches) {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:不正な番号です。(Invalid number.) [%s]", tmpIndex)))
+			return
+		}
+		match = matches[index-1]
+		stopTiming = timings.Start("fetch")
+		fmt.Print("- 譜面を取得中 (Getting chart)... ")
+	}
+	chartSource, chart := match.Source, match.Level
+	diagnosticsChart.source = chartSource.Id
+	fmt.Printf("%s%s%s ", RgbColorEscape(chartSource.Color), chartSource.Name, ResetEscape())
+
+	// 別の難易度も生成する場合は、現在の譜面の後に順番に生成する
+	otherDifficulties := []string{}
+	if difficulty != "" {
+		levels, err := client.FetchRelatedLevels(chartSource, chartId)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL: %s", err.Error())))
+			return
+		}
+		if difficulty == "all" {
+			if !strings.Contains(outDir, "_chartId_") && !strings.Contains(outDir, "{id}") {
+				fmt.Println(color.RedString("FAIL:--difficulty allでは、--out-dirに_chartId_か{id}を含めて下さい。(With --difficulty all, --out-dir must contain _chartId_ or {id}.)"))
+				return
+			}
+			for _, level := range levels {
+				if level.Name != chartId {
+					otherDifficulties = append(otherDifficulties, level.Name)
+				}
+			}
+		} else {
+			chart, err = pjsekaioverlay.SelectDifficulty(levels, difficulty)
+			if err != nil {
+				fmt.Println(color.RedString(fmt.Sprintf("FAIL: %s", err.Error())))
+				return
+			}
+			chartId = chart.Name
+			diagnosticsChart.id = chartId
+		}
+	}
+
+	if chart.Engine.Version != 12 {
+		fmt.Println(color.RedString(fmt.Sprintf("失敗：エンジンのバージョンが古い。\nFAIL: Unsupported engine version. - [ver.%d]", chart.Engine.Version)))
+		return
+	}
+
+	stopTiming()
+	fmt.Println(color.GreenString("OK"))
+	fmt.Print(SourceHeader(chartSource,
+		fmt.Sprintf("%s / %s", color.CyanString(chart.Title), color.CyanString(chart.Artists)),
+		fmt.Sprintf("%s (Lv. %s)", color.CyanString(chart.Author), color.MagentaString(strconv.Itoa(chart.Rating))),
+	))
+
+	tagPreset := pjsekaioverlay.TagPreset{}
+	if tagPresetFile != "none" {
+		tagPresets := pjsekaioverlay.DefaultTagPresets
+		if tagPresetFile != "" {
+			tagPresets, err = pjsekaioverlay.LoadTagPresets(tagPresetFile)
+			if err != nil {
+				fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+				return
+			}
+		}
+		if preset, ok := pjsekaioverlay.MatchTagPreset(tagPresets, chart.Tags); ok {
+			tagPreset = preset
+			fmt.Printf("- タグのプリセット (Tag preset): %s\n", color.CyanString(preset.Tag))
+		}
+	}
+
+	fmt.Printf("- exeのパスを取得中 (Getting executable path)... ")
+	executablePath, err := os.Executable()
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	fmt.Println(color.GreenString("OK"))
+
+	cwd, err := os.Getwd()
+
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	formattedOutDir := filepath.Join(cwd, pjsekaioverlay.FormatOutDir(outDir, chartId, chart))
+	fmt.Printf("- 出力先ディレクトリ (Output path): %s\n", color.CyanString(filepath.Dir(formattedOutDir)))
+
+	hiddenElements := []pjsekaioverlay.ExoElement{}
+	if noScore {
+		hiddenElements = append(hiddenElements, pjsekaioverlay.ExoElementScore)
+	}
+	if noCombo {
+		hiddenElements = append(hiddenElements, pjsekaioverlay.ExoElementCombo)
+	}
+	if noJacket {
+		hiddenElements = append(hiddenElements, pjsekaioverlay.ExoElementJacket)
+	}
+	if noBackground {
+		hiddenElements = append(hiddenElements, pjsekaioverlay.ExoElementBackground)
+	}
+	if !autoBadge {
+		hiddenElements = append(hiddenElements, pjsekaioverlay.ExoElementAuto)
+	}
+
+	labelLanguage, err := pjsekaioverlay.ParseLabelLanguage(labels)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	// 深い場所や長い曲名では、AviUtlが開けない長さのパスになることがある
+	outputExoFiles := pjsekaioverlay.ExoFileNames(pjsekaioverlay.ExoOptions{Hidden: hiddenElements, Split: splitExo})
+	if shortenPath {
+		shortened, err := pjsekaioverlay.ShortenOutDir(formattedOutDir, outputExoFiles)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		if shortened != formattedOutDir {
+			fmt.Printf("- 出力先を短くしました (Shortened output path): %s\n", color.CyanString(filepath.Base(shortened)))
+			formattedOutDir = shortened
+		}
+	} else if err := pjsekaioverlay.ValidateOutputPathLength(formattedOutDir, outputExoFiles); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	if dryRun {
+		fmt.Println(color.CyanString("\n[dry-run] 以下のファイルが生成されます (The following files would be generated):"))
+		printRemote := func(name string, srl sonolus.SRL) {
+			size := client.FetchRemoteSize(chartSource, srl)
+			if size < 0 {
+				fmt.Printf("  %s (?)\n", name)
+			} else {
+				fmt.Printf("  %s (%d bytes)\n", name, size)
+			}
+		}
+		coverImageFormat, err := pjsekaioverlay.ParseImageFormat(coverFormat)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		printRemote(coverImageFormat.FileName("cover"), chart.Cover)
+		if coverOriginal {
+			printRemote(coverImageFormat.FileName("cover_original"), chart.Cover)
+		}
+		if chart.UseBackground.Item.Image.Url == "" {
+			fmt.Printf("  background.png (%s)\n", backgroundStyle)
+		} else {
+			printRemote("background.png", chart.UseBackground.Item.Image)
+		}
+		if waveformMode != string(pjsekaioverlay.WaveformModeNone) {
+			if waveformAudio == "" {
+				printRemote(pjsekaioverlay.BgmFileName(chart), chart.Bgm)
+			}
+			fmt.Println("  waveform/*.png")
+		}
+		if labelLanguage.Labels().Combo != "" {
+			fmt.Println("  labels/combo/*.png")
+		}
+		fmt.Println("  data.ped")
+		files := pjsekaioverlay.ExoFileNames(pjsekaioverlay.ExoOptions{Hidden: hiddenElements, Split: splitExo})
+		if stats {
+			files = append(files, "stats.json", "stats.md")
+		}
+		if densityGraph || densityOverlay {
+			files = append(files, "density.png")
+		}
+		if markers {
+			files = append(files, "markers.json", "chapters.txt")
+		}
+		for _, backend := range selectedBackends {
+			files = append(files, backend.FileNames()...)
+		}
+		if scp {
+			files = append(files, pjsekaioverlay.ScpFileName(chartId))
+		}
+		files = append(files, "manifest.json")
+		if archive {
+			files = append(files, "../"+filepath.Base(formattedOutDir)+".zip")
+		}
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
+		printRemote("譜面データ (Chart data)", chart.Data)
+		fmt.Println("  長さ (Duration): 譜面データの取得後に決まります。(Determined after fetching chart data.)")
+		return
+	}
+
+	if exportDebug != "" {
+		if err := writeDebugBundle(client, chartSource, chart, levelFile, archetypeMapping, teamPower, exportDebug); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		}
+		return
+	}
+
+	// オプション指定時は入力が全て決まっているので、前回の出力と比較できる
+	outputHash := ""
+	// ローカルの譜面データは内容が変わりうるので比較しない
+	if isOptionSpecified && levelFile == "" {
+		options := []string{chartId, filepath.Dir(executablePath)}
+		flag.VisitAll(func(f *flag.Flag) {
+			if f.Name != "force" {
+				options = append(options, f.Name+"="+f.Value.String())
+			}
+		})
+		outputHash = pjsekaioverlay.CalculateOutputHash(chart, options...)
+		if !force && pjsekaioverlay.IsOutputUpToDate(formattedOutDir, outputHash) {
+			fmt.Println(color.GreenString("\n出力は最新です。(Up to date.)"))
+			return
+		}
+	}
+
+	// 前回の生成後にサーバー上の譜面が差し替えられていないか確認する
+	if previous, err := pjsekaioverlay.ReadManifest(formattedOutDir); err == nil && previous.DataHash != "" && previous.DataHash != chart.Data.Hash {
+		message := fmt.Sprintf("前回の生成後に譜面データが変更されています。(The chart data has changed since the last generation.) [%s -> %s]", previous.DataHash, chart.Data.Hash)
+		if requireHash {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", message)))
+			return
+		}
+		fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", message)))
+	}
+
+	scoreRulesets := pjsekaioverlay.BuiltinScoreRules
+	if scoreRulesFile != "" {
+		loadedRules, err := pjsekaioverlay.LoadScoreRules(scoreRulesFile)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		scoreRulesets = append(scoreRulesets, loadedRules...)
+	}
+	scoreRules, err := pjsekaioverlay.SelectScoreRules(scoreRulesets, gameVersion)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	entityFilter, err := pjsekaioverlay.ParseEntityFilter(skipEntities)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	unknownArchetypeMode, err := pjsekaioverlay.ParseUnknownArchetypeMode(unknownArchetypes)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	// 生成中のファイルは一時ディレクトリに書き込み、全て成功してから出力先に移動する
+	finalOutDir := formattedOutDir
+	tempOutDir, err := pjsekaioverlay.NewTempOutDir(finalOutDir)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	// 途中で失敗した場合は一時ディレクトリを残さない
+	defer tempOutDir.Discard()
+	formattedOutDir = tempOutDir.Path
+
+	stopTiming = timings.Start("fetch")
+	fmt.Print("- ジャケットをダウンロード中 (Downloading jacket)... ")
+	coverImageFormat, err := pjsekaioverlay.ParseImageFormat(coverFormat)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	coverFitMode, err := pjsekaioverlay.ParseFitMode(coverFit)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	coverOptions := pjsekaioverlay.CoverOptions{
+		Format:   coverImageFormat,
+		Size:     coverSize,
+		Fit:      coverFitMode,
+		Original: coverOriginal,
+	}
+	if coverUpscale != "" {
+		coverOptions.Upscaler = &pjsekaioverlay.CoverUpscaler{Command: coverUpscale, MinSize: coverUpscaleMin}
+		if err := coverOptions.Upscaler.Validate(); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+	imageFilters := pjsekaioverlay.ImageFilterConfig{}
+	if imageFilterFile != "" {
+		imageFilters, err = pjsekaioverlay.LoadImageFilterConfig(imageFilterFile)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		coverOptions.Filters = imageFilters.Cover
+	}
+
+	// 失敗しても譜面データを取得し直さないように、途中経過は出力先の隣に保存する
+	pipeline, err := client.NewPipeline(pjsekaioverlay.GenerateOptions{
+		ChartId:           chartId,
+		SourceId:          chartSource.Id,
+		LevelFile:         levelFile,
+		OutDir:            formattedOutDir,
+		BackgroundStyle:   backgroundStyle,
+		Cover:             coverOptions,
+		BackgroundFilters: imageFilters.Background,
+		ScoreRules:        scoreRules,
+		EntityFilter:      entityFilter,
+		UnknownArchetypes: unknownArchetypeMode,
+	}, pjsekaioverlay.DirOutput(formattedOutDir), finalOutDir+"."+pjsekaioverlay.PipelineStateFileName)
+	if err == nil {
+		err = pipeline.SetChart(chartSource, chart)
+	}
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	// 一時ディレクトリは毎回作り直すので、assetsは常にやり直す
+	err = pipeline.RunStage(pjsekaioverlay.StageAssets, func(step string) {
+		if step != "background" {
+			return
+		}
+		fmt.Println(color.GreenString("OK"))
+		if jacketPreview && SupportsTrueColor() {
+			if cover, err := pjsekaioverlay.LoadCover(formattedOutDir, coverImageFormat); err == nil {
+				fmt.Print(ImagePreview(cover, 32))
+			}
+		}
+		fmt.Print("- 背景をダウンロード中 (Downloading background)... ")
+	})
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	var backgroundVideoFile string
+	switch backgroundVideo {
+	case "none":
+	case "auto":
+		backgroundVideoFile, err = client.DownloadBackgroundVideo(chartSource, chart, formattedOutDir)
+	default:
+		backgroundVideoFile, err = pjsekaioverlay.CopyBackgroundVideo(backgroundVideo, formattedOutDir)
+	}
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	fmt.Println(color.GreenString("OK"))
+
+	var coverFrames []pjsekaioverlay.AnimatedCoverFrame
+	if animatedCover {
+		fmt.Print("- アニメーションするジャケットをダウンロード中 (Downloading animated jacket)... ")
+		coverFrames, err = client.DownloadAnimatedCover(chartSource, chart, formattedOutDir, coverOptions)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		if len(coverFrames) == 0 {
+			fmt.Println(color.YellowString("WARN:ジャケットはアニメーションしません。(The jacket is not animated.)"))
+		} else {
+			fmt.Println(color.GreenString("OK"))
+		}
+	}
+	stopTiming()
+
+	waveform := pjsekaioverlay.Waveform{}
+	if waveformMode != string(pjsekaioverlay.WaveformModeNone) {
+		stopTiming = timings.Start("export")
+		fmt.Print("- ビジュアライザーを生成中 (Generating visualizer)... ")
+		mode, err := pjsekaioverlay.ParseWaveformMode(waveformMode)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		audioPath := waveformAudio
+		if audioPath == "" {
+			audioPath, err = client.DownloadBgm(chartSource, chart, formattedOutDir)
+			if err != nil {
+				fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+				return
+			}
+		}
+		waveform, err = pjsekaioverlay.WriteWaveform(audioPath, formattedOutDir, mode)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		stopTiming()
+		fmt.Println(color.GreenString("OK"))
+	}
+
+	hook := hookEnv{
+		stage:   "assets",
+		chartId: chartId,
+		source:  chartSource.Id,
+		level:   chart,
+		outDir:  finalOutDir,
+		workDir: formattedOutDir,
+	}
+	if archive {
+		hook.archive = finalOutDir + ".zip"
+	}
+	if err := runHook(hookAfterAssets, hook); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	// 譜面データは受信しながら読み込むので、ダウンロードの時間も含む
+	stopTiming = timings.Start("decode")
+	fmt.Print("- 譜面を解析中 (Analyzing chart)... ")
+	// 前回の実行で取得済みの場合は読み込まない
+	if !pipeline.Completed(pjsekaioverlay.StageFetch) {
+		if err := pipeline.RunStage(pjsekaioverlay.StageFetch, nil); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+
+	stopTiming()
+	fmt.Println(color.GreenString("OK"))
+
+	if !isOptionSpecified {
+		fmt.Print("総合力を指定してください。\nInput your team's power.\n> ")
+		var tmpTeamPower string
+		fmt.Scanln(&tmpTeamPower)
+		teamPower, err = strconv.Atoi(tmpTeamPower)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		fmt.Printf("\033[A\033[2K\r> %s\n", color.GreenString(tmpTeamPower))
+
+	}
+
+	if err := comboCounter.Validate(); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	// 対応していないアーキタイプがあると、ノーツ数やスコアが合わなくなる
+	if unknown := pjsekaioverlay.FindUnknownArchetypes(entityFilter.Apply(pipeline.State.LevelData), scoreRules); len(unknown) > 0 {
+		if unknownArchetypeMode == pjsekaioverlay.UnknownArchetypeCombo {
+			fmt.Println(color.YellowString("WARN:未対応のアーキタイプを通常のタップノーツとして数えます。(Counting unsupported archetypes as normal tap notes.)"))
+		} else {
+			fmt.Println(color.YellowString("WARN:未対応のアーキタイプはコンボに含めません。--unknown-archetypes comboか--archetype-mapで指定して下さい。(Unsupported archetypes are excluded from the combo. Use --unknown-archetypes combo or --archetype-map.)"))
+		}
+		for _, archetype := range unknown {
+			fmt.Printf("  %s: %d\n", archetype.Archetype, archetype.Count)
+		}
+	}
+	levelData := pipeline.LevelData()
+
+	if err := pjsekaioverlay.ValidatePlaybackSpeed(playbackSpeed); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	// 統計は再生速度や区間に関係なく譜面全体から求める
+	chartLevelData := levelData
+	levelData = pjsekaioverlay.ScaleLevelData(levelData, playbackSpeed)
+
+	// 一時停止の区間で時間をずらしてから、録画のフレームに合わせる
+	timeMappers := pjsekaioverlay.TimeMappers{}
+	if len(pauses) > 0 {
+		timeMappers = append(timeMappers, pjsekaioverlay.NewPauseMap(pauses))
+	}
+	if timeMapFile != "" {
+		timeMap, err := pjsekaioverlay.LoadTimeMap(timeMapFile)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		timeMappers = append(timeMappers, timeMap)
+	}
+	var timeMapper pjsekaioverlay.TimeMapper
+	if len(timeMappers) > 0 {
+		timeMapper = timeMappers
+	}
+
+	// 小節番号は拍子の指定がなければ4拍子として数える
+	beatsPerMeasure := 4
+	if beatGrid > 0 {
+		beatsPerMeasure = beatGrid
+	}
+	var timeRange pjsekaioverlay.TimeRange
+	timeRange.From, err = pjsekaioverlay.ParseTimeRangePoint(rangeFrom, levelData, beatsPerMeasure)
+	if err == nil {
+		timeRange.To, err = pjsekaioverlay.ParseTimeRangePoint(rangeTo, levelData, beatsPerMeasure)
+	}
+	if err == nil {
+		err = timeRange.Validate()
+	}
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	timeRange.ShiftLevelData(&levelData)
+	comboStart := comboCounter.Start
+
+	missTimeList, err := pjsekaioverlay.ParseMissTimes(missTimes)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	stopTiming = timings.Start("simulate")
+	fmt.Print("- スコアを計算中 (Calculating score)... ")
+	fullScoreData := pjsekaioverlay.CalculateScoreWithMisses(chart, levelData, teamPower, scoreRules, missTimeList)
+	scoreData, skippedNotes := pjsekaioverlay.TrimFrames(fullScoreData, timeRange)
+	// 区間より前のノーツの分だけコンボ数を進める
+	comboCounter.Start = comboStart + skippedNotes
+
+	multiLiveOptions.Seed, err = pjsekaioverlay.ParseSeed(seed)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	multiLiveOptions.Players, err = pjsekaioverlay.ParseMultiLivePlayers(multiPlayers)
+	if err == nil {
+		err = multiLiveOptions.Validate()
+	}
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	multiLive := pjsekaioverlay.TrimMultiLive(pjsekaioverlay.SimulateMultiLive(chart, levelData, scoreRules, multiLiveOptions), timeRange)
+
+	stopTiming()
+	fmt.Println(color.GreenString("OK"))
+
+	comparison := pjsekaioverlay.Comparison{}
+	if compareChartId != "" {
+		stopTiming = timings.Start("fetch")
+		fmt.Print("- 比較する譜面を取得中 (Getting chart to compare)... ")
+		compareMatches, err := client.FindChart(compareChartId)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		// 同じサーバーでも見つかった場合はそちらを使う
+		compareMatch := compareMatches[0]
+		for _, m := range compareMatches {
+			if m.Source.Id == chartSource.Id {
+				compareMatch = m
+				break
+			}
+		}
+		if compareMatch.Level.Engine.Version != 12 {
+			fmt.Println(color.RedString(fmt.Sprintf("失敗：エンジンのバージョンが古い。\nFAIL: Unsupported engine version. - [ver.%d]", compareMatch.Level.Engine.Version)))
+			return
+		}
+		compareLevelData, err := client.FetchLevelData(compareMatch.Source, compareMatch.Level)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		// 同じ再生速度・区間で計算する
+		compareLevelData = pjsekaioverlay.ScaleLevelData(unknownArchetypeMode.Apply(entityFilter.Apply(compareLevelData), scoreRules), playbackSpeed)
+		timeRange.ShiftLevelData(&compareLevelData)
+		compareFrames, _ := pjsekaioverlay.TrimFrames(pjsekaioverlay.CalculateScoreWithRules(compareMatch.Level, compareLevelData, teamPower, scoreRules), timeRange)
+		// 同じ曲の譜面を比べることが多いので、曲名が同じ場合（や曲名がない場合）は譜面IDを表示する
+		compareTitle := compareMatch.Level.Title
+		if strings.TrimSpace(compareTitle) == "" || compareTitle == chart.Title {
+			compareTitle = compareChartId
+		}
+		comparison = pjsekaioverlay.NewComparison(compareTitle, scoreData, compareFrames)
+		stopTiming()
+		fmt.Println(color.GreenString(fmt.Sprintf("OK (%d)", len(comparison.Differences))))
+	}
+
+	ghost := pjsekaioverlay.Ghost{}
+	if targetScore != "" {
+		ghost, err = pjsekaioverlay.ParseGhost(targetScore)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		// 区間より前のノーツの分も進めてから取り除く
+		ghost.Frames, _ = pjsekaioverlay.TrimFrames(pjsekaioverlay.CalculateGhostFrames(fullScoreData, ghost.Score), timeRange)
+	}
+
+	if syncRecording != "" && len(scoreData) > 0 {
+		if leadIn >= 0 {
+			fmt.Println(color.RedString("FAIL:--lead-inと--sync-recordingは同時に指定できません。(--lead-in and --sync-recording cannot be used together.)"))
+			return
+		}
+		fmt.Print("- 録画と同期中 (Syncing with recording)... ")
+		onsets, err := pjsekaioverlay.LoadAudioOnsets(syncRecording)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		offset, confidence, err := pjsekaioverlay.DetectSyncOffset(onsets, pjsekaioverlay.MapFrames(scoreData, timeMapper))
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		// 録画の先頭が再生開始の位置なので、譜面の0秒をoffset秒目にずらす
+		timeMappers = append(timeMappers, pjsekaioverlay.TimeOffset(offset-pjsekaioverlay.DefaultLeadIn))
+		timeMapper = timeMappers
+		if confidence < 2 {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:同期の結果が不確かです。(The sync result is uncertain.) [%.3fs]", offset)))
+		} else {
+			fmt.Println(color.GreenString(fmt.Sprintf("OK (%.3fs)", offset)))
+		}
+	}
+	if leadIn >= 0 && len(scoreData) > 0 {
+		// ほかの変換の後で、最初のノーツの位置をそろえる
+		timeMappers = append(timeMappers, pjsekaioverlay.LeadInOffset(leadIn, pjsekaioverlay.MapTime(timeMapper, scoreData[0].Time)))
+		timeMapper = timeMappers
+	}
+
+	lyrics := []pjsekaioverlay.LyricLine{}
+	if lyricsFile != "" {
+		loadedLyrics, err := pjsekaioverlay.LoadLyrics(lyricsFile)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		lyrics = pjsekaioverlay.AdjustLyrics(loadedLyrics, playbackSpeed, timeRange, timeMapper)
+	}
+
+	if !isOptionSpecified {
+		fmt.Print("コンボのAP表示を有効にしますか？ (Enable AP indicator for combo?) [y/n]\n> ")
+		before, _ := rawmode.Enable()
+		tmpEnableComboApByte, _ := bufio.NewReader(os.Stdin).ReadByte()
+		tmpEnableComboAp := string(tmpEnableComboApByte)
+		rawmode.Restore(before)
+		fmt.Printf("\n\033[A\033[2K\r> %s\n", color.GreenString(tmpEnableComboAp))
+		if tmpEnableComboAp == "Y" || tmpEnableComboAp == "y" || tmpEnableComboAp == "" {
+			apCombo = true
+		} else {
+			apCombo = false
+		}
+	}
+	assets, err := resolveAssetsDir(assetsDir)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	hook.assets = assets
 
-	var teamPower int
-	flag.IntVar(&teamPower, "team-power", 250000, "総合力を指定します。(Enter the team's power.)")
+	stopTiming = timings.Start("export")
+	fmt.Print("- pedファイルを生成中 (Generating ped file)... ")
 
-	var apCombo bool
-	flag.BoolVar(&apCombo, "ap-combo", true, "コンボのAP表示を有効にします。(Enable AP display for combo.)")
+	milestoneInterval := 0
+	exoObjects := []pjsekaioverlay.ExoObject{}
+	if comboMilestone {
+		milestoneInterval = 100
+		exoObjects = append(exoObjects, pjsekaioverlay.MilestoneExoObject)
+	}
+	milestones := pjsekaioverlay.CalculateMilestones(scoreData, milestoneInterval, comboCounter)
+	rankCrossings := []pjsekaioverlay.RankCrossing{}
+	if rankEffect {
+		rankCrossings = pjsekaioverlay.CalculateRankCrossings(scoreData, chart.Rating)
+		exoObjects = append(exoObjects, pjsekaioverlay.RankExoObject)
+	}
 
-	flag.Usage = func() {
-		fmt.Println("Usage: pjsekai-overlay [譜面ID] [オプション]")
-		flag.PrintDefaults()
+	if judgmentCounter {
+		exoObjects = append(exoObjects, pjsekaioverlay.JudgmentCountExoObject)
+	}
+	if judgmentText {
+		exoObjects = append(exoObjects, pjsekaioverlay.JudgmentTextExoObject)
 	}
 
-	flag.Parse()
+	beats := pjsekaioverlay.CalculateBeats(levelData, scoreData, beatGrid)
+	if beatGrid > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.BeatExoObject)
+	}
+	measures := []pjsekaioverlay.MeasurePosition{}
+	if measureCounter {
+		measures = pjsekaioverlay.CalculateMeasures(levelData, scoreData, beatsPerMeasure)
+		exoObjects = append(exoObjects, pjsekaioverlay.MeasureExoObject)
+	}
+	npsMeter := pjsekaioverlay.CalculateNpsMeter(levelData, scoreData, npsWindow)
+	if npsWindow > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.NpsMeterExoObject)
+	}
+	guideBoxes, err := pjsekaioverlay.ParseGuides(guides)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
 
-	if shouldCheckUpdate() {
-		checkUpdate()
+	if len(multiLive) > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.MultiLiveExoObject)
+	}
+	if len(comparison.Frames) > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.ComparisonExoObject)
+	}
+	if len(ghost.Frames) > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.GhostExoObject)
+	}
+	misses := pjsekaioverlay.CalculateMisses(scoreData, comboCounter)
+	if len(misses) > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.MissFlashExoObject)
+		// ミスがある場合はAP表示にならない
+		apCombo = false
 	}
 
-	if !skipAviutlInstall {
-		success := pjsekaioverlay.TryInstallObject()
-		if success {
-			fmt.Println(color.GreenString("AviUtlオブジェクトのインストールに成功しました。(AviUtl object successfully installed.)"))
+	density := pjsekaioverlay.DensityGraph{}
+	if densityGraph || densityOverlay {
+		density, err = pjsekaioverlay.WriteDensityGraph(pjsekaioverlay.CalculateNoteStats(chartLevelData), formattedOutDir)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
 		}
 	}
-
-	var chartId string
-	if flag.Arg(0) != "" {
-		chartId = flag.Arg(0)
-		fmt.Printf("譜面ID (Chart ID): %s\n", color.GreenString(chartId))
+	if densityOverlay {
+		exoObjects = append(exoObjects, pjsekaioverlay.DensityExoObject)
 	} else {
-		fmt.Print("譜面IDをプレフィックス込みで入力して下さい。\nEnter the chart ID including the prefix.\n\n'chcy-': Chart Cyanvas (cc.sevenc7c.com)\n'ptlv-': Potato Leaves (ptlv.sevenc7c.com)\n> ")
-		fmt.Scanln(&chartId)
-		fmt.Printf("\033[A\033[2K\r> %s\n", color.GreenString(chartId))
+		density = pjsekaioverlay.DensityGraph{}
 	}
 
-	chartSource, err := pjsekaioverlay.DetectChartSource(chartId)
-	if err != nil {
-		fmt.Println(color.RedString("譜面のサーバーを判別できませんでした。プレフィックスも込め、正しい譜面IDを入力して下さい。\nThe specified chart doesn't exist. Please enter the correct chart ID including the prefix."))
+	if waveform.Path != "" {
+		// 曲の先頭に、再生速度・区間・時間の変換を歌詞と同じように適用する
+		waveform.Start = pjsekaioverlay.MapTime(timeMapper, -timeRange.From)
+	}
+
+	if err := comboAnimation.Validate(); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+	if err := scoreAnimation.Validate(); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
-	fmt.Printf("- 譜面を取得中 (Getting chart): %s%s%s ", RgbColorEscape(chartSource.Color), chartSource.Name, ResetEscape())
-	chart, err := pjsekaioverlay.FetchChart(chartSource, chartId)
 
+	liveMode, err := pjsekaioverlay.ParseLiveMode(liveModeName, teamColor)
 	if err != nil {
-		fmt.Println(color.RedString(fmt.Sprintf("FAIL: %s", err.Error())))
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
-	if chart.Engine.Version != 12 {
-		fmt.Println(color.RedString(fmt.Sprintf("失敗：エンジンのバージョンが古い。\nFAIL: Unsupported engine version. - [ver.%d]", chart.Engine.Version)))
+
+	scoreFormat.Separator, err = pjsekaioverlay.ParseScoreLocale(scoreLocale)
+	if err == nil {
+		err = scoreFormat.Validate()
+	}
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
 
-	fmt.Println(color.GreenString("OK"))
-	fmt.Printf("  %s / %s - %s (Lv. %s)\n",
-		color.CyanString(chart.Title),
-		color.CyanString(chart.Artists),
-		color.CyanString(chart.Author),
-		color.MagentaString(strconv.Itoa(chart.Rating)),
-	)
+	// --tintを指定した場合はそちらを優先する
+	if tagPreset.Tint != "" && tintColor == "" {
+		tintColor = tagPreset.Tint
+		if tagPreset.TintElements != "" {
+			tintElements = tagPreset.TintElements
+		}
+	}
+	var tints []pjsekaioverlay.PedTint
+	if tintColor != "" {
+		tint, err := pjsekaioverlay.ParseTint(tintColor, chartSource, tintElements)
+		if err == nil {
+			tints, err = pjsekaioverlay.WriteTintedAssets(assets, formattedOutDir, tint)
+		}
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
 
-	fmt.Printf("- exeのパスを取得中 (Getting executable path)... ")
-	executablePath, err := os.Executable()
+	gaugePath := ""
+	gauge, err := pjsekaioverlay.ParseScoreGauge(scoreGauge)
+	if err == nil && gauge == pjsekaioverlay.ScoreGaugeProportional {
+		// 枠の色を変えた場合は、色を変えた画像の目盛りを描き直す
+		frameDir := assets
+		for _, tint := range tints {
+			if tint.Element == pjsekaioverlay.TintElementFrame {
+				frameDir = tint.Path
+			}
+		}
+		gaugePath, err = pjsekaioverlay.WriteGaugeAssets(frameDir, formattedOutDir, chart.Rating)
+	}
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
 
-	fmt.Println(color.GreenString("OK"))
+	comboImagesDir := ""
+	if comboImages {
+		fmt.Print("- コンボの画像を生成中 (Rendering combo images)... ")
+		comboImagesDir, err = pjsekaioverlay.WriteComboImages(assets, formattedOutDir, comboCounter.Max(len(scoreData)-1), apCombo, tints)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		fmt.Println(color.GreenString("OK"))
+	}
 
-	cwd, err := os.Getwd()
+	counterFontName := ""
+	if counterFont != "" {
+		counterFontName, err = installFont(counterFont)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
 
+	pedStamp := outputHash
+	// シードを指定した場合は、生成時刻の代わりにシードから求めた値を書き込む
+	if seed != "" && pedStamp == "" {
+		pedStamp = pjsekaioverlay.CalculateOutputHash(chart, "seed="+seed)
+	}
+	pedOptions := pjsekaioverlay.PedOptions{
+		Milestones:     milestones,
+		RankCrossings:  rankCrossings,
+		ComboAnimation: comboAnimation,
+		ScoreAnimation: scoreAnimation,
+		ComboCounter:   comboCounter,
+		ScoreFormat:    scoreFormat,
+		PlaybackSpeed:  playbackSpeed,
+		LiveMode:       liveMode,
+		MultiLive:      multiLive,
+		Comparison:     comparison,
+		Ghost:          ghost,
+		Misses:         misses,
+		TimeMapper:     timeMapper,
+		Beats:          beats,
+		Measures:       measures,
+		NpsMeter:       npsMeter,
+		Guides:         guideBoxes,
+		OutputHash:     pedStamp,
+		DensityGraph:   density,
+		Waveform:       waveform,
+		CounterFont:    counterFontName,
+		ComboImages:    comboImagesDir,
+		CoverFrames:    coverFrames,
+		Tints:          tints,
+	}
+	if accessible {
+		pedOptions.Outline = 4
+	}
+	pedOptions.Gauge = gaugePath
+	// 指定したフォントに文字がない場合は、言語ごとのフォントを使う
+	labelFonts := []string{}
+	if textFont != "" {
+		labelFonts = append(labelFonts, textFont)
+	}
+	pedOptions.Labels, err = pjsekaioverlay.WriteLabelAssets(labelFonts, formattedOutDir, labelLanguage)
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
+	// 一時ディレクトリに書き込んだファイルは、移動後のパスで参照する
+	pedOptions = pedOptions.InOutDir(formattedOutDir, finalOutDir)
+	err = pjsekaioverlay.WritePedFile(scoreData, assets, apCombo, filepath.Join(formattedOutDir, "data.ped"), sonolus.LevelInfo{Rating: chart.Rating}, pedOptions)
 
-	formattedOutDir := filepath.Join(cwd, strings.Replace(outDir, "_chartId_", chartId, -1))
-	fmt.Printf("- 出力先ディレクトリ (Output path): %s\n", color.CyanString(filepath.Dir(formattedOutDir)))
-
-	fmt.Print("- ジャケットをダウンロード中 (Downloading jacket)... ")
-	err = pjsekaioverlay.DownloadCover(chartSource, chart, formattedOutDir)
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
@@ -162,17 +1517,105 @@ func origMain(isOptionSpecified bool) {
 
 	fmt.Println(color.GreenString("OK"))
 
-	fmt.Print("- 背景をダウンロード中 (Downloading background)... ")
-	err = pjsekaioverlay.DownloadBackground(chartSource, chart, formattedOutDir)
+	fmt.Print("- exoファイルを生成中 (Generating exo file)... ")
+
+	var finaleVideo string
+	switch finale {
+	case "ap":
+		finaleVideo = pjsekaioverlay.FinaleVideo(true)
+	case "fc":
+		finaleVideo = pjsekaioverlay.FinaleVideo(false)
+	case "auto":
+		finaleVideo = pjsekaioverlay.FinaleVideo(apCombo)
+	default:
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:不明な演出です。(Unknown finale.) [%s]", finale)))
+		return
+	}
+	// FC用の動画が用意されていない場合はAP用の動画を使う
+	if _, err := os.Stat(filepath.Join(assets, finaleVideo)); err != nil {
+		finaleVideo = pjsekaioverlay.FinaleVideo(true)
+	}
+	// ロングの終点まで含めた譜面の終わり（曲の長さが分かる場合はそれまで）
+	chartEndLimit := pjsekaioverlay.ChartEndLimit(waveform.Duration(), playbackSpeed, timeRange)
+	exoFinale := pjsekaioverlay.ExoFinale{
+		LastNoteTime: pjsekaioverlay.MapTime(timeMapper, pjsekaioverlay.CalculateChartEnd(levelData, scoreData, chartEndLimit)),
+		Video:        finaleVideo,
+		Speed:        playbackSpeed,
+		Tail:         tail,
+	}
+
+	artists := pjsekaioverlay.FormatExoArtistsIn(labelLanguage, chartSource, chart)
+
+	textFontName := ""
+	if textFont != "" {
+		textFontName, err = installFont(textFont)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		// 文字がない場合はAviUtlで正しく表示されない
+		missing, err := pjsekaioverlay.FontMissingGlyphs(textFont, chart.Title+artists)
+		if err == nil && len(missing) > 0 {
+			fmt.Print(color.YellowString(fmt.Sprintf("WARN:フォントに含まれない文字があります。(The font is missing some characters.) [%s] ", string(missing))))
+		}
+	}
+
+	exoFormat, err := pjsekaioverlay.ParseExoFormat(exoEncoding, exoLineEnding)
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
 
-	fmt.Println(color.GreenString("OK"))
-
-	fmt.Print("- 譜面を解析中 (Analyzing chart)... ")
-	levelData, err := pjsekaioverlay.FetchLevelData(chartSource, chart)
+	// 他の要素より上のレイヤーに置く
+	if len(guideBoxes) > 0 {
+		exoObjects = append(exoObjects, pjsekaioverlay.GuidesExoObject)
+	}
+	exoOptions := pjsekaioverlay.ExoOptions{
+		CoverFormat: coverImageFormat,
+		DistDir:     finalOutDir,
+		Objects:     exoObjects,
+		Finale:      exoFinale,
+		Hidden:      hiddenElements,
+		Font:        textFontName,
+		Labels:      labelLanguage,
+		Difficulty:  tagPreset.Difficulty,
+		Format:      exoFormat,
+		Split:       splitExo,
+		Lyrics:      lyrics,
+		// アニメーションしない場合は通常のジャケットのまま
+		AnimatedCover:   len(coverFrames) > 0,
+		BackgroundVideo: backgroundVideoFile,
+		Waveform:        waveform.Path != "",
+	}
+	if accessible {
+		if accessibleScale <= 0 {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:拡大率は0より大きくして下さい。(The scale must be greater than 0.) [%g]", accessibleScale)))
+			return
+		}
+		exoOptions.CounterScale = accessibleScale
+	}
+	if chromaKey {
+		key, err := pjsekaioverlay.ParseChromaKey(chromaKeyColor, chromaKeyAntialias)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		exoOptions.ChromaKey = &key
+	}
+	if cutIns {
+		exoOptions.CutIns = pjsekaioverlay.MapCutIns(pjsekaioverlay.CalculateCutIns(levelData, scoreData), timeMapper)
+	}
+	writeExos := func() error {
+		switch exoOut {
+		case "":
+			return pjsekaioverlay.WriteExoFiles(assets, formattedOutDir, chart.Title, artists, exoOptions)
+		case "-":
+			return pjsekaioverlay.WriteExo(exoStdout, exoOutVariant, assets, finalOutDir, chart.Title, artists, exoOptions)
+		default:
+			return writeExoOut(exoOut, exoOutVariant, assets, finalOutDir, chart.Title, artists, exoOptions)
+		}
+	}
+	err = writeExos()
 
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
@@ -181,70 +1624,243 @@ func origMain(isOptionSpecified bool) {
 
 	fmt.Println(color.GreenString("OK"))
 
-	if !isOptionSpecified {
-		fmt.Print("総合力を指定してください。\nInput your team's power.\n> ")
-		var tmpTeamPower string
-		fmt.Scanln(&tmpTeamPower)
-		teamPower, err = strconv.Atoi(tmpTeamPower)
+	if stats {
+		fmt.Print("- 統計を生成中 (Generating stats)... ")
+
+		err = pjsekaioverlay.WriteNoteStats(pjsekaioverlay.CalculateNoteStats(chartLevelData), chart.Title, formattedOutDir)
+
 		if err != nil {
 			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 			return
 		}
-		fmt.Printf("\033[A\033[2K\r> %s\n", color.GreenString(tmpTeamPower))
 
+		fmt.Println(color.GreenString("OK"))
 	}
 
-	fmt.Print("- スコアを計算中 (Calculating score)... ")
-	scoreData := pjsekaioverlay.CalculateScore(chart, levelData, teamPower)
+	if markers {
+		fmt.Print("- マーカーを生成中 (Generating markers)... ")
 
-	fmt.Println(color.GreenString("OK"))
+		err = pjsekaioverlay.WriteMarkers(pjsekaioverlay.MapMarkers(pjsekaioverlay.CalculateMarkers(levelData, scoreData), timeMapper), formattedOutDir)
 
-	if !isOptionSpecified {
-		fmt.Print("コンボのAP表示を有効にしますか？ (Enable AP indicator for combo?) [y/n]\n> ")
-		before, _ := rawmode.Enable()
-		tmpEnableComboApByte, _ := bufio.NewReader(os.Stdin).ReadByte()
-		tmpEnableComboAp := string(tmpEnableComboApByte)
-		rawmode.Restore(before)
-		fmt.Printf("\n\033[A\033[2K\r> %s\n", color.GreenString(tmpEnableComboAp))
-		if tmpEnableComboAp == "Y" || tmpEnableComboAp == "y" || tmpEnableComboAp == "" {
-			apCombo = true
-		} else {
-			apCombo = false
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
 		}
+
+		fmt.Println(color.GreenString("OK"))
 	}
-	executableDir := filepath.Dir(executablePath)
-	assets := filepath.Join(executableDir, "assets")
 
-	fmt.Print("- pedファイルを生成中 (Generating ped file)... ")
+	if len(selectedBackends) > 0 {
+		timeline := pjsekaioverlay.Timeline{
+			Source:         chartSource,
+			Level:          chart,
+			Artists:        artists,
+			Frames:         pjsekaioverlay.MapFrames(scoreData, timeMapper),
+			ComboCounter:   comboCounter,
+			ScoreAnimation: scoreAnimation,
+			ComboImages:    pedOptions.ComboImages,
+			Lyrics:         lyrics,
+			CoverFormat:    coverImageFormat,
+			Exo:            exoOptions,
+		}
+		for _, backend := range selectedBackends {
+			fmt.Printf("- 出力形式「%s」を生成中 (Generating %s)... ", backend.Name(), backend.Name())
+
+			err = backend.Generate(timeline, assets, formattedOutDir, backendOptions[backend.Name()])
+
+			if err != nil {
+				fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+				return
+			}
 
-	err = pjsekaioverlay.WritePedFile(scoreData, assets, apCombo, filepath.Join(formattedOutDir, "data.ped"), sonolus.LevelInfo{Rating: chart.Rating})
+			fmt.Println(color.GreenString("OK"))
+		}
+	}
+
+	if scp {
+		fmt.Print("- コレクションパッケージを生成中 (Generating collection package)... ")
+		if levelFile != "" {
+			fmt.Print(color.YellowString("WARN:--level-fileの譜面データではなく、サーバーの譜面を保存します。(Saving the chart on the server, not the --level-file data.) "))
+		}
+		err = client.WriteLevelPackage(chartSource, chartId, filepath.Join(formattedOutDir, pjsekaioverlay.ScpFileName(chartId)))
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		fmt.Println(color.GreenString("OK"))
+	}
 
+	err = tempOutDir.Commit()
+	if err == nil {
+		err = pipeline.Finish()
+	}
 	if err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
+	formattedOutDir = finalOutDir
+	hook.workDir = finalOutDir
 
-	fmt.Println(color.GreenString("OK"))
+	manifest := pjsekaioverlay.ArchiveManifest{
+		ChartId:  chartId,
+		Source:   chartSource.Id,
+		Title:    chart.Title,
+		Artists:  chart.Artists,
+		Author:   chart.Author,
+		Rating:   chart.Rating,
+		DataHash: chart.Data.Hash,
+		Options:  map[string]string{},
+	}
+	flag.Visit(func(f *flag.Flag) {
+		manifest.Options[f.Name] = f.Value.String()
+	})
+	if archive {
+		fmt.Print("- zipファイルを生成中 (Generating zip file)... ")
 
-	fmt.Print("- exoファイルを生成中 (Generating exo file)... ")
+		err = pjsekaioverlay.WriteArchive(formattedOutDir, formattedOutDir+".zip", manifest)
+
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+
+		fmt.Println(color.GreenString("OK"))
+	} else {
+		err = pjsekaioverlay.WriteManifest(formattedOutDir, manifest)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
+
+	if outputHash != "" {
+		err = pjsekaioverlay.WriteOutputHash(formattedOutDir, outputHash)
+		if err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
 
-	composerAndVocals := []string{chart.Artists, "？"}
-	if separateAttempt := strings.Split(chart.Artists, " / "); chartSource.Id == "chart_cyanvas" && len(separateAttempt) <= 2 {
-		composerAndVocals = separateAttempt
+	if uploadOutput != nil {
+		fmt.Print("- アップロード中 (Uploading)... ")
+		if err := pjsekaioverlay.UploadDir(formattedOutDir, uploadOutput); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		fmt.Println(color.GreenString("OK"))
 	}
 
-	artists := fmt.Sprintf("作詞：？    作曲：%s    編曲：？\r\nVo：%s   譜面作成：%s", composerAndVocals[0], composerAndVocals[1], chart.Author)
+	stopTiming()
+	fmt.Print("- 処理時間 (Timing):\n" + timings.Report())
 
-	err = pjsekaioverlay.WriteExoFiles(assets, formattedOutDir, chart.Title, artists)
+	if !noHistory {
+		err = appendHistory(pjsekaioverlay.HistoryEntry{
+			Time:       startTime,
+			ChartId:    chartId,
+			Source:     chartSource.Id,
+			Title:      chart.Title,
+			Rating:     chart.Rating,
+			OutDir:     formattedOutDir,
+			Options:    manifest.Options,
+			DurationMs: time.Since(startTime).Milliseconds(),
+			Timings:    timings.List(),
+		})
+		if err != nil && strict {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+		if err != nil {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		}
+	}
 
-	if err != nil {
+	hook.stage = "generate"
+	if err := runHook(hookAfterGenerate, hook); err != nil {
 		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
 		return
 	}
 
-	fmt.Println(color.GreenString("OK"))
+	for _, otherChartId := range otherDifficulties {
+		fmt.Printf("\n- 別の難易度を生成中 (Generating another difficulty): %s\n", color.GreenString(otherChartId))
+		if err := runForChart(otherChartId); err != nil {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+			return
+		}
+	}
 
 	fmt.Println(color.GreenString("\n全ての処理が完了しました。READMEの規約を確認した上で、exoファイルをAviUtlにインポートして下さい。\nExecution complete! Please import the exo file into AviUtl after reviewing the README terms and conditions."))
+
+	if openOutDir {
+		if err := pjsekaioverlay.OpenDirectory(formattedOutDir); err != nil {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		}
+	}
+	if launchAviUtl != "" {
+		var exoPath string
+		switch exoOut {
+		case "":
+			exoPath, err = pjsekaioverlay.ExoFilePath(formattedOutDir, exoOutVariant)
+		case "-":
+			err = fmt.Errorf("標準出力に書き出したexoは開けません。(The exo written to stdout cannot be opened.)")
+		default:
+			// AviUtlはaviutl.exeの場所で起動する
+			exoPath, err = filepath.Abs(exoOut)
+		}
+		if err == nil {
+			err = pjsekaioverlay.LaunchAviUtl(launchAviUtl, exoPath)
+		}
+		if err != nil {
+			fmt.Println(color.YellowString(fmt.Sprintf("WARN:%s", err.Error())))
+		}
+	}
+
+	if watch {
+		fmt.Println(color.CyanString(fmt.Sprintf("\n- 譜面データを監視中 (Watching level data): %s", levelFile)))
+		pjsekaioverlay.WatchFile(levelFile, 500*time.Millisecond, func() error {
+			fmt.Print("- pedファイルとexoファイルを再生成中 (Regenerating ped and exo files)... ")
+			levelData, err := pjsekaioverlay.LoadLevelFile(levelFile, archetypeMapping)
+			if err != nil {
+				return err
+			}
+			levelData = pjsekaioverlay.ScaleLevelData(unknownArchetypeMode.Apply(entityFilter.Apply(levelData), scoreRules), playbackSpeed)
+			timeRange.ShiftLevelData(&levelData)
+			fullScoreData := pjsekaioverlay.CalculateScoreWithMisses(chart, levelData, teamPower, scoreRules, missTimeList)
+			scoreData, skippedNotes := pjsekaioverlay.TrimFrames(fullScoreData, timeRange)
+			comboCounter.Start = comboStart + skippedNotes
+			pedOptions.ComboCounter = comboCounter
+			pedOptions.Milestones = pjsekaioverlay.CalculateMilestones(scoreData, milestoneInterval, comboCounter)
+			if rankEffect {
+				pedOptions.RankCrossings = pjsekaioverlay.CalculateRankCrossings(scoreData, chart.Rating)
+			}
+			pedOptions.Beats = pjsekaioverlay.CalculateBeats(levelData, scoreData, beatGrid)
+			if measureCounter {
+				pedOptions.Measures = pjsekaioverlay.CalculateMeasures(levelData, scoreData, beatsPerMeasure)
+			}
+			pedOptions.NpsMeter = pjsekaioverlay.CalculateNpsMeter(levelData, scoreData, npsWindow)
+			if len(comparison.Frames) > 0 {
+				pedOptions.Comparison = pjsekaioverlay.NewComparison(comparison.Title, scoreData, comparison.Frames)
+			}
+			pedOptions.Misses = pjsekaioverlay.CalculateMisses(scoreData, comboCounter)
+			if len(ghost.Frames) > 0 {
+				pedOptions.Ghost.Frames, _ = pjsekaioverlay.TrimFrames(pjsekaioverlay.CalculateGhostFrames(fullScoreData, ghost.Score), timeRange)
+			}
+			pedOptions.MultiLive = pjsekaioverlay.TrimMultiLive(pjsekaioverlay.SimulateMultiLive(chart, levelData, scoreRules, multiLiveOptions), timeRange)
+			if err := pjsekaioverlay.WritePedFile(scoreData, assets, apCombo, filepath.Join(formattedOutDir, "data.ped"), sonolus.LevelInfo{Rating: chart.Rating}, pedOptions); err != nil {
+				return err
+			}
+			exoOptions.Finale.LastNoteTime = pjsekaioverlay.MapTime(timeMapper, pjsekaioverlay.CalculateChartEnd(levelData, scoreData, chartEndLimit))
+			if cutIns {
+				exoOptions.CutIns = pjsekaioverlay.MapCutIns(pjsekaioverlay.CalculateCutIns(levelData, scoreData), timeMapper)
+			}
+			if err := writeExos(); err != nil {
+				return err
+			}
+			fmt.Println(color.GreenString("OK"))
+			return nil
+		}, func(err error) {
+			fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		})
+	}
 }
 
 func main() {
@@ -254,7 +1870,73 @@ func main() {
 
 	windows.GetConsoleMode(stdout, &originalMode)
 	windows.SetConsoleMode(stdout, originalMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
-	origMain(isOptionSpecified)
+
+	if isOptionSpecified && os.Args[1] == "serve" {
+		serveMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "daemon" {
+		daemonMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "grpc" {
+		grpcMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "update-assets" {
+		updateAssetsMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "doctor" {
+		doctorMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "history" {
+		historyMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "verify" {
+		verifyMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "reference" {
+		referenceMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "preview" {
+		previewMain(os.Args[2:])
+		return
+	}
+
+	if isOptionSpecified && os.Args[1] == "export-debug" {
+		os.Args = append(os.Args[:1], exportDebugArgs(os.Args[2:])...)
+	}
+
+	// ウィザードで選んだ内容は引数になるので、先に決めておく
+	defaultMode := defaultDiagnosticsMode(isOptionSpecified)
+	if isOptionSpecified {
+		selectProgressOutput(os.Args[1:])
+		runWithDiagnostics(defaultMode, func() { origMain(true) })
+	} else {
+		// 引数なしで起動した場合は、ウィザードで選んだ内容を引数として扱う（使えない端末では従来の入力に戻す）
+		args, err := runWizard()
+		if errors.Is(err, errWizardCancelled) {
+			return
+		}
+		if err == nil {
+			os.Args = append(os.Args[:1], args...)
+		}
+		selectProgressOutput(os.Args[1:])
+		runWithDiagnostics(defaultMode, func() { origMain(err == nil) })
+	}
 
 	if !isOptionSpecified {
 		fmt.Print(color.CyanString("\n- 何かキーを押すと終了します...\n- Press any key to exit..."))
@@ -0,0 +1,259 @@
+// discordは、Discordのスラッシュコマンドから譜面IDを受け取り、
+// 生成したファイルをzipで返信するボットを提供する。
+//
+// Gatewayには接続せず、Interactions Endpoint URLに指定したHTTPサーバーで動作する。
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+)
+
+const apiBase = "https://discord.com/api/v10"
+
+// スラッシュコマンドの名前
+const CommandName = "overlay"
+
+const (
+	interactionTypePing               = 1
+	interactionTypeApplicationCommand = 2
+
+	responseTypePong                   = 1
+	responseTypeChannelMessage         = 4
+	responseTypeDeferredChannelMessage = 5
+
+	optionTypeString  = 3
+	optionTypeInteger = 4
+	optionTypeBoolean = 5
+)
+
+// 同時に生成する数の上限
+const maxJobs = 2
+
+// 添付できるファイルの大きさの上限（ブーストしていないサーバーの上限）
+const maxAttachmentSize = 10 * 1024 * 1024
+
+type Bot struct {
+	publicKey     ed25519.PublicKey
+	applicationId string
+	botToken      string
+	// 生成中の数（maxJobsまで）
+	jobs chan struct{}
+
+	Client     *pjsekaioverlay.Client
+	HTTPClient *http.Client
+	// AviUtlオブジェクトが参照するassetsディレクトリ
+	Assets string
+	// 生成したファイルの一時的な保存先
+	WorkDir string
+}
+
+func NewBot(publicKey string, applicationId string, botToken string) (*Bot, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("公開鍵が不正です。(Invalid public key.)")
+	}
+	return &Bot{
+		publicKey:     ed25519.PublicKey(key),
+		applicationId: applicationId,
+		botToken:      botToken,
+		jobs:          make(chan struct{}, maxJobs),
+		Client:        pjsekaioverlay.New(),
+		HTTPClient:    http.DefaultClient,
+		WorkDir:       os.TempDir(),
+	}, nil
+}
+
+type interaction struct {
+	Type  int    `json:"type"`
+	Id    string `json:"id"`
+	Token string `json:"token"`
+	Data  struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+type commandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []commandOption `json:"options"`
+}
+
+// スラッシュコマンドを登録する（起動時に一度呼べばよい）
+func (b *Bot) RegisterCommand() error {
+	body, err := json.Marshal([]command{{
+		Name:        CommandName,
+		Description: "Generate pjsekai-overlay files",
+		Options: []commandOption{
+			{Type: optionTypeString, Name: "chart_id", Description: "Chart ID including the prefix", Required: true},
+			{Type: optionTypeInteger, Name: "team_power", Description: "Team power"},
+			{Type: optionTypeBoolean, Name: "ap_combo", Description: "Enable AP indicator for combo"},
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/applications/%s/commands", apiBase, b.applicationId), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bot "+b.botToken)
+	return b.do(request)
+}
+
+func (b *Bot) do(request *http.Request) error {
+	request.Header.Set("User-Agent", "DiscordBot (https://github.com/TootieJin/pjsekai-overlay-APPEND, "+pjsekaioverlay.Version+")")
+	resp, err := b.HTTPClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("Discordに接続できませんでした。(Could not connect to Discord.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Discordへのリクエストに失敗しました。(Request to Discord failed.) [%d: %s]", resp.StatusCode, message)
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Interactions Endpoint URLへのリクエストを処理する
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || !ed25519.Verify(b.publicKey, append([]byte(r.Header.Get("X-Signature-Timestamp")), body...), signature) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var received interaction
+	if err := json.Unmarshal(body, &received); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch received.Type {
+	case interactionTypePing:
+		writeResponse(w, map[string]any{"type": responseTypePong})
+	case interactionTypeApplicationCommand:
+		if received.Data.Name != CommandName {
+			http.Error(w, "unknown command", http.StatusBadRequest)
+			return
+		}
+		options := pjsekaioverlay.DefaultGenerateOptions
+		for _, option := range received.Data.Options {
+			switch option.Name {
+			case "chart_id":
+				json.Unmarshal(option.Value, &options.ChartId)
+			case "team_power":
+				json.Unmarshal(option.Value, &options.TeamPower)
+			case "ap_combo":
+				json.Unmarshal(option.Value, &options.ApCombo)
+			}
+		}
+		// 上限に達している場合は待たせずに断る
+		select {
+		case b.jobs <- struct{}{}:
+		default:
+			writeResponse(w, map[string]any{
+				"type": responseTypeChannelMessage,
+				"data": map[string]any{"content": "混み合っています。しばらくしてからもう一度お試しください。(Busy. Please try again later.)"},
+			})
+			return
+		}
+		// 生成には時間がかかるので、先に応答してから結果を送る
+		writeResponse(w, map[string]any{"type": responseTypeDeferredChannelMessage})
+		go b.generate(received.Token, options)
+	default:
+		http.Error(w, "unknown interaction type", http.StatusBadRequest)
+	}
+}
+
+func (b *Bot) generate(token string, options pjsekaioverlay.GenerateOptions) {
+	defer func() { <-b.jobs }()
+	content, archive := b.generateArchive(options)
+	if err := b.reply(token, content, archive); err != nil && archive != nil {
+		// zipファイルを送れなかった場合は、理由だけを送る
+		b.reply(token, fmt.Sprintf("FAIL: %s", err), nil)
+	}
+}
+
+// 生成したファイルのzipと、返信するメッセージを返す（失敗した場合はzipなし）
+func (b *Bot) generateArchive(options pjsekaioverlay.GenerateOptions) (string, *bytes.Buffer) {
+	outDir, err := os.MkdirTemp(b.WorkDir, "pjsekai-overlay-")
+	if err != nil {
+		return fmt.Sprintf("FAIL: %s", err), nil
+	}
+	defer os.RemoveAll(outDir)
+
+	options.OutDir = outDir
+	options.Assets = b.Assets
+	if err := b.Client.Generate(options, nil); err != nil {
+		return fmt.Sprintf("FAIL: %s", err), nil
+	}
+
+	var archive bytes.Buffer
+	if err := pjsekaioverlay.ArchiveDir(&archive, outDir); err != nil {
+		return fmt.Sprintf("FAIL: %s", err), nil
+	}
+	if archive.Len() > maxAttachmentSize {
+		return fmt.Sprintf("FAIL: zipファイルが大きすぎて添付できません。(The zip file is too large to attach.) [%d/%d]", archive.Len(), maxAttachmentSize), nil
+	}
+	return fmt.Sprintf("`%s`", options.ChartId), &archive
+}
+
+// 遅延した応答を、メッセージとzipファイルで置き換える
+func (b *Bot) reply(token string, content string, archive *bytes.Buffer) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	payload := map[string]any{"content": content}
+	if archive != nil {
+		payload["attachments"] = []map[string]any{{"id": 0, "filename": "pjsekai-overlay.zip"}}
+	}
+	payloadJson, _ := json.Marshal(payload)
+	writer.WriteField("payload_json", string(payloadJson))
+	if archive != nil {
+		part, err := writer.CreateFormFile("files[0]", "pjsekai-overlay.zip")
+		if err != nil {
+			return err
+		}
+		part.Write(archive.Bytes())
+	}
+	writer.Close()
+
+	request, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", apiBase, b.applicationId, token), &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return b.do(request)
+}
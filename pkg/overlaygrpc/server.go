@@ -0,0 +1,111 @@
+// overlaygrpcは、生成処理をgRPCのサービス（proto/overlay.proto）として提供する。
+package overlaygrpc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/overlaypb"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// zipファイルを分けて送る大きさ（gRPCの既定の上限4MBより小さくする）
+const archiveChunkSize = 1024 * 1024
+
+type Server struct {
+	overlaypb.UnimplementedOverlayServer
+
+	Client *pjsekaioverlay.Client
+	// AviUtlオブジェクトが参照するassetsディレクトリ
+	Assets string
+	// 生成中のファイルを置くディレクトリ（送信後に削除する）
+	WorkDir string
+}
+
+func (s *Server) GetChartInfo(ctx context.Context, request *overlaypb.GetChartInfoRequest) (*overlaypb.ChartInfo, error) {
+	if request.GetChartId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "譜面IDを指定して下さい。(Please specify the chart ID.)")
+	}
+	matches, err := s.Client.FindChart(request.GetChartId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	level := matches[0].Level
+	return &overlaypb.ChartInfo{
+		ChartId:       level.Name,
+		Source:        matches[0].Source.Id,
+		Title:         level.Title,
+		Artists:       level.Artists,
+		Author:        level.Author,
+		Rating:        int32(level.Rating),
+		EngineVersion: int32(level.Engine.Version),
+	}, nil
+}
+
+func (s *Server) GenerateOverlay(request *overlaypb.GenerateOverlayRequest, stream overlaypb.Overlay_GenerateOverlayServer) error {
+	if request.GetChartId() == "" {
+		return status.Error(codes.InvalidArgument, "譜面IDを指定して下さい。(Please specify the chart ID.)")
+	}
+	options := pjsekaioverlay.DefaultGenerateOptions
+	options.ChartId = request.GetChartId()
+	options.Assets = s.Assets
+	if request.TeamPower != nil {
+		options.TeamPower = int(request.GetTeamPower())
+	}
+	if request.BackgroundStyle != nil {
+		options.BackgroundStyle = request.GetBackgroundStyle()
+	}
+	if request.ApCombo != nil {
+		options.ApCombo = request.GetApCombo()
+	}
+	if request.ComboMilestone != nil {
+		options.ComboMilestone = request.GetComboMilestone()
+	}
+	if request.RankEffect != nil {
+		options.RankEffect = request.GetRankEffect()
+	}
+	if _, err := pjsekaioverlay.GetBackgroundComposer(options.BackgroundStyle); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := os.MkdirAll(s.WorkDir, 0755); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	outDir, err := os.MkdirTemp(s.WorkDir, "grpc-")
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(outDir)
+	options.OutDir = filepath.Join(outDir, pjsekaioverlay.SanitizeFileName(options.ChartId))
+
+	// 送信に失敗した場合（接続が切れた場合など）は、生成が終わった後にエラーを返す
+	var sendErr error
+	err = s.Client.Generate(options, func(step string) {
+		if sendErr == nil {
+			sendErr = stream.Send(&overlaypb.GenerateOverlayResponse{Event: &overlaypb.GenerateOverlayResponse_Step{Step: step}})
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	var archive bytes.Buffer
+	if err := pjsekaioverlay.ArchiveDir(&archive, options.OutDir); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	for data := archive.Bytes(); len(data) > 0; {
+		chunk := data[:min(len(data), archiveChunkSize)]
+		data = data[len(chunk):]
+		if err := stream.Send(&overlaypb.GenerateOverlayResponse{Event: &overlaypb.GenerateOverlayResponse_ArchiveChunk{ArchiveChunk: chunk}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
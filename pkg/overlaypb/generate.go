@@ -0,0 +1,4 @@
+// overlaypbは、proto/overlay.protoから生成したgRPCのコードを提供する。
+package overlaypb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative overlay.proto
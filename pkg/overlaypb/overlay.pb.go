@@ -0,0 +1,517 @@
+// pjsekai-overlayの生成処理をgRPCで呼び出すためのサービス
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: overlay.proto
+
+package overlaypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetChartInfoRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// プレフィックス込みの譜面ID
+	ChartId string `protobuf:"bytes,1,opt,name=chart_id,json=chartId,proto3" json:"chart_id,omitempty"`
+}
+
+func (x *GetChartInfoRequest) Reset() {
+	*x = GetChartInfoRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_overlay_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetChartInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChartInfoRequest) ProtoMessage() {}
+
+func (x *GetChartInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_overlay_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChartInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetChartInfoRequest) Descriptor() ([]byte, []int) {
+	return file_overlay_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetChartInfoRequest) GetChartId() string {
+	if x != nil {
+		return x.ChartId
+	}
+	return ""
+}
+
+type ChartInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChartId string `protobuf:"bytes,1,opt,name=chart_id,json=chartId,proto3" json:"chart_id,omitempty"`
+	// 譜面サーバーのID（chart_cyanvas、potato_leavesなど）
+	Source        string `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Title         string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Artists       string `protobuf:"bytes,4,opt,name=artists,proto3" json:"artists,omitempty"`
+	Author        string `protobuf:"bytes,5,opt,name=author,proto3" json:"author,omitempty"`
+	Rating        int32  `protobuf:"varint,6,opt,name=rating,proto3" json:"rating,omitempty"`
+	EngineVersion int32  `protobuf:"varint,7,opt,name=engine_version,json=engineVersion,proto3" json:"engine_version,omitempty"`
+}
+
+func (x *ChartInfo) Reset() {
+	*x = ChartInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_overlay_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChartInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChartInfo) ProtoMessage() {}
+
+func (x *ChartInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_overlay_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChartInfo.ProtoReflect.Descriptor instead.
+func (*ChartInfo) Descriptor() ([]byte, []int) {
+	return file_overlay_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ChartInfo) GetChartId() string {
+	if x != nil {
+		return x.ChartId
+	}
+	return ""
+}
+
+func (x *ChartInfo) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ChartInfo) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ChartInfo) GetArtists() string {
+	if x != nil {
+		return x.Artists
+	}
+	return ""
+}
+
+func (x *ChartInfo) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *ChartInfo) GetRating() int32 {
+	if x != nil {
+		return x.Rating
+	}
+	return 0
+}
+
+func (x *ChartInfo) GetEngineVersion() int32 {
+	if x != nil {
+		return x.EngineVersion
+	}
+	return 0
+}
+
+// 指定しなかった項目はCLIの初期値を使う
+type GenerateOverlayRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChartId   string `protobuf:"bytes,1,opt,name=chart_id,json=chartId,proto3" json:"chart_id,omitempty"`
+	TeamPower *int32 `protobuf:"varint,2,opt,name=team_power,json=teamPower,proto3,oneof" json:"team_power,omitempty"`
+	// v1、v3、blur
+	BackgroundStyle *string `protobuf:"bytes,3,opt,name=background_style,json=backgroundStyle,proto3,oneof" json:"background_style,omitempty"`
+	ApCombo         *bool   `protobuf:"varint,4,opt,name=ap_combo,json=apCombo,proto3,oneof" json:"ap_combo,omitempty"`
+	ComboMilestone  *bool   `protobuf:"varint,5,opt,name=combo_milestone,json=comboMilestone,proto3,oneof" json:"combo_milestone,omitempty"`
+	RankEffect      *bool   `protobuf:"varint,6,opt,name=rank_effect,json=rankEffect,proto3,oneof" json:"rank_effect,omitempty"`
+}
+
+func (x *GenerateOverlayRequest) Reset() {
+	*x = GenerateOverlayRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_overlay_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GenerateOverlayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateOverlayRequest) ProtoMessage() {}
+
+func (x *GenerateOverlayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_overlay_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateOverlayRequest.ProtoReflect.Descriptor instead.
+func (*GenerateOverlayRequest) Descriptor() ([]byte, []int) {
+	return file_overlay_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GenerateOverlayRequest) GetChartId() string {
+	if x != nil {
+		return x.ChartId
+	}
+	return ""
+}
+
+func (x *GenerateOverlayRequest) GetTeamPower() int32 {
+	if x != nil && x.TeamPower != nil {
+		return *x.TeamPower
+	}
+	return 0
+}
+
+func (x *GenerateOverlayRequest) GetBackgroundStyle() string {
+	if x != nil && x.BackgroundStyle != nil {
+		return *x.BackgroundStyle
+	}
+	return ""
+}
+
+func (x *GenerateOverlayRequest) GetApCombo() bool {
+	if x != nil && x.ApCombo != nil {
+		return *x.ApCombo
+	}
+	return false
+}
+
+func (x *GenerateOverlayRequest) GetComboMilestone() bool {
+	if x != nil && x.ComboMilestone != nil {
+		return *x.ComboMilestone
+	}
+	return false
+}
+
+func (x *GenerateOverlayRequest) GetRankEffect() bool {
+	if x != nil && x.RankEffect != nil {
+		return *x.RankEffect
+	}
+	return false
+}
+
+type GenerateOverlayResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*GenerateOverlayResponse_Step
+	//	*GenerateOverlayResponse_ArchiveChunk
+	Event isGenerateOverlayResponse_Event `protobuf_oneof:"event"`
+}
+
+func (x *GenerateOverlayResponse) Reset() {
+	*x = GenerateOverlayResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_overlay_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GenerateOverlayResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateOverlayResponse) ProtoMessage() {}
+
+func (x *GenerateOverlayResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_overlay_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateOverlayResponse.ProtoReflect.Descriptor instead.
+func (*GenerateOverlayResponse) Descriptor() ([]byte, []int) {
+	return file_overlay_proto_rawDescGZIP(), []int{3}
+}
+
+func (m *GenerateOverlayResponse) GetEvent() isGenerateOverlayResponse_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *GenerateOverlayResponse) GetStep() string {
+	if x, ok := x.GetEvent().(*GenerateOverlayResponse_Step); ok {
+		return x.Step
+	}
+	return ""
+}
+
+func (x *GenerateOverlayResponse) GetArchiveChunk() []byte {
+	if x, ok := x.GetEvent().(*GenerateOverlayResponse_ArchiveChunk); ok {
+		return x.ArchiveChunk
+	}
+	return nil
+}
+
+type isGenerateOverlayResponse_Event interface {
+	isGenerateOverlayResponse_Event()
+}
+
+type GenerateOverlayResponse_Step struct {
+	// 処理中の段階（chart、level、cover、background、ped、exo）
+	Step string `protobuf:"bytes,1,opt,name=step,proto3,oneof"`
+}
+
+type GenerateOverlayResponse_ArchiveChunk struct {
+	// 出力のzipファイルの一部（順に連結する）
+	ArchiveChunk []byte `protobuf:"bytes,2,opt,name=archive_chunk,json=archiveChunk,proto3,oneof"`
+}
+
+func (*GenerateOverlayResponse_Step) isGenerateOverlayResponse_Event() {}
+
+func (*GenerateOverlayResponse_ArchiveChunk) isGenerateOverlayResponse_Event() {}
+
+var File_overlay_proto protoreflect.FileDescriptor
+
+var file_overlay_proto_rawDesc = []byte{
+	0x0a, 0x0d, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x11, 0x70, 0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x2e,
+	0x76, 0x31, 0x22, 0x30, 0x0a, 0x13, 0x47, 0x65, 0x74, 0x43, 0x68, 0x61, 0x72, 0x74, 0x49, 0x6e,
+	0x66, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x68, 0x61,
+	0x72, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x68, 0x61,
+	0x72, 0x74, 0x49, 0x64, 0x22, 0xc5, 0x01, 0x0a, 0x09, 0x43, 0x68, 0x61, 0x72, 0x74, 0x49, 0x6e,
+	0x66, 0x6f, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x68, 0x61, 0x72, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x68, 0x61, 0x72, 0x74, 0x49, 0x64, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61,
+	0x72, 0x74, 0x69, 0x73, 0x74, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x72,
+	0x74, 0x69, 0x73, 0x74, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x16, 0x0a,
+	0x06, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72,
+	0x61, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x65,
+	0x6e, 0x67, 0x69, 0x6e, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xd0, 0x02, 0x0a,
+	0x16, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x68, 0x61, 0x72, 0x74,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x68, 0x61, 0x72, 0x74,
+	0x49, 0x64, 0x12, 0x22, 0x0a, 0x0a, 0x74, 0x65, 0x61, 0x6d, 0x5f, 0x70, 0x6f, 0x77, 0x65, 0x72,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x09, 0x74, 0x65, 0x61, 0x6d, 0x50, 0x6f,
+	0x77, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12, 0x2e, 0x0a, 0x10, 0x62, 0x61, 0x63, 0x6b, 0x67, 0x72,
+	0x6f, 0x75, 0x6e, 0x64, 0x5f, 0x73, 0x74, 0x79, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x01, 0x52, 0x0f, 0x62, 0x61, 0x63, 0x6b, 0x67, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x53, 0x74,
+	0x79, 0x6c, 0x65, 0x88, 0x01, 0x01, 0x12, 0x1e, 0x0a, 0x08, 0x61, 0x70, 0x5f, 0x63, 0x6f, 0x6d,
+	0x62, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x48, 0x02, 0x52, 0x07, 0x61, 0x70, 0x43, 0x6f,
+	0x6d, 0x62, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x2c, 0x0a, 0x0f, 0x63, 0x6f, 0x6d, 0x62, 0x6f, 0x5f,
+	0x6d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x48,
+	0x03, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x62, 0x6f, 0x4d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e,
+	0x65, 0x88, 0x01, 0x01, 0x12, 0x24, 0x0a, 0x0b, 0x72, 0x61, 0x6e, 0x6b, 0x5f, 0x65, 0x66, 0x66,
+	0x65, 0x63, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x48, 0x04, 0x52, 0x0a, 0x72, 0x61, 0x6e,
+	0x6b, 0x45, 0x66, 0x66, 0x65, 0x63, 0x74, 0x88, 0x01, 0x01, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x74,
+	0x65, 0x61, 0x6d, 0x5f, 0x70, 0x6f, 0x77, 0x65, 0x72, 0x42, 0x13, 0x0a, 0x11, 0x5f, 0x62, 0x61,
+	0x63, 0x6b, 0x67, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x5f, 0x73, 0x74, 0x79, 0x6c, 0x65, 0x42, 0x0b,
+	0x0a, 0x09, 0x5f, 0x61, 0x70, 0x5f, 0x63, 0x6f, 0x6d, 0x62, 0x6f, 0x42, 0x12, 0x0a, 0x10, 0x5f,
+	0x63, 0x6f, 0x6d, 0x62, 0x6f, 0x5f, 0x6d, 0x69, 0x6c, 0x65, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x42,
+	0x0e, 0x0a, 0x0c, 0x5f, 0x72, 0x61, 0x6e, 0x6b, 0x5f, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74, 0x22,
+	0x5f, 0x0a, 0x17, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4f, 0x76, 0x65, 0x72, 0x6c,
+	0x61, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x04, 0x73, 0x74,
+	0x65, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x04, 0x73, 0x74, 0x65, 0x70,
+	0x12, 0x25, 0x0a, 0x0d, 0x61, 0x72, 0x63, 0x68, 0x69, 0x76, 0x65, 0x5f, 0x63, 0x68, 0x75, 0x6e,
+	0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x0c, 0x61, 0x72, 0x63, 0x68, 0x69,
+	0x76, 0x65, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x32, 0xcb, 0x01, 0x0a, 0x07, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x12, 0x54, 0x0a, 0x0c,
+	0x47, 0x65, 0x74, 0x43, 0x68, 0x61, 0x72, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x26, 0x2e, 0x70,
+	0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x2e, 0x76, 0x31,
+	0x2e, 0x47, 0x65, 0x74, 0x43, 0x68, 0x61, 0x72, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x70, 0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x6f, 0x76,
+	0x65, 0x72, 0x6c, 0x61, 0x79, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x68, 0x61, 0x72, 0x74, 0x49, 0x6e,
+	0x66, 0x6f, 0x12, 0x6a, 0x0a, 0x0f, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4f, 0x76,
+	0x65, 0x72, 0x6c, 0x61, 0x79, 0x12, 0x29, 0x2e, 0x70, 0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x6f,
+	0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61,
+	0x74, 0x65, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x2a, 0x2e, 0x70, 0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61,
+	0x79, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x4f, 0x76, 0x65,
+	0x72, 0x6c, 0x61, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x3b,
+	0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x54, 0x6f, 0x6f,
+	0x74, 0x69, 0x65, 0x4a, 0x69, 0x6e, 0x2f, 0x70, 0x6a, 0x73, 0x65, 0x6b, 0x61, 0x69, 0x2d, 0x6f,
+	0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x2d, 0x41, 0x50, 0x50, 0x45, 0x4e, 0x44, 0x2f, 0x70, 0x6b,
+	0x67, 0x2f, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_overlay_proto_rawDescOnce sync.Once
+	file_overlay_proto_rawDescData = file_overlay_proto_rawDesc
+)
+
+func file_overlay_proto_rawDescGZIP() []byte {
+	file_overlay_proto_rawDescOnce.Do(func() {
+		file_overlay_proto_rawDescData = protoimpl.X.CompressGZIP(file_overlay_proto_rawDescData)
+	})
+	return file_overlay_proto_rawDescData
+}
+
+var file_overlay_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_overlay_proto_goTypes = []any{
+	(*GetChartInfoRequest)(nil),     // 0: pjsekaioverlay.v1.GetChartInfoRequest
+	(*ChartInfo)(nil),               // 1: pjsekaioverlay.v1.ChartInfo
+	(*GenerateOverlayRequest)(nil),  // 2: pjsekaioverlay.v1.GenerateOverlayRequest
+	(*GenerateOverlayResponse)(nil), // 3: pjsekaioverlay.v1.GenerateOverlayResponse
+}
+var file_overlay_proto_depIdxs = []int32{
+	0, // 0: pjsekaioverlay.v1.Overlay.GetChartInfo:input_type -> pjsekaioverlay.v1.GetChartInfoRequest
+	2, // 1: pjsekaioverlay.v1.Overlay.GenerateOverlay:input_type -> pjsekaioverlay.v1.GenerateOverlayRequest
+	1, // 2: pjsekaioverlay.v1.Overlay.GetChartInfo:output_type -> pjsekaioverlay.v1.ChartInfo
+	3, // 3: pjsekaioverlay.v1.Overlay.GenerateOverlay:output_type -> pjsekaioverlay.v1.GenerateOverlayResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_overlay_proto_init() }
+func file_overlay_proto_init() {
+	if File_overlay_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_overlay_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*GetChartInfoRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_overlay_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ChartInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_overlay_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*GenerateOverlayRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_overlay_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*GenerateOverlayResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_overlay_proto_msgTypes[2].OneofWrappers = []any{}
+	file_overlay_proto_msgTypes[3].OneofWrappers = []any{
+		(*GenerateOverlayResponse_Step)(nil),
+		(*GenerateOverlayResponse_ArchiveChunk)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_overlay_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_overlay_proto_goTypes,
+		DependencyIndexes: file_overlay_proto_depIdxs,
+		MessageInfos:      file_overlay_proto_msgTypes,
+	}.Build()
+	File_overlay_proto = out.File
+	file_overlay_proto_rawDesc = nil
+	file_overlay_proto_goTypes = nil
+	file_overlay_proto_depIdxs = nil
+}
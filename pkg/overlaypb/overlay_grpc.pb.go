@@ -0,0 +1,169 @@
+// pjsekai-overlayの生成処理をgRPCで呼び出すためのサービス
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: overlay.proto
+
+package overlaypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Overlay_GetChartInfo_FullMethodName    = "/pjsekaioverlay.v1.Overlay/GetChartInfo"
+	Overlay_GenerateOverlay_FullMethodName = "/pjsekaioverlay.v1.Overlay/GenerateOverlay"
+)
+
+// OverlayClient is the client API for Overlay service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OverlayClient interface {
+	// 譜面の情報を取得する
+	GetChartInfo(ctx context.Context, in *GetChartInfoRequest, opts ...grpc.CallOption) (*ChartInfo, error)
+	// 譜面からオーバーレイを生成し、進捗と出力のzipファイルを順に返す
+	GenerateOverlay(ctx context.Context, in *GenerateOverlayRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GenerateOverlayResponse], error)
+}
+
+type overlayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOverlayClient(cc grpc.ClientConnInterface) OverlayClient {
+	return &overlayClient{cc}
+}
+
+func (c *overlayClient) GetChartInfo(ctx context.Context, in *GetChartInfoRequest, opts ...grpc.CallOption) (*ChartInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChartInfo)
+	err := c.cc.Invoke(ctx, Overlay_GetChartInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *overlayClient) GenerateOverlay(ctx context.Context, in *GenerateOverlayRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GenerateOverlayResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Overlay_ServiceDesc.Streams[0], Overlay_GenerateOverlay_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GenerateOverlayRequest, GenerateOverlayResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Overlay_GenerateOverlayClient = grpc.ServerStreamingClient[GenerateOverlayResponse]
+
+// OverlayServer is the server API for Overlay service.
+// All implementations must embed UnimplementedOverlayServer
+// for forward compatibility.
+type OverlayServer interface {
+	// 譜面の情報を取得する
+	GetChartInfo(context.Context, *GetChartInfoRequest) (*ChartInfo, error)
+	// 譜面からオーバーレイを生成し、進捗と出力のzipファイルを順に返す
+	GenerateOverlay(*GenerateOverlayRequest, grpc.ServerStreamingServer[GenerateOverlayResponse]) error
+	mustEmbedUnimplementedOverlayServer()
+}
+
+// UnimplementedOverlayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOverlayServer struct{}
+
+func (UnimplementedOverlayServer) GetChartInfo(context.Context, *GetChartInfoRequest) (*ChartInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChartInfo not implemented")
+}
+func (UnimplementedOverlayServer) GenerateOverlay(*GenerateOverlayRequest, grpc.ServerStreamingServer[GenerateOverlayResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GenerateOverlay not implemented")
+}
+func (UnimplementedOverlayServer) mustEmbedUnimplementedOverlayServer() {}
+func (UnimplementedOverlayServer) testEmbeddedByValue()                 {}
+
+// UnsafeOverlayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OverlayServer will
+// result in compilation errors.
+type UnsafeOverlayServer interface {
+	mustEmbedUnimplementedOverlayServer()
+}
+
+func RegisterOverlayServer(s grpc.ServiceRegistrar, srv OverlayServer) {
+	// If the following call pancis, it indicates UnimplementedOverlayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Overlay_ServiceDesc, srv)
+}
+
+func _Overlay_GetChartInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChartInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OverlayServer).GetChartInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Overlay_GetChartInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OverlayServer).GetChartInfo(ctx, req.(*GetChartInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Overlay_GenerateOverlay_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GenerateOverlayRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OverlayServer).GenerateOverlay(m, &grpc.GenericServerStream[GenerateOverlayRequest, GenerateOverlayResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Overlay_GenerateOverlayServer = grpc.ServerStreamingServer[GenerateOverlayResponse]
+
+// Overlay_ServiceDesc is the grpc.ServiceDesc for Overlay service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Overlay_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pjsekaioverlay.v1.Overlay",
+	HandlerType: (*OverlayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetChartInfo",
+			Handler:    _Overlay_GetChartInfo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GenerateOverlay",
+			Handler:       _Overlay_GenerateOverlay_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "overlay.proto",
+}
@@ -0,0 +1,260 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"path/filepath"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"github.com/gen2brain/webp"
+)
+
+// アニメーションするジャケットの1フレーム
+type AnimatedCoverFrame struct {
+	// 出力先に保存したフレームの画像
+	Path string
+	// 表示する時間（秒）
+	Delay float64
+}
+
+// 表示する時間が指定されていないフレームは、ブラウザと同じく0.1秒にする
+const defaultAnimatedCoverDelay = 0.1
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// GIF・APNG・WebPのアニメーションを、合成済みのフレームに分ける（アニメーションでない場合は空）
+func decodeAnimatedCover(data []byte) ([]image.Image, []float64, error) {
+	var frames []image.Image
+	var delays []float64
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		frames, delays = composeGifFrames(decoded)
+	case bytes.HasPrefix(data, pngSignature):
+		var err error
+		frames, delays, err = decodeApngFrames(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		decoded, err := webp.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		frames = decoded.Image
+		for _, delay := range decoded.Delay {
+			delays = append(delays, float64(delay)/1000)
+		}
+	}
+	if len(frames) <= 1 {
+		return nil, nil, nil
+	}
+	for i := range delays {
+		if delays[i] <= 0.01 {
+			delays[i] = defaultAnimatedCoverDelay
+		}
+	}
+	return frames, delays, nil
+}
+
+func cloneRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+func composeGifFrames(decoded *gif.GIF) ([]image.Image, []float64) {
+	canvas := image.NewRGBA(image.Rect(0, 0, decoded.Config.Width, decoded.Config.Height))
+	frames := []image.Image{}
+	delays := []float64{}
+	for i, frame := range decoded.Image {
+		var previous *image.RGBA
+		if decoded.Disposal[i] == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneRGBA(canvas))
+		delays = append(delays, float64(decoded.Delay[i])/100)
+		switch decoded.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, delays
+}
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+func writePngChunk(w io.Writer, kind string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	w.Write([]byte(kind))
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// APNGのフレームの位置と合成方法（fcTLチャンク）
+type apngFrame struct {
+	rect    image.Rectangle
+	delay   float64
+	dispose byte
+	blend   byte
+	data    [][]byte
+}
+
+// APNGの各フレームを単独のPNGに組み立て直してから読み込み、合成する
+func decodeApngFrames(data []byte) ([]image.Image, []float64, error) {
+	reader := bytes.NewReader(data[len(pngSignature):])
+	var header []byte
+	// IHDRとIDATの間にある、各フレームにも必要なチャンク（PLTE、tRNSなど）
+	shared := []pngChunk{}
+	frames := []*apngFrame{}
+	animated := false
+	for {
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			break
+		}
+		chunk := make([]byte, length+8)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, nil, err
+		}
+		kind, body := string(chunk[:4]), chunk[4:length+4]
+		switch kind {
+		case "IHDR":
+			header = body
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(body) < 26 {
+				return nil, nil, errors.New("invalid fcTL chunk")
+			}
+			x, y := int(binary.BigEndian.Uint32(body[12:])), int(binary.BigEndian.Uint32(body[16:]))
+			width, height := int(binary.BigEndian.Uint32(body[4:])), int(binary.BigEndian.Uint32(body[8:]))
+			numerator, denominator := float64(binary.BigEndian.Uint16(body[20:])), float64(binary.BigEndian.Uint16(body[22:]))
+			if denominator == 0 {
+				denominator = 100
+			}
+			frames = append(frames, &apngFrame{
+				rect:    image.Rect(x, y, x+width, y+height),
+				delay:   numerator / denominator,
+				dispose: body[24],
+				blend:   body[25],
+			})
+		case "IDAT":
+			// fcTLより前のIDATはアニメーションに含まれない既定の画像
+			if len(frames) > 0 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, body)
+			}
+		case "fdAT":
+			if len(frames) > 0 && len(body) > 4 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, body[4:])
+			}
+		case "IEND":
+		default:
+			if len(frames) == 0 {
+				shared = append(shared, pngChunk{kind, body})
+			}
+		}
+	}
+	if !animated || header == nil || len(frames) <= 1 {
+		return nil, nil, nil
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(binary.BigEndian.Uint32(header[0:])), int(binary.BigEndian.Uint32(header[4:]))))
+	images := []image.Image{}
+	delays := []float64{}
+	for _, frame := range frames {
+		var buffer bytes.Buffer
+		buffer.Write(pngSignature)
+		frameHeader := bytes.Clone(header)
+		binary.BigEndian.PutUint32(frameHeader[0:], uint32(frame.rect.Dx()))
+		binary.BigEndian.PutUint32(frameHeader[4:], uint32(frame.rect.Dy()))
+		writePngChunk(&buffer, "IHDR", frameHeader)
+		for _, chunk := range shared {
+			writePngChunk(&buffer, chunk.kind, chunk.data)
+		}
+		for _, data := range frame.data {
+			writePngChunk(&buffer, "IDAT", data)
+		}
+		writePngChunk(&buffer, "IEND", nil)
+		decoded, err := png.Decode(&buffer)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var previous *image.RGBA
+		if frame.dispose == 2 {
+			previous = cloneRGBA(canvas)
+		}
+		op := draw.Over
+		if frame.blend == 0 {
+			op = draw.Src
+		}
+		draw.Draw(canvas, frame.rect, decoded, image.Point{}, op)
+		images = append(images, cloneRGBA(canvas))
+		delays = append(delays, frame.delay)
+		switch frame.dispose {
+		case 1:
+			draw.Draw(canvas, frame.rect, image.Transparent, image.Point{}, draw.Src)
+		case 2:
+			canvas = previous
+		}
+	}
+	return images, delays, nil
+}
+
+// ジャケットがアニメーションする場合、各フレームをcover_framesに保存する（アニメーションしない場合は空）
+func (c *Client) DownloadAnimatedCover(source Source, level sonolus.LevelInfo, destPath string, options CoverOptions) ([]AnimatedCoverFrame, error) {
+	if level.Cover.Url == "" && c.allowMissingAssets {
+		return []AnimatedCoverFrame{}, nil
+	}
+	url, err := sonolus.JoinUrl("https://"+source.Host, level.Cover.Url)
+	if err != nil {
+		return nil, fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%d]", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ジャケットの読み込みに失敗しました。(Loading jacket failed.) [%s]", err)
+	}
+
+	images, delays, err := decodeAnimatedCover(data)
+	if err != nil {
+		return nil, fmt.Errorf("アニメーションの読み込みに失敗しました。(Loading animation failed.) [%s]", err)
+	}
+	frames := make([]AnimatedCoverFrame, 0, len(images))
+	for i, img := range images {
+		name := options.Format.FileName(fmt.Sprintf("%03d", i))
+		if err := writeImage(DirOutput(filepath.Join(destPath, "cover_frames")), name, options.Filters.Apply(resizeImage(img, options.Size, options.Fit)), options.Format); err != nil {
+			return nil, err
+		}
+		frames = append(frames, AnimatedCoverFrame{Path: filepath.Join(destPath, "cover_frames", name), Delay: delays[i]})
+	}
+	return frames, nil
+}
@@ -0,0 +1,74 @@
+package pjsekaioverlay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// pjsekai以外のエンジンの譜面用に、アーキタイプをpjsekaiのアーキタイプに読み替える
+//
+// 例：{"TapNote": "NormalTapNote", "HoldTick": "NormalSlideTickNote"}
+type ArchetypeMapping map[string]string
+
+func LoadArchetypeMapping(path string) (ArchetypeMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("アーキタイプの対応表の読み込みに失敗しました。(Loading archetype mapping failed.) [%s]", err)
+	}
+	mapping := ArchetypeMapping{}
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("アーキタイプの対応表の読み込みに失敗しました。(Loading archetype mapping failed.) [%s]", err)
+	}
+	for from, to := range mapping {
+		if _, ok := WEIGHT_MAP[to]; ok {
+			continue
+		}
+		if _, ok := markerArchetypes[to]; ok {
+			continue
+		}
+		// 拍子の変化を別の名前で出力するエンジン用
+		if to == timeSignatureArchetype {
+			continue
+		}
+		return nil, fmt.Errorf("不明なアーキタイプです。(Unknown archetype.) [%s: %s]", from, to)
+	}
+	return mapping, nil
+}
+
+// キャッシュの区別に使うハッシュ（対応表が空の場合は空）
+func (mapping ArchetypeMapping) hash() string {
+	if len(mapping) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", key, mapping[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// 対応表で読み替えてから、スコアやマーカーの計算に使うエンティティだけを読み込む
+func decodeLevelData(r io.Reader, mapping ArchetypeMapping) (sonolus.LevelData, error) {
+	data, err := sonolus.DecodeLevelDataStream(r, func(entity *sonolus.LevelDataEntity) bool {
+		if archetype, ok := mapping[entity.Archetype]; ok {
+			entity.Archetype = archetype
+		}
+		return isTimelineEntity(*entity)
+	})
+	if err != nil {
+		return sonolus.LevelData{}, err
+	}
+	return data, nil
+}
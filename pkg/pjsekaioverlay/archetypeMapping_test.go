@@ -0,0 +1,21 @@
+package pjsekaioverlay
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeLevelDataMapsBeforeFiltering(t *testing.T) {
+	input := `{"bgmOffset":0,"entities":[
+		{"archetype":"CustomTapNote","data":[{"name":"#BEAT","value":1}]},
+		{"archetype":"CustomLine","data":[{"name":"#BEAT","value":2}]}
+	]}`
+	// 読み替えた後のアーキタイプで、読み込むかを決める
+	levelData, err := decodeLevelData(strings.NewReader(input), ArchetypeMapping{"CustomTapNote": "NormalTapNote", "CustomLine": "SimLine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levelData.Entities) != 1 || levelData.Entities[0].Archetype != "NormalTapNote" {
+		t.Errorf("entities = %+v, want [NormalTapNote]", levelData.Entities)
+	}
+}
@@ -0,0 +1,163 @@
+package pjsekaioverlay
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ディレクトリ内のファイルをzipにまとめる
+func ArchiveDir(w io.Writer, dir string) error {
+	zipWriter := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		writer, err := zipWriter.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		zipWriter.Close()
+		return err
+	}
+	return zipWriter.Close()
+}
+
+type ArchiveFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// zipに同梱するmanifest.json
+type ArchiveManifest struct {
+	Version string `json:"version"`
+	ChartId string `json:"chartId"`
+	Source  string `json:"source"`
+	Title   string `json:"title"`
+	Artists string `json:"artists"`
+	Author  string `json:"author"`
+	Rating  int    `json:"rating"`
+	// 生成に使った譜面データのハッシュ
+	DataHash string `json:"dataHash"`
+	// 生成時に指定したオプション
+	Options map[string]string `json:"options,omitempty"`
+	Files   []ArchiveFile     `json:"files"`
+}
+
+func listArchiveFiles(dir string) ([]ArchiveFile, error) {
+	files := []ArchiveFile{}
+	err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil || name == "manifest.json" {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		size, err := io.Copy(hash, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, ArchiveFile{Name: filepath.ToSlash(name), Size: size, Sha256: hex.EncodeToString(hash.Sum(nil))})
+		return nil
+	})
+	return files, err
+}
+
+// 前回の生成時に書き込んだmanifest.jsonを読み込む
+func ReadManifest(destDir string) (ArchiveManifest, error) {
+	data, err := os.ReadFile(filepath.Join(destDir, "manifest.json"))
+	if err != nil {
+		return ArchiveManifest{}, err
+	}
+	var manifest ArchiveManifest
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// 出力先ディレクトリのファイル一覧を付けてmanifest.jsonを書き込む
+func WriteManifest(destDir string, manifest ArchiveManifest) error {
+	files, err := listArchiveFiles(destDir)
+	if err != nil {
+		return fmt.Errorf("ファイルの読み込みに失敗しました。(Failed to read file.) [%s]", err)
+	}
+	manifest.Version = Version
+	manifest.Files = files
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("manifest.jsonの生成に失敗しました。(Failed to generate manifest.json.) [%s]", err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "manifest.json"), data, 0644); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
+
+// manifest.jsonを書き込んでから、出力先ディレクトリをarchivePathのzipにまとめる
+func WriteArchive(destDir string, archivePath string, manifest ArchiveManifest) error {
+	if err := WriteManifest(destDir, manifest); err != nil {
+		return err
+	}
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+	if err := ArchiveDir(file, destDir); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
+
+// manifest.jsonに記録したファイルと現在のファイルを比べ、違いの説明を返す（一致する場合は空）
+func VerifyManifest(destDir string) ([]string, error) {
+	manifest, err := ReadManifest(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("manifest.jsonの読み込みに失敗しました。(Failed to read manifest.json.) [%s]", err)
+	}
+	files, err := listArchiveFiles(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("ファイルの読み込みに失敗しました。(Failed to read file.) [%s]", err)
+	}
+	current := map[string]ArchiveFile{}
+	for _, file := range files {
+		current[file.Name] = file
+	}
+	problems := []string{}
+	for _, expected := range manifest.Files {
+		actual, ok := current[expected.Name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: ファイルがありません (Missing)", expected.Name))
+		case actual.Sha256 != expected.Sha256:
+			problems = append(problems, fmt.Sprintf("%s: 内容が変更されています (Modified)", expected.Name))
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,179 @@
+package pjsekaioverlay
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// インストールしたアセットパックのバージョンを記録するファイル
+const assetPackVersionFileName = ".asset-pack-version"
+
+// リリースのアセットパックに署名する鍵の公開鍵（16進数）
+//
+//go:embed assetPack.pub
+var releasePublicKeyHex string
+
+// リリースのアセットパックの署名を確認する公開鍵
+func ReleasePublicKey() ed25519.PublicKey {
+	key, err := hex.DecodeString(strings.TrimSpace(releasePublicKeyHex))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		panic("assetPack.pub: 公開鍵が不正です。(Invalid public key.)")
+	}
+	return key
+}
+
+// リリースで配布されるアセットパック
+type AssetPack struct {
+	Version string
+	Url     string
+	// zipファイルのSHA-256（16進数）
+	Sha256 string
+	// zipファイルのSHA-256（バイト列）に対するEd25519の署名
+	Signature []byte
+}
+
+// インストール済みのアセットパックのバージョン（記録がない場合は空）
+func InstalledAssetPackVersion(assetsDir string) string {
+	data, err := os.ReadFile(filepath.Join(assetsDir, assetPackVersionFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// チェックサムファイル（sha256sumの形式）からハッシュを取り出す
+func ParseAssetPackChecksum(data string) (string, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("チェックサムが不正です。(Invalid checksum.) [%s]", strings.TrimSpace(data))
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("チェックサムが不正です。(Invalid checksum.) [%s]", fields[0])
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// アセットパックをダウンロードし、ハッシュと署名を確認してからassetsDirを置き換える
+//
+// publicKeyが空の場合はリリースの公開鍵で確認する。置き換えに失敗した場合は元のアセットに戻す
+func (c *Client) InstallAssetPack(pack AssetPack, publicKey ed25519.PublicKey, assetsDir string) error {
+	if publicKey == nil {
+		publicKey = ReleasePublicKey()
+	}
+	if len(pack.Signature) == 0 {
+		return fmt.Errorf("アセットパックに署名がありません。(The asset pack is not signed.) [%s]", pack.Version)
+	}
+
+	resp, err := c.httpClient.Get(pack.Url)
+	if err != nil {
+		return fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("アセットパックが見つかりませんでした。(Asset pack not found.) [%d]", resp.StatusCode)
+	}
+
+	// 展開する前に全体を確認するため、一度ファイルに保存する
+	parentDir := filepath.Dir(assetsDir)
+	archive, err := os.CreateTemp(parentDir, "asset-pack-*.zip")
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(archive, hash), resp.Body)
+	if err != nil {
+		return fmt.Errorf("アセットパックのダウンロードに失敗しました。(Failed to download asset pack.) [%s]", err)
+	}
+	digest := hash.Sum(nil)
+	if hex.EncodeToString(digest) != strings.ToLower(pack.Sha256) {
+		return fmt.Errorf("アセットパックのハッシュが一致しません。(Asset pack checksum mismatch.) [%s]", hex.EncodeToString(digest))
+	}
+	if !ed25519.Verify(publicKey, digest, pack.Signature) {
+		return fmt.Errorf("アセットパックの署名が不正です。(Invalid asset pack signature.) [%s]", pack.Version)
+	}
+
+	newDir := assetsDir + ".new"
+	os.RemoveAll(newDir)
+	if err := extractAssetPack(archive, size, newDir); err != nil {
+		os.RemoveAll(newDir)
+		return fmt.Errorf("アセットパックの展開に失敗しました。(Failed to extract asset pack.) [%s]", err)
+	}
+	if err := os.WriteFile(filepath.Join(newDir, assetPackVersionFileName), []byte(pack.Version+"\n"), 0644); err != nil {
+		os.RemoveAll(newDir)
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+
+	if err := replaceAssetsDir(newDir, assetsDir); err != nil {
+		os.RemoveAll(newDir)
+		return fmt.Errorf("アセットの置き換えに失敗しました。(Failed to replace assets.) [%s]", err)
+	}
+	return nil
+}
+
+// assetsDirをnewDirに置き換える。元のアセットはassets.oldに残し、失敗した場合は元に戻す
+func replaceAssetsDir(newDir string, assetsDir string) error {
+	oldDir := assetsDir + ".old"
+	os.RemoveAll(oldDir)
+	if err := os.Rename(assetsDir, oldDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(newDir, assetsDir); err != nil {
+		os.Rename(oldDir, assetsDir)
+		return err
+	}
+	return nil
+}
+
+func extractAssetPack(archive io.ReaderAt, size int64, destDir string) error {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		// zipの外に書き込むパスは受け付けない
+		name := filepath.FromSlash(file.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("不正なパスが含まれています。(Invalid path.) [%s]", file.Name)
+		}
+		destPath := filepath.Join(destDir, name)
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractAssetPackFile(file, destPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractAssetPackFile(file *zip.File, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,156 @@
+package pjsekaioverlay
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAssetPackChecksum(t *testing.T) {
+	hash := "0123456789ABCDEF0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"sha256sum", hash + "  assets.zip\n", "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false},
+		{"hash only", hash, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false},
+		{"empty", "\n", "", true},
+		{"short", "0123  assets.zip", "", true},
+		{"not hex", "z" + hash[1:] + "  assets.zip", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseAssetPackChecksum(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// 指定したファイルを含むzipファイル
+func buildAssetPack(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestExtractAssetPackRejectsOutsidePaths(t *testing.T) {
+	for _, name := range []string{"../evil.png", "combo/../../evil.png", "/evil.png"} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			destDir := filepath.Join(root, "assets")
+			data := buildAssetPack(t, map[string]string{name: "evil"})
+			if err := extractAssetPack(bytes.NewReader(data), int64(len(data)), destDir); err == nil {
+				t.Fatal("expected an error")
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil.png")); !os.IsNotExist(err) {
+				t.Errorf("evil.png was written outside the assets directory")
+			}
+		})
+	}
+}
+
+func TestReplaceAssetsDirRollback(t *testing.T) {
+	assetsDir := filepath.Join(t.TempDir(), "assets")
+	if err := os.MkdirAll(assetsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(assetsDir, "bg.png"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新しいアセットが無いので置き換えに失敗する
+	if err := replaceAssetsDir(assetsDir+".new", assetsDir); err == nil {
+		t.Fatal("expected an error")
+	}
+	data, err := os.ReadFile(filepath.Join(assetsDir, "bg.png"))
+	if err != nil || string(data) != "old" {
+		t.Errorf("assets were not restored: %q, %v", data, err)
+	}
+}
+
+func TestInstallAssetPack(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildAssetPack(t, map[string]string{"bg.png": "new"})
+	digest := sha256.Sum256(data)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+	pack := AssetPack{Version: "1.2.3", Url: server.URL, Sha256: hex.EncodeToString(digest[:]), Signature: ed25519.Sign(privateKey, digest[:])}
+
+	tests := []struct {
+		name    string
+		pack    func(AssetPack) AssetPack
+		wantErr bool
+	}{
+		{"signed", func(pack AssetPack) AssetPack { return pack }, false},
+		{"unsigned", func(pack AssetPack) AssetPack { pack.Signature = nil; return pack }, true},
+		{"bad signature", func(pack AssetPack) AssetPack {
+			pack.Signature = ed25519.Sign(privateKey, []byte("other"))
+			return pack
+		}, true},
+		{"bad checksum", func(pack AssetPack) AssetPack {
+			pack.Sha256 = hex.EncodeToString(make([]byte, sha256.Size))
+			return pack
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assetsDir := filepath.Join(t.TempDir(), "assets")
+			if err := os.MkdirAll(assetsDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(assetsDir, "bg.png"), []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := New().InstallAssetPack(test.pack(pack), publicKey, assetsDir)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, test.wantErr)
+			}
+			want, version := "new", "1.2.3"
+			if test.wantErr {
+				want, version = "old", ""
+			}
+			if data, _ := os.ReadFile(filepath.Join(assetsDir, "bg.png")); string(data) != want {
+				t.Errorf("got %q, want %q", data, want)
+			}
+			if got := InstalledAssetPackVersion(assetsDir); got != version {
+				t.Errorf("got version %q, want %q", got, version)
+			}
+		})
+	}
+}
+
+func TestReleasePublicKey(t *testing.T) {
+	if len(ReleasePublicKey()) != ed25519.PublicKeySize {
+		t.Error("invalid release public key")
+	}
+}
@@ -0,0 +1,121 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 生成中のファイルを書き込む一時ディレクトリの接尾辞（出力先と同じ場所に作り、完了したら移動する）
+const tempOutDirSuffix = ".partial"
+
+// 生成中のファイルを書き込む一時ディレクトリ
+type TempOutDir struct {
+	Path      string
+	outDir    string
+	committed bool
+}
+
+// outDirの一時ディレクトリを作る。前回中断して残ったものは消す
+func NewTempOutDir(outDir string) (*TempOutDir, error) {
+	tempDir := outDir + tempOutDirSuffix
+	if err := os.RemoveAll(tempDir); err != nil {
+		return nil, fmt.Errorf("一時ディレクトリの削除に失敗しました。(Failed to remove the temporary directory.) [%s]", err)
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("一時ディレクトリの作成に失敗しました。(Failed to create the temporary directory.) [%s]", err)
+	}
+	return &TempOutDir{Path: tempDir, outDir: outDir}, nil
+}
+
+// 一時ディレクトリのファイルを出力先に移動する
+func (dir *TempOutDir) Commit() error {
+	if err := CommitOutDir(dir.Path, dir.outDir); err != nil {
+		return err
+	}
+	dir.committed = true
+	return nil
+}
+
+// Commitする前に失敗した場合は、次の生成に混ざらないよう一時ディレクトリを消す（deferで呼ぶ）
+func (dir *TempOutDir) Discard() {
+	if !dir.committed {
+		os.RemoveAll(dir.Path)
+	}
+}
+
+// 一時ディレクトリに書き込んだファイルのパスを、移動後の出力先でのパスにする（一時ディレクトリの外のパスはそのまま）
+//
+// ファイルの中に書き込むパスは、全てこのパスか出力先から求める
+func FinalOutPath(tempDir string, outDir string, path string) string {
+	if path == "" {
+		return path
+	}
+	rel, err := filepath.Rel(tempDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(outDir, rel)
+}
+
+// 一時ディレクトリのファイルを出力先に移動する
+//
+// 出力先がない場合はディレクトリごと名前を変え、ある場合はファイルごとに置き換える（自分で置いたファイルは残る）
+func CommitOutDir(tempDir string, outDir string) error {
+	files := []string{}
+	err := filepath.WalkDir(tempDir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		files = append(files, filePath)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("出力の移動に失敗しました。(Failed to move the output.) [%s]", err)
+	}
+
+	if _, err := os.Stat(outDir); os.IsNotExist(err) {
+		if err := os.Rename(tempDir, outDir); err != nil {
+			return fmt.Errorf("出力の移動に失敗しました。(Failed to move the output.) [%s]", err)
+		}
+		return nil
+	}
+	for _, filePath := range files {
+		name, err := filepath.Rel(tempDir, filePath)
+		if err != nil {
+			return fmt.Errorf("出力の移動に失敗しました。(Failed to move the output.) [%s]", err)
+		}
+		target := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("出力の移動に失敗しました。(Failed to move the output.) [%s]", err)
+		}
+		if err := os.Rename(filePath, target); err != nil {
+			return fmt.Errorf("出力の移動に失敗しました。(Failed to move the output.) [%s]", err)
+		}
+	}
+	return os.RemoveAll(tempDir)
+}
+
+// pedファイルが参照するファイルのパスを、移動後の出力先でのパスにしたもの
+func (options PedOptions) InOutDir(tempDir string, outDir string) PedOptions {
+	rebase := func(path string) string { return FinalOutPath(tempDir, outDir, path) }
+	options.DensityGraph.Path = rebase(options.DensityGraph.Path)
+	options.Waveform.Path = rebase(options.Waveform.Path)
+	options.ComboImages = rebase(options.ComboImages)
+	options.Gauge = rebase(options.Gauge)
+	options.Labels = rebase(options.Labels)
+	coverFrames := make([]AnimatedCoverFrame, len(options.CoverFrames))
+	for i, frame := range options.CoverFrames {
+		frame.Path = rebase(frame.Path)
+		coverFrames[i] = frame
+	}
+	options.CoverFrames = coverFrames
+	tints := make([]PedTint, len(options.Tints))
+	for i, tint := range options.Tints {
+		tint.Path = rebase(tint.Path)
+		tints[i] = tint
+	}
+	options.Tints = tints
+	return options
+}
@@ -0,0 +1,58 @@
+package pjsekaioverlay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTempOutDirDiscardedOnFailure(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	err := func() error {
+		tempOutDir, err := NewTempOutDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tempOutDir.Discard()
+		if err := os.WriteFile(filepath.Join(tempOutDir.Path, "data.ped"), []byte("p|assets\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 曲名が長すぎてexoの書き込みに失敗する
+		if err := WriteExoFiles("assets", tempOutDir.Path, strings.Repeat("a", exoTextLimit), "", ExoOptions{CoverFormat: ImageFormatPng}); err != nil {
+			return err
+		}
+		return tempOutDir.Commit()
+	}()
+	if err == nil {
+		t.Fatal("WriteExoFiles accepted a title that is too long")
+	}
+	if _, err := os.Stat(outDir + tempOutDirSuffix); !os.IsNotExist(err) {
+		t.Errorf("%s remains after the failure (%v)", outDir+tempOutDirSuffix, err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Errorf("%s was created after the failure (%v)", outDir, err)
+	}
+}
+
+func TestTempOutDirCommit(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	tempOutDir, err := NewTempOutDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteExoFiles("assets", tempOutDir.Path, "title", "", ExoOptions{CoverFormat: ImageFormatPng, DistDir: outDir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tempOutDir.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// Commitした後は消さない
+	tempOutDir.Discard()
+	if _, err := os.Stat(filepath.Join(outDir, exoVariants[0].fileName)); err != nil {
+		t.Errorf("exo was not committed: %s", err)
+	}
+	if _, err := os.Stat(outDir + tempOutDirSuffix); !os.IsNotExist(err) {
+		t.Errorf("%s remains after the commit (%v)", outDir+tempOutDirSuffix, err)
+	}
+}
@@ -0,0 +1,105 @@
+package pjsekaioverlay
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// AviUtl2のオブジェクトファイルでの設定項目名
+var aviutl2KeyMapping = map[string]string{
+	"_name": "effect.name",
+	"file":  "ファイル",
+	"text":  "テキスト",
+}
+
+// encodeStringで16進数にしたテキストを元に戻す
+func decodeExoString(encoded string) string {
+	data, err := hex.DecodeString(encoded)
+	if err != nil {
+		return encoded
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		unit := uint16(data[i]) | uint16(data[i+1])<<8
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
+
+// exoをAviUtl2のオブジェクトファイル（.object）の形式に変換する
+func convertExoToAviUtl2(exo string) string {
+	var builder strings.Builder
+	inHeader := true
+	var start string
+	for _, line := range strings.Split(exo, "\n") {
+		if line == "" {
+			continue
+		}
+		if match := exoSectionPattern.FindStringSubmatch(line); match != nil {
+			inHeader = false
+			builder.WriteString(line + "\n")
+			continue
+		}
+		if inHeader {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "start":
+			start = value
+			continue
+		case "end":
+			// AviUtl2では0始まりのフレーム範囲で指定する
+			startFrame, _ := strconv.Atoi(start)
+			endFrame, _ := strconv.Atoi(value)
+			fmt.Fprintf(&builder, "frame=%d,%d\n", startFrame-1, endFrame-1)
+			continue
+		case "layer":
+			layer, _ := strconv.Atoi(value)
+			fmt.Fprintf(&builder, "layer=%d\n", layer-1)
+			continue
+		case "overlay", "camera", "audio":
+			continue
+		case "text":
+			value = strings.ReplaceAll(decodeExoString(value), "\r\n", "\\n")
+		case "file":
+			value = strings.ReplaceAll(value, "/", "\\")
+		}
+		if mapped, ok := aviutl2KeyMapping[key]; ok {
+			key = mapped
+		}
+		builder.WriteString(key + "=" + value + "\n")
+	}
+	return builder.String()
+}
+
+// AviUtl2用のオブジェクトファイルを出力する（日本語版のみ）
+func WriteAviUtl2Files(assets string, destDir string, title string, description string, options ExoOptions) error {
+	exos, err := buildExoFiles(assets, destDir, title, description, options)
+	if err != nil {
+		return err
+	}
+	for _, exo := range exos {
+		if exo.variant.english {
+			continue
+		}
+		fileName := strings.TrimSuffix(exo.variant.fileName, ".exo") + ".object"
+		if err := os.WriteFile(filepath.Join(destDir, fileName),
+			[]byte(convertExoToAviUtl2(exo.content)),
+			0644); err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 出力形式に渡す、計算済みのタイムライン
+type Timeline struct {
+	Source Source
+	Level  sonolus.LevelInfo
+	// exoの説明文に使う作詞・作曲などの表記
+	Artists string
+	// 時間は出力上の時間（速度やポーズを反映したもの）
+	Frames         []PedFrame
+	ComboCounter   ComboCounter
+	ScoreAnimation ScoreAnimation
+	// 事前に組み立てたコンボ数の画像のディレクトリ（空の場合はテキストで表示する）
+	ComboImages string
+	Lyrics      []LyricLine
+	CoverFormat ImageFormat
+	// exoを元にする出力形式用
+	Exo ExoOptions `json:"-"`
+}
+
+// 出力形式が受け付けるオプション
+type BackendOption struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// exo以外の出力形式。RegisterBackendで登録すると--backendで選べるようになる
+type OutputBackend interface {
+	Name() string
+	// 進捗の表示に使う説明
+	Description() string
+	Options() []BackendOption
+	// 出力するファイル名（--dry-run用）
+	FileNames() []string
+	Generate(timeline Timeline, assets string, destDir string, options map[string]string) error
+}
+
+var (
+	backendsMu sync.Mutex
+	backends   = map[string]OutputBackend{}
+)
+
+// 出力形式を登録する。同じ名前を二度登録するとパニックする
+func RegisterBackend(backend OutputBackend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[backend.Name()]; ok {
+		panic("pjsekaioverlay: RegisterBackend called twice for " + backend.Name())
+	}
+	backends[backend.Name()] = backend
+}
+
+func GetBackend(name string) (OutputBackend, error) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backend, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("不明な出力形式です。(Unknown output backend.) [%s]", name)
+	}
+	return backend, nil
+}
+
+// 登録済みの出力形式（名前順）
+func Backends() []OutputBackend {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	list := make([]OutputBackend, 0, len(backends))
+	for _, backend := range backends {
+		list = append(list, backend)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+// --backend-optの値（backend.key=value）を出力形式ごとに分ける
+func ParseBackendOption(value string, dest map[string]map[string]string) error {
+	name, option, ok := strings.Cut(value, ".")
+	key, optionValue, hasValue := strings.Cut(option, "=")
+	if !ok || !hasValue || name == "" || key == "" {
+		return fmt.Errorf("オプションはbackend.key=valueの形式で指定して下さい。(Use the form backend.key=value.) [%s]", value)
+	}
+	backend, err := GetBackend(name)
+	if err != nil {
+		return err
+	}
+	known := false
+	for _, backendOption := range backend.Options() {
+		known = known || backendOption.Name == key
+	}
+	if !known {
+		return fmt.Errorf("不明なオプションです。(Unknown option.) [%s]", value)
+	}
+	if dest[name] == nil {
+		dest[name] = map[string]string{}
+	}
+	dest[name][key] = optionValue
+	return nil
+}
+
+type aviutl2Backend struct{}
+
+func (aviutl2Backend) Name() string             { return "aviutl2" }
+func (aviutl2Backend) Description() string      { return "AviUtl2用のファイル (AviUtl2 files)" }
+func (aviutl2Backend) Options() []BackendOption { return nil }
+func (aviutl2Backend) FileNames() []string      { return AviUtl2FileNames() }
+func (aviutl2Backend) Generate(timeline Timeline, assets string, destDir string, options map[string]string) error {
+	return WriteAviUtl2Files(assets, destDir, timeline.Level.Title, timeline.Artists, timeline.Exo)
+}
+
+type ymm4Backend struct{}
+
+func (ymm4Backend) Name() string             { return "ymm4" }
+func (ymm4Backend) Description() string      { return "YMM4用のプロジェクト (YMM4 project)" }
+func (ymm4Backend) Options() []BackendOption { return nil }
+func (ymm4Backend) FileNames() []string      { return []string{"main.ymmp"} }
+func (ymm4Backend) Generate(timeline Timeline, assets string, destDir string, options map[string]string) error {
+	return WriteYmm4Project(timeline.Frames, destDir, timeline.CoverFormat, Ymm4Options{ComboCounter: timeline.ComboCounter, ScoreAnimation: timeline.ScoreAnimation, ComboImages: timeline.ComboImages, Lyrics: timeline.Lyrics, BackgroundVideo: timeline.Exo.BackgroundVideo, DistDir: timeline.Exo.DistDir})
+}
+
+func init() {
+	RegisterBackend(aviutl2Backend{})
+	RegisterBackend(ymm4Backend{})
+}
@@ -0,0 +1,149 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	backgroundWidth  = 2048
+	backgroundHeight = 1280
+)
+
+// 出力先に保存したジャケットを読み込む
+func LoadCover(destPath string, format ImageFormat) (image.Image, error) {
+	file, err := os.Open(path.Join(destPath, format.FileName("cover")))
+	if err != nil {
+		return nil, fmt.Errorf("ジャケットの読み込みに失敗しました。(Loading jacket failed.) [%s]", err)
+	}
+	defer file.Close()
+
+	cover, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("ジャケットの読み込みに失敗しました。(Loading jacket failed.) [%s]", err)
+	}
+	return cover, nil
+}
+
+// ジャケットを縮小してから拡大し直すことで、簡易的なぼかしをかける
+func blurImage(dst *image.RGBA, src image.Image, srcRect image.Rectangle, strength int) {
+	small := image.NewRGBA(image.Rect(0, 0, max(srcRect.Dx()/strength, 1), max(srcRect.Dy()/strength, 1)))
+	draw.BiLinear.Scale(small, small.Bounds(), src, srcRect, draw.Src, nil)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), small, small.Bounds(), draw.Src, nil)
+}
+
+// アスペクト比を保ったまま、dstを覆う範囲をsrcから切り出す
+func coverRect(src image.Rectangle, dst image.Rectangle) image.Rectangle {
+	srcRatio := float64(src.Dx()) / float64(src.Dy())
+	dstRatio := float64(dst.Dx()) / float64(dst.Dy())
+	if srcRatio > dstRatio {
+		width := int(float64(src.Dy()) * dstRatio)
+		offset := (src.Dx() - width) / 2
+		return image.Rect(src.Min.X+offset, src.Min.Y, src.Min.X+offset+width, src.Max.Y)
+	}
+	height := int(float64(src.Dx()) / dstRatio)
+	offset := (src.Dy() - height) / 2
+	return image.Rect(src.Min.X, src.Min.Y+offset, src.Max.X, src.Min.Y+offset+height)
+}
+
+// 背景の生成方法
+type BackgroundComposer interface {
+	Compose(cover image.Image) *image.RGBA
+}
+
+// 旧バージョンの背景：ジャケットを大きく置き、下に反射を付ける
+type backgroundV1 struct{}
+
+// 現行バージョンの背景：ジャケットを中央に枠付きで置く
+type backgroundV3 struct{}
+
+// ぼかしたジャケットのみの背景
+type backgroundBlur struct{}
+
+var BackgroundStyles = map[string]BackgroundComposer{
+	"v1":   backgroundV1{},
+	"v3":   backgroundV3{},
+	"blur": backgroundBlur{},
+}
+
+func GetBackgroundComposer(style string) (BackgroundComposer, error) {
+	composer, ok := BackgroundStyles[style]
+	if !ok {
+		return nil, fmt.Errorf("不明な背景スタイルです。(Unknown background style.) [%s]", style)
+	}
+	return composer, nil
+}
+
+func newBlurredCanvas(cover image.Image, darkness uint8) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, backgroundWidth, backgroundHeight))
+
+	// ぼかしたジャケットを全体に敷く
+	blurImage(canvas, cover, coverRect(cover.Bounds(), canvas.Bounds()), 32)
+
+	// 暗くする
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, darkness}), image.Point{}, draw.Over)
+
+	return canvas
+}
+
+func (backgroundV1) Compose(cover image.Image) *image.RGBA {
+	canvas := newBlurredCanvas(cover, 0x60)
+
+	jacketSize := backgroundHeight * 5 / 8
+	jacketRect := image.Rect(
+		(backgroundWidth-jacketSize)/2,
+		backgroundHeight/8,
+		(backgroundWidth+jacketSize)/2,
+		backgroundHeight/8+jacketSize,
+	)
+	draw.CatmullRom.Scale(canvas, jacketRect, cover, cover.Bounds(), draw.Src, nil)
+
+	// 上下反転したジャケットを半透明で描画して反射に見せる
+	reflection := image.NewRGBA(image.Rect(0, 0, jacketSize, jacketSize))
+	draw.CatmullRom.Scale(reflection, reflection.Bounds(), cover, cover.Bounds(), draw.Src, nil)
+	flipped := image.NewRGBA(reflection.Bounds())
+	for y := 0; y < jacketSize; y++ {
+		draw.Draw(flipped, image.Rect(0, y, jacketSize, y+1), reflection, image.Pt(0, jacketSize-1-y), draw.Src)
+	}
+	reflectionRect := image.Rect(jacketRect.Min.X, jacketRect.Max.Y, jacketRect.Max.X, backgroundHeight)
+	draw.DrawMask(canvas, reflectionRect, flipped, image.Point{}, image.NewUniform(color.Alpha{0x40}), image.Point{}, draw.Over)
+
+	return canvas
+}
+
+func (backgroundV3) Compose(cover image.Image) *image.RGBA {
+	canvas := newBlurredCanvas(cover, 0x80)
+
+	// 中央にジャケットを枠付きで配置する
+	jacketSize := backgroundHeight / 2
+	frameWidth := 8
+	jacketRect := image.Rect(
+		(backgroundWidth-jacketSize)/2,
+		(backgroundHeight-jacketSize)/2,
+		(backgroundWidth+jacketSize)/2,
+		(backgroundHeight+jacketSize)/2,
+	)
+	draw.Draw(canvas, jacketRect.Inset(-frameWidth), image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0xe0}), image.Point{}, draw.Over)
+	draw.CatmullRom.Scale(canvas, jacketRect, cover, cover.Bounds(), draw.Src, nil)
+
+	return canvas
+}
+
+func (backgroundBlur) Compose(cover image.Image) *image.RGBA {
+	return newBlurredCanvas(cover, 0x40)
+}
+
+// 背景が設定されていない譜面用に、ジャケットからゲーム風の背景を生成する
+func GenerateBackground(composer BackgroundComposer, destPath string, coverFormat ImageFormat, filters ImageFilters) error {
+	cover, err := LoadCover(destPath, coverFormat)
+	if err != nil {
+		return err
+	}
+
+	return writeImage(DirOutput(destPath), "background.png", filters.Apply(composer.Compose(cover)), ImageFormatPng)
+}
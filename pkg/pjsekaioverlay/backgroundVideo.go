@@ -0,0 +1,74 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 出力先に置く背景動画のファイル名（拡張子を除く）
+const backgroundVideoName = "background_video"
+
+func backgroundVideoFileName(ext string) string {
+	if ext == "" {
+		ext = ".mp4"
+	}
+	return backgroundVideoName + strings.ToLower(ext)
+}
+
+// 指定した動画を背景動画として出力先に置き、ファイル名を返す。動画は大きいので、できる場合はハードリンクにする
+func CopyBackgroundVideo(src string, destPath string) (string, error) {
+	name := backgroundVideoFileName(filepath.Ext(src))
+	dest := filepath.Join(destPath, name)
+	os.Remove(dest)
+	if err := os.Link(src, dest); err == nil {
+		return name, nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("背景動画の読み込みに失敗しました。(Loading background video failed.) [%s]", err)
+	}
+	defer in.Close()
+	err = writeOutputFile(DirOutput(destPath), name, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// サーバーが背景動画を提供している場合はダウンロードし、ファイル名を返す（提供していない場合は空）
+func (c *Client) DownloadBackgroundVideo(source Source, level sonolus.LevelInfo, destPath string) (string, error) {
+	if level.UseBackground.Item.Video.Url == "" {
+		return "", nil
+	}
+	videoUrl, err := sonolus.JoinUrl("https://"+source.Host, level.UseBackground.Item.Video.Url)
+	if err != nil {
+		return "", fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+	resp, err := c.httpClient.Get(videoUrl)
+	if err != nil {
+		return "", fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("背景動画が見つかりませんでした。(Background video not found.) [%d]", resp.StatusCode)
+	}
+
+	name := backgroundVideoFileName(path.Ext(level.UseBackground.Item.Video.Url))
+	err = writeOutputFile(DirOutput(destPath), name, func(w io.Writer) error {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,97 @@
+package pjsekaioverlay_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolustest"
+)
+
+// 長い譜面（マラソン譜面）のノーツ数
+const benchNotes = 15000
+
+var benchLevelInfo = sonolus.LevelInfo{Title: "bench", Rating: 33}
+
+func BenchmarkLoadLevelFile(b *testing.B) {
+	levelFile := filepath.Join(b.TempDir(), "level.json.gz")
+	file, err := os.Create(levelFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	writer := gzip.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(sonolustest.LargeLevelData(benchNotes)); err != nil {
+		b.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		b.Fatal(err)
+	}
+	file.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pjsekaioverlay.LoadLevelFile(levelFile, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCalculateScore(b *testing.B) {
+	levelData := sonolustest.LargeLevelData(benchNotes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pjsekaioverlay.CalculateScore(benchLevelInfo, levelData, 250000)
+	}
+}
+
+func BenchmarkWritePed(b *testing.B) {
+	levelData := sonolustest.LargeLevelData(benchNotes)
+	frames := pjsekaioverlay.CalculateScore(benchLevelInfo, levelData, 250000)
+	options := pjsekaioverlay.PedOptions{
+		Milestones:    pjsekaioverlay.CalculateMilestones(frames, 100, pjsekaioverlay.ComboCounter{}),
+		RankCrossings: pjsekaioverlay.CalculateRankCrossings(frames, benchLevelInfo.Rating),
+		Beats:         pjsekaioverlay.CalculateBeats(levelData, frames, 4),
+		OutputHash:    "bench",
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pjsekaioverlay.WritePed(io.Discard, frames, "assets", true, benchLevelInfo, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteExoFiles(b *testing.B) {
+	levelData := sonolustest.LargeLevelData(benchNotes)
+	frames := pjsekaioverlay.CalculateScore(benchLevelInfo, levelData, 250000)
+	options := pjsekaioverlay.ExoOptions{
+		CoverFormat: pjsekaioverlay.ImageFormatPng,
+		Objects:     []pjsekaioverlay.ExoObject{pjsekaioverlay.MilestoneExoObject, pjsekaioverlay.RankExoObject},
+		Finale:      pjsekaioverlay.ExoFinale{LastNoteTime: frames[len(frames)-1].Time, Video: pjsekaioverlay.FinaleVideo(true)},
+		CutIns:      pjsekaioverlay.CalculateCutIns(levelData, frames),
+	}
+	destDir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pjsekaioverlay.WriteExoFiles("assets", destDir, benchLevelInfo.Title, "", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteYmm4Project(b *testing.B) {
+	levelData := sonolustest.LargeLevelData(benchNotes)
+	frames := pjsekaioverlay.CalculateScore(benchLevelInfo, levelData, 250000)
+	destDir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pjsekaioverlay.WriteYmm4Project(frames, destDir, pjsekaioverlay.ImageFormatPng, pjsekaioverlay.Ymm4Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 条件付きリクエストで再検証するための情報
+type cacheEntry struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+}
+
+// 応答をキャッシュし、ETag/Last-Modifiedで再検証する。変更がなければ本文は転送されない
+type cacheTransport struct {
+	dir    string
+	strict bool
+	base   http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet {
+		return t.base.RoundTrip(request)
+	}
+	bodyPath := filepath.Join(t.dir, snapshotFileName(request.URL))
+	entryPath := bodyPath + ".json"
+
+	var entry cacheEntry
+	cached := false
+	if data, err := os.ReadFile(entryPath); err == nil && json.Unmarshal(data, &entry) == nil {
+		if _, err := os.Stat(bodyPath); err == nil {
+			cached = true
+		}
+	}
+
+	if cached {
+		request = request.Clone(request.Context())
+		if entry.ETag != "" {
+			request.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(request)
+	if err != nil {
+		return resp, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		data, err := os.ReadFile(bodyPath)
+		if err != nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		resp.ContentLength = int64(len(data))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		return resp, nil
+	}
+
+	entry = cacheEntry{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if resp.StatusCode != http.StatusOK || (entry.ETag == "" && entry.LastModified == "") {
+		return resp, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	// キャッシュの書き込みに失敗しても処理は続ける（strictの場合を除く）
+	if err := t.write(bodyPath, entryPath, data, entry); err != nil && t.strict {
+		return nil, fmt.Errorf("キャッシュの書き込みに失敗しました。(Failed to write the cache.) [%s]", err)
+	}
+	return resp, nil
+}
+
+func (t *cacheTransport) write(bodyPath string, entryPath string, data []byte, entry cacheEntry) error {
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	entryData, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(bodyPath, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(entryPath, entryData, 0644)
+}
+
+func (c *Client) wrapCacheTransport() {
+	if c.cacheDir == "" {
+		return
+	}
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *c.httpClient
+	wrapped.Transport = &cacheTransport{dir: filepath.Join(c.cacheDir, "http"), strict: c.strict, base: base}
+	c.httpClient = &wrapped
+}
@@ -1,20 +1,21 @@
 package pjsekaioverlay
 
 import (
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
 	_ "image/jpeg"
-	"image/png"
 	"io"
-	"net/http"
 	"os"
 	"path"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-
-	"golang.org/x/image/draw"
+	"sync"
 
 	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
 )
@@ -24,64 +25,135 @@ type Source struct {
 	Name  string
 	Color int
 	Host  string
+	// 譜面IDの接頭辞
+	Prefix string
+}
+
+var DefaultSources = []Source{
+	{
+		Id:     "potato_leaves",
+		Name:   "Potato Leaves",
+		Color:  0x88cb7f,
+		Host:   "ptlv.sevenc7c.com",
+		Prefix: "ptlv-",
+	},
+	{
+		Id:     "chart_cyanvas",
+		Name:   "Chart Cyanvas",
+		Color:  0x83ccd2,
+		Host:   "cc.sevenc7c.com",
+		Prefix: "chcy-",
+	},
 }
 
-func FetchChart(source Source, chartId string) (sonolus.LevelInfo, error) {
+func (c *Client) fetchLevelDetails(source Source, chartId string) (sonolus.InfoResponse[sonolus.LevelInfo], error) {
+	if err := ValidateChartId(source, chartId); err != nil {
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, err
+	}
 	var url = "https://" + source.Host + "/sonolus/levels/" + chartId
 
-	resp, err := http.Get(url)
+	resp, err := c.httpClient.Get(url)
 
 	if err != nil {
-		return sonolus.LevelInfo{}, errors.New("サーバーに接続できませんでした。(Could not connect to server.)")
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, errors.New("サーバーに接続できませんでした。(Could not connect to server.)")
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 404 {
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, ErrChartNotFound
+	}
 	if resp.StatusCode != 200 {
-		return sonolus.LevelInfo{}, errors.New("譜面が見つかりませんでした。(Unable to search chart.)")
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, errors.New("譜面が見つかりませんでした。(Unable to search chart.)")
 	}
 
 	var chart sonolus.InfoResponse[sonolus.LevelInfo]
-	json.NewDecoder(resp.Body).Decode(&chart)
+	if err := json.NewDecoder(resp.Body).Decode(&chart); err != nil {
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, fmt.Errorf("譜面情報の読み込みに失敗しました。(Loading chart info failed.) [%s]", err)
+	}
+	normalizeLevelInfo(source, &chart.Item)
+	if err := validateLevelInfo(source, chart.Item, c.allowMissingAssets); err != nil {
+		return sonolus.InfoResponse[sonolus.LevelInfo]{}, err
+	}
+	for i := range chart.Sections {
+		for j := range chart.Sections[i].Items {
+			normalizeLevelInfo(source, &chart.Sections[i].Items[j])
+		}
+	}
 
+	return chart, nil
+}
+
+func (c *Client) FetchChart(source Source, chartId string) (sonolus.LevelInfo, error) {
+	chart, err := c.fetchLevelDetails(source, chartId)
+	if err != nil {
+		return sonolus.LevelInfo{}, err
+	}
 	return chart.Item, nil
 }
 
-func DetectChartSource(chartId string) (Source, error) {
-	var source Source
-	if strings.HasPrefix(chartId, "ptlv-") {
-		source = Source{
-			Id:    "potato_leaves",
-			Name:  "Potato Leaves",
-			Color: 0x88cb7f,
-			Host:  "ptlv.sevenc7c.com",
+// 同じ曲の別の難易度の譜面を、レベルの低い順に返す（指定した譜面も含む）
+//
+// 譜面の詳細ページに表示される関連譜面のうち、曲名が同じものを別の難易度とみなす
+func (c *Client) FetchRelatedLevels(source Source, chartId string) ([]sonolus.LevelInfo, error) {
+	chart, err := c.fetchLevelDetails(source, chartId)
+	if err != nil {
+		return nil, err
+	}
+	levels := []sonolus.LevelInfo{chart.Item}
+	for _, section := range chart.Sections {
+		for _, level := range section.Items {
+			// 関連譜面は必要な項目がなければ候補にしない
+			if validateLevelInfo(source, level, c.allowMissingAssets) != nil || level.Title != chart.Item.Title || slices.ContainsFunc(levels, func(l sonolus.LevelInfo) bool { return l.Name == level.Name }) {
+				continue
+			}
+			levels = append(levels, level)
 		}
-	} else if strings.HasPrefix(chartId, "chcy-") {
-		source = Source{
-			Id:    "chart_cyanvas",
-			Name:  "Chart Cyanvas",
-			Color: 0x83ccd2,
-			Host:  "cc.sevenc7c.com",
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].Rating < levels[j].Rating
+	})
+	return levels, nil
+}
+
+// --difficultyの値（譜面IDまたはレベル）に一致する譜面を選ぶ
+func SelectDifficulty(levels []sonolus.LevelInfo, difficulty string) (sonolus.LevelInfo, error) {
+	for _, level := range levels {
+		if level.Name == difficulty || strconv.Itoa(level.Rating) == difficulty {
+			return level, nil
 		}
 	}
-	if source.Id == "" {
-		return Source{
-			Id:    chartId,
-			Name:  "",
-			Color: 0,
-			Host:  "",
-		}, errors.New("unknown chart source")
+	return sonolus.LevelInfo{}, fmt.Errorf("指定した難易度の譜面が見つかりませんでした。(No chart found for the specified difficulty.) [%s]", difficulty)
+}
+
+func (c *Client) DetectChartSource(chartId string) (Source, error) {
+	for _, source := range c.sources {
+		if strings.HasPrefix(chartId, source.Prefix) {
+			return source, nil
+		}
 	}
-	return source, nil
+	return Source{
+		Id:    chartId,
+		Name:  "",
+		Color: 0,
+		Host:  "",
+	}, errors.New("unknown chart source")
 }
 
-func FetchLevelData(source Source, level sonolus.LevelInfo) (sonolus.LevelData, error) {
+func (c *Client) FetchLevelData(source Source, level sonolus.LevelInfo) (sonolus.LevelData, error) {
+	cachePath := c.levelDataCachePath(source, level)
+	if cachePath != "" {
+		if data, err := readLevelDataCache(cachePath); err == nil {
+			return data, nil
+		}
+	}
+
 	url, err := sonolus.JoinUrl("https://"+source.Host, level.Data.Url)
 
 	if err != nil {
 		return sonolus.LevelData{}, fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
 	}
 
-	resp, err := http.Get(url)
+	resp, err := c.httpClient.Get(url)
 
 	if err != nil {
 		return sonolus.LevelData{}, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
@@ -98,69 +170,175 @@ func FetchLevelData(source Source, level sonolus.LevelInfo) (sonolus.LevelData,
 		return sonolus.LevelData{}, fmt.Errorf("譜面データの読み込みに失敗しました。(Loading chart data failed.) [%s]", err)
 	}
 
-	err = json.NewDecoder(gzipReader).Decode(&data)
+	data, err = decodeLevelData(gzipReader, c.archetypeMapping)
 
 	if err != nil {
 		return sonolus.LevelData{}, fmt.Errorf("譜面データの読み込みに失敗しました。(Loading chart data failed.) [%s]", err)
 	}
 
+	// キャッシュの書き込みに失敗しても処理は続ける（strictの場合を除く）
+	if cachePath != "" {
+		if err := writeLevelDataCache(cachePath, data); err != nil && c.strict {
+			return sonolus.LevelData{}, fmt.Errorf("キャッシュの書き込みに失敗しました。(Failed to write the cache.) [%s]", err)
+		}
+	}
+
 	return data, nil
 }
 
-func DownloadCover(source Source, level sonolus.LevelInfo, destPath string) error {
-	url, err := sonolus.JoinUrl("https://"+source.Host, level.Cover.Url)
+// キャッシュした譜面データの形式（読み込むエンティティを増やした場合は上げる）
+const levelDataCacheVersion = 4
+
+// キャッシュのパス（キャッシュが無効の場合は空）
+//
+// ハッシュがない譜面は差し替えられても気付けないので、キャッシュしない
+func (c *Client) levelDataCachePath(source Source, level sonolus.LevelInfo) string {
+	if c.cacheDir == "" || level.Data.Hash == "" {
+		return ""
+	}
+	key := level.Data.Hash
+	// 読み込むエンティティを増やした場合は、古いキャッシュを使わないようにする
+	key += fmt.Sprintf("-v%d", levelDataCacheVersion)
+	// 読み替えたデータは別に保存する
+	if mappingHash := c.archetypeMapping.hash(); mappingHash != "" {
+		key += "-" + mappingHash
+	}
+	return path.Join(c.cacheDir, source.Id, key+".json")
+}
 
+func readLevelDataCache(cachePath string) (sonolus.LevelData, error) {
+	file, err := os.Open(cachePath)
 	if err != nil {
-		return fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+		return sonolus.LevelData{}, err
 	}
+	defer file.Close()
 
-	resp, err := http.Get(url)
+	var data sonolus.LevelData
+	err = json.NewDecoder(file).Decode(&data)
+	return data, err
+}
 
+func writeLevelDataCache(cachePath string, data sonolus.LevelData) error {
+	if err := os.MkdirAll(path.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(cachePath)
 	if err != nil {
-		return fmt.Errorf("サーバーに接続できませんでした。（%s）", err)
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(data); err != nil {
+		file.Close()
+		return err
 	}
+	return file.Close()
+}
 
-	defer resp.Body.Close()
+func (c *Client) DownloadCover(source Source, level sonolus.LevelInfo, destPath string, options CoverOptions) error {
+	_, err := c.WriteCover(source, level, DirOutput(destPath), options)
+	return err
+}
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%d]", resp.StatusCode)
+// ジャケットをダウンロードしてoutputに書き込み、リサイズ前の画像を返す
+func (c *Client) WriteCover(source Source, level sonolus.LevelInfo, output Output, options CoverOptions) (image.Image, error) {
+	if options.Size <= 0 {
+		return nil, fmt.Errorf("ジャケットのサイズが不正です。(Invalid jacket size.) [%d]", options.Size)
 	}
 
-	os.MkdirAll(destPath, 0755)
-	imageData, _, err := image.Decode(resp.Body)
-
+	imageData, err := c.fetchCover(source, level)
+	if errors.Is(err, errMissingAsset) && c.allowMissingAssets {
+		imageData, err = renderPlaceholderCover(source, level, nil), nil
+	}
 	if err != nil {
-		return fmt.Errorf("ジャケットの読み込みに失敗しました。(Loading jacket failed.) [%s]", err)
+		return nil, err
+	}
+
+	if options.Upscaler != nil && options.Upscaler.needsUpscale(imageData) {
+		imageData, err = options.Upscaler.Upscale(imageData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Original {
+		err = writeImage(output, options.Format.FileName("cover_original"), imageData, options.Format)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// 画像のリサイズ
 
-	newImage := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	newImage := options.Filters.Apply(resizeImage(imageData, options.Size, options.Fit))
+
+	return imageData, writeImage(output, options.Format.FileName("cover"), newImage, options.Format)
+}
+
+func (c *Client) fetchCover(source Source, level sonolus.LevelInfo) (image.Image, error) {
+	if level.Cover.Url == "" {
+		return nil, fmt.Errorf("ジャケットが設定されていません。(The chart has no jacket.) [%w]", errMissingAsset)
+	}
+
+	url, err := sonolus.JoinUrl("https://"+source.Host, level.Cover.Url)
 
-	draw.ApproxBiLinear.Scale(newImage, newImage.Bounds(), imageData, imageData.Bounds(), draw.Over, nil)
+	if err != nil {
+		return nil, fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
 
-	file, err := os.Create(path.Join(destPath, "cover.png"))
+	resp, err := c.httpClient.Get(url)
 
 	if err != nil {
-		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+		return nil, fmt.Errorf("サーバーに接続できませんでした。（%s）", err)
 	}
 
-	defer file.Close()
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, fmt.Errorf("ジャケットが見つかりませんでした。(Jacket not found.) [%w]", errMissingAsset)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%d]", resp.StatusCode)
+	}
 
-	err = png.Encode(file, newImage)
+	imageData, _, err := image.Decode(resp.Body)
 
 	if err != nil {
-		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		return nil, fmt.Errorf("ジャケットの読み込みに失敗しました。(Loading jacket failed.) [%s]", err)
 	}
+	return imageData, nil
+}
 
-	return nil
+func (c *Client) DownloadBackground(source Source, level sonolus.LevelInfo, destPath string, composer BackgroundComposer, coverFormat ImageFormat, filters ImageFilters) error {
+	// 背景が設定されていない場合はジャケットから生成する
+	if level.UseBackground.Item.Image.Url == "" {
+		return GenerateBackground(composer, destPath, coverFormat, filters)
+	}
+	err := c.writeRemoteBackground(source, level, DirOutput(destPath), filters)
+	if errors.Is(err, errMissingAsset) && c.allowMissingAssets {
+		return GenerateBackground(composer, destPath, coverFormat, filters)
+	}
+	return err
 }
-func DownloadBackground(source Source, level sonolus.LevelInfo, destPath string) error {
-	var backgroundUrl string
-	var err error
-	backgroundUrl, err = sonolus.JoinUrl("https://"+source.Host, level.UseBackground.Item.Image.Url)
 
-	resp, err := http.Get(backgroundUrl)
+// 背景をoutputに書き込む。背景が設定されていない場合はcoverから生成する
+func (c *Client) WriteBackground(source Source, level sonolus.LevelInfo, output Output, composer BackgroundComposer, cover image.Image, filters ImageFilters) error {
+	if level.UseBackground.Item.Image.Url == "" {
+		return writeImage(output, "background.png", filters.Apply(composer.Compose(cover)), ImageFormatPng)
+	}
+	err := c.writeRemoteBackground(source, level, output, filters)
+	if errors.Is(err, errMissingAsset) && c.allowMissingAssets {
+		return writeImage(output, "background.png", filters.Apply(composer.Compose(cover)), ImageFormatPng)
+	}
+	return err
+}
+
+func (c *Client) writeRemoteBackground(source Source, level sonolus.LevelInfo, output Output, filters ImageFilters) error {
+	backgroundUrl, err := sonolus.JoinUrl("https://"+source.Host, level.UseBackground.Item.Image.Url)
+
+	if err != nil {
+		return fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+
+	resp, err := c.httpClient.Get(backgroundUrl)
 
 	if err != nil {
 		return fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
@@ -168,21 +346,92 @@ func DownloadBackground(source Source, level sonolus.LevelInfo, destPath string)
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 404 {
+		return fmt.Errorf("背景が見つかりませんでした。(Background not found.) [%w]", errMissingAsset)
+	}
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("背景が見つかりませんでした。(Background not found.) [%d]", resp.StatusCode)
 	}
 
-	file, err := os.Create(path.Join(destPath, "background.png"))
+	data, err := io.ReadAll(resp.Body)
 
 	if err != nil {
-		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+		return fmt.Errorf("背景の読み込みに失敗しました。(Loading background failed.) [%s]", err)
 	}
 
-	defer file.Close()
+	// AviUtlで読み込めない形式（WebP、AVIFなど）と、加工する場合はPNGに変換する
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil && c.strict {
+		return fmt.Errorf("背景の読み込みに失敗しました。(Loading background failed.) [%s]", err)
+	}
+	if err == nil && (format != "png" && format != "jpeg" || !filters.IsZero()) {
+		imageData, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("背景の読み込みに失敗しました。(Loading background failed.) [%s]", err)
+		}
+		return writeImage(output, "background.png", filters.Apply(imageData), ImageFormatPng)
+	}
 
-	if _, err := io.Copy(file, resp.Body); err != nil {
-		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	return writeOutputFile(output, "background.png", func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// 譜面IDに一致した譜面
+type ChartMatch struct {
+	Source Source
+	Level  sonolus.LevelInfo
+}
+
+// 譜面IDの接頭辞に一致するサーバー（一致しない場合は全てのサーバー）に同時に問い合わせ、譜面が見つかったものを返す
+func (c *Client) FindChart(chartId string) ([]ChartMatch, error) {
+	candidates := []Source{}
+	for _, source := range c.sources {
+		if source.Prefix != "" && strings.HasPrefix(chartId, source.Prefix) {
+			candidates = append(candidates, source)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = c.sources
 	}
 
-	return nil
+	results := make([]*ChartMatch, len(candidates))
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for i, source := range candidates {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			level, err := c.FetchChart(source, chartId)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = &ChartMatch{Source: source, Level: level}
+		}(i, source)
+	}
+	wg.Wait()
+
+	// サーバーの順番を保つ
+	matches := []ChartMatch{}
+	for _, result := range results {
+		if result != nil {
+			matches = append(matches, *result)
+		}
+	}
+	if len(matches) == 0 {
+		if len(candidates) == 1 {
+			// 接頭辞で判別できたサーバーに無い場合は、打ち間違いの候補を出す
+			if errors.Is(errs[0], ErrChartNotFound) {
+				if suggestions := c.SuggestChartIds(candidates[0], chartId); len(suggestions) > 0 {
+					list := strings.Join(suggestions, ", ")
+					return nil, fmt.Errorf("譜面が見つかりませんでした。もしかして: %s (Unable to search chart. Did you mean %s?)", list, list)
+				}
+			}
+			return nil, errs[0]
+		}
+		return nil, errors.New("譜面が見つかりませんでした。(Unable to search chart.)")
+	}
+	return matches, nil
 }
@@ -0,0 +1,54 @@
+package pjsekaioverlay
+
+import (
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 判定はないが、譜面の終わりに含めるアーキタイプ（ガイドの終点など）
+var chartEndArchetypes = map[string]bool{
+	"HiddenSlideTickNote": true,
+}
+
+// 譜面の終わりの時間（秒）。最後のノーツだけでなく、ロングの終点やガイドの終点など、レーン上の最後のエンティティまでを含める
+//
+// maxTimeが0より大きい場合（曲の長さや区間の終わりが分かる場合）は、それを超えないようにする。
+// ただし、framesの最後のノーツより前にはしない
+func CalculateChartEnd(levelData sonolus.LevelData, frames []PedFrame, maxTime float64) float64 {
+	end := 0.0
+	if len(frames) > 0 {
+		end = frames[len(frames)-1].Time
+	}
+	lastNote := end
+	bpmChanges := GetBpmChanges(levelData)
+	for _, entity := range levelData.Entities {
+		if WEIGHT_MAP[entity.Archetype] <= 0 && !chartEndArchetypes[entity.Archetype] {
+			continue
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		end = max(end, getTimeFromBpmChanges(bpmChanges, beat)+levelData.BgmOffset)
+	}
+	if maxTime > 0 && end > maxTime {
+		end = max(maxTime, lastNote)
+	}
+	return end
+}
+
+// 曲の終わりまでの時間（秒）を、ShiftLevelDataでずらした譜面の時間で返す（区間の終わりがある場合はそれまで）
+//
+// 曲の長さが分からない場合は0を返す
+func ChartEndLimit(bgmLength float64, speed float64, timeRange TimeRange) float64 {
+	limit := 0.0
+	if bgmLength > 0 {
+		if speed <= 0 {
+			speed = 1
+		}
+		limit = bgmLength/speed - timeRange.From
+	}
+	if timeRange.To > 0 && (limit <= 0 || timeRange.To-timeRange.From < limit) {
+		limit = timeRange.To - timeRange.From
+	}
+	return limit
+}
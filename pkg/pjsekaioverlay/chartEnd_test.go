@@ -0,0 +1,76 @@
+package pjsekaioverlay
+
+import (
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+type testEntity struct {
+	archetype string
+	beat      float64
+}
+
+func TestCalculateChartEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		entities []testEntity
+		offset   float64
+		maxTime  float64
+		want     float64
+	}{
+		{
+			name:     "hold tail last",
+			entities: []testEntity{{"NormalTapNote", 1}, {"NormalSlideStartNote", 2}, {"HiddenSlideTickNote", 5}},
+			want:     5,
+		},
+		{
+			name:     "tap last",
+			entities: []testEntity{{"NormalSlideStartNote", 1}, {"NormalSlideEndNote", 2}, {"NormalTapNote", 4}},
+			want:     4,
+		},
+		{
+			name:     "trailing hold overlapping a tap",
+			entities: []testEntity{{"NormalSlideStartNote", 1}, {"NormalTapNote", 3}, {"HiddenSlideTickNote", 4}},
+			want:     4,
+		},
+		{
+			name:     "bgm offset",
+			entities: []testEntity{{"NormalTapNote", 1}, {"HiddenSlideTickNote", 3}},
+			offset:   0.5,
+			want:     3.5,
+		},
+		{
+			name:     "limited by max time",
+			entities: []testEntity{{"NormalSlideStartNote", 2}, {"HiddenSlideTickNote", 5}},
+			maxTime:  3,
+			want:     3,
+		},
+		{
+			// 最後のノーツより前にはしない
+			name:     "max time before last note",
+			entities: []testEntity{{"NormalSlideStartNote", 2}, {"HiddenSlideTickNote", 5}},
+			maxTime:  1,
+			want:     2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// BPM60なので1拍1秒
+			levelData := sonolus.LevelData{BgmOffset: test.offset, Entities: []sonolus.LevelDataEntity{{
+				Archetype: "#BPM_CHANGE",
+				Data:      []sonolus.LevelDataEntityValue{{Name: "#BEAT", Value: 0}, {Name: "#BPM", Value: 60}},
+			}}}
+			for _, entity := range test.entities {
+				levelData.Entities = append(levelData.Entities, sonolus.LevelDataEntity{
+					Archetype: entity.archetype,
+					Data:      []sonolus.LevelDataEntityValue{{Name: "#BEAT", Value: entity.beat}},
+				})
+			}
+			frames := CalculateScore(sonolus.LevelInfo{Rating: 30}, levelData, 250000)
+			if got := CalculateChartEnd(levelData, frames, test.maxTime); got != test.want {
+				t.Errorf("CalculateChartEnd = %f, want %f", got, test.want)
+			}
+		})
+	}
+}
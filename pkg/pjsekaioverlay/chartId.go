@@ -0,0 +1,116 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+const (
+	maxChartIdLength = 128
+	// 候補として表示する譜面IDの数
+	maxChartIdSuggestions = 3
+)
+
+// サーバーが404を返した（譜面IDが存在しない）
+var ErrChartNotFound = errors.New("譜面が見つかりませんでした。(Unable to search chart.)")
+
+// 通信する前に、譜面IDの形式（接頭辞の後ろが空でないか、長さ、使える文字）を確認する
+func ValidateChartId(source Source, chartId string) error {
+	rest := chartId
+	if source.Prefix != "" && strings.HasPrefix(chartId, source.Prefix) {
+		rest = strings.TrimPrefix(chartId, source.Prefix)
+	}
+	if rest == "" {
+		return fmt.Errorf("譜面IDが空です。(The chart ID is empty.) [%s]", chartId)
+	}
+	if len(chartId) > maxChartIdLength {
+		return fmt.Errorf("譜面IDが長すぎます。(The chart ID is too long.) [%d/%d]", len(chartId), maxChartIdLength)
+	}
+	for _, r := range chartId {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("譜面IDに使えない文字が含まれています。(The chart ID contains an invalid character.) [%q]", r)
+		}
+	}
+	return nil
+}
+
+// 編集距離（文字の挿入・削除・置換の回数）
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func (c *Client) fetchLevelList(source Source, keywords string) ([]sonolus.LevelInfo, error) {
+	listUrl := "https://" + source.Host + "/sonolus/levels/list"
+	if keywords != "" {
+		listUrl += "?keywords=" + url.QueryEscape(keywords)
+	}
+	resp, err := c.httpClient.Get(listUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var list sonolus.ItemList[sonolus.LevelInfo]
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// サーバーの譜面一覧（IDで検索した結果と最新の譜面）から、譜面IDが近いものを返す
+func (c *Client) SuggestChartIds(source Source, chartId string) []string {
+	levels := []sonolus.LevelInfo{}
+	for _, keywords := range []string{strings.TrimPrefix(chartId, source.Prefix), ""} {
+		// 候補が見つからないだけなので、一覧を取得できなくてもエラーにしない
+		if items, err := c.fetchLevelList(source, keywords); err == nil {
+			levels = append(levels, items...)
+		}
+	}
+
+	// 違いが長さの1/4（最低2文字）までのものを近いとみなす
+	threshold := max(len(chartId)/4, 2)
+	distances := map[string]int{}
+	for _, level := range levels {
+		if level.Name == "" || level.Name == chartId {
+			continue
+		}
+		if distance := editDistance(chartId, level.Name); distance <= threshold {
+			distances[level.Name] = distance
+		}
+	}
+	suggestions := make([]string, 0, len(distances))
+	for name := range distances {
+		suggestions = append(suggestions, name)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] == distances[suggestions[j]] {
+			return suggestions[i] < suggestions[j]
+		}
+		return distances[suggestions[i]] < distances[suggestions[j]]
+	})
+	return suggestions[:min(len(suggestions), maxChartIdSuggestions)]
+}
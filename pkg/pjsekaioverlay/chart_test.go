@@ -0,0 +1,109 @@
+package pjsekaioverlay_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolustest"
+)
+
+func newTestClient(t *testing.T) (*pjsekaioverlay.Client, pjsekaioverlay.Source) {
+	server := sonolustest.NewServer()
+	t.Cleanup(server.Close)
+	source := pjsekaioverlay.Source{Id: "test", Name: "Test", Host: server.Host, Prefix: "chcy-"}
+	client := pjsekaioverlay.New(pjsekaioverlay.WithHTTPClient(server.Client()), pjsekaioverlay.WithSources(source))
+	return client, source
+}
+
+func TestFetchChart(t *testing.T) {
+	client, source := newTestClient(t)
+
+	level, err := client.FetchChart(source, sonolustest.LevelId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := sonolustest.LevelInfo(); level.Title != expected.Title || level.Rating != expected.Rating {
+		t.Errorf("got %q (Lv. %d), want %q (Lv. %d)", level.Title, level.Rating, expected.Title, expected.Rating)
+	}
+
+	if _, err := client.FetchChart(source, "chcy-missing"); !errors.Is(err, pjsekaioverlay.ErrChartNotFound) {
+		t.Errorf("got %v, want ErrChartNotFound", err)
+	}
+}
+
+func TestFetchLevelData(t *testing.T) {
+	client, source := newTestClient(t)
+	level, err := client.FetchChart(source, sonolustest.LevelId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	levelData, err := client.FetchLevelData(source, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 読み込み時に使わないエンティティは取り除かれるので、ノーツ数で比べる
+	frames := pjsekaioverlay.CalculateScore(level, levelData, 250000)
+	expected := pjsekaioverlay.CalculateScore(level, sonolustest.LevelData(), 250000)
+	if len(frames) != len(expected) {
+		t.Errorf("got %d frames, want %d", len(frames), len(expected))
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		// エラーに含まれる文字列
+		want string
+	}{
+		{"not found", "/sonolus/repository/data/missing", "[404]"},
+		{"not gzip", sonolustest.BrokenLevelDataUrl, "Loading chart data failed."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			broken := level
+			broken.Data.Url = test.url
+			_, err := client.FetchLevelData(source, broken)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchLevelDataCache(t *testing.T) {
+	server := sonolustest.NewServer()
+	defer server.Close()
+	source := pjsekaioverlay.Source{Id: "test", Name: "Test", Host: server.Host, Prefix: "chcy-"}
+
+	tests := []struct {
+		name string
+		hash string
+		// キャッシュされるか
+		cached bool
+	}{
+		{"with hash", "0123abcd", true},
+		// ハッシュがない場合は差し替えに気付けない
+		{"without hash", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+			client := pjsekaioverlay.New(pjsekaioverlay.WithHTTPClient(server.Client()), pjsekaioverlay.WithSources(source), pjsekaioverlay.WithCacheDir(cacheDir))
+			level, err := client.FetchChart(source, sonolustest.LevelId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			level.Data.Hash = test.hash
+			if _, err := client.FetchLevelData(source, level); err != nil {
+				t.Fatal(err)
+			}
+			files, _ := filepath.Glob(filepath.Join(cacheDir, source.Id, "*.json"))
+			if cached := len(files) > 0; cached != test.cached {
+				t.Errorf("cached = %v, want %v (%v)", cached, test.cached, files)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 背景の代わりに単色（キー色）を敷いたexo。透過での読み込みが苦手な編集ソフトで、クロマキーで切り抜く用
+type ExoChromaKey struct {
+	Color int
+	// falseの場合はテキストのアンチエイリアスを切り、キー色と混ざった縁が残らないようにする
+	Antialias bool
+}
+
+const DefaultChromaKeyColor = 0x00ff00
+
+// colorは「00ff00」のような16進数（空の場合は緑）
+func ParseChromaKey(color string, antialias bool) (ExoChromaKey, error) {
+	key := ExoChromaKey{Color: DefaultChromaKeyColor, Antialias: antialias}
+	if color != "" {
+		value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 24)
+		if err != nil {
+			return ExoChromaKey{}, fmt.Errorf("キー色の形式が不正です。(Invalid key color.) [%s]", color)
+		}
+		key.Color = int(value)
+	}
+	return key, nil
+}
+
+var exoEndPattern = regexp.MustCompile(`(?m)^end=([0-9]+)$`)
+var exoSoftPattern = regexp.MustCompile(`(?m)^soft=1$`)
+
+// 画面全体を覆う図形（開始・終了演出の色付けや暗転）と4:3の余白の画像。キー色と混ざるので背景と一緒に取り除く
+func isExoBackdrop(object []string) bool {
+	for _, line := range object {
+		if line == "ライン幅=4000" || line == "Line width=4000" || strings.HasSuffix(line, "\\background_full.png") {
+			return true
+		}
+	}
+	return false
+}
+
+// 背景を取り除き、一番下のレイヤーにキー色の図形を最後まで敷く
+func applyExoChromaKey(exo string, variant exoVariant, key ExoChromaKey, coverFormat ImageFormat) string {
+	exo = filterExoObjects(exo, func(object []string) bool {
+		return !ExoElementBackground.matches(object, coverFormat) && !isExoBackdrop(object)
+	})
+	if !key.Antialias {
+		exo = exoSoftPattern.ReplaceAllLiteralString(exo, "soft=0")
+	}
+
+	figure, size, aspect, lineWidth, standardDrawing, zoom, clearness, rotation :=
+		"図形", "サイズ", "縦横比", "ライン幅", "標準描画", "拡大率", "透明度", "回転"
+	if variant.english {
+		figure, size, aspect, lineWidth, standardDrawing, zoom, clearness, rotation =
+			"Graphic", "Size", "rAspect", "Line width", "Standard drawing", "Zoom%", "Clearness", "Rotation"
+	}
+	index := maxExoNumber(exo, exoIndexPattern) + 1
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(exo, "\n"))
+	builder.WriteString("\n")
+	fmt.Fprintf(&builder, "[%d]\nstart=1\nend=%d\nlayer=1\noverlay=1\ncamera=0\n", index, maxExoNumber(exo, exoEndPattern))
+	fmt.Fprintf(&builder, "[%d.0]\n_name=%s\n%s=100\n%s=0.0\n%s=4000\ntype=0\ncolor=%06x\nname=\n", index, figure, size, aspect, lineWidth, key.Color)
+	fmt.Fprintf(&builder, "[%d.1]\n_name=%s\nX=0.0\nY=0.0\nZ=0.0\n%s=150.00\n%s=0.0\n%s=0.00\nblend=0\n", index, standardDrawing, zoom, clearness, rotation)
+	return builder.String()
+}
@@ -0,0 +1,103 @@
+package pjsekaioverlay
+
+import (
+	"net/http"
+	"time"
+)
+
+// 譜面の取得などを行うクライアント
+type Client struct {
+	httpClient *http.Client
+	cacheDir   string
+	locale     string
+	sources    []Source
+
+	snapshotDir     string
+	offline         bool
+	requestInterval time.Duration
+	rateLimit       int64
+	dialOptions     *DialOptions
+
+	archetypeMapping ArchetypeMapping
+	strict           bool
+	// ジャケットと背景がない場合に、代わりの画像で続ける
+	allowMissingAssets bool
+}
+
+type Option func(*Client)
+
+// 通信に使うHTTPクライアントを指定する
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// 譜面データやジャケットなどをキャッシュするディレクトリを指定する（空の場合はキャッシュしない）
+func WithCacheDir(cacheDir string) Option {
+	return func(c *Client) {
+		c.cacheDir = cacheDir
+	}
+}
+
+// 言語（ja、en）を指定する
+func WithLocale(locale string) Option {
+	return func(c *Client) {
+		c.locale = locale
+	}
+}
+
+// 譜面IDから判別する譜面サーバーを指定する
+func WithSources(sources ...Source) Option {
+	return func(c *Client) {
+		c.sources = sources
+	}
+}
+
+// 譜面データのアーキタイプの読み替えを指定する
+func WithArchetypeMapping(mapping ArchetypeMapping) Option {
+	return func(c *Client) {
+		c.archetypeMapping = mapping
+	}
+}
+
+// キャッシュの書き込みなど、失敗しても処理を続けていたエラーを返す（無人で一括生成する場合向け）
+func WithStrict(strict bool) Option {
+	return func(c *Client) {
+		c.strict = strict
+	}
+}
+
+// ジャケットがない譜面は曲名を書いた画像、背景が取得できない譜面はジャケットから生成した背景で続ける
+func WithAllowMissingAssets(allow bool) Option {
+	return func(c *Client) {
+		c.allowMissingAssets = allow
+	}
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+		locale:     "ja",
+		sources:    DefaultSources,
+
+		requestInterval: DefaultRequestInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.configureDialer()
+	c.wrapRateLimitTransport()
+	c.wrapPoliteTransport()
+	c.wrapCacheTransport()
+	c.wrapSnapshotTransport()
+	return c
+}
+
+func (c *Client) Locale() string {
+	return c.locale
+}
+
+func (c *Client) Sources() []Source {
+	return c.sources
+}
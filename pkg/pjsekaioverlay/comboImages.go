@@ -0,0 +1,85 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 画像上での数字の間隔（sekai.objでは0.70倍で72ずつ並べる）
+const comboDigitSpacing = 72 / 0.70
+
+// 種類ごとの数字の画像（combo/n0.pngなど）を読み込む。dirはcombo/があるディレクトリ
+func loadComboDigits(dir string, kind string) ([10]image.Image, error) {
+	digits := [10]image.Image{}
+	for i := range digits {
+		file, err := os.Open(filepath.Join(dir, "combo", kind+strconv.Itoa(i)+".png"))
+		if err != nil {
+			return digits, fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [%s]", err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			return digits, fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [%s%d.png: %s]", kind, i, err)
+		}
+		digits[i] = img
+	}
+	return digits, nil
+}
+
+// 数字の画像を、sekai.objと同じ間隔で中央揃えに並べる
+func renderComboImage(digits [10]image.Image, combo int) *image.NRGBA {
+	text := strconv.Itoa(combo)
+	width, height := 0, 0
+	for _, digit := range digits {
+		width = max(width, digit.Bounds().Dx())
+		height = max(height, digit.Bounds().Dy())
+	}
+	span := comboDigitSpacing * float64(len(text)-1)
+	dst := image.NewNRGBA(image.Rect(0, 0, int(math.Ceil(span))+width, height))
+	center := float64(dst.Bounds().Dx()) / 2
+	for i, char := range text {
+		digit := digits[char-'0']
+		bounds := digit.Bounds()
+		x := int(math.Round(center - span/2 + comboDigitSpacing*float64(i) - float64(bounds.Dx())/2))
+		y := (height - bounds.Dy()) / 2
+		draw.Draw(dst, image.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()), digit, bounds.Min, draw.Over)
+	}
+	return dst
+}
+
+// 1からmaxComboまでのコンボ数を数字の画像から組み立て、出力先のcombo_imagesに書き込んでそのディレクトリを返す
+//
+// フォントやAviUtlの描画によらず、どの環境でも同じ見た目になる。APの場合はAP中の画像（p、b）も書き込む
+func WriteComboImages(assets string, destPath string, maxCombo int, ap bool, tints []PedTint) (string, error) {
+	kinds := map[string]string{"n": assets}
+	if ap {
+		kinds["p"] = assets
+		kinds["b"] = assets
+	}
+	// 色を変えた場合は、色を変えた画像から組み立てる（AP中の色は変えない）
+	for _, tint := range tints {
+		if tint.Element == TintElementCombo {
+			kinds["n"] = tint.Path
+		}
+	}
+
+	imagesDir := filepath.Join(destPath, "combo_images")
+	for kind, dir := range kinds {
+		digits, err := loadComboDigits(dir, kind)
+		if err != nil {
+			return "", err
+		}
+		output := DirOutput(filepath.Join(imagesDir, kind))
+		for combo := 1; combo <= maxCombo; combo++ {
+			if err := writeImage(output, strconv.Itoa(combo)+".png", renderComboImage(digits, combo), ImageFormatPng); err != nil {
+				return "", err
+			}
+		}
+	}
+	return imagesDir, nil
+}
@@ -0,0 +1,69 @@
+package pjsekaioverlay
+
+import (
+	"strings"
+)
+
+// 同じノーツとみなす時間のずれ（秒）
+const comparisonTolerance = 0.001
+
+// 片方の譜面にしかないノーツの種類
+type DifferenceKind string
+
+const (
+	// 比較する譜面にはないノーツ
+	DifferenceAdded DifferenceKind = "added"
+	// 比較する譜面にしかないノーツ
+	DifferenceRemoved DifferenceKind = "removed"
+)
+
+type ChartDifference struct {
+	Time float64
+	Kind DifferenceKind
+}
+
+// 並べて表示する、比較する譜面（更新前の譜面など）のスコア
+type Comparison struct {
+	// 表示する名前
+	Title string
+	// 比較する譜面のフレーム（同じ総合力・再生速度・区間で計算したもの）
+	Frames      []PedFrame
+	Differences []ChartDifference
+}
+
+var ComparisonExoObject = ExoObject{NameJP: "比較", NameEN: "Comparison", X: 760.0, Y: 250.0, Zoom: 100}
+
+// 2つの譜面のノーツを時間順に突き合わせ、片方にしかないノーツを求める
+func CompareFrames(frames []PedFrame, otherFrames []PedFrame) []ChartDifference {
+	differences := []ChartDifference{}
+	// 先頭は開始時点のフレームなので含めない
+	i, j := 1, 1
+	for i < len(frames) || j < len(otherFrames) {
+		switch {
+		case j >= len(otherFrames) || (i < len(frames) && frames[i].Time < otherFrames[j].Time-comparisonTolerance):
+			differences = append(differences, ChartDifference{Time: frames[i].Time, Kind: DifferenceAdded})
+			i++
+		case i >= len(frames) || otherFrames[j].Time < frames[i].Time-comparisonTolerance:
+			differences = append(differences, ChartDifference{Time: otherFrames[j].Time, Kind: DifferenceRemoved})
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return differences
+}
+
+func NewComparison(title string, frames []PedFrame, otherFrames []PedFrame) Comparison {
+	// pedファイルの区切り文字は使えない
+	title = strings.TrimSpace(strings.NewReplacer(":", " ", "|", " ", "\n", " ").Replace(title))
+	// 空の行は読み込まれないので、名前は空にしない
+	if title == "" {
+		title = "COMPARE"
+	}
+	return Comparison{
+		Title:       title,
+		Frames:      otherFrames,
+		Differences: CompareFrames(frames, otherFrames),
+	}
+}
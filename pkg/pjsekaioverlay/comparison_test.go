@@ -0,0 +1,39 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+func TestNewComparisonTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Tell Your World", "Tell Your World"},
+		{"a:b|c", "a b c"},
+		{"", "COMPARE"},
+		{" : | ", "COMPARE"},
+	}
+	for _, test := range tests {
+		comparison := NewComparison(test.title, referenceTestFrames, referenceTestFrames)
+		if comparison.Title != test.want {
+			t.Errorf("NewComparison(%q).Title = %q, want %q", test.title, comparison.Title, test.want)
+		}
+	}
+}
+
+func TestWritePedComparisonTitleLine(t *testing.T) {
+	var buffer bytes.Buffer
+	options := PedOptions{Comparison: NewComparison("", referenceTestFrames, referenceTestFrames)}
+	if err := WritePed(&buffer, referenceTestFrames, "assets", false, sonolus.LevelInfo{Rating: 30}, options); err != nil {
+		t.Fatal(err)
+	}
+	// iの行に続くyの行は、名前のある行がないと読み込めない
+	if !strings.Contains(buffer.String(), "\ni|COMPARE\n") {
+		t.Errorf("ped does not contain a comparison title line:\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,96 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// MV風の編集でキャラクターのカットインを入れる位置
+type CutIn struct {
+	Time  float64
+	Label string
+}
+
+const (
+	// この秒数以上ノーツがなければ、次のノーツから新しいセクションとみなす
+	cutInBreakLength = 3.0
+	// これより近いカットインはまとめる（秒）
+	cutInMinInterval = 4.0
+	// カットインの目印の長さ（フレーム）
+	cutInDuration = 120
+)
+
+// フィーバー・BPM変化・ノーツのない区間の切れ目から、セクションの境目を求める
+func CalculateCutIns(levelData sonolus.LevelData, frames []PedFrame) []CutIn {
+	candidates := []CutIn{}
+	bpmChangeCount := 0
+	for _, marker := range CalculateMarkers(levelData, frames) {
+		switch marker.Type {
+		case MarkerTypeFeverStart:
+			candidates = append(candidates, CutIn{Time: marker.Time, Label: "Fever"})
+		case MarkerTypeFeverEnd:
+			candidates = append(candidates, CutIn{Time: marker.Time, Label: "Fever End"})
+		case MarkerTypeBpmChange:
+			// 最初のBPMは曲の始まりなので使わない
+			bpmChangeCount++
+			if bpmChangeCount > 1 {
+				candidates = append(candidates, CutIn{Time: marker.Time, Label: marker.Label})
+			}
+		}
+	}
+	times, _ := getNoteTimes(levelData)
+	for i := 1; i < len(times); i++ {
+		if times[i]-times[i-1] >= cutInBreakLength {
+			candidates = append(candidates, CutIn{Time: times[i], Label: "Section"})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Time < candidates[j].Time
+	})
+
+	cutIns := []CutIn{}
+	for _, candidate := range candidates {
+		if candidate.Time < 0 || (len(frames) > 0 && candidate.Time > frames[len(frames)-1].Time) {
+			continue
+		}
+		if len(cutIns) > 0 && candidate.Time-cutIns[len(cutIns)-1].Time < cutInMinInterval {
+			last := &cutIns[len(cutIns)-1]
+			if !strings.Contains(last.Label, candidate.Label) {
+				last.Label += " / " + candidate.Label
+			}
+			continue
+		}
+		cutIns = append(cutIns, candidate)
+	}
+	return cutIns
+}
+
+func MapCutIns(cutIns []CutIn, mapper TimeMapper) []CutIn {
+	if mapper == nil {
+		return cutIns
+	}
+	mapped := make([]CutIn, len(cutIns))
+	for i, cutIn := range cutIns {
+		mapped[i] = CutIn{Time: mapper.MapTime(cutIn.Time), Label: cutIn.Label}
+	}
+	return mapped
+}
+
+// カットインの位置に、差し替え用の目印のオブジェクトを追加する
+func appendExoCutIns(exo string, variant exoVariant, cutIns []CutIn) string {
+	index := maxExoNumber(exo, exoIndexPattern)
+	layer := maxExoNumber(exo, exoLayerPattern) + 1
+
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(exo, "\n"))
+	builder.WriteString("\n")
+	for _, cutIn := range cutIns {
+		index++
+		start := noteFrame(cutIn.Time) + 1
+		writeExoCustomObject(&builder, variant, CutInExoObject, index, layer, start, start+cutInDuration-1, fmt.Sprintf("label=%q", cutIn.Label))
+	}
+	return builder.String()
+}
@@ -0,0 +1,115 @@
+package pjsekaioverlay
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 不具合報告用のzipに含めるファイル
+const (
+	DebugBundleFileName    = "debug.json"
+	DebugLevelDataFileName = "level_data.json"
+)
+
+// 曲名や作者などを含まない、不具合の再現に必要な情報
+type DebugBundle struct {
+	Version string `json:"version"`
+	// サーバーの種類（chart_cyanvasなど）。譜面IDは含めない
+	Source string `json:"source"`
+	// 難易度やエンジンのバージョンだけを残した譜面情報
+	Level sonolus.LevelInfo `json:"level"`
+	// 指定したオプション（パスは伏せる）
+	Options map[string]string `json:"options"`
+	Summary DebugSummary      `json:"summary"`
+}
+
+// 報告者の環境で計算した結果（再現できたかの確認に使う）
+type DebugSummary struct {
+	Notes        int     `json:"notes"`
+	Combo        int     `json:"combo"`
+	Score        int     `json:"score"`
+	LastNoteTime float64 `json:"lastNoteTime"`
+}
+
+// 計算に使う値以外を空にする
+func AnonymizeLevelInfo(level sonolus.LevelInfo) sonolus.LevelInfo {
+	return sonolus.LevelInfo{
+		Name:    "debug",
+		Title:   "debug",
+		Version: level.Version,
+		Rating:  level.Rating,
+		Engine:  level.Engine,
+	}
+}
+
+// ファイルのパスにはユーザー名などが含まれうるので伏せる（「1.5」のような数値は残す）
+func anonymizeDebugOption(value string) string {
+	ext := strings.TrimPrefix(filepath.Ext(value), ".")
+	isFileName := ext != "" && strings.IndexFunc(ext, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+	if strings.ContainsAny(value, `/\`) || filepath.IsAbs(value) || isFileName {
+		return "<path>"
+	}
+	return value
+}
+
+func NewDebugBundle(source Source, level sonolus.LevelInfo, frames []PedFrame, options map[string]string) DebugBundle {
+	bundle := DebugBundle{
+		Version: Version,
+		Source:  source.Id,
+		Level:   AnonymizeLevelInfo(level),
+		Options: map[string]string{},
+	}
+	for name, value := range options {
+		bundle.Options[name] = anonymizeDebugOption(value)
+	}
+	for _, frame := range frames {
+		if frame.Judgment == JudgmentNone {
+			continue
+		}
+		bundle.Summary.Notes++
+		if frame.Judgment == JudgmentPerfect || frame.Judgment == JudgmentGreat {
+			bundle.Summary.Combo++
+		} else {
+			bundle.Summary.Combo = 0
+		}
+	}
+	if len(frames) > 0 {
+		bundle.Summary.Score = frames[len(frames)-1].Score
+		bundle.Summary.LastNoteTime = frames[len(frames)-1].Time
+	}
+	return bundle
+}
+
+// 情報と譜面データをzipに書き込む。譜面データは--level-fileでそのまま読み込める
+func WriteDebugBundle(path string, bundle DebugBundle, levelData sonolus.LevelData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for name, value := range map[string]any{DebugBundleFileName: bundle, DebugLevelDataFileName: levelData} {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
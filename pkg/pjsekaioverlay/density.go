@@ -0,0 +1,52 @@
+package pjsekaioverlay
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	densityGraphWidth  = 1200
+	densityGraphHeight = 160
+)
+
+// ノーツ密度グラフ（オーバーレイとして表示する場合のみ）
+type DensityGraph struct {
+	Path   string
+	Length float64 // グラフの横幅に対応する時間（秒）
+}
+
+// 1秒ごとのノーツ数を棒グラフにする
+func renderDensityGraph(stats NoteStats) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, densityGraphWidth, densityGraphHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.NRGBA{0, 0, 0, 0x80}), image.Point{}, draw.Src)
+	if len(stats.Nps) == 0 || stats.PeakNps == 0 {
+		return canvas
+	}
+
+	barWidth := float64(densityGraphWidth) / float64(len(stats.Nps))
+	for second, count := range stats.Nps {
+		height := count * (densityGraphHeight - 8) / stats.PeakNps
+		// 密度が高いほど赤くする
+		ratio := float64(count) / float64(stats.PeakNps)
+		barColor := color.NRGBA{uint8(0x60 + 0x9f*ratio), uint8(0xe0 - 0xa0*ratio), 0xff - uint8(0x9f*ratio), 0xff}
+		rect := image.Rect(int(float64(second)*barWidth), densityGraphHeight-height, int(float64(second+1)*barWidth), densityGraphHeight)
+		draw.Draw(canvas, rect, image.NewUniform(barColor), image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+// density.pngを出力する
+func WriteDensityGraph(stats NoteStats, destDir string) (DensityGraph, error) {
+	graphPath := filepath.Join(destDir, "density.png")
+	if err := writeImageFile(graphPath, renderDensityGraph(stats), ImageFormatPng); err != nil {
+		return DensityGraph{}, err
+	}
+	return DensityGraph{
+		Path:   graphPath,
+		Length: float64(len(stats.Nps)),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package pjsekaioverlay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 接続方法の設定
+type DialOptions struct {
+	// ホスト名からIPアドレスへの上書き
+	Resolve map[string]string
+	// IPのバージョン（tcp、tcp4、tcp6）
+	Network string
+	// IPv6で接続できない場合にIPv4を試すまでの時間（Happy Eyeballs、0で既定値）
+	FallbackDelay time.Duration
+	Timeout       time.Duration
+}
+
+// --resolveの値（host:ip）を読み込む
+func ParseResolve(value string) (string, string, error) {
+	host, ip, ok := strings.Cut(value, ":")
+	if !ok || host == "" || net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("--resolveの形式が不正です。(Invalid --resolve format.) [%s]", value)
+	}
+	return host, ip, nil
+}
+
+func ParseNetwork(ipVersion string) (string, error) {
+	switch ipVersion {
+	case "auto":
+		return "tcp", nil
+	case "4":
+		return "tcp4", nil
+	case "6":
+		return "tcp6", nil
+	}
+	return "", fmt.Errorf("不明なIPのバージョンです。(Unknown IP version.) [%s]", ipVersion)
+}
+
+func WithDialOptions(options DialOptions) Option {
+	return func(c *Client) {
+		c.dialOptions = &options
+	}
+}
+
+func (c *Client) configureDialer() {
+	if c.dialOptions == nil {
+		return
+	}
+	options := *c.dialOptions
+	var transport *http.Transport
+	switch base := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = base.Clone()
+	default:
+		// 独自のTransportには手を加えない
+		return
+	}
+
+	dialer := &net.Dialer{
+		Timeout:       options.Timeout,
+		FallbackDelay: options.FallbackDelay,
+		KeepAlive:     30 * time.Second,
+	}
+	if dialer.Timeout == 0 {
+		dialer.Timeout = 30 * time.Second
+	}
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if options.Network != "" && options.Network != "tcp" {
+			network = options.Network
+		}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := options.Resolve[host]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	wrapped := *c.httpClient
+	wrapped.Transport = transport
+	c.httpClient = &wrapped
+}
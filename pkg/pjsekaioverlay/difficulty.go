@@ -0,0 +1,133 @@
+package pjsekaioverlay
+
+import (
+	"math"
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+const (
+	// ノーツ密度を測る区間の長さ（秒）
+	difficultyWindow = 5.0
+	// 同じレーンの連打（縦連）とみなす間隔（秒）
+	jackInterval = 0.25
+	// 2つのレーンの交互押し（トリル）とみなす間隔（秒）と、最小のノーツ数
+	trillInterval = 0.2
+	trillLength   = 4
+)
+
+// 推定の難易度と、その元になった値
+//
+// 譜面のレベルが合っているかの目安で、公式の譜面定数ではない
+type DifficultyEstimate struct {
+	// 推定の難易度（譜面のレベルと同じ尺度）
+	Rating float64 `json:"rating"`
+	// 平均と5秒間の最大のノーツ密度（NPS）
+	AverageDensity float64 `json:"averageDensity"`
+	PeakDensity    float64 `json:"peakDensity"`
+	// 縦連とトリルになっているノーツの数
+	Jacks  int `json:"jacks"`
+	Trills int `json:"trills"`
+	// スライド1本あたりの中継点の数
+	SlideComplexity float64 `json:"slideComplexity"`
+}
+
+type difficultyNote struct {
+	time float64
+	lane float64
+}
+
+// 中継点を持つスライドの始点
+var slideStartArchetypes = map[string]bool{
+	"NormalSlideStartNote":   true,
+	"CriticalSlideStartNote": true,
+}
+
+// スライドの中継点（見えないものも含む）
+var slideTickArchetypes = map[string]bool{
+	"HiddenSlideTickNote":           true,
+	"NormalSlideTickNote":           true,
+	"CriticalSlideTickNote":         true,
+	"IgnoredSlideTickNote":          true,
+	"NormalAttachedSlideTickNote":   true,
+	"CriticalAttachedSlideTickNote": true,
+}
+
+// ノーツ密度・縦連・トリル・スライドの複雑さから難易度を推定する
+func EstimateDifficulty(levelData sonolus.LevelData) DifficultyEstimate {
+	bpmChanges := GetBpmChanges(levelData)
+	notes := []difficultyNote{}
+	slides, ticks := 0, 0
+	for _, entity := range levelData.Entities {
+		if slideTickArchetypes[entity.Archetype] {
+			ticks++
+			continue
+		}
+		// 中継点以外の、判定のあるノーツ
+		if WEIGHT_MAP[entity.Archetype] < 1 {
+			continue
+		}
+		if slideStartArchetypes[entity.Archetype] {
+			slides++
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		lane, _ := getValueFromData(entity.Data, "lane")
+		notes = append(notes, difficultyNote{time: getTimeFromBpmChanges(bpmChanges, beat), lane: lane})
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].time < notes[j].time
+	})
+
+	estimate := DifficultyEstimate{}
+	if slides > 0 {
+		estimate.SlideComplexity = float64(ticks) / float64(slides)
+	}
+	if len(notes) < 2 {
+		return estimate
+	}
+	length := notes[len(notes)-1].time - notes[0].time
+	if length > 0 {
+		estimate.AverageDensity = float64(len(notes)) / length
+	}
+	start := 0
+	for end := range notes {
+		for notes[end].time-notes[start].time > difficultyWindow {
+			start++
+		}
+		estimate.PeakDensity = max(estimate.PeakDensity, float64(end-start+1)/difficultyWindow)
+	}
+
+	// 同時押しは1つの動作として、前後のノーツとの間隔を見る
+	trill := 1
+	for i := 1; i < len(notes); i++ {
+		interval := notes[i].time - notes[i-1].time
+		if interval > 0 && interval <= jackInterval && notes[i].lane == notes[i-1].lane {
+			estimate.Jacks++
+		}
+		fast := interval > 0 && interval <= trillInterval && notes[i].lane != notes[i-1].lane
+		if fast && trill > 1 && notes[i].lane == notes[i-2].lane {
+			trill++
+		} else if fast {
+			trill = 2
+		} else if interval > 0 {
+			trill = 1
+		}
+		if trill == trillLength {
+			estimate.Trills += trillLength
+		} else if trill > trillLength {
+			estimate.Trills++
+		}
+	}
+
+	// 密度を対数で譜面のレベルの尺度に合わせ、縦連・トリル・スライドの割合で補正する
+	density := 0.6*estimate.AverageDensity + 0.4*estimate.PeakDensity
+	rating := 15*math.Log(max(density, 1)) - 4.5
+	rating += 6 * float64(estimate.Jacks+estimate.Trills) / float64(len(notes))
+	rating += min(estimate.SlideComplexity, 10) * 0.2
+	estimate.Rating = math.Round(min(max(rating, 1), 40)*10) / 10
+	return estimate
+}
@@ -0,0 +1,57 @@
+package pjsekaioverlay
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// サーバー上のファイルのサイズをHEADリクエストで求める（不明な場合は-1）
+func (c *Client) FetchRemoteSize(source Source, srl sonolus.SRL) int64 {
+	if srl.Url == "" {
+		return -1
+	}
+	url, err := sonolus.JoinUrl("https://"+source.Host, srl.Url)
+	if err != nil {
+		return -1
+	}
+	resp, err := c.httpClient.Head(url)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	return resp.ContentLength
+}
+
+// WriteExoFilesが出力するファイル名
+func ExoFileNames(options ExoOptions) []string {
+	names := []string{}
+	for _, variant := range exoVariants {
+		names = append(names, variant.fileName)
+		if !options.Split {
+			continue
+		}
+		for _, element := range ExoElements {
+			if !slices.Contains(options.Hidden, element) {
+				names = append(names, strings.TrimSuffix(variant.fileName, ".exo")+"_"+string(element)+".exo")
+			}
+		}
+	}
+	return names
+}
+
+// WriteAviUtl2Filesが出力するファイル名
+func AviUtl2FileNames() []string {
+	names := []string{}
+	for _, variant := range exoVariants {
+		if !variant.english {
+			names = append(names, strings.TrimSuffix(variant.fileName, ".exo")+".object")
+		}
+	}
+	return names
+}
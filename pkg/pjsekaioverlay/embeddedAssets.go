@@ -0,0 +1,74 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// 同梱のアセットのうち、destDirにないファイルだけを書き出す（手で差し替えたファイルは上書きしない）
+func ExtractAssets(assets fs.FS, destDir string) error {
+	err := fs.WalkDir(assets, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destDir, filepath.FromSlash(name))
+		if entry.IsDir() {
+			return os.MkdirAll(destPath, 0755)
+		}
+		if _, err := os.Stat(destPath); err == nil {
+			return nil
+		}
+		return extractAssetFile(assets, name, destPath)
+	})
+	if err != nil {
+		return fmt.Errorf("アセットの書き出しに失敗しました。(Failed to extract assets.) [%s]", err)
+	}
+	return nil
+}
+
+func extractAssetFile(assets fs.FS, name string, destPath string) error {
+	src, err := assets.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// 途中で失敗しても壊れたファイルを残さない
+	tmpPath := destPath + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, destPath)
+}
+
+// 同梱のアセットと比べ、dirにないファイルと大きさの違うファイル（差し替えたものを含む）を返す
+func VerifyAssets(assets fs.FS, dir string) (missing []string, changed []string, err error) {
+	err = fs.WalkDir(assets, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		expected, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		actual, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			missing = append(missing, name)
+		} else if actual.Size() != expected.Size() {
+			changed = append(changed, name)
+		}
+		return nil
+	})
+	return missing, changed, err
+}
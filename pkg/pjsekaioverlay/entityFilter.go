@@ -0,0 +1,58 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 名前で指定できる、飾りのエンティティの除外ルール
+var EntityFilterRules = map[string][]string{
+	"sim-lines":      {"SimLine"},
+	"hidden-ticks":   {"HiddenSlideTickNote"},
+	"ignored-ticks":  {"IgnoredSlideTickNote"},
+	"attached-ticks": {"NormalAttachedSlideTickNote", "CriticalAttachedSlideTickNote"},
+}
+
+// 除外するアーキタイプ
+type EntityFilter []string
+
+// カンマ区切りのルール名またはアーキタイプ名を読み込む
+func ParseEntityFilter(value string) (EntityFilter, error) {
+	filter := EntityFilter{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if archetypes, ok := EntityFilterRules[name]; ok {
+			filter = append(filter, archetypes...)
+			continue
+		}
+		if _, ok := WEIGHT_MAP[name]; !ok {
+			rules := make([]string, 0, len(EntityFilterRules))
+			for rule := range EntityFilterRules {
+				rules = append(rules, rule)
+			}
+			sort.Strings(rules)
+			return nil, fmt.Errorf("不明な除外ルールです。(Unknown filter rule.) [%s] (%s)", name, strings.Join(rules, ", "))
+		}
+		filter = append(filter, name)
+	}
+	return filter, nil
+}
+
+// 除外するアーキタイプのエンティティを取り除く（元の譜面データは変更しない）
+func (filter EntityFilter) Apply(levelData sonolus.LevelData) sonolus.LevelData {
+	if len(filter) == 0 {
+		return levelData
+	}
+	filtered := levelData
+	filtered.Entities = slices.DeleteFunc(slices.Clone(levelData.Entities), func(entity sonolus.LevelDataEntity) bool {
+		return slices.Contains(filter, entity.Archetype)
+	})
+	return filtered
+}
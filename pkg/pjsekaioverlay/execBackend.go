@@ -0,0 +1,81 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// 外部の出力形式の実行ファイル名の接頭辞
+const ExecBackendPrefix = "pjsekai-overlay-backend-"
+
+// 外部の実行ファイルを呼び出す出力形式
+//
+// `<実行ファイル> describe`は標準出力に説明のJSONを、`<実行ファイル> generate`は
+// 標準入力のJSON（timeline, assets, destDir, options）から出力を生成し、失敗した場合は0以外で終了する
+type execBackend struct {
+	path        string
+	description execBackendDescription
+}
+
+type execBackendDescription struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []BackendOption `json:"options"`
+	Files       []string        `json:"files"`
+}
+
+type execBackendRequest struct {
+	Timeline Timeline `json:"timeline"`
+	Assets   string   `json:"assets"`
+	DestDir  string   `json:"destDir"`
+	// ファイルに書き込むパスに使う出力先（一時ディレクトリに書き込む場合は移動後の場所）
+	OutDir  string            `json:"outDir"`
+	Options map[string]string `json:"options"`
+}
+
+func (b execBackend) Name() string             { return b.description.Name }
+func (b execBackend) Description() string      { return b.description.Description }
+func (b execBackend) Options() []BackendOption { return b.description.Options }
+func (b execBackend) FileNames() []string      { return b.description.Files }
+
+func (b execBackend) Generate(timeline Timeline, assets string, destDir string, options map[string]string) error {
+	request, err := json.Marshal(execBackendRequest{timeline, assets, destDir, timeline.Exo.distDir(destDir), options})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(b.path, "generate")
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("出力形式の実行に失敗しました。(Backend failed.) [%s: %s %s]", b.Name(), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// dirにある外部の出力形式を読み込んで登録する（dirがない場合は何もしない）
+func LoadExecBackends(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, ExecBackendPrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		output, err := exec.Command(path, "describe").Output()
+		if err != nil {
+			return fmt.Errorf("出力形式の読み込みに失敗しました。(Failed to load backend.) [%s: %s]", filepath.Base(path), err)
+		}
+		var description execBackendDescription
+		if err := json.Unmarshal(output, &description); err != nil || description.Name == "" {
+			return fmt.Errorf("出力形式の説明が不正です。(Invalid backend description.) [%s]", filepath.Base(path))
+		}
+		if _, err := GetBackend(description.Name); err == nil {
+			return fmt.Errorf("同じ名前の出力形式があります。(A backend with the same name exists.) [%s]", description.Name)
+		}
+		RegisterBackend(execBackend{path, description})
+	}
+	return nil
+}
@@ -1,24 +1,27 @@
 package pjsekaioverlay
 
 import (
+	"bufio"
 	_ "embed"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"unicode/utf16"
-
-	"golang.org/x/text/encoding/japanese"
-	"golang.org/x/text/transform"
 )
 
-func encodeString(str string) string {
-	bytes := utf16.Encode([]rune(str))
-	encoded := make([]string, 1024)
-	if len(str) > 1024 {
-		panic("too long string")
+// テキストオブジェクトのtext=に書く16進数にする（長さはUTF-16で数え、入りきらない場合はエラー）
+func encodeString(name string, str string) (string, error) {
+	if err := validateExoText(name, str); err != nil {
+		return "", err
 	}
+	bytes := utf16.Encode([]rune(str))
+	encoded := make([]string, exoTextLimit)
 	for i := range encoded {
 		var hex string
 		if i >= len(bytes) {
@@ -30,7 +33,7 @@ func encodeString(str string) string {
 		encoded[i] = hex
 	}
 
-	return strings.Join(encoded, "")
+	return strings.Join(encoded, ""), nil
 }
 
 //go:embed main_jp_16-9_1920x1080.exo
@@ -45,88 +48,586 @@ var rawBaseExoEN []byte
 //go:embed main_en_4-3_1440x1080.exo
 var rawBaseExoEN43 []byte
 
-func WriteExoFiles(assets string, destDir string, title string, description string) error {
-	baseExoJP := string(rawBaseExoJP)
-	baseExoJP43 := string(rawBaseExoJP43)
-	baseExoEN := string(rawBaseExoEN)
-	baseExoEN43 := string(rawBaseExoEN43)
-	replacedExoJP := baseExoJP
-	replacedExoJP43 := baseExoJP43
-	replacedExoEN := baseExoEN
-	replacedExoEN43 := baseExoEN43
+type exoVariant struct {
+	fileName string
+	raw      []byte
+	english  bool
+	// 16:9を基準とした座標・拡大率の倍率
+	scale float64
+}
+
+var exoVariants = []exoVariant{
+	{"main_jp_16-9_1920x1080.exo", rawBaseExoJP, false, 1},
+	{"main_jp_4-3_1440x1080.exo", rawBaseExoJP43, false, 0.75},
+	{"main_en_16-9_1920x1080.exo", rawBaseExoEN, true, 1},
+	{"main_en_4-3_1440x1080.exo", rawBaseExoEN43, true, 0.75},
+}
+
+// ゲームプレイ部分の開始・終了フレーム
+const (
+	exoPlayStart = 311
+	exoPlayEnd   = 3920
+	// テンプレートでのAP/FC演出の開始フレーム
+	exoFinaleStart = 3560
+	// 設定オブジェクトのオフセットの初期値
+	exoRootOffset = 216
+	exoFrameRate  = 60
+	// 最後のノーツからAP/FC演出までの間隔
+	exoFinaleDelay = 60
+)
+
+// 曲終わりのAP/FC演出
+type ExoFinale struct {
+	// 最後のノーツの時間（秒）。0以下の場合はテンプレートのタイミングのまま
+	LastNoteTime float64
+	// assets内の動画ファイル名
+	Video string
+	// 録画の再生速度（0の場合は等速）
+	Speed float64
+	// 最後のノーツからAP/FC演出までの秒数（0以下の場合は既定の1秒を再生速度に合わせたもの）
+	Tail float64
+}
+
+func FinaleVideo(ap bool) string {
+	if ap {
+		return "ap.mp4"
+	}
+	return "fc.mp4"
+}
+
+var exoTimingPattern = regexp.MustCompile(`(?m)^(start|end|length)=([0-9]+)$`)
+
+// 最後のノーツに合わせて、AP/FC演出以降のオブジェクトをずらす
+func retimeExoFinale(exo string, finale ExoFinale) string {
+	delay := float64(exoFinaleDelay)
+	if finale.Tail > 0 {
+		delay = finale.Tail * exoFrameRate
+	} else if finale.Speed > 0 {
+		delay /= finale.Speed
+	}
+	finaleFrame := exoPlayStart + exoRootOffset + int(math.Ceil(finale.LastNoteTime*exoFrameRate)) + int(math.Round(delay))
+	// 譜面が短すぎる場合でも、開始演出と重ならないようにする
+	if finaleFrame < exoPlayStart+exoRootOffset {
+		finaleFrame = exoPlayStart + exoRootOffset
+	}
+	delta := finaleFrame - exoFinaleStart
+	return exoTimingPattern.ReplaceAllStringFunc(exo, func(line string) string {
+		match := exoTimingPattern.FindStringSubmatch(line)
+		frame, _ := strconv.Atoi(match[2])
+		if frame < exoFinaleStart-1 {
+			return line
+		}
+		return fmt.Sprintf("%s=%d", match[1], frame+delta)
+	})
+}
+
+// テンプレートの末尾に追加するカスタムオブジェクト
+type ExoObject struct {
+	NameJP string
+	NameEN string
+	// 16:9での座標・拡大率
+	X    float64
+	Y    float64
+	Zoom float64
+}
+
+var MilestoneExoObject = ExoObject{NameJP: "コンボ演出", NameEN: "Milestone", X: 673.5, Y: -62.5, Zoom: 150}
+
+var RankExoObject = ExoObject{NameJP: "ランク", NameEN: "Rank", X: -583.5, Y: -469.0, Zoom: 150}
+
+var JudgmentCountExoObject = ExoObject{NameJP: "判定数", NameEN: "JudgmentCount", X: -760.0, Y: 120.0, Zoom: 100}
+
+// コンボ数の下に表示する
+var JudgmentTextExoObject = ExoObject{NameJP: "判定文字", NameEN: "JudgmentText", X: 673.5, Y: 40.0, Zoom: 100}
+
+var BeatExoObject = ExoObject{NameJP: "拍", NameEN: "Beat", X: 0.0, Y: 440.0, Zoom: 100}
+
+// 拍の点滅の上に表示する
+var MeasureExoObject = ExoObject{NameJP: "小節", NameEN: "Measure", X: 0.0, Y: 380.0, Zoom: 100}
+
+var DensityExoObject = ExoObject{NameJP: "ノーツ密度", NameEN: "Density", X: 0.0, Y: 380.0, Zoom: 100}
+
+// 画面下部に表示し、背景のすぐ上に差し込む
+var WaveformExoObject = ExoObject{NameJP: "波形", NameEN: "Waveform", X: 0.0, Y: 420.0, Zoom: 100}
+
+var CutInExoObject = ExoObject{NameJP: "カットイン", NameEN: "CutIn", X: 0.0, Y: 0.0, Zoom: 100}
+
+// 位置はテンプレートのジャケットをそのまま使う
+var AnimatedJacketExoObject = ExoObject{NameJP: "アニメーションジャケット", NameEN: "AnimatedJacket"}
+
+var exoIndexPattern = regexp.MustCompile(`(?m)^\[([0-9]+)\]$`)
+var exoLayerPattern = regexp.MustCompile(`(?m)^layer=([0-9]+)$`)
+
+func maxExoNumber(exo string, pattern *regexp.Regexp) int {
+	ret := 0
+	for _, match := range pattern.FindAllStringSubmatch(exo, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil && n > ret {
+			ret = n
+		}
+	}
+	return ret
+}
+
+// カスタムオブジェクトを1つ書き込む
+func writeExoCustomObject(builder *strings.Builder, variant exoVariant, object ExoObject, index int, layer int, start int, end int, param string) {
+	name, customObject, standardDrawing, zoom, clearness, rotation, script :=
+		object.NameJP, "カスタムオブジェクト", "標準描画", "拡大率", "透明度", "回転", "pjsekai-overlay"
+	if variant.english {
+		name, customObject, standardDrawing, zoom, clearness, rotation, script =
+			object.NameEN, "Custom object", "Standard drawing", "Zoom%", "Clearness", "Rotation", "pjsekai-overlay-en"
+	}
+	fmt.Fprintf(builder, "[%d]\nstart=%d\nend=%d\nlayer=%d\noverlay=1\ncamera=0\n", index, start, end, layer)
+	fmt.Fprintf(builder, "[%d.0]\n_name=%s\ntrack0=0.00\ntrack1=0.00\ntrack2=0.00\ntrack3=0.00\ncheck0=0\ntype=0\nfilter=0\nname=%s@%s\nparam=%s\n",
+		index, customObject, name, script, param)
+	fmt.Fprintf(builder, "[%d.1]\n_name=%s\nX=%.1f\nY=%.1f\nZ=0.0\n%s=%.2f\n%s=0.0\n%s=0.00\nblend=0\n",
+		index, standardDrawing, object.X*variant.scale, object.Y*variant.scale, zoom, object.Zoom*variant.scale, clearness, rotation)
+}
+
+func appendExoObjects(exo string, variant exoVariant, objects []ExoObject) string {
+	index := maxExoNumber(exo, exoIndexPattern)
+	layer := maxExoNumber(exo, exoLayerPattern)
+
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(exo, "\n"))
+	builder.WriteString("\n")
+	for _, object := range objects {
+		index++
+		layer++
+		writeExoCustomObject(&builder, variant, object, index, layer, exoPlayStart, exoPlayEnd, "")
+	}
+	return builder.String()
+}
+
+// 背景（レイヤー1）のすぐ上にオブジェクトを差し込み、それより上のレイヤーを1つずつずらす
+func insertExoBackdropObject(exo string, variant exoVariant, object ExoObject) string {
+	exo = exoLayerPattern.ReplaceAllStringFunc(exo, func(line string) string {
+		layer, _ := strconv.Atoi(exoLayerPattern.FindStringSubmatch(line)[1])
+		if layer < 2 {
+			return line
+		}
+		return fmt.Sprintf("layer=%d", layer+1)
+	})
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(exo, "\n"))
+	builder.WriteString("\n")
+	writeExoCustomObject(&builder, variant, object, maxExoNumber(exo, exoIndexPattern)+1, 2, exoPlayStart, exoPlayEnd, "")
+	return builder.String()
+}
+
+// ジャケットの画像ファイルを、同じ位置のアニメーションジャケットに差し替える
+func replaceExoCover(exo string, variant exoVariant, coverFormat ImageFormat) string {
+	imageFile, customObject, name, script := "画像ファイル", "カスタムオブジェクト", AnimatedJacketExoObject.NameJP, "pjsekai-overlay"
+	if variant.english {
+		imageFile, customObject, name, script = "Image file", "Custom object", AnimatedJacketExoObject.NameEN, "pjsekai-overlay-en"
+	}
+	lines := strings.Split(exo, "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if lines[i] != "_name="+imageFile || !strings.HasPrefix(lines[i+1], "file=") || !strings.HasSuffix(lines[i+1], "\\"+coverFormat.FileName("cover")) {
+			continue
+		}
+		lines[i] = fmt.Sprintf("_name=%s\ntrack0=0.00\ntrack1=0.00\ntrack2=0.00\ntrack3=0.00\ncheck0=0\ntype=0\nfilter=0\nname=%s@%s", customObject, name, script)
+		lines[i+1] = "param="
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 背景の画像ファイルを、同じレイヤーでループ再生する動画ファイルに差し替える
+func replaceExoBackground(exo string, variant exoVariant, video string) string {
+	imageFile, videoFile := "画像ファイル", "動画ファイル\n再生位置=1\n再生速度=100.0\nループ再生=1\nアルファチャンネルを読み込む=0"
+	if variant.english {
+		imageFile, videoFile = "Image file", "Video file\nPlayback position=1\nvPlay=100.0\nLoop playback=1\nImport alpha channel=0"
+	}
+	lines := strings.Split(exo, "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if lines[i] != "_name="+imageFile || !strings.HasPrefix(lines[i+1], "file=") || !strings.HasSuffix(lines[i+1], "\\background.png") {
+			continue
+		}
+		lines[i] = "_name=" + videoFile
+		lines[i+1] = strings.TrimSuffix(lines[i+1], "background.png") + video
+	}
+	return strings.Join(lines, "\n")
+}
+
+var exoDrawingPattern = regexp.MustCompile(`^(X|Y|拡大率|Zoom%)=([-0-9.]+)$`)
+
+// スコアとコンボを拡大する。スコアは画面の左上にはみ出さないよう、左上をそろえる
+func scaleExoCounters(exo string, scale float64) string {
+	lines := strings.Split(exo, "\n")
+	for i := 0; i < len(lines); i++ {
+		score := strings.HasPrefix(lines[i], "name=スコア@") || strings.HasPrefix(lines[i], "name=Score@")
+		if !score && !strings.HasPrefix(lines[i], "name=コンボ@") && !strings.HasPrefix(lines[i], "name=Combo@") {
+			continue
+		}
+		// 標準描画の位置と拡大率
+		values := map[string]int{}
+		for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "blend="); j++ {
+			if match := exoDrawingPattern.FindStringSubmatch(lines[j]); match != nil {
+				values[match[1]] = j
+			}
+		}
+		zoomLine, ok := values["拡大率"]
+		if !ok {
+			zoomLine, ok = values["Zoom%"]
+		}
+		if !ok {
+			continue
+		}
+		key, value, _ := strings.Cut(lines[zoomLine], "=")
+		zoom, _ := strconv.ParseFloat(value, 64)
+		lines[zoomLine] = fmt.Sprintf("%s=%.2f", key, zoom*scale)
+		if !score {
+			continue
+		}
+		// スコアの描画範囲は444x95
+		for axis, half := range map[string]float64{"X": 222, "Y": 47.5} {
+			line, ok := values[axis]
+			if !ok {
+				continue
+			}
+			_, value, _ := strings.Cut(lines[line], "=")
+			position, _ := strconv.ParseFloat(value, 64)
+			lines[line] = fmt.Sprintf("%s=%.1f", axis, position+half*zoom/100*(scale-1))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 非表示にできるUI要素
+type ExoElement string
+
+const (
+	ExoElementScore      ExoElement = "score"
+	ExoElementCombo      ExoElement = "combo"
+	ExoElementJacket     ExoElement = "jacket"
+	ExoElementBackground ExoElement = "background"
+	// オートプレイ中の「AUTO」の表示（分割したexoには出力しない）
+	ExoElementAuto ExoElement = "auto"
+)
+
+func (element ExoElement) matches(object []string, coverFormat ImageFormat) bool {
+	for _, line := range object {
+		switch element {
+		case ExoElementScore:
+			for _, name := range []string{"スコア", "Score", RankExoObject.NameJP, RankExoObject.NameEN} {
+				if strings.HasPrefix(line, "name="+name+"@") {
+					return true
+				}
+			}
+		case ExoElementCombo:
+			for _, name := range []string{"コンボ", "Combo", MilestoneExoObject.NameJP, MilestoneExoObject.NameEN} {
+				if strings.HasPrefix(line, "name="+name+"@") {
+					return true
+				}
+			}
+		case ExoElementJacket:
+			if strings.HasPrefix(line, "file=") && strings.HasSuffix(line, "\\"+coverFormat.FileName("cover")) {
+				return true
+			}
+			for _, name := range []string{AnimatedJacketExoObject.NameJP, AnimatedJacketExoObject.NameEN} {
+				if strings.HasPrefix(line, "name="+name+"@") {
+					return true
+				}
+			}
+		case ExoElementBackground:
+			if strings.HasPrefix(line, "file=") && strings.HasSuffix(line, "\\background.png") {
+				return true
+			}
+			if strings.HasPrefix(line, "file=") && strings.Contains(line, "\\"+backgroundVideoName+".") {
+				return true
+			}
+		case ExoElementAuto:
+			if strings.HasPrefix(line, "file=") && strings.HasSuffix(line, "\\auto.mp4") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var exoSectionPattern = regexp.MustCompile(`^\[([0-9]+)(\.[0-9]+)?\]$`)
+
+// keepがtrueを返すオブジェクトだけを残し、オブジェクト番号を振り直す
+func filterExoObjects(exo string, keep func(object []string) bool) string {
+	lines := strings.Split(exo, "\n")
+	head := []string{}
+	objects := [][]string{}
+	for _, line := range lines {
+		match := exoSectionPattern.FindStringSubmatch(line)
+		if match != nil && match[2] == "" {
+			objects = append(objects, []string{})
+		}
+		if len(objects) == 0 {
+			head = append(head, line)
+		} else {
+			objects[len(objects)-1] = append(objects[len(objects)-1], line)
+		}
+	}
+
+	result := head
+	index := 0
+	for _, object := range objects {
+		if !keep(object) {
+			continue
+		}
+		for _, line := range object {
+			if match := exoSectionPattern.FindStringSubmatch(line); match != nil {
+				line = fmt.Sprintf("[%d%s]", index, match[2])
+			}
+			result = append(result, line)
+		}
+		index++
+	}
+	return strings.Join(result, "\n")
+}
+
+// 指定した要素のオブジェクトを取り除く
+func removeExoObjects(exo string, hidden []ExoElement, coverFormat ImageFormat) string {
+	return filterExoObjects(exo, func(object []string) bool {
+		for _, element := range hidden {
+			if element.matches(object, coverFormat) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func isExoRootObject(object []string) bool {
+	for _, line := range object {
+		if strings.HasPrefix(line, "name=設定@") || strings.HasPrefix(line, "name=Root@") {
+			return true
+		}
+	}
+	return false
+}
+
+// 指定した要素のオブジェクトだけを取り出す。スコアとコンボにはpedファイルを読み込む設定オブジェクトも含める
+func extractExoElement(exo string, element ExoElement, coverFormat ImageFormat) string {
+	return filterExoObjects(exo, func(object []string) bool {
+		if element == ExoElementScore || element == ExoElementCombo {
+			if isExoRootObject(object) {
+				return true
+			}
+		}
+		return element.matches(object, coverFormat)
+	})
+}
+
+var ExoElements = []ExoElement{ExoElementScore, ExoElementCombo, ExoElementJacket, ExoElementBackground}
+
+type ExoOptions struct {
+	CoverFormat ImageFormat
+	// exoが参照する出力先（空の場合は書き込み先と同じ）。一時ディレクトリに書き込む場合に、移動後の出力先を指定する
+	DistDir string
+	// テンプレートに追加するオブジェクト
+	Objects []ExoObject
+	Finale  ExoFinale
+	// 非表示にするUI要素
+	Hidden []ExoElement
+	// テキストオブジェクトのフォント名（空の場合は言語に合わせる）
+	Font string
+	// 説明文などの文言の言語（空の場合は日本語）
+	Labels LabelLanguage
+	// 難易度の表示（空の場合は「APPEND」）
+	Difficulty string
+	Format     ExoFormat
+	// 要素ごとに分けたexoも出力する
+	Split bool
+	// 歌詞（時間は譜面と同じ）
+	Lyrics []LyricLine
+	// カットインの目印
+	CutIns []CutIn
+	// ジャケットの画像をアニメーションジャケットのカスタムオブジェクトに差し替える
+	AnimatedCover bool
+	// 背景の画像の代わりに使う、出力先の動画のファイル名（空の場合は画像のまま）
+	BackgroundVideo string
+	// 指定した場合は背景の代わりにキー色を敷く
+	ChromaKey *ExoChromaKey
+	// スコアとコンボの拡大率（0の場合は等倍）
+	CounterScale float64
+	// 曲のビジュアライザーを背景とスコア・コンボの間に追加する
+	Waveform bool
+}
+
+func (options ExoOptions) distDir(destDir string) string {
+	if options.DistDir != "" {
+		return options.DistDir
+	}
+	return destDir
+}
+
+var exoFontPattern = regexp.MustCompile(`(?m)^font=.*$`)
+
+// テンプレートを置き換えた、エンコード前のexo
+type builtExo struct {
+	variant exoVariant
+	content string
+}
+
+func buildExoFiles(assets string, destDir string, title string, description string, options ExoOptions) ([]builtExo, error) {
+	difficulty := options.Difficulty
+	if difficulty == "" {
+		difficulty = "APPEND"
+	}
 	mapping := []string{
 		"{assets}", strings.ReplaceAll(assets, "\\", "/"),
-		"{dist}", strings.ReplaceAll(destDir, "\\", "/"),
-		"{text:difficulty}", encodeString("APPEND"),
-		"{text:extra}", encodeString("動画：TootieJin"),
-		"{text:title}", encodeString(title),
-		"{text:description}", encodeString(description),
-	}
-	for i := range mapping {
-		if i%2 == 0 {
-			continue
+		"{dist}", strings.ReplaceAll(options.distDir(destDir), "\\", "/"),
+		"{file:cover}", options.CoverFormat.FileName("cover"),
+		"{file:finale}", options.Finale.Video,
+	}
+	for _, text := range []struct{ key, name, value string }{
+		{"{text:difficulty}", "難易度 (Difficulty)", difficulty},
+		{"{text:extra}", "ラベル (Label)", fmt.Sprintf(options.Labels.Labels().Video, "TootieJin")},
+		{"{text:title}", "タイトル (Title)", title},
+		{"{text:description}", "説明 (Description)", description},
+	} {
+		encoded, err := encodeString(text.name, text.value)
+		if err != nil {
+			return nil, err
 		}
-		if !strings.Contains(replacedExoJP, mapping[i-1]) {
-			panic(fmt.Sprintf("exoファイルの生成に失敗しました (Failed to generate exo file) [Missing: %s]", mapping[i-1]))
+		mapping = append(mapping, text.key, encoded)
+	}
+	font := options.Font
+	if font == "" {
+		font = options.Labels.TextFont(title, description)
+	}
+	exos := make([]builtExo, 0, len(exoVariants))
+	for _, variant := range exoVariants {
+		replacedExo := string(variant.raw)
+		for i := range mapping {
+			if i%2 == 0 {
+				continue
+			}
+			if !strings.Contains(replacedExo, mapping[i-1]) {
+				panic(fmt.Sprintf("exoファイルの生成に失敗しました (Failed to generate exo file) [Missing: %s]", mapping[i-1]))
+			}
+			replacedExo = strings.ReplaceAll(replacedExo, mapping[i-1], mapping[i])
+		}
+		if font != "" {
+			replacedExo = exoFontPattern.ReplaceAllLiteralString(replacedExo, "font="+font)
+		}
+		if len(options.Objects) > 0 {
+			replacedExo = appendExoObjects(replacedExo, variant, options.Objects)
+		}
+		if options.Waveform {
+			replacedExo = insertExoBackdropObject(replacedExo, variant, WaveformExoObject)
+		}
+		if options.AnimatedCover {
+			replacedExo = replaceExoCover(replacedExo, variant, options.CoverFormat)
 		}
-		if !strings.Contains(replacedExoJP43, mapping[i-1]) {
-			panic(fmt.Sprintf("exoファイルの生成に失敗しました (Failed to generate exo file) [Missing: %s]", mapping[i-1]))
+		if options.CounterScale > 0 && options.CounterScale != 1 {
+			replacedExo = scaleExoCounters(replacedExo, options.CounterScale)
 		}
-		if !strings.Contains(replacedExoEN, mapping[i-1]) {
-			panic(fmt.Sprintf("exoファイルの生成に失敗しました (Failed to generate exo file) [Missing: %s]", mapping[i-1]))
+		if options.BackgroundVideo != "" {
+			replacedExo = replaceExoBackground(replacedExo, variant, options.BackgroundVideo)
 		}
-		if !strings.Contains(replacedExoEN43, mapping[i-1]) {
-			panic(fmt.Sprintf("exoファイルの生成に失敗しました (Failed to generate exo file) [Missing: %s]", mapping[i-1]))
+		if len(options.Hidden) > 0 {
+			replacedExo = removeExoObjects(replacedExo, options.Hidden, options.CoverFormat)
 		}
-		replacedExoJP = strings.ReplaceAll(replacedExoJP, mapping[i-1], mapping[i])
-		replacedExoJP43 = strings.ReplaceAll(replacedExoJP43, mapping[i-1], mapping[i])
-		replacedExoEN = strings.ReplaceAll(replacedExoEN, mapping[i-1], mapping[i])
-		replacedExoEN43 = strings.ReplaceAll(replacedExoEN43, mapping[i-1], mapping[i])
+		if options.Finale.LastNoteTime > 0 {
+			replacedExo = retimeExoFinale(replacedExo, options.Finale)
+		}
+		// AP/FC演出に合わせてずらさないよう、最後に追加する
+		if len(options.Lyrics) > 0 {
+			var err error
+			replacedExo, err = appendExoLyrics(replacedExo, variant, options.Lyrics, font)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(options.CutIns) > 0 {
+			replacedExo = appendExoCutIns(replacedExo, variant, options.CutIns)
+		}
+		// 追加したテキストのアンチエイリアスも切り替えるので、最後に適用する
+		if options.ChromaKey != nil {
+			replacedExo = applyExoChromaKey(replacedExo, variant, *options.ChromaKey, options.CoverFormat)
+		}
+		exos = append(exos, builtExo{variant: variant, content: replacedExo})
 	}
-	replacedExoJP = strings.ReplaceAll(replacedExoJP, "\n", "\r\n")
-	replacedExoJP43 = strings.ReplaceAll(replacedExoJP43, "\n", "\r\n")
-	replacedExoEN = strings.ReplaceAll(replacedExoEN, "\n", "\r\n")
-	replacedExoEN43 = strings.ReplaceAll(replacedExoEN43, "\n", "\r\n")
+	return exos, nil
+}
 
-	encodedExoJP, err := io.ReadAll(transform.NewReader(
-		strings.NewReader(replacedExoJP), japanese.ShiftJIS.NewEncoder()))
-	if err != nil {
-		return fmt.Errorf("エンコードに失敗しました (Encoding failed) [%w]", err)
+func WriteExoFiles(assets string, destDir string, title string, description string, options ExoOptions) error {
+	if options.Format == (ExoFormat{}) {
+		options.Format = DefaultExoFormat
 	}
-	encodedExoJP43, err := io.ReadAll(transform.NewReader(
-		strings.NewReader(replacedExoJP43), japanese.ShiftJIS.NewEncoder()))
+	exos, err := buildExoFiles(assets, destDir, title, description, options)
 	if err != nil {
-		return fmt.Errorf("エンコードに失敗しました (Encoding failed) [%w]", err)
+		return err
 	}
-	encodedExoEN, err := io.ReadAll(transform.NewReader(
-		strings.NewReader(replacedExoEN), japanese.ShiftJIS.NewEncoder()))
-	if err != nil {
-		return fmt.Errorf("エンコードに失敗しました (Encoding failed) [%w]", err)
+	for _, exo := range exos {
+		if err := writeExoFile(filepath.Join(destDir, exo.variant.fileName), exo.content, options.Format); err != nil {
+			return err
+		}
+		if !options.Split {
+			continue
+		}
+		for _, element := range ExoElements {
+			if slices.Contains(options.Hidden, element) {
+				continue
+			}
+			fileName := strings.TrimSuffix(exo.variant.fileName, ".exo") + "_" + string(element) + ".exo"
+			if err := writeExoFile(filepath.Join(destDir, fileName), extractExoElement(exo.content, element, options.CoverFormat), options.Format); err != nil {
+				return err
+			}
+		}
 	}
-	encodedExoEN43, err := io.ReadAll(transform.NewReader(
-		strings.NewReader(replacedExoEN43), japanese.ShiftJIS.NewEncoder()))
+	return nil
+}
+
+// exoの種類の名前（jp_16-9など）
+func exoVariantName(variant exoVariant) string {
+	parts := strings.Split(strings.TrimSuffix(variant.fileName, ".exo"), "_")
+	return parts[1] + "_" + parts[2]
+}
+
+// 指定した種類のexoだけを任意の書き込み先に出力する（ファイルの出力先はdestDirとして参照される）
+func WriteExo(w io.Writer, variantName string, assets string, destDir string, title string, description string, options ExoOptions) error {
+	if options.Format == (ExoFormat{}) {
+		options.Format = DefaultExoFormat
+	}
+	exos, err := buildExoFiles(assets, destDir, title, description, options)
 	if err != nil {
-		return fmt.Errorf("エンコードに失敗しました (Encoding failed) [%w]", err)
+		return err
 	}
-	if err := os.WriteFile(filepath.Join(destDir, "main_jp_16-9_1920x1080.exo"),
-		encodedExoJP,
-		0644); err != nil {
-		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+	for _, exo := range exos {
+		if exoVariantName(exo.variant) != variantName {
+			continue
+		}
+		return writeExo(w, exo.content, options.Format)
 	}
-	if err := os.WriteFile(filepath.Join(destDir, "main_jp_4-3_1440x1080.exo"),
-		encodedExoJP43,
-		0644); err != nil {
-		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+	return fmt.Errorf("不明なexoの種類です。(Unknown exo variant.) [%s]", variantName)
+}
+
+// 変換しながら書き込む
+func writeExo(w io.Writer, exo string, format ExoFormat) error {
+	buffered := bufio.NewWriter(w)
+	writer := format.newWriter(buffered)
+	if _, err := writer.WriteString(exo); err != nil {
+		return err
 	}
-	if err := os.WriteFile(filepath.Join(destDir, "main_en_16-9_1920x1080.exo"),
-		encodedExoEN,
-		0644); err != nil {
-		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+	if err := writer.Close(); err != nil {
+		return err
 	}
-	if err := os.WriteFile(filepath.Join(destDir, "main_en_4-3_1440x1080.exo"),
-		encodedExoEN43,
-		0644); err != nil {
+	if err := buffered.Flush(); err != nil {
 		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
 	}
 	return nil
 }
+
+// 変換しながらファイルに書き込む。途中で失敗した場合は書きかけのファイルを残さない
+func writeExoFile(path string, exo string, format ExoFormat) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました (Failed to create file) [%w]", err)
+	}
+	err = writeExo(file, exo, format)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
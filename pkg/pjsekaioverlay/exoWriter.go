@@ -0,0 +1,149 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+type ExoEncoding string
+
+const (
+	// AviUtl（拡張編集）が読み込める形式
+	ExoEncodingShiftJIS ExoEncoding = "sjis"
+	// 文字コードを変換できるプラグイン向け
+	ExoEncodingUTF8 ExoEncoding = "utf8"
+)
+
+type ExoLineEnding string
+
+const (
+	ExoLineEndingCRLF ExoLineEnding = "crlf"
+	ExoLineEndingLF   ExoLineEnding = "lf"
+)
+
+// 対象とする拡張編集のバージョン（テンプレートはこのバージョンで書き出したもの）
+const ExoExEditVersion = "0.92"
+
+// exoの文字コードと改行コード
+//
+// 拡張編集のバージョンごとのテンプレートや項目の違いには対応しておらず、ExoExEditVersion向けに出力する。
+// AviUtl2にはaviutl2バックエンドの.objectを使う
+type ExoFormat struct {
+	Encoding   ExoEncoding
+	LineEnding ExoLineEnding
+}
+
+var DefaultExoFormat = ExoFormat{
+	Encoding:   ExoEncodingShiftJIS,
+	LineEnding: ExoLineEndingCRLF,
+}
+
+// テキストオブジェクトの文字数の上限（UTF-16、終端文字を含む）
+const exoTextLimit = 1024
+
+func ParseExoFormat(encodingName string, lineEnding string) (ExoFormat, error) {
+	format := ExoFormat{
+		Encoding:   ExoEncoding(encodingName),
+		LineEnding: ExoLineEnding(lineEnding),
+	}
+	switch format.Encoding {
+	case ExoEncodingShiftJIS, ExoEncodingUTF8:
+	default:
+		return ExoFormat{}, fmt.Errorf("不明な文字コードです。(Unknown encoding.) [%s]", encodingName)
+	}
+	switch format.LineEnding {
+	case ExoLineEndingCRLF, ExoLineEndingLF:
+	default:
+		return ExoFormat{}, fmt.Errorf("不明な改行コードです。(Unknown line ending.) [%s]", lineEnding)
+	}
+	return format, nil
+}
+
+func (format ExoFormat) encoding() encoding.Encoding {
+	if format.Encoding == ExoEncodingUTF8 {
+		return unicode.UTF8
+	}
+	return japanese.ShiftJIS
+}
+
+// テキストオブジェクトに入りきる長さかを確認する
+func validateExoText(name string, text string) error {
+	if len(utf16.Encode([]rune(text))) >= exoTextLimit {
+		return fmt.Errorf("%sが長すぎます。(%s is too long.) [%d/%d]", name, name, len(utf16.Encode([]rune(text))), exoTextLimit-1)
+	}
+	return nil
+}
+
+// exoを指定した形式に変換しながら書き込む。繋げた文字列を作らずに済むよう、1行ずつ変換する
+type exoWriter struct {
+	w       io.Writer
+	format  ExoFormat
+	encoder *encoding.Encoder
+	// 改行が来るまでの書きかけの行
+	line   []byte
+	lineNo int
+}
+
+func (format ExoFormat) newWriter(w io.Writer) *exoWriter {
+	return &exoWriter{w: w, format: format, encoder: format.encoding().NewEncoder()}
+}
+
+func (writer *exoWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			writer.line = append(writer.line, p...)
+			return written + len(p), nil
+		}
+		writer.line = append(writer.line, p[:i]...)
+		if err := writer.writeLine(true); err != nil {
+			return written, err
+		}
+		written += i + 1
+		p = p[i+1:]
+	}
+	return written, nil
+}
+
+func (writer *exoWriter) WriteString(s string) (int, error) {
+	return writer.Write([]byte(s))
+}
+
+// 書きかけの行を書き出す
+func (writer *exoWriter) Close() error {
+	return writer.writeLine(false)
+}
+
+// 変換できない文字がある場合は、その行と文字を含めたエラーを返す
+func (writer *exoWriter) writeLine(newline bool) error {
+	writer.lineNo++
+	line := string(writer.line)
+	writer.line = writer.line[:0]
+	encoded, err := writer.encoder.String(line)
+	if err != nil {
+		for _, char := range line {
+			if _, err := writer.encoder.String(string(char)); err != nil {
+				return fmt.Errorf("exoファイルに使用できない文字が含まれています。タイトルやパスから取り除いてください。(The exo file contains a character that can't be encoded. Remove it from the title or path.) [line %d: %q]", writer.lineNo, char)
+			}
+		}
+		return fmt.Errorf("エンコードに失敗しました (Encoding failed) [%w]", err)
+	}
+	if newline {
+		if writer.format.LineEnding == ExoLineEndingCRLF {
+			encoded += "\r\n"
+		} else {
+			encoded += "\n"
+		}
+	}
+	if _, err := io.WriteString(writer.w, encoded); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+	}
+	return nil
+}
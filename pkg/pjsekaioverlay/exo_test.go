@@ -0,0 +1,36 @@
+package pjsekaioverlay
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeStringCountsUtf16(t *testing.T) {
+	// UTF-8では1024バイトを超えるが、UTF-16では入りきる
+	encoded, err := encodeString("タイトル (Title)", strings.Repeat("譜", 400))
+	if err != nil {
+		t.Fatalf("encodeString: %s", err)
+	}
+	if len(encoded) != exoTextLimit*4 {
+		t.Errorf("len = %d, want %d", len(encoded), exoTextLimit*4)
+	}
+	if decoded := decodeExoString(encoded); decoded != strings.Repeat("譜", 400) {
+		t.Errorf("decoded = %q", decoded)
+	}
+
+	if _, err := encodeString("タイトル (Title)", strings.Repeat("a", exoTextLimit)); err == nil {
+		t.Error("encodeString accepted a text without room for the terminator")
+	}
+}
+
+func TestAppendExoLyricsValidatesExpandedText(t *testing.T) {
+	// 改行をCRLFにすると入りきらなくなる
+	text := strings.Repeat("a\n", exoTextLimit/2-1)
+	if err := validateExoText("歌詞 (Lyrics)", text); err != nil {
+		t.Fatalf("raw text should fit: %s", err)
+	}
+	_, err := appendExoLyrics("", exoVariants[0], []LyricLine{{Start: 0, End: 1, Text: text}}, "")
+	if err == nil {
+		t.Error("appendExoLyrics accepted a text that is too long after expanding line breaks")
+	}
+}
@@ -0,0 +1,73 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// TTF/OTF/TTCファイルを読み込む（TTCの場合は最初のフォント）
+func loadFontFile(fontPath string) (*sfnt.Font, error) {
+	data, err := os.ReadFile(fontPath)
+	if err != nil {
+		return nil, fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+	}
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		collection, err := sfnt.ParseCollection(data)
+		if err != nil {
+			return nil, fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+		}
+		font, err := collection.Font(0)
+		if err != nil {
+			return nil, fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+		}
+		return font, nil
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+	}
+	return font, nil
+}
+
+// TTF/OTFファイルからフォント名を読み込む
+func LoadFontName(fontPath string) (string, error) {
+	font, err := loadFontFile(fontPath)
+	if err != nil {
+		return "", err
+	}
+	name, err := font.Name(nil, sfnt.NameIDFamily)
+	if err != nil {
+		return "", fmt.Errorf("フォント名の取得に失敗しました。(Failed to get font name.) [%s]", err)
+	}
+	return name, nil
+}
+
+// フォントに含まれない文字（空白や改行などは除く）を、重複なく出現順に返す
+func missingGlyphs(font *sfnt.Font, text string) []rune {
+	missing := []rune{}
+	seen := map[rune]bool{}
+	var buffer sfnt.Buffer
+	for _, r := range text {
+		if seen[r] || unicode.IsSpace(r) || unicode.IsControl(r) {
+			continue
+		}
+		seen[r] = true
+		if index, err := font.GlyphIndex(&buffer, r); err != nil || index == 0 {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// フォントファイルに含まれない文字を返す
+func FontMissingGlyphs(fontPath string, text string) ([]rune, error) {
+	font, err := loadFontFile(fontPath)
+	if err != nil {
+		return nil, err
+	}
+	return missingGlyphs(font, text), nil
+}
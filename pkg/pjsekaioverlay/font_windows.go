@@ -0,0 +1,44 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// AviUtlから使えるように、フォントを現在のユーザーにインストールする
+func InstallFont(fontPath string, name string) error {
+	fontDir := filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Windows", "Fonts")
+	destPath := filepath.Join(fontDir, filepath.Base(fontPath))
+	if _, err := os.Stat(destPath); err != nil {
+		if err := os.MkdirAll(fontDir, 0755); err != nil {
+			return fmt.Errorf("フォントのインストールに失敗しました。(Failed to install font.) [%s]", err)
+		}
+		src, err := os.Open(fontPath)
+		if err != nil {
+			return fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+		}
+		defer src.Close()
+		dest, err := os.Create(destPath)
+		if err != nil {
+			return fmt.Errorf("フォントのインストールに失敗しました。(Failed to install font.) [%s]", err)
+		}
+		defer dest.Close()
+		if _, err := io.Copy(dest, src); err != nil {
+			return fmt.Errorf("フォントのインストールに失敗しました。(Failed to install font.) [%s]", err)
+		}
+	}
+
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, `Software\Microsoft\Windows NT\CurrentVersion\Fonts`, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("フォントのインストールに失敗しました。(Failed to install font.) [%s]", err)
+	}
+	defer key.Close()
+	if err := key.SetStringValue(name+" (TrueType)", destPath); err != nil {
+		return fmt.Errorf("フォントのインストールに失敗しました。(Failed to install font.) [%s]", err)
+	}
+	return nil
+}
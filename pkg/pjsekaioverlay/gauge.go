@@ -0,0 +1,88 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+)
+
+// スコアバーの目盛りの置き方
+type ScoreGauge string
+
+const (
+	// ゲームと同じく、ランクの境界を固定の位置に置く（区間ごとに伸び方が変わる）
+	ScoreGaugeGame ScoreGauge = "game"
+	// ランクの境界を、難易度ごとのボーダーに対する割合の位置に置く
+	ScoreGaugeProportional ScoreGauge = "proportional"
+)
+
+func ParseScoreGauge(gauge string) (ScoreGauge, error) {
+	switch ScoreGauge(gauge) {
+	case ScoreGaugeGame, ScoreGaugeProportional:
+		return ScoreGauge(gauge), nil
+	}
+	return "", fmt.Errorf("不明なスコアバーの種類です。(Unknown score gauge.) [%s]", gauge)
+}
+
+const (
+	scoreBarWidth = 357
+	// score/fg.pngでのスコアバーの左端
+	scoreBarLeft = 78
+	// score/fg.pngの目盛り（C・B・A・S）の幅の半分と高さ
+	gaugeMarkerHalfWidth = 12
+	gaugeMarkerHeight    = 53
+)
+
+// ゲームでの目盛りの位置（getRankの区間の境目）
+var gameGaugeMarkers = [4]float64{161, 215, 267, 320}
+
+// ボーダーに対する割合でのスコアバーの長さ（0〜357）
+func proportionalGaugeX(score int, rating int) float64 {
+	return min(float64(score)/float64(getRankBorders(rating).Border), 1) * scoreBarWidth
+}
+
+// 割合で置いた場合の目盛りの位置
+func proportionalGaugeMarkers(rating int) [4]float64 {
+	borders := getRankBorders(rating)
+	markers := [4]float64{}
+	for i, border := range []int{borders.C, borders.B, borders.A, borders.S} {
+		markers[i] = proportionalGaugeX(border, rating)
+	}
+	return markers
+}
+
+// score/fg.pngの目盛りを割合の位置に描き直し、gaugeに書き込んだパスを返す。frameDirは元のscore/fg.pngがあるディレクトリ
+func WriteGaugeAssets(frameDir string, destPath string, rating int) (string, error) {
+	file, err := os.Open(filepath.Join(frameDir, "score", "fg.png"))
+	if err != nil {
+		return "", fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [%s]", err)
+	}
+	src, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return "", fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [fg.png: %s]", err)
+	}
+
+	dst := image.NewNRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+	markerRect := func(x float64) image.Rectangle {
+		center := src.Bounds().Min.X + scoreBarLeft + int(x)
+		return image.Rect(center-gaugeMarkerHalfWidth, src.Bounds().Min.Y, center+gaugeMarkerHalfWidth, src.Bounds().Min.Y+gaugeMarkerHeight)
+	}
+	// 元の目盛りを消してから、同じ画像を新しい位置に置く
+	for _, x := range gameGaugeMarkers {
+		draw.Draw(dst, markerRect(x), image.Transparent, image.Point{}, draw.Src)
+	}
+	for i, x := range proportionalGaugeMarkers(rating) {
+		rect := markerRect(x)
+		draw.Draw(dst, rect, src, markerRect(gameGaugeMarkers[i]).Min, draw.Over)
+	}
+
+	gaugeDir := filepath.Join(destPath, "gauge")
+	if err := writeImage(DirOutput(gaugeDir), "fg.png", dst, ImageFormatPng); err != nil {
+		return "", err
+	}
+	return filepath.Join(gaugeDir, "fg.png"), nil
+}
@@ -0,0 +1,50 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 目標スコア（ライバルのスコアなど）に向けて進むゴースト
+type Ghost struct {
+	// 表示する名前
+	Title string
+	Score int
+	// ゴーストのフレーム（区間を指定した場合は、スコアと同じように取り除いたもの）
+	Frames []PedFrame
+}
+
+var GhostExoObject = ExoObject{NameJP: "ゴースト", NameEN: "Ghost", X: 760.0, Y: 420.0, Zoom: 100}
+
+// 「スコア」または「名前:スコア」の形式の目標スコアを読み込む
+func ParseGhost(value string) (Ghost, error) {
+	title, scoreValue := "TARGET", value
+	if index := strings.LastIndex(value, ":"); index >= 0 {
+		title, scoreValue = strings.TrimSpace(value[:index]), value[index+1:]
+	}
+	score, err := strconv.Atoi(strings.TrimSpace(scoreValue))
+	if err != nil || score <= 0 || title == "" {
+		return Ghost{}, fmt.Errorf("目標スコアの形式が不正です。(Invalid target score.) [%s] (1100000, Rival:1100000)", value)
+	}
+	return Ghost{
+		// pedファイルの区切り文字は使えない
+		Title: strings.NewReplacer(":", " ", "|", " ", "\n", " ").Replace(title),
+		Score: score,
+	}, nil
+}
+
+// 区間を指定する前の全てのフレームから、ノーツごとに同じだけ進み、最後のノーツで目標スコアになるゴーストのフレームを求める
+func CalculateGhostFrames(frames []PedFrame, target int) []PedFrame {
+	ghostFrames := make([]PedFrame, len(frames))
+	notes := len(frames) - 1
+	for i, frame := range frames {
+		// 先頭は開始時点のフレーム
+		score := 0
+		if notes > 0 {
+			score = int(float64(target) * float64(i) / float64(notes))
+		}
+		ghostFrames[i] = PedFrame{Time: frame.Time, Score: score, Judgment: JudgmentNone}
+	}
+	return ghostFrames
+}
@@ -0,0 +1,163 @@
+package pjsekaioverlay_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolustest"
+)
+
+// go test -run TestGolden -update でゴールデンファイルを書き換える
+var update = flag.Bool("update", false, "testdata/goldenのゴールデンファイルを書き換える")
+
+const goldenDir = "testdata/golden"
+
+// ゴールデンファイルの出力先の代わりに書き込む文字列
+const goldenDistPlaceholder = "{golden:dist}"
+
+// ゴールデンファイルを生成する設定
+type goldenCase struct {
+	Name  string
+	Speed float64
+	Multi []pjsekaioverlay.MultiLivePlayer
+	// 空の場合はLevelData
+	Level func() sonolus.LevelData
+}
+
+var goldenCases = []goldenCase{
+	{Name: "default", Speed: 1},
+	{Name: "speed", Speed: 1.5},
+	{Name: "multi", Speed: 1, Multi: []pjsekaioverlay.MultiLivePlayer{
+		{Name: "A", Talent: 250000},
+		{Name: "B", Talent: 300000},
+	}},
+	{Name: "hold-tail", Speed: 1, Level: sonolustest.HoldTailLevelData},
+}
+
+// テスト用の譜面から、pedファイルとexoファイルを出力する
+func writeGoldenOutputs(c goldenCase, dir string) error {
+	levelInfo := sonolustest.LevelInfo()
+	level := c.Level
+	if level == nil {
+		level = sonolustest.LevelData
+	}
+	levelData := pjsekaioverlay.ScaleLevelData(level(), c.Speed)
+	frames := pjsekaioverlay.CalculateScore(levelInfo, levelData, 250000)
+	pedOptions := pjsekaioverlay.PedOptions{
+		ComboAnimation: pjsekaioverlay.DefaultComboAnimation,
+		ScoreFormat:    pjsekaioverlay.DefaultScoreFormat,
+		PlaybackSpeed:  c.Speed,
+		OutputHash:     "golden",
+		MultiLive: pjsekaioverlay.SimulateMultiLive(levelInfo, levelData, pjsekaioverlay.DefaultScoreRules, pjsekaioverlay.MultiLiveOptions{
+			Players:  c.Multi,
+			Accuracy: 0.9,
+			Seed:     1,
+		}),
+	}
+	if err := pjsekaioverlay.WritePedFile(frames, "assets", true, filepath.Join(dir, "data.ped"), levelInfo, pedOptions); err != nil {
+		return err
+	}
+	exoOptions := pjsekaioverlay.ExoOptions{
+		CoverFormat: pjsekaioverlay.ImageFormatPng,
+		// 出力先のパスは環境ごとに変わるので置き換える
+		DistDir: goldenDistPlaceholder,
+		Finale: pjsekaioverlay.ExoFinale{
+			LastNoteTime: pjsekaioverlay.CalculateChartEnd(levelData, frames, 0),
+			Video:        pjsekaioverlay.FinaleVideo(true),
+			Speed:        c.Speed,
+		},
+	}
+	return pjsekaioverlay.WriteExoFiles("assets", dir, levelInfo.Title, levelInfo.Artists, exoOptions)
+}
+
+// 最初に異なる行を説明する（一致する場合は空）
+func diffLines(expected []byte, actual []byte) string {
+	if bytes.Equal(expected, actual) {
+		return ""
+	}
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a string
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			return fmt.Sprintf("%d行目 (line %d): %q -> %q", i+1, i+1, strings.TrimRight(e, "\r"), strings.TrimRight(a, "\r"))
+		}
+	}
+	return ""
+}
+
+// ディレクトリ内のファイルの一覧（相対パス）
+func listGoldenFiles(t *testing.T, dir string) map[string]bool {
+	names := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		names[filepath.ToSlash(name)] = true
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.Name, func(t *testing.T) {
+			expectedDir := filepath.Join(goldenDir, c.Name)
+			if *update {
+				if err := os.RemoveAll(expectedDir); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(expectedDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := writeGoldenOutputs(c, expectedDir); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			actualDir := t.TempDir()
+			if err := writeGoldenOutputs(c, actualDir); err != nil {
+				t.Fatal(err)
+			}
+			expectedNames := listGoldenFiles(t, expectedDir)
+			for name := range listGoldenFiles(t, actualDir) {
+				if !expectedNames[name] {
+					t.Errorf("%s: ゴールデンファイルがありません (No golden file)", name)
+				}
+			}
+			for name := range expectedNames {
+				expected, err := os.ReadFile(filepath.Join(expectedDir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				actual, err := os.ReadFile(filepath.Join(actualDir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Errorf("%s: 出力されませんでした (Not generated)", name)
+					continue
+				}
+				if diff := diffLines(expected, actual); diff != "" {
+					t.Errorf("%s: %s", name, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 編集時に確認するためのガイド（書き出す前に非表示にする）
+type GuideBox struct {
+	Kind string
+	// 画面に対する割合での範囲（左上が0、右下が1）
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+	// 枠ではなく、半透明で塗りつぶす（プレイヤーのUIで隠れる範囲）
+	Fill  bool
+	Color int
+}
+
+var GuideKinds = []string{"safe", "youtube", "tiktok"}
+
+var guideBoxes = map[string][]GuideBox{
+	// アクションセーフ（93%）とタイトルセーフ（90%）
+	"safe": {
+		{Left: 0.035, Top: 0.035, Right: 0.965, Bottom: 0.965, Color: 0x00ff00},
+		{Left: 0.05, Top: 0.05, Right: 0.95, Bottom: 0.95, Color: 0xffff00},
+	},
+	// 再生中に表示されるタイトルと操作バー
+	"youtube": {
+		{Left: 0, Top: 0, Right: 1, Bottom: 0.09, Fill: true, Color: 0xff0000},
+		{Left: 0, Top: 0.87, Right: 1, Bottom: 1, Fill: true, Color: 0xff0000},
+	},
+	// 横長の動画をそのまま表示した場合の、右側のボタンと下部の説明文
+	"tiktok": {
+		{Left: 0.86, Top: 0.3, Right: 1, Bottom: 0.85, Fill: true, Color: 0x25f4ee},
+		{Left: 0, Top: 0.8, Right: 0.86, Bottom: 1, Fill: true, Color: 0x25f4ee},
+	},
+}
+
+// 一番上のレイヤーに全画面で置く
+var GuidesExoObject = ExoObject{NameJP: "ガイド", NameEN: "Guides", X: 0.0, Y: 0.0, Zoom: 100}
+
+// カンマ区切りのガイドの種類から、描画する枠を求める
+func ParseGuides(value string) ([]GuideBox, error) {
+	boxes := []GuideBox{}
+	if value == "" {
+		return boxes, nil
+	}
+	for _, kind := range strings.Split(value, ",") {
+		kind = strings.TrimSpace(kind)
+		if !slices.Contains(GuideKinds, kind) {
+			return nil, fmt.Errorf("不明なガイドの種類です。(Unknown guide.) [%s] (%s)", kind, strings.Join(GuideKinds, ", "))
+		}
+		for _, box := range guideBoxes[kind] {
+			box.Kind = kind
+			boxes = append(boxes, box)
+		}
+	}
+	return boxes, nil
+}
@@ -0,0 +1,71 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 生成の履歴を保存するファイル（外部には送信しない）
+const HistoryFileName = "history.jsonl"
+
+// 1回の生成の記録
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	ChartId string    `json:"chartId"`
+	Source  string    `json:"source"`
+	Title   string    `json:"title"`
+	Rating  int       `json:"rating"`
+	OutDir  string    `json:"outDir"`
+	// コマンドラインで指定したオプション
+	Options    map[string]string `json:"options"`
+	DurationMs int64             `json:"durationMs"`
+	// 段階ごとの処理時間
+	Timings []StageTiming `json:"timings,omitempty"`
+}
+
+// 履歴のファイルに1行追記する
+func AppendHistory(path string, entry HistoryEntry) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("履歴の書き込みに失敗しました。(Failed to write history.) [%s]", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("履歴の書き込みに失敗しました。(Failed to write history.) [%s]", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("履歴の書き込みに失敗しました。(Failed to write history.) [%s]", err)
+	}
+	return nil
+}
+
+// 履歴を古い順に読み込む（ファイルがない場合は空、壊れた行は読み飛ばす）
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("履歴の読み込みに失敗しました。(Failed to read history.) [%s]", err)
+	}
+	defer file.Close()
+
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("履歴の読み込みに失敗しました。(Failed to read history.) [%s]", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,114 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+
+	// 新しい形式のジャケット・背景を読み込めるようにする
+	_ "github.com/gen2brain/avif"
+	"github.com/gen2brain/webp"
+)
+
+type ImageFormat string
+
+type FitMode string
+
+const (
+	FitModeCrop      FitMode = "crop"
+	FitModeLetterbox FitMode = "letterbox"
+	FitModeStretch   FitMode = "stretch"
+)
+
+type CoverOptions struct {
+	Format   ImageFormat
+	Size     int
+	Fit      FitMode
+	Original bool // 縮小前のジャケットもcover_originalとして保存する
+	// 指定した場合は、縮小する前に低解像度のジャケットを拡大する
+	Upscaler *CoverUpscaler
+	// 縮小した後にかける加工（cover_originalにはかけない）
+	Filters ImageFilters
+}
+
+var DefaultCoverOptions = CoverOptions{
+	Format: ImageFormatPng,
+	Size:   512,
+	Fit:    FitModeCrop,
+}
+
+const (
+	ImageFormatPng  ImageFormat = "png"
+	ImageFormatWebp ImageFormat = "webp"
+)
+
+func ParseImageFormat(format string) (ImageFormat, error) {
+	switch ImageFormat(format) {
+	case ImageFormatPng, ImageFormatWebp:
+		return ImageFormat(format), nil
+	}
+	return "", fmt.Errorf("不明な画像形式です。(Unknown image format.) [%s]", format)
+}
+
+func (format ImageFormat) FileName(name string) string {
+	return name + "." + string(format)
+}
+
+func encodeImage(writer io.Writer, img image.Image, format ImageFormat) error {
+	switch format {
+	case ImageFormatWebp:
+		return webp.Encode(writer, img, webp.Options{Lossless: true})
+	default:
+		return png.Encode(writer, img)
+	}
+}
+
+func writeImageFile(filePath string, img image.Image, format ImageFormat) error {
+	return writeImage(DirOutput(filepath.Dir(filePath)), filepath.Base(filePath), img, format)
+}
+
+func writeImage(output Output, name string, img image.Image, format ImageFormat) error {
+	return writeOutputFile(output, name, func(w io.Writer) error {
+		return encodeImage(w, img, format)
+	})
+}
+
+func ParseFitMode(mode string) (FitMode, error) {
+	switch FitMode(mode) {
+	case FitModeCrop, FitModeLetterbox, FitModeStretch:
+		return FitMode(mode), nil
+	}
+	return "", fmt.Errorf("不明なリサイズ方法です。(Unknown fit mode.) [%s]", mode)
+}
+
+// アスペクト比を保ったまま、srcがdstに収まる範囲を求める
+func containRect(src image.Rectangle, dst image.Rectangle) image.Rectangle {
+	srcRatio := float64(src.Dx()) / float64(src.Dy())
+	dstRatio := float64(dst.Dx()) / float64(dst.Dy())
+	if srcRatio > dstRatio {
+		height := int(float64(dst.Dx()) / srcRatio)
+		offset := (dst.Dy() - height) / 2
+		return image.Rect(dst.Min.X, dst.Min.Y+offset, dst.Max.X, dst.Min.Y+offset+height)
+	}
+	width := int(float64(dst.Dy()) * srcRatio)
+	offset := (dst.Dx() - width) / 2
+	return image.Rect(dst.Min.X+offset, dst.Min.Y, dst.Min.X+offset+width, dst.Max.Y)
+}
+
+func resizeImage(src image.Image, size int, fit FitMode) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	srcRect := src.Bounds()
+	dstRect := dst.Bounds()
+	switch fit {
+	case FitModeCrop:
+		srcRect = coverRect(srcRect, dstRect)
+	case FitModeLetterbox:
+		dstRect = containRect(srcRect, dstRect)
+	}
+	draw.CatmullRom.Scale(dst, dstRect, src, srcRect, draw.Over, nil)
+	return dst
+}
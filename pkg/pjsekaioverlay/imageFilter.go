@@ -0,0 +1,110 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+
+	"golang.org/x/image/draw"
+)
+
+// ジャケット・背景にかける加工（ゼロ値の項目は何もしない）
+type ImageFilters struct {
+	// ぼかしの強さ（縮小する倍率）
+	Blur int `json:"blur"`
+	// 明るさの倍率（0の場合は変えない）
+	Brightness float64 `json:"brightness"`
+	// 周辺減光の強さ（0〜1）
+	Vignette float64 `json:"vignette"`
+	// 角丸の半径（出力する画像のピクセル）
+	CornerRadius float64 `json:"cornerRadius"`
+}
+
+// --image-filtersで指定するJSON
+//
+// 例：{"cover": {"cornerRadius": 48}, "background": {"blur": 16, "brightness": 0.8, "vignette": 0.5}}
+type ImageFilterConfig struct {
+	Cover      ImageFilters `json:"cover"`
+	Background ImageFilters `json:"background"`
+}
+
+func LoadImageFilterConfig(path string) (ImageFilterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ImageFilterConfig{}, fmt.Errorf("画像の加工の設定の読み込みに失敗しました。(Loading image filter config failed.) [%s]", err)
+	}
+	config := ImageFilterConfig{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ImageFilterConfig{}, fmt.Errorf("画像の加工の設定の読み込みに失敗しました。(Loading image filter config failed.) [%s]", err)
+	}
+	for name, filters := range map[string]ImageFilters{"cover": config.Cover, "background": config.Background} {
+		if err := filters.Validate(); err != nil {
+			return ImageFilterConfig{}, fmt.Errorf("%s [%s]", err, name)
+		}
+	}
+	return config, nil
+}
+
+func (filters ImageFilters) Validate() error {
+	if filters.Blur < 0 || filters.Brightness < 0 || filters.CornerRadius < 0 || filters.Vignette < 0 || filters.Vignette > 1 {
+		return fmt.Errorf("画像の加工の値が不正です。(Invalid image filter value.) [%+v]", filters)
+	}
+	return nil
+}
+
+func (filters ImageFilters) IsZero() bool {
+	return filters == ImageFilters{}
+}
+
+// ぼかし・明るさ・周辺減光・角丸の順に加工する
+func (filters ImageFilters) Apply(src image.Image) image.Image {
+	if filters.IsZero() {
+		return src
+	}
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if filters.Blur > 1 {
+		blurImage(dst, src, bounds, filters.Blur)
+	} else {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	width, height := float64(dst.Bounds().Dx()), float64(dst.Bounds().Dy())
+	radius := min(filters.CornerRadius, width/2, height/2)
+	for y := 0; y < dst.Bounds().Dy(); y++ {
+		for x := 0; x < dst.Bounds().Dx(); x++ {
+			pixel := color.NRGBAModel.Convert(dst.RGBAAt(x, y)).(color.NRGBA)
+			scale := 1.0
+			if filters.Brightness > 0 {
+				scale *= filters.Brightness
+			}
+			if filters.Vignette > 0 {
+				// 中心からの距離（角で1）に応じて暗くする
+				dx, dy := (float64(x)+0.5)/width*2-1, (float64(y)+0.5)/height*2-1
+				scale *= 1 - filters.Vignette*min((dx*dx+dy*dy)/2, 1)
+			}
+			alpha := float64(pixel.A)
+			if radius > 0 {
+				alpha *= cornerCoverage(float64(x)+0.5, float64(y)+0.5, width, height, radius)
+			}
+			dst.Set(x, y, color.NRGBA{
+				R: uint8(min(float64(pixel.R)*scale, 255)),
+				G: uint8(min(float64(pixel.G)*scale, 255)),
+				B: uint8(min(float64(pixel.B)*scale, 255)),
+				A: uint8(alpha),
+			})
+		}
+	}
+	return dst
+}
+
+// 角丸の内側にある割合（縁の1ピクセルでなめらかにする）
+func cornerCoverage(x float64, y float64, width float64, height float64, radius float64) float64 {
+	cx := min(max(x, radius), width-radius)
+	cy := min(max(y, radius), height-radius)
+	distance := math.Hypot(x-cx, y-cy)
+	return min(max(radius-distance+0.5, 0), 1)
+}
@@ -20,7 +20,8 @@ var sekaiObj []byte
 //go:embed sekai-en.obj
 var sekaiObjEn []byte
 
-func TryInstallObject() bool {
+// 起動中のAviUtlから拡張編集のディレクトリを探す（見つからない場合は空）
+func FindExeditRoot() string {
 	processes, _ := wapi.ProcessList()
 	var aviutlProcess *so.Process
 	for _, process := range processes {
@@ -30,16 +31,21 @@ func TryInstallObject() bool {
 		}
 	}
 	if aviutlProcess == nil {
-		return false
+		return ""
 	}
 	var aviutlPath string
 	aviutlPath = filepath.Dir(aviutlProcess.Fullpath)
-	var exeditRoot string
 	if _, err := os.Stat(filepath.Join(aviutlPath, "exedit.auf")); err == nil {
-		exeditRoot = filepath.Join(aviutlPath)
+		return filepath.Join(aviutlPath)
 	} else if _, err := os.Stat(filepath.Join(aviutlPath, "Plugins", "exedit.auf")); err == nil {
-		exeditRoot = filepath.Join(aviutlPath, "Plugins")
-	} else {
+		return filepath.Join(aviutlPath, "Plugins")
+	}
+	return ""
+}
+
+func TryInstallObject() bool {
+	exeditRoot := FindExeditRoot()
+	if exeditRoot == "" {
 		return false
 	}
 
@@ -0,0 +1,197 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// オーバーレイの文言の言語
+type LabelLanguage string
+
+const (
+	LabelLanguageJa     LabelLanguage = "ja"
+	LabelLanguageEn     LabelLanguage = "en"
+	LabelLanguageZhHans LabelLanguage = "zh-hans"
+	LabelLanguageZhHant LabelLanguage = "zh-hant"
+	LabelLanguageKo     LabelLanguage = "ko"
+)
+
+// 言語ごとの文言と、その文字を含むフォント
+type OverlayLabels struct {
+	// 説明文の書式（%[1]s：作曲、%[2]s：ボーカル、%[3]s：譜面作成者）
+	Artists string
+	// 動画の作成者の書式
+	Video string
+	// コンボの見出し（空の場合はテンプレートの画像を使う）
+	Combo string
+	// テキストオブジェクトのフォント名（空の場合はテンプレートのまま）
+	FontName string
+	// 見出しの画像に使う、Windowsのフォントファイルの候補
+	FontFiles []string
+}
+
+var overlayLabels = map[LabelLanguage]OverlayLabels{
+	LabelLanguageJa: {
+		Artists: "作詞：？    作曲：%[1]s    編曲：？\r\nVo：%[2]s   譜面作成：%[3]s",
+		Video:   "動画：%s",
+	},
+	LabelLanguageEn: {
+		Artists: "Lyrics: ?    Music: %[1]s    Arrangement: ?\r\nVo: %[2]s   Chart: %[3]s",
+		Video:   "Video: %s",
+	},
+	LabelLanguageZhHans: {
+		Artists:   "作词：？    作曲：%[1]s    编曲：？\r\n演唱：%[2]s   谱面：%[3]s",
+		Video:     "视频：%s",
+		Combo:     "连击",
+		FontName:  "Microsoft YaHei",
+		FontFiles: []string{"msyhbd.ttc", "msyh.ttc", "simhei.ttf"},
+	},
+	LabelLanguageZhHant: {
+		Artists:   "作詞：？    作曲：%[1]s    編曲：？\r\n演唱：%[2]s   譜面：%[3]s",
+		Video:     "影片：%s",
+		Combo:     "連擊",
+		FontName:  "Microsoft JhengHei",
+		FontFiles: []string{"msjhbd.ttc", "msjh.ttc", "mingliu.ttc"},
+	},
+	LabelLanguageKo: {
+		Artists:   "작사: ?    작곡: %[1]s    편곡: ?\r\n보컬: %[2]s   채보: %[3]s",
+		Video:     "영상: %s",
+		Combo:     "콤보",
+		FontName:  "Malgun Gothic",
+		FontFiles: []string{"malgunbd.ttf", "malgun.ttf"},
+	},
+}
+
+func ParseLabelLanguage(language string) (LabelLanguage, error) {
+	if _, ok := overlayLabels[LabelLanguage(language)]; ok {
+		return LabelLanguage(language), nil
+	}
+	return "", fmt.Errorf("不明な言語です。(Unknown language.) [%s]", language)
+}
+
+// 空の場合は日本語
+func (language LabelLanguage) Labels() OverlayLabels {
+	if labels, ok := overlayLabels[language]; ok {
+		return labels
+	}
+	return overlayLabels[LabelLanguageJa]
+}
+
+// ハングルを含むか（テンプレートのフォントや中国語のフォントには含まれない）
+func containsHangul(text string) bool {
+	for _, r := range text {
+		if r >= 0x1100 && r <= 0x11ff || r >= 0x3130 && r <= 0x318f || r >= 0xac00 && r <= 0xd7af {
+			return true
+		}
+	}
+	return false
+}
+
+// テキストオブジェクトに使うフォント名。言語のフォントに含まれない文字がある場合は、含むフォントにする
+func (language LabelLanguage) TextFont(texts ...string) string {
+	if language != LabelLanguageKo && containsHangul(strings.Join(texts, "")) {
+		return overlayLabels[LabelLanguageKo].FontName
+	}
+	return language.Labels().FontName
+}
+
+// 候補のうち、文言の文字を全て含む最初のフォントを読み込む
+func chooseLabelFont(fontPaths []string, text string) (*sfnt.Font, error) {
+	for _, fontPath := range fontPaths {
+		font, err := loadFontFile(fontPath)
+		if err != nil {
+			continue
+		}
+		if len(missingGlyphs(font, text)) == 0 {
+			return font, nil
+		}
+	}
+	return nil, fmt.Errorf("文言の文字を含むフォントが見つかりませんでした。--fontで指定して下さい。(No font contains the label text. Please specify one with --font.) [%s]", text)
+}
+
+const (
+	labelFontSize = 34
+	labelHeight   = 45
+	labelOutline  = 4
+)
+
+// 縁取りした文言の画像
+func renderLabel(face font.Face, text string, outline color.Color) *image.NRGBA {
+	drawer := font.Drawer{Face: face}
+	width := drawer.MeasureString(text).Ceil() + labelOutline*2 + 2
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, labelHeight))
+	metrics := face.Metrics()
+	baseline := (labelHeight + metrics.Ascent.Ceil() - metrics.Descent.Ceil()) / 2
+	drawer.Dst = canvas
+	// 縁取りは周りにずらして重ねて描く
+	drawer.Src = image.NewUniform(outline)
+	for dy := -labelOutline; dy <= labelOutline; dy++ {
+		for dx := -labelOutline; dx <= labelOutline; dx++ {
+			if dx*dx+dy*dy > labelOutline*labelOutline {
+				continue
+			}
+			drawer.Dot = fixed.P(labelOutline+1+dx, baseline+dy)
+			drawer.DrawString(text)
+		}
+	}
+	drawer.Src = image.White
+	drawer.Dot = fixed.P(labelOutline+1, baseline)
+	drawer.DrawString(text)
+	return canvas
+}
+
+// コンボの見出しの画像（nt.png、AP用のpt.png）をlabels/comboに書き込み、labelsのパスを返す。見出しがない言語では空を返す
+//
+// fontPathsのフォントに文言の文字がない場合は、言語ごとのWindowsのフォントを使う
+func WriteLabelAssets(fontPaths []string, destPath string, language LabelLanguage) (string, error) {
+	labels := language.Labels()
+	if labels.Combo == "" {
+		return "", nil
+	}
+	candidates := append([]string{}, fontPaths...)
+	for _, name := range labels.FontFiles {
+		candidates = append(candidates, filepath.Join(os.Getenv("WINDIR"), "Fonts", name))
+	}
+	labelFont, err := chooseLabelFont(candidates, labels.Combo)
+	if err != nil {
+		return "", err
+	}
+	face, err := opentype.NewFace(labelFont, &opentype.FaceOptions{Size: labelFontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return "", fmt.Errorf("フォントの読み込みに失敗しました。(Loading font failed.) [%s]", err)
+	}
+	defer face.Close()
+
+	labelsDir := filepath.Join(destPath, "labels")
+	output := DirOutput(filepath.Join(labelsDir, "combo"))
+	// テンプレートの画像に近い色（通常は紫、APはピンク）
+	for name, outline := range map[string]color.Color{
+		"nt.png": color.NRGBA{0xb0, 0x88, 0xe8, 0xff},
+		"pt.png": color.NRGBA{0xf0, 0x78, 0xc8, 0xff},
+	} {
+		img := renderLabel(face, labels.Combo, outline)
+		if err := writeImage(output, name, img, ImageFormatPng); err != nil {
+			return "", err
+		}
+	}
+	return labelsDir, nil
+}
+
+// 説明文に使う作詞・作曲などの表記を、言語に合わせて作る
+func FormatExoArtistsIn(language LabelLanguage, source Source, level sonolus.LevelInfo) string {
+	composerAndVocals := []string{level.Artists, "？"}
+	if separateAttempt := strings.Split(level.Artists, " / "); source.Id == "chart_cyanvas" && len(separateAttempt) == 2 {
+		composerAndVocals = separateAttempt
+	}
+	return fmt.Sprintf(language.Labels().Artists, composerAndVocals[0], composerAndVocals[1], level.Author)
+}
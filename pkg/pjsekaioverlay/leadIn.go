@@ -0,0 +1,19 @@
+package pjsekaioverlay
+
+import "math"
+
+// 譜面の時間を一定の秒数だけずらす
+type TimeOffset float64
+
+func (offset TimeOffset) MapTime(time float64) float64 {
+	return time + float64(offset)
+}
+
+// テンプレートでの、再生開始から譜面の0秒までの長さ（秒）
+const DefaultLeadIn = float64(exoRootOffset) / exoFrameRate
+
+// 再生開始から最初のノーツまでをleadIn秒にするためのずれ。フレーム単位に丸める
+func LeadInOffset(leadIn float64, firstNoteTime float64) TimeOffset {
+	frames := math.Round(leadIn*exoFrameRate) - math.Round((DefaultLeadIn+firstNoteTime)*exoFrameRate)
+	return TimeOffset(frames / exoFrameRate)
+}
@@ -0,0 +1,66 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// ローカルの譜面データ（LevelData、gzip圧縮済みでも可）を読み込む（mappingはnilでも可）
+func LoadLevelFile(levelPath string, mapping ArchetypeMapping) (sonolus.LevelData, error) {
+	switch strings.ToLower(filepath.Ext(levelPath)) {
+	case ".sus", ".usc":
+		return sonolus.LevelData{}, fmt.Errorf("SUS/USC形式には対応していません。Sonolusの譜面データを指定して下さい。(SUS/USC files are not supported. Please specify Sonolus level data.) [%s]", levelPath)
+	}
+
+	file, err := os.Open(levelPath)
+	if err != nil {
+		return sonolus.LevelData{}, fmt.Errorf("譜面データの読み込みに失敗しました。(Loading chart data failed.) [%s]", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var source io.Reader = reader
+	// gzipのマジックナンバー
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return sonolus.LevelData{}, fmt.Errorf("譜面データの読み込みに失敗しました。(Loading chart data failed.) [%s]", err)
+		}
+		defer gzipReader.Close()
+		source = gzipReader
+	}
+
+	data, err := decodeLevelData(source, mapping)
+	if err != nil {
+		return sonolus.LevelData{}, fmt.Errorf("譜面データの読み込みに失敗しました。(Loading chart data failed.) [%s]", err)
+	}
+	return data, nil
+}
+
+// ファイルの更新を監視し、保存されるたびにonChangeを呼ぶ（onChangeがエラーを返しても監視は続ける）
+func WatchFile(watchPath string, interval time.Duration, onChange func() error, onError func(error)) {
+	lastModified := time.Time{}
+	if info, err := os.Stat(watchPath); err == nil {
+		lastModified = info.ModTime()
+	}
+	for {
+		time.Sleep(interval)
+		info, err := os.Stat(watchPath)
+		// 保存中は一時的にファイルが存在しないことがある
+		if err != nil || !info.ModTime().After(lastModified) {
+			continue
+		}
+		lastModified = info.ModTime()
+		if err := onChange(); err != nil {
+			onError(err)
+		}
+	}
+}
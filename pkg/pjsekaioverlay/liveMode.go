@@ -0,0 +1,41 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ライブの種類ごとの表示
+//
+// スコアの計算式はどの種類でも通常のライブと同じで、チャレンジライブは総合力（1人分のデッキ）だけが異なる
+type LiveMode struct {
+	Name string
+	// 枠とラベルの色（チアフルカーニバルではチームカラー）
+	Color int
+}
+
+var LiveModes = []string{"normal", "challenge", "cheerful"}
+
+var liveModeColors = map[string]int{
+	"normal":    0xffffff,
+	"challenge": 0xffcc33,
+	"cheerful":  0xff5a78,
+}
+
+// teamColorは「ff5a78」のような16進数（空の場合は既定の色）
+func ParseLiveMode(name string, teamColor string) (LiveMode, error) {
+	if !slices.Contains(LiveModes, name) {
+		return LiveMode{}, fmt.Errorf("不明なライブの種類です。(Unknown live mode.) [%s] (%s)", name, strings.Join(LiveModes, ", "))
+	}
+	mode := LiveMode{Name: name, Color: liveModeColors[name]}
+	if teamColor != "" {
+		color, err := strconv.ParseUint(strings.TrimPrefix(teamColor, "#"), 16, 24)
+		if err != nil {
+			return LiveMode{}, fmt.Errorf("チームカラーの形式が不正です。(Invalid team color.) [%s]", teamColor)
+		}
+		mode.Color = int(color)
+	}
+	return mode, nil
+}
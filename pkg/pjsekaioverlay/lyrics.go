@@ -0,0 +1,207 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 歌詞の1行
+type LyricLine struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// 最後の行の表示時間（秒）
+const lastLyricDuration = 5
+
+// LRC・SRTファイルを読み込む（拡張子で判別する）
+func LoadLyrics(path string) ([]LyricLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("歌詞の読み込みに失敗しました。(Loading lyrics failed.) [%s]", err)
+	}
+	defer file.Close()
+
+	var lines []LyricLine
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".lrc":
+		lines, err = parseLrc(bufio.NewScanner(file))
+	case ".srt":
+		lines, err = parseSrt(bufio.NewScanner(file))
+	default:
+		return nil, fmt.Errorf("対応していない歌詞の形式です。(Unsupported lyrics format.) [%s]", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("歌詞の読み込みに失敗しました。(Loading lyrics failed.) [%s]", err)
+	}
+	for _, line := range lines {
+		// exoには改行をCRLFにして書き込む
+		if err := validateExoText("歌詞 (Lyrics)", exoLyricText(line)); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+var lrcTimePattern = regexp.MustCompile(`^\[([0-9]+):([0-9]+(?:\.[0-9]+)?)\]`)
+var lrcOffsetPattern = regexp.MustCompile(`^\[offset:\s*([+-]?[0-9]+)\]`)
+
+func parseLrc(scanner *bufio.Scanner) ([]LyricLine, error) {
+	lines := []LyricLine{}
+	offset := 0.0
+	for scanner.Scan() {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if match := lrcOffsetPattern.FindStringSubmatch(text); match != nil {
+			// 正の値で歌詞が早く表示される
+			milliseconds, _ := strconv.Atoi(match[1])
+			offset = -float64(milliseconds) / 1000
+			continue
+		}
+		// 「[00:12.34][01:23.45]歌詞」のように複数の時間を持つ行がある
+		starts := []float64{}
+		for {
+			match := lrcTimePattern.FindStringSubmatch(text)
+			if match == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(match[1])
+			seconds, _ := strconv.ParseFloat(match[2], 64)
+			starts = append(starts, float64(minutes)*60+seconds)
+			text = text[len(match[0]):]
+		}
+		for _, start := range starts {
+			lines = append(lines, LyricLine{Start: start + offset, Text: strings.TrimSpace(text)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Start < lines[j].Start
+	})
+	// 次の行まで表示し、空の行は表示を消すためだけに使う
+	result := []LyricLine{}
+	for i, line := range lines {
+		line.End = line.Start + lastLyricDuration
+		if i+1 < len(lines) {
+			line.End = lines[i+1].Start
+		}
+		if line.Text != "" && line.End > line.Start {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+var srtTimePattern = regexp.MustCompile(`^([0-9]+):([0-9]+):([0-9]+)[,.]([0-9]+)\s*-->\s*([0-9]+):([0-9]+):([0-9]+)[,.]([0-9]+)`)
+
+func parseSrtTime(match []string) float64 {
+	hours, _ := strconv.Atoi(match[0])
+	minutes, _ := strconv.Atoi(match[1])
+	seconds, _ := strconv.Atoi(match[2])
+	milliseconds, _ := strconv.Atoi(match[3])
+	return float64(hours*3600+minutes*60+seconds) + float64(milliseconds)/math.Pow10(len(match[3]))
+}
+
+func parseSrt(scanner *bufio.Scanner) ([]LyricLine, error) {
+	lines := []LyricLine{}
+	var current *LyricLine
+	for scanner.Scan() {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if match := srtTimePattern.FindStringSubmatch(text); match != nil {
+			lines = append(lines, LyricLine{Start: parseSrtTime(match[1:5]), End: parseSrtTime(match[5:9])})
+			current = &lines[len(lines)-1]
+			continue
+		}
+		if text == "" {
+			current = nil
+			continue
+		}
+		// 番号の行は読み飛ばす
+		if current == nil {
+			continue
+		}
+		if current.Text != "" {
+			current.Text += "\n"
+		}
+		current.Text += text
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Start < lines[j].Start
+	})
+	return lines, nil
+}
+
+// 再生速度・区間・時間の変換を歌詞の時間にも適用する。区間外の行は取り除く
+func AdjustLyrics(lines []LyricLine, speed float64, timeRange TimeRange, mapper TimeMapper) []LyricLine {
+	adjusted := []LyricLine{}
+	for _, line := range lines {
+		line.Start = line.Start/speed - timeRange.From
+		line.End = line.End/speed - timeRange.From
+		if line.End <= 0 || (timeRange.To > 0 && line.Start >= timeRange.To-timeRange.From) {
+			continue
+		}
+		line.Start = MapTime(mapper, math.Max(line.Start, 0))
+		line.End = MapTime(mapper, line.End)
+		adjusted = append(adjusted, line)
+	}
+	return adjusted
+}
+
+// exoのタイムライン上の開始・終了フレーム
+func lyricFrames(line LyricLine) (int, int) {
+	return noteFrame(line.Start) + 1, noteFrame(line.End)
+}
+
+// 歌詞のテキストオブジェクトを追加する（ゲーム内のMVのように、画面下部の中央に縁取り文字で表示する）
+func exoLyricText(line LyricLine) string {
+	return strings.ReplaceAll(line.Text, "\n", "\r\n")
+}
+
+func appendExoLyrics(exo string, variant exoVariant, lines []LyricLine, font string) (string, error) {
+	index := maxExoNumber(exo, exoIndexPattern)
+	layer := maxExoNumber(exo, exoLayerPattern)
+	if font == "" {
+		font = "FOT-ロダンNTLG Pro DB"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(strings.TrimRight(exo, "\n"))
+	builder.WriteString("\n")
+	for i, line := range lines {
+		start, end := lyricFrames(line)
+		if end < start {
+			continue
+		}
+		index++
+		text, size, displaySpeed, oneCharOneObject, motionCoordinate, autoScroll, standardDrawing, zoom, clearness, rotation :=
+			"テキスト", "サイズ", "表示速度", "文字毎に個別オブジェクト", "移動座標上に表示する", "自動スクロール", "標準描画", "拡大率", "透明度", "回転"
+		if variant.english {
+			text, size, displaySpeed, oneCharOneObject, motionCoordinate, autoScroll, standardDrawing, zoom, clearness, rotation =
+				"Text", "Size", "vDisplay", "1char1obj", "Show on motion coordinate", "Automatic scrolling", "Standard drawing", "Zoom%", "Clearness", "Rotation"
+		}
+		encoded, err := encodeString("歌詞 (Lyrics)", exoLyricText(line))
+		if err != nil {
+			return "", err
+		}
+		// 行が重なる場合に備えて2つのレイヤーを交互に使う
+		fmt.Fprintf(&builder, "[%d]\nstart=%d\nend=%d\nlayer=%d\noverlay=1\ncamera=0\n", index, start, end, layer+1+i%2)
+		fmt.Fprintf(&builder, "[%d.0]\n_name=%s\n%s=36\n%s=0.0\n%s=0\n%s=0\n%s=0\nB=0\nI=0\ntype=3\nautoadjust=0\nsoft=1\nmonospace=0\nalign=4\nspacing_x=0\nspacing_y=8\nprecision=1\ncolor=ffffff\ncolor2=404060\nfont=%s\ntext=%s\n",
+			index, text, size, displaySpeed, oneCharOneObject, motionCoordinate, autoScroll, font, encoded)
+		fmt.Fprintf(&builder, "[%d.1]\n_name=%s\nX=0.0\nY=%.1f\nZ=0.0\n%s=%.2f\n%s=0.0\n%s=0.00\nblend=0\n",
+			index, standardDrawing, 420*variant.scale, zoom, 150*variant.scale, clearness, rotation)
+	}
+	return builder.String(), nil
+}
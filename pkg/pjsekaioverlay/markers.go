@@ -0,0 +1,116 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+type MarkerType string
+
+const (
+	MarkerTypeBpmChange  MarkerType = "bpm_change"
+	MarkerTypeSkill      MarkerType = "skill"
+	MarkerTypeFeverStart MarkerType = "fever_start"
+	MarkerTypeFeverEnd   MarkerType = "fever_end"
+	MarkerTypeChartEnd   MarkerType = "chart_end"
+)
+
+// 最後のチャプターの長さ（秒）
+const markerChapterMinLength = 5.0
+
+type Marker struct {
+	Type  MarkerType `json:"type"`
+	Label string     `json:"label"`
+	// 譜面上の時間（秒）
+	Time float64 `json:"time"`
+	// exoのタイムライン上のフレーム
+	Frame int `json:"frame"`
+}
+
+// スキル・フィーバーを表すアーキタイプ（エンジンが出力する場合のみ）
+var markerArchetypes = map[string]MarkerType{
+	"Skill":       MarkerTypeSkill,
+	"FeverChance": MarkerTypeFeverStart,
+	"FeverStart":  MarkerTypeFeverStart,
+	"FeverEnd":    MarkerTypeFeverEnd,
+}
+
+func newMarker(markerType MarkerType, label string, time float64) Marker {
+	return Marker{
+		Type:  markerType,
+		Label: label,
+		Time:  time,
+		Frame: noteFrame(time),
+	}
+}
+
+// BPM変化、スキル、フィーバーの開始・終了のタイミングを求める
+func CalculateMarkers(levelData sonolus.LevelData, frames []PedFrame) []Marker {
+	markers := []Marker{}
+	bpmChanges := GetBpmChanges(levelData)
+	for _, bpmChange := range bpmChanges {
+		markers = append(markers, newMarker(
+			MarkerTypeBpmChange,
+			fmt.Sprintf("BPM %g", bpmChange.Bpm),
+			getTimeFromBpmChanges(bpmChanges, bpmChange.Beat)+levelData.BgmOffset,
+		))
+	}
+	for _, entity := range levelData.Entities {
+		markerType, ok := markerArchetypes[entity.Archetype]
+		if !ok {
+			continue
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		markers = append(markers, newMarker(markerType, entity.Archetype, getTimeFromBpmChanges(bpmChanges, beat)+levelData.BgmOffset))
+	}
+	if len(frames) > 0 {
+		markers = append(markers, newMarker(MarkerTypeChartEnd, "End", frames[len(frames)-1].Time))
+	}
+	// 区間を指定した場合、区間外のマーカーは使わない
+	markers = slices.DeleteFunc(markers, func(marker Marker) bool {
+		return marker.Time < 0 || (len(frames) > 0 && marker.Time > frames[len(frames)-1].Time)
+	})
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].Time < markers[j].Time
+	})
+	return markers
+}
+
+// markers.jsonと、動画編集ソフトで読み込めるチャプター（FFMETADATA形式）を出力する
+func WriteMarkers(markers []Marker, destDir string) error {
+	data, err := json.MarshalIndent(markers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("マーカーの生成に失敗しました。(Failed to generate markers.) [%s]", err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "markers.json"), data, 0644); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(";FFMETADATA1\n")
+	for i, marker := range markers {
+		start := int(float64(marker.Frame) * 1000 / exoFrameRate)
+		end := start + int(markerChapterMinLength*1000)
+		if i+1 < len(markers) {
+			end = int(float64(markers[i+1].Frame) * 1000 / exoFrameRate)
+		}
+		if end <= start {
+			continue
+		}
+		fmt.Fprintf(&builder, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", start, end, marker.Label)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "chapters.txt"), []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
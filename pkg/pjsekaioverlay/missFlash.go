@@ -0,0 +1,65 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// ミスしたノーツ（画面の端の赤い点滅と、切れたコンボ数の揺れを表示する）
+type Miss struct {
+	Time float64
+	// 切れる直前に表示していたコンボ数
+	Combo int
+}
+
+var MissFlashExoObject = ExoObject{NameJP: "ミス演出", NameEN: "MissFlash", X: 0.0, Y: 0.0, Zoom: 100}
+
+// カンマ区切りの秒数を読み込む
+func ParseMissTimes(value string) ([]float64, error) {
+	times := []float64{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		time, err := strconv.ParseFloat(part, 64)
+		if err != nil || time < 0 {
+			return nil, fmt.Errorf("ミスの時間の形式が不正です。(Invalid miss time.) [%s] (12.5,40)", part)
+		}
+		times = append(times, time)
+	}
+	sort.Float64s(times)
+	return times, nil
+}
+
+// 指定した時間ごとに、その時間以降の最初のノーツをMISSとしてスコアを計算する（他はPERFECT）
+func CalculateScoreWithMisses(levelInfo sonolus.LevelInfo, levelData sonolus.LevelData, power int, rules ScoreRules, missTimes []float64) []PedFrame {
+	next := 0
+	return simulateScore(levelInfo, levelData, power, rules, func(time float64) Judgment {
+		if next >= len(missTimes) || time < missTimes[next] {
+			return JudgmentPerfect
+		}
+		// 同じノーツより前の時間はまとめて1回のミスにする
+		for next < len(missTimes) && missTimes[next] <= time {
+			next++
+		}
+		return JudgmentMiss
+	})
+}
+
+// MISSのフレームから演出を求める
+func CalculateMisses(frames []PedFrame, counter ComboCounter) []Miss {
+	misses := []Miss{}
+	combos := counter.Combos(frames)
+	for i := 1; i < len(frames); i++ {
+		if frames[i].Judgment != JudgmentMiss {
+			continue
+		}
+		misses = append(misses, Miss{Time: frames[i].Time, Combo: combos[i-1]})
+	}
+	return misses
+}
@@ -0,0 +1,92 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// マルチライブの部屋の最大人数
+const maxMultiLivePlayers = 5
+
+// マルチライブで一緒にプレイする、シミュレーションする参加者
+type MultiLivePlayer struct {
+	Name string
+	// 総合力
+	Talent int
+}
+
+type MultiLiveOptions struct {
+	Players []MultiLivePlayer
+	// PERFECTになる確率（それ以外はGREAT）
+	Accuracy float64
+	Seed     uint64
+}
+
+type MultiLiveScore struct {
+	Player MultiLivePlayer
+	Frames []PedFrame
+}
+
+var MultiLiveExoObject = ExoObject{NameJP: "マルチライブ", NameEN: "MultiLive", X: 760.0, Y: -250.0, Zoom: 100}
+
+// 「名前:総合力」（名前は省略可）のカンマ区切りを読み込む
+func ParseMultiLivePlayers(value string) ([]MultiLivePlayer, error) {
+	players := []MultiLivePlayer{}
+	if value == "" {
+		return players, nil
+	}
+	for i, entry := range strings.Split(value, ",") {
+		name, talentStr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			name, talentStr = fmt.Sprintf("Player %d", i+1), name
+		}
+		talent, err := strconv.Atoi(talentStr)
+		if err != nil || talent <= 0 {
+			return nil, fmt.Errorf("参加者の総合力が不正です。(Invalid player talent.) [%s]", entry)
+		}
+		// pedファイルの区切り文字は使えない
+		name = strings.NewReplacer(":", " ", "|", " ").Replace(name)
+		players = append(players, MultiLivePlayer{Name: name, Talent: talent})
+	}
+	if len(players) > maxMultiLivePlayers {
+		return nil, fmt.Errorf("参加者が多すぎます。(Too many players.) [%d/%d]", len(players), maxMultiLivePlayers)
+	}
+	return players, nil
+}
+
+func (options MultiLiveOptions) Validate() error {
+	if options.Accuracy < 0 || options.Accuracy > 1 {
+		return fmt.Errorf("PERFECTの確率が不正です。(Invalid accuracy.) [%f]", options.Accuracy)
+	}
+	return nil
+}
+
+// 参加者ごとに、ランダムな判定でスコアをシミュレーションする（同じシードなら同じ結果になる）
+func SimulateMultiLive(levelInfo sonolus.LevelInfo, levelData sonolus.LevelData, rules ScoreRules, options MultiLiveOptions) []MultiLiveScore {
+	scores := make([]MultiLiveScore, 0, len(options.Players))
+	for i, player := range options.Players {
+		random := rand.New(rand.NewPCG(options.Seed, uint64(i)))
+		frames := simulateScore(levelInfo, levelData, player.Talent, rules, func(time float64) Judgment {
+			if random.Float64() < options.Accuracy {
+				return JudgmentPerfect
+			}
+			return JudgmentGreat
+		})
+		scores = append(scores, MultiLiveScore{Player: player, Frames: frames})
+	}
+	return scores
+}
+
+// 参加者ごとのスコアを区間に合わせて切り出す
+func TrimMultiLive(scores []MultiLiveScore, timeRange TimeRange) []MultiLiveScore {
+	trimmed := make([]MultiLiveScore, 0, len(scores))
+	for _, score := range scores {
+		frames, _ := TrimFrames(score.Frames, timeRange)
+		trimmed = append(trimmed, MultiLiveScore{Player: score.Player, Frames: frames})
+	}
+	return trimmed
+}
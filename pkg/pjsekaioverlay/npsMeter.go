@@ -0,0 +1,35 @@
+package pjsekaioverlay
+
+import (
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 拍の時点での、直前の区間のノーツ密度
+type NpsSample struct {
+	Time float64
+	Nps  float64
+}
+
+// 小節の表示の左に置く
+var NpsMeterExoObject = ExoObject{NameJP: "NPS", NameEN: "NPS", X: -760.0, Y: 380.0, Zoom: 100}
+
+// 拍ごとに、直前window秒のノーツ数から1秒あたりのノーツ数を求める（windowが0以下の場合は無効）
+func CalculateNpsMeter(levelData sonolus.LevelData, frames []PedFrame, window float64) []NpsSample {
+	samples := []NpsSample{}
+	if window <= 0 || len(frames) < 2 {
+		return samples
+	}
+	// 先頭は開始時点のフレームなので含めない
+	times := make([]float64, 0, len(frames)-1)
+	for _, frame := range frames[1:] {
+		times = append(times, frame.Time)
+	}
+	for _, position := range CalculateMeasures(levelData, frames, 4) {
+		from := sort.SearchFloat64s(times, position.Time-window)
+		to := sort.Search(len(times), func(i int) bool { return times[i] > position.Time })
+		samples = append(samples, NpsSample{Time: position.Time, Nps: float64(to-from) / window})
+	}
+	return samples
+}
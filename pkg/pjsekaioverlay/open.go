@@ -0,0 +1,16 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// 指定した種類のexoについて、WriteExoFilesが出力するファイルのパス
+func ExoFilePath(outDir string, variantName string) (string, error) {
+	for _, variant := range exoVariants {
+		if exoVariantName(variant) == variantName {
+			return filepath.Join(outDir, variant.fileName), nil
+		}
+	}
+	return "", fmt.Errorf("不明なexoの種類です。(Unknown exo variant.) [%s]", variantName)
+}
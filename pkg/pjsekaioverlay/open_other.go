@@ -0,0 +1,27 @@
+//go:build !windows
+
+package pjsekaioverlay
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Finderやファイルマネージャーでディレクトリを開く
+func OpenDirectory(dir string) error {
+	command := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		command = "open"
+	}
+	if err := exec.Command(command, dir).Start(); err != nil {
+		return fmt.Errorf("出力先ディレクトリを開けませんでした。(Could not open the output directory.) [%s]", err)
+	}
+	return nil
+}
+
+// AviUtlはWindowsでしか動かない
+func LaunchAviUtl(aviutlPath string, exoPath string) error {
+	return errors.New("AviUtlの起動はWindowsでのみ使えます。(Launching AviUtl is only supported on Windows.)")
+}
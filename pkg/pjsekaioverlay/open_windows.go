@@ -0,0 +1,31 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// エクスプローラーでディレクトリを開く
+func OpenDirectory(dir string) error {
+	// explorer.exeは成功しても終了コードが0にならないので、終了を待たない
+	if err := exec.Command("explorer.exe", dir).Start(); err != nil {
+		return fmt.Errorf("出力先ディレクトリを開けませんでした。(Could not open the output directory.) [%s]", err)
+	}
+	return nil
+}
+
+// exoファイルを指定してAviUtlを起動する（拡張編集のFile Readerで新しいプロジェクトとして開かれる）
+func LaunchAviUtl(aviutlPath string, exoPath string) error {
+	if _, err := os.Stat(aviutlPath); err != nil {
+		return fmt.Errorf("AviUtlが見つかりませんでした。(AviUtl not found.) [%s]", err)
+	}
+	cmd := exec.Command(aviutlPath, exoPath)
+	// プラグインはaviutl.exeの場所から読み込まれる
+	cmd.Dir = filepath.Dir(aviutlPath)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("AviUtlを起動できませんでした。(Could not launch AviUtl.) [%s]", err)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,148 @@
+package pjsekaioverlay
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// ファイル名に使えない文字
+var fileNameReplacer = strings.NewReplacer(
+	"<", "_", ">", "_", ":", "_", "\"", "_", "/", "_", "\\", "_", "|", "_", "?", "_", "*", "_",
+)
+
+// Windowsで予約されているファイル名
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ファイル名の最大の長さ（文字数）
+const maxFileNameLength = 200
+
+// 文字列をファイル名として使える形にする
+func SanitizeFileName(name string) string {
+	// 全角英数字・記号を半角に、半角カナを全角にそろえてから正規化する
+	name = norm.NFC.String(width.Fold.String(name))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = fileNameReplacer.Replace(name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+	// Windowsでは末尾のピリオドと空白は無視される
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if reservedFileNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+	return name
+}
+
+// 出力先ディレクトリのテンプレートを展開する
+//
+// {id}（_chartId_）、{title}、{artist}、{author}、{rating}が使える
+func FormatOutDir(template string, chartId string, level sonolus.LevelInfo) string {
+	return strings.NewReplacer(
+		"_chartId_", SanitizeFileName(chartId),
+		"{id}", SanitizeFileName(chartId),
+		"{title}", SanitizeFileName(level.Title),
+		"{artist}", SanitizeFileName(level.Artists),
+		"{author}", SanitizeFileName(level.Author),
+		"{rating}", strconv.Itoa(level.Rating),
+	).Replace(template)
+}
+
+// テンプレートのうち、譜面によらない部分（プレースホルダーを含まない親ディレクトリ）
+func OutDirRoot(template string) string {
+	elements := strings.FieldsFunc(template, func(r rune) bool { return r == '/' || r == '\\' })
+	root := []string{}
+	for _, element := range elements {
+		if strings.Contains(element, "{") || strings.Contains(element, "_chartId_") {
+			break
+		}
+		root = append(root, element)
+	}
+	if len(root) == 0 {
+		return "."
+	}
+	if strings.HasPrefix(template, "/") || strings.HasPrefix(template, "\\") {
+		return string(template[0]) + strings.Join(root, string(template[0]))
+	}
+	return strings.Join(root, "/")
+}
+
+// AviUtlなど多くのWindowsのアプリが開けるパスの長さ（MAX_PATHから終端の1文字を除いた、UTF-16での長さ）
+const maxPathLength = 259
+
+// exo以外で、出力先の中で最も深い・長い名前になるファイル
+var deepestOutputFiles = []string{
+	filepath.Join("labels", "combo", "nt.png"),
+	filepath.Join("waveform", "00000.png"),
+	"cover_original.png",
+	"manifest.json",
+}
+
+func pathLength(path string) int {
+	return len(utf16.Encode([]rune(path)))
+}
+
+// 出力先に書き込むファイルのうち、最も長いパスの長さ
+func longestOutputPath(outDir string, files []string) int {
+	longest := 0
+	for _, file := range slices.Concat(files, deepestOutputFiles) {
+		longest = max(longest, pathLength(filepath.Join(outDir, file)))
+	}
+	return longest
+}
+
+// 出力するファイルのパスがMAX_PATHを超えないか確かめる（filesは出力先からの相対パス）
+//
+// Goでは長いパスも書き込めるが、AviUtlが読み込めなくなる
+func ValidateOutputPathLength(outDir string, files []string) error {
+	if length := longestOutputPath(outDir, files); length > maxPathLength {
+		return fmt.Errorf("出力先のパスが長すぎます。--out-dirで短い場所を指定するか、--shorten-pathを指定して下さい。(The output path is too long. Specify a shorter --out-dir or use --shorten-path.) [%d/%d: %s]", length, maxPathLength, outDir)
+	}
+	return nil
+}
+
+// 出力先ディレクトリの名前を、ファイルのパスがMAX_PATHに収まるように短くする
+//
+// 短くした名前の末尾には、元の名前のハッシュを付けて別の譜面と重ならないようにする
+func ShortenOutDir(outDir string, files []string) (string, error) {
+	excess := longestOutputPath(outDir, files) - maxPathLength
+	if excess <= 0 {
+		return outDir, nil
+	}
+	parent, name := filepath.Dir(outDir), filepath.Base(outDir)
+	sum := sha1.Sum([]byte(name))
+	suffix := "~" + hex.EncodeToString(sum[:])[:6]
+	runes := []rune(name)
+	for len(runes) > 0 && pathLength(string(runes))+pathLength(suffix) > pathLength(name)-excess {
+		runes = runes[:len(runes)-1]
+	}
+	shortened := filepath.Join(parent, strings.TrimRight(string(runes), ". ")+suffix)
+	if length := longestOutputPath(shortened, files); length > maxPathLength {
+		return "", fmt.Errorf("出力先の親ディレクトリのパスが長すぎます。--out-dirで短い場所を指定して下さい。(The parent of the output directory is too long. Please specify a shorter --out-dir.) [%d/%d: %s]", length, maxPathLength, parent)
+	}
+	return shortened, nil
+}
@@ -0,0 +1,87 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// 生成したファイルの書き込み先（ブラウザではファイルに書き込めないのでメモリ上に置く）
+type Output interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+// ディレクトリに書き込む（ディレクトリがない場合は作成する）
+type DirOutput string
+
+func (dir DirOutput) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(string(dir), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(string(dir), name))
+}
+
+// メモリ上に書き込む
+type MemoryOutput struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemoryOutput() *MemoryOutput {
+	return &MemoryOutput{files: map[string][]byte{}}
+}
+
+type memoryFile struct {
+	bytes.Buffer
+	output *MemoryOutput
+	name   string
+}
+
+func (file *memoryFile) Close() error {
+	file.output.mu.Lock()
+	defer file.output.mu.Unlock()
+	file.output.files[file.name] = file.Bytes()
+	return nil
+}
+
+func (output *MemoryOutput) Create(name string) (io.WriteCloser, error) {
+	return &memoryFile{output: output, name: name}, nil
+}
+
+// 書き込まれたファイルの名前（名前順）
+func (output *MemoryOutput) Names() []string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	names := make([]string, 0, len(output.files))
+	for name := range output.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (output *MemoryOutput) File(name string) []byte {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return output.files[name]
+}
+
+// outputにファイルを書き込む
+func writeOutputFile(output Output, name string, write func(w io.Writer) error) error {
+	file, err := output.Create(name)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
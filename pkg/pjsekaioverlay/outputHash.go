@@ -0,0 +1,50 @@
+package pjsekaioverlay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+const outputHashFileName = ".pjsekai-overlay-hash"
+
+// 譜面データと設定から、出力内容を一意に決めるハッシュを求める
+func CalculateOutputHash(level sonolus.LevelInfo, options ...string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%d\n", Version, level.Name, level.Version)
+	for _, srl := range []sonolus.SRL{level.Data, level.Cover, level.UseBackground.Item.Image} {
+		fmt.Fprintf(hash, "%s:%s\n", srl.Url, srl.Hash)
+	}
+	hash.Write([]byte(strings.Join(options, "\n")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// 前回と同じ内容で出力済みか
+func IsOutputUpToDate(destDir string, hash string) bool {
+	data, err := os.ReadFile(filepath.Join(destDir, outputHashFileName))
+	if err != nil {
+		return false
+	}
+	if strings.TrimSpace(string(data)) != hash {
+		return false
+	}
+	for _, name := range []string{"data.ped", exoVariants[0].fileName} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func WriteOutputHash(destDir string, hash string) error {
+	err := os.WriteFile(filepath.Join(destDir, outputHashFileName), []byte(hash+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
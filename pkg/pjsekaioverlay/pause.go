@@ -0,0 +1,68 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 録画が一時停止していた区間
+type Pause struct {
+	// 録画上の一時停止した時間（秒）
+	Start float64
+	// 一時停止していた長さ（秒）
+	Duration float64
+}
+
+// 「1:30+5」のような「開始+長さ」の形式を読み込む
+func ParsePause(value string) (Pause, error) {
+	startStr, durationStr, ok := strings.Cut(value, "+")
+	if !ok {
+		return Pause{}, fmt.Errorf("一時停止の形式が不正です。(Invalid pause format.) [%s]", value)
+	}
+	start, err := parseSeconds(startStr)
+	if err != nil {
+		return Pause{}, err
+	}
+	duration, err := parseSeconds(durationStr)
+	if err != nil {
+		return Pause{}, err
+	}
+	if duration <= 0 {
+		return Pause{}, fmt.Errorf("一時停止の長さが不正です。(Invalid pause duration.) [%s]", value)
+	}
+	return Pause{Start: start, Duration: duration}, nil
+}
+
+// 一時停止の間は表示を止め、再開後の時間をずらす
+type PauseMap []Pause
+
+func NewPauseMap(pauses []Pause) PauseMap {
+	pauseMap := make(PauseMap, len(pauses))
+	copy(pauseMap, pauses)
+	sort.SliceStable(pauseMap, func(i, j int) bool {
+		return pauseMap[i].Start < pauseMap[j].Start
+	})
+	return pauseMap
+}
+
+func (pauseMap PauseMap) MapTime(time float64) float64 {
+	// 開始時間は録画上の時間なので、それまでの一時停止の分ずれた時間と比べる
+	for _, pause := range pauseMap {
+		if time < pause.Start {
+			break
+		}
+		time += pause.Duration
+	}
+	return time
+}
+
+// 複数の変換を順に適用する
+type TimeMappers []TimeMapper
+
+func (mappers TimeMappers) MapTime(time float64) float64 {
+	for _, mapper := range mappers {
+		time = mapper.MapTime(time)
+	}
+	return time
+}
@@ -1,9 +1,11 @@
 package pjsekaioverlay
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"time"
@@ -11,9 +13,112 @@ import (
 	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
 )
 
+type Judgment string
+
+const (
+	JudgmentNone    Judgment = "none"
+	JudgmentPerfect Judgment = "perfect"
+	JudgmentGreat   Judgment = "great"
+	JudgmentGood    Judgment = "good"
+	JudgmentBad     Judgment = "bad"
+	JudgmentMiss    Judgment = "miss"
+)
+
 type PedFrame struct {
+	Time     float64
+	Score    int
+	Judgment Judgment
+}
+
+// コンボ数の節目に到達した時間
+type Milestone struct {
 	Time  float64
-	Score int
+	Combo int
+}
+
+// コンボ数のアニメーション
+type ComboAnimation struct {
+	Easing   string
+	Duration float64 // フレーム数
+	Scale    float64 // 拡大の大きさ
+}
+
+var DefaultComboAnimation = ComboAnimation{
+	Easing:   "linear",
+	Duration: 8,
+	Scale:    0.5,
+}
+
+// 表示するコンボ数の開始値と上限（分割した動画の続きから数える場合など）
+type ComboCounter struct {
+	Start int
+	Cap   int  // 0以下の場合は上限なし
+	Loop  bool // 上限を超えたら1から数え直す
+}
+
+func (counter ComboCounter) Validate() error {
+	if counter.Start < 0 {
+		return fmt.Errorf("コンボの開始値が不正です。(Invalid combo start.) [%d]", counter.Start)
+	}
+	if counter.Cap > 0 && counter.Start > counter.Cap {
+		return fmt.Errorf("コンボの開始値が上限を超えています。(Combo start exceeds the cap.) [%d > %d]", counter.Start, counter.Cap)
+	}
+	return nil
+}
+
+// combo以下のコンボ数を表示したときの最大値
+func (counter ComboCounter) Max(combo int) int {
+	if counter.Cap <= 0 || combo+counter.Start <= counter.Cap {
+		return combo + counter.Start
+	}
+	return counter.Cap
+}
+
+// 実際のコンボ数を表示するコンボ数に変換する
+func (counter ComboCounter) Apply(combo int) int {
+	combo += counter.Start
+	if counter.Cap <= 0 || combo <= counter.Cap {
+		return combo
+	}
+	if counter.Loop {
+		return (combo-1)%counter.Cap + 1
+	}
+	return counter.Cap
+}
+
+// GOOD以下の判定でコンボが切れる
+func breaksCombo(judgment Judgment) bool {
+	return judgment == JudgmentGood || judgment == JudgmentBad || judgment == JudgmentMiss
+}
+
+// フレームごとに表示するコンボ数を求める（コンボが切れた後は開始値を足さずに数え直す）
+func (counter ComboCounter) Combos(frames []PedFrame) []int {
+	combos := make([]int, len(frames))
+	restart := ComboCounter{Cap: counter.Cap, Loop: counter.Loop}
+	broken := -1
+	for i, frame := range frames {
+		if i > 0 && breaksCombo(frame.Judgment) {
+			broken = i
+		}
+		if broken < 0 {
+			combos[i] = counter.Apply(i)
+		} else {
+			combos[i] = restart.Apply(i - broken)
+		}
+	}
+	return combos
+}
+
+var ComboEasings = []string{"linear", "ease_in", "ease_out", "ease_in_out", "back"}
+
+func (animation ComboAnimation) Validate() error {
+	if !slices.Contains(ComboEasings, animation.Easing) {
+		return fmt.Errorf("不明なイージングです。(Unknown easing.) [%s]", animation.Easing)
+	}
+	if animation.Duration <= 0 {
+		return fmt.Errorf("アニメーションの長さが不正です。(Invalid animation duration.) [%f]", animation.Duration)
+	}
+	return nil
 }
 
 type BpmChange struct {
@@ -64,20 +169,24 @@ var WEIGHT_MAP = map[string]float64{
 	"FlickSlotGlowEffect":    0,
 	"CriticalSlotGlowEffect": 0,
 
+	// トレース（フリクション）ノーツはゲームでも中継点と同じ重みで、コンボに含まれる
 	"NormalTraceNote":   0.1,
 	"CriticalTraceNote": 0.2,
 
 	"NormalTraceSlotEffect":     0,
 	"NormalTraceSlotGlowEffect": 0,
 
-	"DamageNote":           0.1,
+	// ダメージノーツは避けても判定がなく、スコアにもコンボにも含まれない
+	"DamageNote":           0,
 	"DamageSlotEffect":     0,
 	"DamageSlotGlowEffect": 0,
 
+	// トレースフリックはフリックと同じ重み
 	"NormalTraceFlickNote":         1,
 	"CriticalTraceFlickNote":       3,
 	"NonDirectionalTraceFlickNote": 1,
 
+	// 始点・終点がトレースのロングは、始点・終点もトレースと同じ重み
 	"NormalTraceSlideStartNote":   0.1,
 	"NormalTraceSlideEndNote":     0.1,
 	"CriticalTraceSlideStartNote": 0.2,
@@ -87,6 +196,20 @@ var WEIGHT_MAP = map[string]float64{
 	"TimeScaleChange": 0,
 }
 
+// スコアやマーカー、譜面の終わりの計算に使うエンティティか（それ以外は読み込み時に捨てる）
+//
+// 未対応のアーキタイプは、警告やルールの重みの指定に使うので残す
+func isTimelineEntity(entity sonolus.LevelDataEntity) bool {
+	if entity.Archetype == "#BPM_CHANGE" || entity.Archetype == timeSignatureArchetype || WEIGHT_MAP[entity.Archetype] > 0 || chartEndArchetypes[entity.Archetype] {
+		return true
+	}
+	if !isKnownArchetype(entity.Archetype) {
+		return true
+	}
+	_, ok := markerArchetypes[entity.Archetype]
+	return ok
+}
+
 func getValueFromData(data []sonolus.LevelDataEntityValue, name string) (float64, error) {
 	for _, value := range data {
 		if value.Name == name {
@@ -116,155 +239,421 @@ func getTimeFromBpmChanges(bpmChanges []BpmChange, beat float64) float64 {
 	return ret
 }
 
+// 譜面のBPM変化を拍順に並べて返す
+func GetBpmChanges(levelData sonolus.LevelData) []BpmChange {
+	bpmChanges := ([]BpmChange{})
+	for _, entity := range levelData.Entities {
+		if entity.Archetype != "#BPM_CHANGE" {
+			continue
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		bpm, err := getValueFromData(entity.Data, "#BPM")
+		if err != nil {
+			continue
+		}
+		bpmChanges = append(bpmChanges, BpmChange{
+			Beat: beat,
+			Bpm:  bpm,
+		})
+	}
+	sort.SliceStable(bpmChanges, func(i, j int) bool {
+		return bpmChanges[i].Beat < bpmChanges[j].Beat
+	})
+	return bpmChanges
+}
+
 func CalculateScore(levelInfo sonolus.LevelInfo, levelData sonolus.LevelData, power int) []PedFrame {
+	return CalculateScoreWithRules(levelInfo, levelData, power, DefaultScoreRules)
+}
+
+// 指定したゲームのバージョンのルールでスコアを計算する（全てPERFECTとする）
+func CalculateScoreWithRules(levelInfo sonolus.LevelInfo, levelData sonolus.LevelData, power int, rules ScoreRules) []PedFrame {
+	return simulateScore(levelInfo, levelData, power, rules, func(time float64) Judgment {
+		return JudgmentPerfect
+	})
+}
+
+// 判定ごとのスコアの倍率
+var judgmentWeights = map[Judgment]float64{
+	JudgmentPerfect: 1,
+	JudgmentGreat:   0.9,
+	JudgmentGood:    0.5,
+	JudgmentBad:     0,
+	JudgmentMiss:    0,
+}
+
+// ノーツごとにjudgeがノーツの時間から返す判定でスコアを計算する
+func simulateScore(levelInfo sonolus.LevelInfo, levelData sonolus.LevelData, power int, rules ScoreRules, judge func(time float64) Judgment) []PedFrame {
+	// ScoreRules{}のように省略した場合も割り算できるようにする
+	rules = rules.withDefaults()
 	rating := levelInfo.Rating
-	framesLen := 0
 	var weightedNotesCount float64 = 0
+	type noteEntity struct {
+		archetype string
+		beat      float64
+	}
+	noteEntities := []noteEntity{}
+
 	for _, entity := range levelData.Entities {
-		weight := WEIGHT_MAP[entity.Archetype]
-		if weight == 0 {
+		if rules.weight(entity.Archetype) <= 0.0 {
+			continue
+		}
+		// 拡張アーキタイプでは#BEATが先頭のデータとは限らない
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
 			continue
 		}
-		weightedNotesCount += weight
-		framesLen += 1
+		noteEntities = append(noteEntities, noteEntity{archetype: entity.Archetype, beat: beat})
+		weightedNotesCount += rules.weight(entity.Archetype)
 	}
+	sort.SliceStable(noteEntities, func(i, j int) bool {
+		return noteEntities[i].beat < noteEntities[j].beat
+	})
 
-	frames := make([]PedFrame, 0, int(weightedNotesCount)+1)
-	frames = append(frames, PedFrame{Time: 0, Score: 0})
-	bpmChanges := ([]BpmChange{})
+	frames := make([]PedFrame, 0, len(noteEntities)+1)
+	frames = append(frames, PedFrame{Time: 0, Score: 0, Judgment: JudgmentNone})
+	bpmChanges := GetBpmChanges(levelData)
 	levelFax := float64(rating-5)*0.005 + 1
 	comboFax := 1.0
 
 	score := 0
 	entityCounter := 0
-	noteEntities := ([]sonolus.LevelDataEntity{})
-
-	for _, entity := range levelData.Entities {
-		weight := WEIGHT_MAP[entity.Archetype]
-		if weight > 0.0 && len(entity.Data) > 0 {
-			noteEntities = append(noteEntities, entity)
-		} else if entity.Archetype == "#BPM_CHANGE" {
-			beat, err := getValueFromData(entity.Data, "#BEAT")
-			if err != nil {
-				continue
-			}
-			bpm, err := getValueFromData(entity.Data, "#BPM")
-			if err != nil {
-				continue
-			}
-			bpmChanges = append(bpmChanges, BpmChange{
-				Beat: beat,
-				Bpm:  bpm,
-			})
-		}
-	}
-	sort.SliceStable(noteEntities, func(i, j int) bool {
-		return noteEntities[i].Data[0].Value < noteEntities[j].Data[0].Value
-	})
-	sort.SliceStable(bpmChanges, func(i, j int) bool {
-		return bpmChanges[i].Beat < bpmChanges[j].Beat
-	})
 	for _, entity := range noteEntities {
-		weight := WEIGHT_MAP[entity.Archetype]
+		weight := rules.weight(entity.archetype)
 		entityCounter += 1
-		if entityCounter%100 == 0 {
-			comboFax += 0.01
+		if entityCounter%rules.ComboFaxInterval == 0 {
+			comboFax += rules.ComboFaxStep
 		}
-		if comboFax > 1.1 {
-			comboFax = 1.1
+		if comboFax > rules.ComboFaxMax {
+			comboFax = rules.ComboFaxMax
 		}
 
+		time := getTimeFromBpmChanges(bpmChanges, entity.beat) + levelData.BgmOffset
+		judgment := judge(time)
 		score += int(
 			(float64(power) / weightedNotesCount) * // Team power / weighted notes count
 				4 * // Constant
 				weight * // Note weight
-				1 * // Judge weight (Always 1)
+				judgmentWeights[judgment] * // Judge weight
 				levelFax * // Level fax
 				comboFax * // Combo fax
 				1, // Skill fax (Always 1)
 		)
-		beat, err := getValueFromData(entity.Data, "#BEAT")
-		if err != nil {
-			continue
-		}
 		frames = append(frames, PedFrame{
-			Time:  getTimeFromBpmChanges(bpmChanges, beat) + levelData.BgmOffset,
-			Score: score,
+			Time:     time,
+			Score:    score,
+			Judgment: judgment,
 		})
 	}
 
 	return frames
 }
 
-func WritePedFile(frames []PedFrame, assets string, ap bool, path string, levelInfo sonolus.LevelInfo) error {
+// 表示するコンボ数がintervalの倍数になる節目を求める（0以下の場合は無効）
+func CalculateMilestones(frames []PedFrame, interval int, counter ComboCounter) []Milestone {
+	milestones := []Milestone{}
+	if interval <= 0 {
+		return milestones
+	}
+	// frames[0]は開始時点（0コンボ）
+	combos := counter.Combos(frames)
+	for i := 1; i < len(frames); i++ {
+		displayed := combos[i]
+		if displayed == 0 || displayed%interval != 0 || displayed == combos[i-1] {
+			continue
+		}
+		milestones = append(milestones, Milestone{
+			Time:  frames[i].Time,
+			Combo: displayed,
+		})
+	}
+	return milestones
+}
+
+// 拍のタイミング
+type Beat struct {
+	Time     float64
+	Downbeat bool // 小節の頭
+}
+
+// BPM変化と拍子の変化を考慮して、最後のノーツまでの拍を求める（beatsPerMeasureが0以下の場合は無効）
+func CalculateBeats(levelData sonolus.LevelData, frames []PedFrame, beatsPerMeasure int) []Beat {
+	beats := []Beat{}
+	for _, position := range CalculateMeasures(levelData, frames, beatsPerMeasure) {
+		beats = append(beats, Beat{
+			Time:     position.Time,
+			Downbeat: position.Beat == 1,
+		})
+	}
+	return beats
+}
+
+type RankBorders struct {
+	Border int
+	S      int
+	A      int
+	B      int
+	C      int
+}
+
+func getRankBorders(rating int) RankBorders {
+	if rating < 5 {
+		return RankBorders{Border: 1200000, S: 1040000, A: 840000, B: 400000, C: 20000}
+	} else if rating > 40 {
+		return RankBorders{Border: 1343500, S: 1222000, A: 987000, B: 470000, C: 23500}
+	}
+	return RankBorders{
+		Border: 1200000 + (rating-5)*4100,
+		S:      1040000 + (rating-5)*5200,
+		A:      840000 + (rating-5)*4200,
+		B:      400000 + (rating-5)*2000,
+		C:      20000 + (rating-5)*100,
+	}
+}
+
+// スコアからランクとスコアバーの長さ（0〜357）を求める
+func getRank(score int, rating int) (string, float64) {
+	// 161, 215, 267, 320, 357
+	borders := getRankBorders(rating)
+	if score >= borders.Border {
+		return "s", 357
+	} else if score >= borders.S {
+		return "s", (float64((score-borders.S))/float64((borders.Border-borders.S)))*37 + 320
+	} else if score >= borders.A {
+		return "a", (float64((score-borders.A))/float64((borders.S-borders.A)))*53 + 267
+	} else if score >= borders.B {
+		return "b", (float64((score-borders.B))/float64((borders.A-borders.B)))*53 + 215
+	} else if score >= borders.C {
+		return "c", (float64((score-borders.C))/float64((borders.B-borders.C)))*54 + 161
+	}
+	return "d", (float64(score) / float64(borders.C)) * 160
+}
+
+// ランクが上がった時間
+type RankCrossing struct {
+	Time  float64
+	Rank  string
+	Final bool
+}
+
+// ランクが上がるタイミングと、最後の最終ランクを求める
+func CalculateRankCrossings(frames []PedFrame, rating int) []RankCrossing {
+	crossings := []RankCrossing{}
+	if len(frames) == 0 {
+		return crossings
+	}
+	lastRank, _ := getRank(frames[0].Score, rating)
+	for _, frame := range frames[1:] {
+		rank, _ := getRank(frame.Score, rating)
+		if rank != lastRank {
+			crossings = append(crossings, RankCrossing{Time: frame.Time, Rank: rank})
+			lastRank = rank
+		}
+	}
+	crossings = append(crossings, RankCrossing{
+		Time:  frames[len(frames)-1].Time,
+		Rank:  lastRank,
+		Final: true,
+	})
+	return crossings
+}
+
+// 要素の色と、色を変えた画像の読み込み先
+type PedTint struct {
+	Element TintElement
+	Color   int
+	Path    string
+}
+
+type PedOptions struct {
+	Milestones     []Milestone
+	RankCrossings  []RankCrossing
+	ComboAnimation ComboAnimation
+	ScoreAnimation ScoreAnimation
+	ComboCounter   ComboCounter
+	ScoreFormat    ScoreFormat
+	LiveMode       LiveMode
+	// マルチライブの参加者のスコア
+	MultiLive []MultiLiveScore
+	// 並べて表示する別の譜面（Framesが空の場合は表示しない）
+	Comparison Comparison
+	// 目標スコアのゴースト（Framesが空の場合は表示しない）
+	Ghost Ghost
+	// ミスの演出
+	Misses []Miss
+	// 書き出す時間の変換（nilの場合はそのまま）
+	TimeMapper TimeMapper
+	// 録画の再生速度（0の場合は等速）。アニメーションの長さを合わせる
+	PlaybackSpeed float64
+	Beats         []Beat
+	// 小節:拍の表示に使う拍ごとの位置
+	Measures []MeasurePosition
+	// 拍ごとのノーツ密度
+	NpsMeter     []NpsSample
+	Guides       []GuideBox
+	DensityGraph DensityGraph
+	Waveform     Waveform
+	// 出力内容のハッシュ（空の場合は生成時刻を使う）
+	OutputHash string
+	// スコア・コンボの数字に使うフォント名（空の場合は画像を使う）
+	CounterFont string
+	// 事前に組み立てたコンボ数の画像のディレクトリ（空の場合は数字ごとに描画する）。CounterFontより優先する
+	ComboImages string
+	// アニメーションするジャケットのフレーム
+	CoverFrames []AnimatedCoverFrame
+	Tints       []PedTint
+	// スコアとコンボに付ける縁取りの太さ（0の場合は付けない）。見やすさ重視の表示で使う
+	Outline int
+	// 目盛りを割合の位置に描き直したスコアの枠（空の場合はゲームと同じ固定の目盛り）
+	Gauge string
+	// 言語に合わせたコンボの見出しのディレクトリ（空の場合はテンプレートの画像）
+	Labels string
+}
+
+func WritePedFile(frames []PedFrame, assets string, ap bool, path string, levelInfo sonolus.LevelInfo, options PedOptions) error {
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("ファイルの作成に失敗しました (Failed to create file.) [%s]", err)
 	}
 	defer file.Close()
 
-	writer := io.Writer(file)
+	return WritePed(file, frames, assets, ap, levelInfo, options)
+}
+
+// pedの内容を任意の書き込み先に出力する
+func WritePed(w io.Writer, frames []PedFrame, assets string, ap bool, levelInfo sonolus.LevelInfo, options PedOptions) error {
+	// ノーツごとに書き込むので、まとめて書き出す
+	writer := bufio.NewWriter(w)
 
 	writer.Write([]byte(fmt.Sprintf("p|%s\n", assets)))
 	writer.Write([]byte(fmt.Sprintf("a|%s\n", strconv.FormatBool(ap))))
 	writer.Write([]byte(fmt.Sprintf("v|%s\n", Version)))
-	writer.Write([]byte(fmt.Sprintf("u|%d\n", time.Now().Unix())))
+	if options.OutputHash != "" {
+		writer.Write([]byte(fmt.Sprintf("u|%s\n", options.OutputHash)))
+	} else {
+		writer.Write([]byte(fmt.Sprintf("u|%d\n", time.Now().Unix())))
+	}
+
+	writer.Write([]byte(fmt.Sprintf("c|%s:%f:%f\n", options.ComboAnimation.Easing, options.ComboAnimation.Duration, options.ComboAnimation.Scale)))
+	if options.ScoreAnimation.Animated() {
+		writer.Write([]byte(fmt.Sprintf("sa|%s:%f\n", options.ScoreAnimation.Mode, options.ScoreAnimation.Duration)))
+	}
+	if options.LiveMode.Name != "" && options.LiveMode.Name != "normal" {
+		writer.Write([]byte(fmt.Sprintf("l|%s:%06x\n", options.LiveMode.Name, options.LiveMode.Color)))
+	}
+	if options.PlaybackSpeed != 0 && options.PlaybackSpeed != 1 {
+		writer.Write([]byte(fmt.Sprintf("x|%f\n", options.PlaybackSpeed)))
+	}
+	if options.ScoreFormat.Separator != "" {
+		writer.Write([]byte(fmt.Sprintf("n|%s:%d:%s\n", options.ScoreFormat.Separator, options.ScoreFormat.Digits, strconv.FormatBool(options.ScoreFormat.Monospace))))
+	}
+	if options.CounterFont != "" {
+		writer.Write([]byte(fmt.Sprintf("f|%s\n", options.CounterFont)))
+	}
+	if options.ComboImages != "" {
+		writer.Write([]byte(fmt.Sprintf("ci|%s\n", options.ComboImages)))
+	}
+
+	if options.DensityGraph.Path != "" {
+		writer.Write([]byte(fmt.Sprintf("d|%f:%s\n", options.DensityGraph.Length, options.DensityGraph.Path)))
+	}
+	if options.Waveform.Path != "" {
+		writer.Write([]byte(fmt.Sprintf("o|%f:%f:%d:%s\n", options.Waveform.Start, options.Waveform.FrameRate, options.Waveform.Count, options.Waveform.Path)))
+	}
+	if options.Gauge != "" {
+		writer.Write([]byte(fmt.Sprintf("g|%s\n", options.Gauge)))
+	}
+	if options.Labels != "" {
+		writer.Write([]byte(fmt.Sprintf("k|%s\n", options.Labels)))
+	}
+	if options.Outline > 0 {
+		writer.Write([]byte(fmt.Sprintf("h|%d\n", options.Outline)))
+	}
+	for _, tint := range options.Tints {
+		writer.Write([]byte(fmt.Sprintf("t|%s:%06x:%s\n", tint.Element, tint.Color, tint.Path)))
+	}
+	for _, frame := range options.CoverFrames {
+		writer.Write([]byte(fmt.Sprintf("j|%f:%s\n", frame.Delay, frame.Path)))
+	}
+
+	for _, milestone := range options.Milestones {
+		writer.Write([]byte(fmt.Sprintf("m|%f:%d\n", MapTime(options.TimeMapper, milestone.Time), milestone.Combo)))
+	}
+	for _, beat := range options.Beats {
+		writer.Write([]byte(fmt.Sprintf("b|%f:%s\n", MapTime(options.TimeMapper, beat.Time), strconv.FormatBool(beat.Downbeat))))
+	}
+	for _, box := range options.Guides {
+		writer.Write([]byte(fmt.Sprintf("gd|%f:%f:%f:%f:%s:%06x\n", box.Left, box.Top, box.Right, box.Bottom, strconv.FormatBool(box.Fill), box.Color)))
+	}
+	for _, sample := range options.NpsMeter {
+		writer.Write([]byte(fmt.Sprintf("np|%f:%f\n", MapTime(options.TimeMapper, sample.Time), sample.Nps)))
+	}
+	for _, measure := range options.Measures {
+		writer.Write([]byte(fmt.Sprintf("e|%f:%d:%d\n", MapTime(options.TimeMapper, measure.Time), measure.Measure, measure.Beat)))
+	}
+	for i, player := range options.MultiLive {
+		writer.Write([]byte(fmt.Sprintf("q|%d:%s\n", i+1, player.Player.Name)))
+		for _, frame := range player.Frames {
+			writer.Write([]byte(fmt.Sprintf("w|%d:%f:%d\n", i+1, MapTime(options.TimeMapper, frame.Time), frame.Score)))
+		}
+	}
+	if len(options.Comparison.Frames) > 0 {
+		writer.Write([]byte(fmt.Sprintf("i|%s\n", options.Comparison.Title)))
+		for _, frame := range options.Comparison.Frames[1:] {
+			writer.Write([]byte(fmt.Sprintf("y|%f:%d\n", MapTime(options.TimeMapper, frame.Time), frame.Score)))
+		}
+		for _, difference := range options.Comparison.Differences {
+			writer.Write([]byte(fmt.Sprintf("z|%f:%s\n", MapTime(options.TimeMapper, difference.Time), difference.Kind)))
+		}
+	}
+	if len(options.Ghost.Frames) > 0 {
+		// バーの長さは、目標スコアと最終スコアの大きい方に合わせる
+		scale := options.Ghost.Score
+		if len(frames) > 0 {
+			scale = max(scale, frames[len(frames)-1].Score)
+		}
+		writer.Write([]byte(fmt.Sprintf("gh|%d:%d:%s\n", options.Ghost.Score, scale, options.Ghost.Title)))
+		for _, frame := range options.Ghost.Frames {
+			writer.Write([]byte(fmt.Sprintf("gs|%f:%d\n", MapTime(options.TimeMapper, frame.Time), frame.Score)))
+		}
+	}
+	for _, miss := range options.Misses {
+		writer.Write([]byte(fmt.Sprintf("ms|%f:%d\n", MapTime(options.TimeMapper, miss.Time), miss.Combo)))
+	}
+	for _, crossing := range options.RankCrossings {
+		writer.Write([]byte(fmt.Sprintf("r|%f:%s:%s\n", MapTime(options.TimeMapper, crossing.Time), crossing.Rank, strconv.FormatBool(crossing.Final))))
+	}
 
+	// 区間を指定した場合、先頭のフレームは途中のスコアから始まる
 	lastScore := 0
+	if len(frames) > 0 {
+		lastScore = frames[0].Score
+	}
 	rating := levelInfo.Rating
+	combos := options.ComboCounter.Combos(frames)
 	for i, frame := range frames {
 		score := frame.Score
 		frameScore := score - lastScore
 		lastScore = frame.Score
 
-		// 161, 215, 267, 320, 357
-
-		rank := "n"
-		scoreX := 0.0
-
-		// rank
-		rankBorder := 1200000 + (rating-5)*4100
-		rankS := 1040000 + (rating-5)*5200
-		rankA := 840000 + (rating-5)*4200
-		rankB := 400000 + (rating-5)*2000
-		rankC := 20000 + (rating-5)*100
-		if rating < 5 {
-			rankBorder = 1200000
-			rankS = 1040000
-			rankA = 840000
-			rankB = 400000
-			rankC = 20000
-		} else if rating > 40 {
-			rankBorder = 1343500
-			rankS = 1222000
-			rankA = 987000
-			rankB = 470000
-			rankC = 23500
+		rank, scoreX := getRank(score, rating)
+		if options.Gauge != "" {
+			scoreX = proportionalGaugeX(score, rating)
 		}
 
-		// bar
-		if score >= rankBorder {
-			rank = "s"
-			scoreX = 357
-		} else if score >= rankS {
-			rank = "s"
-			scoreX = (float64((score-rankS))/float64((rankBorder-rankS)))*37 + 320
-		} else if score >= rankA {
-			rank = "a"
-			scoreX = (float64((score-rankA))/float64((rankS-rankA)))*53 + 267
-		} else if score >= rankB {
-			rank = "b"
-			scoreX = (float64((score-rankB))/float64((rankA-rankB)))*53 + 215
-		} else if score >= rankC {
-			rank = "c"
-			scoreX = (float64((score-rankC))/float64((rankB-rankC)))*54 + 161
-		} else {
-			rank = "d"
-			scoreX = (float64(score) / float64(rankC)) * 160
+		judgment := frame.Judgment
+		if judgment == "" {
+			judgment = JudgmentNone
 		}
 
-		writer.Write([]byte(fmt.Sprintf("s|%f:%d:%d:%f:%s:%d\n", frame.Time, score, frameScore, scoreX/357, rank, i)))
+		writer.Write([]byte(fmt.Sprintf("s|%f:%d:%d:%f:%s:%d:%s\n", MapTime(options.TimeMapper, frame.Time), score, frameScore, scoreX/357, rank, combos[i], judgment)))
 	}
 
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file.) [%s]", err)
+	}
 	return nil
 }
@@ -0,0 +1,129 @@
+package pjsekaioverlay
+
+import (
+	"testing"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// BPM60（1拍1秒）で、archetypesを1拍ずつ並べた譜面データ
+func testLevelData(archetypes ...string) sonolus.LevelData {
+	entities := []sonolus.LevelDataEntity{{
+		Archetype: "#BPM_CHANGE",
+		Data:      []sonolus.LevelDataEntityValue{{Name: "#BEAT", Value: 0}, {Name: "#BPM", Value: 60}},
+	}}
+	for i, archetype := range archetypes {
+		entities = append(entities, sonolus.LevelDataEntity{
+			Archetype: archetype,
+			// 拡張アーキタイプと同じく、#BEATを先頭以外に置く
+			Data: []sonolus.LevelDataEntityValue{{Name: "lane", Value: 0}, {Name: "#BEAT", Value: float64(i + 1)}},
+		})
+	}
+	return sonolus.LevelData{Entities: entities}
+}
+
+func repeatArchetype(archetype string, count int) []string {
+	archetypes := make([]string, count)
+	for i := range archetypes {
+		archetypes[i] = archetype
+	}
+	return archetypes
+}
+
+func TestSimulateScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		power      int
+		rating     int
+		archetypes []string
+		// 開始時点を除いたフレーム数（コンボ数）
+		notes int
+		score int
+	}{
+		{
+			// 重みの合計は5.4なので、重み1あたり 54000 / 5.4 * 4 * 1.125 = 45000
+			name:   "trace",
+			power:  54000,
+			rating: 30,
+			archetypes: []string{
+				"NormalTapNote",
+				"CriticalTraceNote",
+				"NormalTraceNote",
+				"CriticalTraceFlickNote",
+				"NormalTraceSlideStartNote",
+				"NonDirectionalTraceFlickNote",
+			},
+			notes: 6,
+			score: 45000 + 9000 + 4500 + 135000 + 4500 + 45000,
+		},
+		{
+			// ダメージノーツはスコアにもコンボにも含まれない
+			name:   "damage",
+			power:  54000,
+			rating: 30,
+			archetypes: []string{
+				"DamageNote",
+				"NormalTapNote",
+				"CriticalTraceNote",
+				"DamageNote",
+				"NormalTraceNote",
+				"CriticalTraceFlickNote",
+				"NormalTraceSlideStartNote",
+				"NonDirectionalTraceFlickNote",
+				"DamageNote",
+			},
+			notes: 6,
+			score: 243000,
+		},
+		{
+			// 100コンボごとにコンボ補正が0.01上がる（重み1あたり 200000 / 200 * 4 = 4000）
+			name:       "combo bonus",
+			power:      200000,
+			rating:     5,
+			archetypes: repeatArchetype("NormalTapNote", 200),
+			notes:      200,
+			score:      99*4000 + 100*4040 + 4080,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames := simulateScore(sonolus.LevelInfo{Rating: test.rating}, testLevelData(test.archetypes...), test.power, DefaultScoreRules, func(time float64) Judgment {
+				return JudgmentPerfect
+			})
+			if len(frames) != test.notes+1 {
+				t.Fatalf("len(frames) = %d, want %d", len(frames), test.notes+1)
+			}
+			if score := frames[len(frames)-1].Score; score != test.score {
+				t.Errorf("score = %d, want %d", score, test.score)
+			}
+		})
+	}
+}
+
+func TestSimulateScoreJudgesByNoteTime(t *testing.T) {
+	times := []float64{}
+	frames := simulateScore(sonolus.LevelInfo{Rating: 30}, testLevelData("CriticalTraceNote", "DamageNote", "NormalTapNote"), 54000, DefaultScoreRules, func(time float64) Judgment {
+		times = append(times, time)
+		if time == 3 {
+			return JudgmentMiss
+		}
+		return JudgmentPerfect
+	})
+	// ダメージノーツは判定しない
+	if len(times) != 2 || times[0] != 1 || times[1] != 3 {
+		t.Fatalf("judged times = %v, want [1 3]", times)
+	}
+	if frames[2].Judgment != JudgmentMiss || frames[2].Score != frames[1].Score {
+		t.Errorf("missed note frame = %+v, previous = %+v", frames[2], frames[1])
+	}
+}
+
+func TestSimulateScoreWithEmptyRules(t *testing.T) {
+	levelData := testLevelData(repeatArchetype("NormalTapNote", 200)...)
+	perfect := func(time float64) Judgment { return JudgmentPerfect }
+	frames := simulateScore(sonolus.LevelInfo{Rating: 5}, levelData, 200000, ScoreRules{}, perfect)
+	expected := simulateScore(sonolus.LevelInfo{Rating: 5}, levelData, 200000, DefaultScoreRules, perfect)
+	if score, want := frames[len(frames)-1].Score, expected[len(expected)-1].Score; score != want {
+		t.Errorf("score = %d, want %d", score, want)
+	}
+}
@@ -0,0 +1,78 @@
+package pjsekaioverlay
+
+import (
+	"path/filepath"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// Generateの設定（CLIの主要なオプションに対応する）
+type GenerateOptions struct {
+	ChartId string
+	// 複数のサーバーで見つかった場合に使うサーバー（空の場合は最初に見つかったサーバー）
+	SourceId string
+	// 空でない場合は、サーバーの譜面データの代わりに読み込む
+	LevelFile string
+	// 出力先ディレクトリ
+	OutDir string
+	// AviUtlオブジェクトが参照するassetsディレクトリ
+	Assets          string
+	TeamPower       int
+	ApCombo         bool
+	BackgroundStyle string
+	Cover           CoverOptions
+	// 背景にかける加工（ジャケットの加工はCover.Filters）
+	BackgroundFilters ImageFilters
+	ComboMilestone    bool
+	RankEffect        bool
+	ComboAnimation    ComboAnimation
+	ScoreAnimation    ScoreAnimation
+	ComboCounter      ComboCounter
+	ScoreRules        ScoreRules
+	// スコアの計算から除外するアーキタイプ
+	EntityFilter      EntityFilter
+	UnknownArchetypes UnknownArchetypeMode
+	// 説明文などの文言の言語（空の場合は日本語）
+	Labels LabelLanguage
+	// 途中経過の読み込みや削除の失敗もエラーにする（Clientの設定はWithStrict）
+	Strict bool
+}
+
+var DefaultGenerateOptions = GenerateOptions{
+	TeamPower:       250000,
+	ApCombo:         true,
+	BackgroundStyle: "v3",
+	Cover:           DefaultCoverOptions,
+	ComboMilestone:  true,
+	RankEffect:      true,
+	ComboAnimation:  DefaultComboAnimation,
+	ScoreAnimation:  DefaultScoreAnimation,
+	ScoreRules:      DefaultScoreRules,
+}
+
+// exoの説明文に使う作詞・作曲などの表記
+func FormatExoArtists(source Source, level sonolus.LevelInfo) string {
+	return FormatExoArtistsIn(LabelLanguageJa, source, level)
+}
+
+// 譜面の取得からexoファイルの出力までを行う。progressには処理中の段階が渡される
+// 途中で失敗した場合は出力先に途中経過を残し、同じ設定でもう一度呼ぶと続きから再開する
+func (c *Client) Generate(options GenerateOptions, progress func(step string)) error {
+	pipeline, err := c.NewPipeline(options, DirOutput(options.OutDir), filepath.Join(options.OutDir, PipelineStateFileName))
+	if err != nil {
+		return err
+	}
+	return pipeline.Run(progress)
+}
+
+// 譜面を取得してスコアを計算し、ジャケットと背景をoutputに書き込む（ファイルを使わないので、ブラウザでも動く）
+func (c *Client) GenerateTimeline(options GenerateOptions, output Output, progress func(step string)) (Timeline, error) {
+	pipeline, err := c.NewPipeline(options, output, "")
+	if err != nil {
+		return Timeline{}, err
+	}
+	if err := pipeline.RunUntil(StageSimulate, progress); err != nil {
+		return Timeline{}, err
+	}
+	return pipeline.Timeline(), nil
+}
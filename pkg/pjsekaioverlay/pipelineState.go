@@ -0,0 +1,329 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 生成の段階。この順に実行する
+type Stage string
+
+const (
+	// 譜面のサーバーの判別と譜面情報の取得
+	StageResolve Stage = "resolve"
+	// 譜面データの取得
+	StageFetch Stage = "fetch"
+	// ジャケットと背景の書き込み
+	StageAssets Stage = "assets"
+	// スコアの計算
+	StageSimulate Stage = "simulate"
+	// pedとexoの書き込み
+	StageExport Stage = "export"
+)
+
+var Stages = []Stage{StageResolve, StageFetch, StageAssets, StageSimulate, StageExport}
+
+// 途中から再開するための状態ファイル（出力先に保存し、完了したら削除する）
+const PipelineStateFileName = "pipeline.json"
+
+// 途中まで実行した結果
+type PipelineState struct {
+	// 完了した段階と、その時の設定のキー
+	Completed map[Stage]string  `json:"completed"`
+	Source    Source            `json:"source"`
+	Level     sonolus.LevelInfo `json:"level"`
+	LevelData sonolus.LevelData `json:"levelData"`
+	Frames    []PedFrame        `json:"frames"`
+}
+
+// 段階ごとに生成する処理。状態ファイルを指定した場合は、段階が終わるごとに保存する
+type Pipeline struct {
+	client  *Client
+	options GenerateOptions
+	output  Output
+	// 空の場合は保存しない
+	statePath string
+	State     PipelineState
+	// このPipelineで実行した段階の処理時間
+	Timings StageTimings
+}
+
+// 状態ファイルがあり、設定が同じ段階は完了したものとして読み込む
+func (c *Client) NewPipeline(options GenerateOptions, output Output, statePath string) (*Pipeline, error) {
+	pipeline := &Pipeline{
+		client:    c,
+		options:   options,
+		output:    output,
+		statePath: statePath,
+		State:     PipelineState{Completed: map[Stage]string{}},
+	}
+	if statePath == "" {
+		return pipeline, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return pipeline, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("途中経過の読み込みに失敗しました。(Failed to read the pipeline state.) [%s]", err)
+	}
+	// 壊れている場合は最初からやり直す（strictの場合を除く）
+	var state PipelineState
+	err = json.Unmarshal(data, &state)
+	if err == nil && state.Completed != nil {
+		pipeline.State = state
+	} else if pipeline.strict() {
+		return nil, fmt.Errorf("途中経過の読み込みに失敗しました。(Failed to read the pipeline state.) [%s]", statePath)
+	}
+	return pipeline, nil
+}
+
+func (p *Pipeline) strict() bool {
+	return p.options.Strict || p.client.strict
+}
+
+// 段階の結果に影響する設定
+func (p *Pipeline) stageKey(stage Stage) string {
+	var key any
+	switch stage {
+	case StageResolve:
+		key = []any{p.options.ChartId, p.options.SourceId}
+	case StageFetch:
+		// サーバー上の譜面が差し替えられた場合は取得し直す
+		key = []any{p.options.ChartId, p.options.SourceId, p.options.LevelFile, p.State.Level.Data.Hash}
+	case StageAssets:
+		key = []any{p.options.ChartId, p.options.SourceId, p.options.Cover, p.options.BackgroundStyle, p.options.BackgroundFilters}
+	case StageSimulate:
+		key = []any{p.options.ChartId, p.options.TeamPower, p.options.ScoreRules, p.options.EntityFilter, p.options.UnknownArchetypes}
+	default:
+		key = p.options
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+// その段階とそれより前の段階が、今の設定で完了しているか
+func (p *Pipeline) Completed(stage Stage) bool {
+	for _, s := range Stages {
+		if key, ok := p.State.Completed[s]; !ok || key != p.stageKey(s) {
+			return false
+		}
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pipeline) saveState() error {
+	if p.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(p.State)
+	if err != nil {
+		return fmt.Errorf("途中経過の保存に失敗しました。(Failed to save the pipeline state.) [%s]", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.statePath), 0755); err != nil {
+		return fmt.Errorf("途中経過の保存に失敗しました。(Failed to save the pipeline state.) [%s]", err)
+	}
+	if err := os.WriteFile(p.statePath, data, 0644); err != nil {
+		return fmt.Errorf("途中経過の保存に失敗しました。(Failed to save the pipeline state.) [%s]", err)
+	}
+	return nil
+}
+
+// 1つの段階を実行する（完了済みでもやり直す）。progressには処理中の段階が渡される
+func (p *Pipeline) RunStage(stage Stage, progress func(step string)) error {
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	stopTiming := p.Timings.Start(string(stage))
+	var err error
+	switch stage {
+	case StageResolve:
+		err = p.resolve(progress)
+	case StageFetch:
+		err = p.fetch(progress)
+	case StageAssets:
+		err = p.assets(progress)
+	case StageSimulate:
+		p.State.Frames = CalculateScoreWithRules(p.State.Level, p.LevelData(), p.options.TeamPower, p.options.ScoreRules)
+	case StageExport:
+		err = p.export(progress)
+	default:
+		err = fmt.Errorf("不明な段階です。(Unknown stage.) [%s]", stage)
+	}
+	stopTiming()
+	if err != nil {
+		return err
+	}
+	p.State.Completed[stage] = p.stageKey(stage)
+	return p.saveState()
+}
+
+// 完了していない段階を、untilまで順に実行する
+func (p *Pipeline) RunUntil(until Stage, progress func(step string)) error {
+	for _, stage := range Stages {
+		if !p.Completed(stage) {
+			if err := p.RunStage(stage, progress); err != nil {
+				return err
+			}
+		}
+		if stage == until {
+			return nil
+		}
+	}
+	return fmt.Errorf("不明な段階です。(Unknown stage.) [%s]", until)
+}
+
+// 全ての段階を実行し、状態ファイルを削除する
+func (p *Pipeline) Run(progress func(step string)) error {
+	if err := p.RunUntil(StageExport, progress); err != nil {
+		return err
+	}
+	return p.Finish()
+}
+
+// 状態ファイルを削除する（全ての段階を自分で実行した場合に呼ぶ）
+func (p *Pipeline) Finish() error {
+	if p.statePath != "" {
+		if err := os.Remove(p.statePath); err != nil && p.strict() {
+			return fmt.Errorf("途中経過の削除に失敗しました。(Failed to remove the pipeline state.) [%s]", err)
+		}
+	}
+	return nil
+}
+
+// 譜面の候補を選んだ場合など、resolveの段階を外で行った結果を設定する
+func (p *Pipeline) SetChart(source Source, level sonolus.LevelInfo) error {
+	if level.Engine.Version != 12 {
+		return fmt.Errorf("エンジンのバージョンが古い。(Unsupported engine version.) [ver.%d]", level.Engine.Version)
+	}
+	p.State.Source, p.State.Level = source, level
+	p.State.Completed[StageResolve] = p.stageKey(StageResolve)
+	return p.saveState()
+}
+
+// 除外するアーキタイプと未対応のアーキタイプの扱いを反映した譜面データ
+func (p *Pipeline) LevelData() sonolus.LevelData {
+	return p.options.UnknownArchetypes.Apply(p.options.EntityFilter.Apply(p.State.LevelData), p.options.ScoreRules)
+}
+
+// simulateまで完了した結果
+func (p *Pipeline) Timeline() Timeline {
+	return Timeline{
+		Source:         p.State.Source,
+		Level:          p.State.Level,
+		Artists:        FormatExoArtistsIn(p.options.Labels, p.State.Source, p.State.Level),
+		Frames:         p.State.Frames,
+		ComboCounter:   p.options.ComboCounter,
+		ScoreAnimation: p.options.ScoreAnimation,
+		CoverFormat:    p.options.Cover.Format,
+	}
+}
+
+func (p *Pipeline) resolve(progress func(step string)) error {
+	progress("chart")
+	matches, err := p.client.FindChart(p.options.ChartId)
+	if err != nil {
+		return err
+	}
+	match := matches[0]
+	if p.options.SourceId != "" {
+		index := slices.IndexFunc(matches, func(m ChartMatch) bool { return m.Source.Id == p.options.SourceId })
+		if index < 0 {
+			return fmt.Errorf("指定したサーバーに譜面が見つかりませんでした。(Chart not found on the specified source.) [%s]", p.options.SourceId)
+		}
+		match = matches[index]
+	}
+	if match.Level.Engine.Version != 12 {
+		return fmt.Errorf("エンジンのバージョンが古い。(Unsupported engine version.) [ver.%d]", match.Level.Engine.Version)
+	}
+	p.State.Source, p.State.Level = match.Source, match.Level
+	return nil
+}
+
+func (p *Pipeline) fetch(progress func(step string)) error {
+	progress("level")
+	var levelData sonolus.LevelData
+	var err error
+	if p.options.LevelFile != "" {
+		levelData, err = LoadLevelFile(p.options.LevelFile, p.client.archetypeMapping)
+	} else {
+		levelData, err = p.client.FetchLevelData(p.State.Source, p.State.Level)
+	}
+	if err != nil {
+		return err
+	}
+	p.State.LevelData = levelData
+	return nil
+}
+
+func (p *Pipeline) assets(progress func(step string)) error {
+	progress("cover")
+	cover, err := p.client.WriteCover(p.State.Source, p.State.Level, p.output, p.options.Cover)
+	if err != nil {
+		return err
+	}
+
+	progress("background")
+	composer, err := GetBackgroundComposer(p.options.BackgroundStyle)
+	if err != nil {
+		return err
+	}
+	return p.client.WriteBackground(p.State.Source, p.State.Level, p.output, composer, cover, p.options.BackgroundFilters)
+}
+
+func (p *Pipeline) export(progress func(step string)) error {
+	progress("ped")
+	if err := p.options.ComboAnimation.Validate(); err != nil {
+		return err
+	}
+	if err := p.options.ScoreAnimation.Validate(); err != nil {
+		return err
+	}
+	exoObjects := []ExoObject{}
+	if err := p.options.ComboCounter.Validate(); err != nil {
+		return err
+	}
+	pedOptions := PedOptions{ComboAnimation: p.options.ComboAnimation, ScoreAnimation: p.options.ScoreAnimation, ComboCounter: p.options.ComboCounter}
+	if p.options.ComboMilestone {
+		pedOptions.Milestones = CalculateMilestones(p.State.Frames, 100, p.options.ComboCounter)
+		exoObjects = append(exoObjects, MilestoneExoObject)
+	}
+	if p.options.RankEffect {
+		pedOptions.RankCrossings = CalculateRankCrossings(p.State.Frames, p.State.Level.Rating)
+		exoObjects = append(exoObjects, RankExoObject)
+	}
+	labelsDir, err := WriteLabelAssets(nil, p.options.OutDir, p.options.Labels)
+	if err != nil {
+		return err
+	}
+	pedOptions.Labels = labelsDir
+	err = WritePedFile(p.State.Frames, p.options.Assets, p.options.ApCombo, filepath.Join(p.options.OutDir, "data.ped"), sonolus.LevelInfo{Rating: p.State.Level.Rating}, pedOptions)
+	if err != nil {
+		return err
+	}
+
+	progress("exo")
+	finaleVideo := FinaleVideo(p.options.ApCombo)
+	// FC用の動画が用意されていない場合はAP用の動画を使う
+	if _, err := os.Stat(filepath.Join(p.options.Assets, finaleVideo)); err != nil {
+		finaleVideo = FinaleVideo(true)
+	}
+	return WriteExoFiles(p.options.Assets, p.options.OutDir, p.State.Level.Title, FormatExoArtistsIn(p.options.Labels, p.State.Source, p.State.Level), ExoOptions{
+		CoverFormat: p.options.Cover.Format,
+		Labels:      p.options.Labels,
+		Objects:     exoObjects,
+		Finale: ExoFinale{
+			LastNoteTime: CalculateChartEnd(p.LevelData(), p.State.Frames, 0),
+			Video:        finaleVideo,
+		},
+	})
+}
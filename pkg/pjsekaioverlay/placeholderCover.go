@@ -0,0 +1,117 @@
+package pjsekaioverlay
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// サーバーに画像がない（URLが空か404）
+var errMissingAsset = errors.New("missing asset")
+
+const (
+	placeholderCoverSize    = 512
+	placeholderCoverPadding = 40
+	placeholderTitleSize    = 52
+	placeholderArtistsSize  = 26
+	placeholderTitleLines   = 4
+)
+
+// 代わりのジャケットに使う、Windowsのフォントファイルの候補
+var placeholderCoverFonts = []string{"YuGothB.ttc", "meiryob.ttc", "meiryo.ttc", "msgothic.ttc", "msyhbd.ttc", "malgunbd.ttf"}
+
+// 色がない譜面サーバーの場合の色
+const placeholderCoverColor = 0x884499
+
+// 幅に収まるように折り返す（空白があればそこで、単語の区切りのない日本語は文字単位で折り返す）
+func wrapText(drawer *font.Drawer, text string, width int, maxLines int) []string {
+	lines := []string{}
+	line := []rune{}
+	for _, r := range text {
+		if r == '\n' || (len(line) > 0 && drawer.MeasureString(string(append(line, r))).Ceil() > width) {
+			rest := []rune{}
+			if space := strings.LastIndex(string(line), " "); r != '\n' && r != ' ' && space > 0 {
+				rest = []rune(string(line)[space+1:])
+				line = []rune(string(line)[:space])
+			}
+			lines = append(lines, strings.TrimRight(string(line), " "))
+			line = rest
+			if r == '\n' || r == ' ' {
+				continue
+			}
+		}
+		line = append(line, r)
+	}
+	lines = append(lines, strings.TrimRight(string(line), " "))
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		lines[maxLines-1] = string(last[:max(len(last)-1, 0)]) + "…"
+	}
+	return lines
+}
+
+func drawCenteredLines(canvas *image.NRGBA, face font.Face, lines []string, top int) int {
+	drawer := font.Drawer{Dst: canvas, Src: image.White, Face: face}
+	lineHeight := face.Metrics().Height.Ceil()
+	for i, line := range lines {
+		x := (placeholderCoverSize - drawer.MeasureString(line).Ceil()) / 2
+		drawer.Dot = fixed.P(x, top+face.Metrics().Ascent.Ceil()+i*lineHeight)
+		drawer.DrawString(line)
+	}
+	return top + len(lines)*lineHeight
+}
+
+// ジャケットがない譜面の代わりに、譜面サーバーの色で曲名と作曲者を書いた画像を作る（文字を含むフォントがない場合は色だけ）
+func renderPlaceholderCover(source Source, level sonolus.LevelInfo, fontPaths []string) image.Image {
+	base := source.Color
+	if base == 0 {
+		base = placeholderCoverColor
+	}
+	r, g, b := float64(base>>16&0xff), float64(base>>8&0xff), float64(base&0xff)
+	canvas := image.NewNRGBA(image.Rect(0, 0, placeholderCoverSize, placeholderCoverSize))
+	// 上から下に暗くする
+	for y := 0; y < placeholderCoverSize; y++ {
+		shade := 0.85 - 0.45*float64(y)/placeholderCoverSize
+		c := color.NRGBA{uint8(r * shade), uint8(g * shade), uint8(b * shade), 0xff}
+		for x := 0; x < placeholderCoverSize; x++ {
+			canvas.SetNRGBA(x, y, c)
+		}
+	}
+
+	candidates := append([]string{}, fontPaths...)
+	for _, name := range placeholderCoverFonts {
+		candidates = append(candidates, filepath.Join(os.Getenv("WINDIR"), "Fonts", name))
+	}
+	coverFont, err := chooseLabelFont(candidates, level.Title+level.Artists)
+	if err != nil {
+		return canvas
+	}
+	titleFace, err := opentype.NewFace(coverFont, &opentype.FaceOptions{Size: placeholderTitleSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return canvas
+	}
+	defer titleFace.Close()
+	artistsFace, err := opentype.NewFace(coverFont, &opentype.FaceOptions{Size: placeholderArtistsSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return canvas
+	}
+	defer artistsFace.Close()
+
+	width := placeholderCoverSize - placeholderCoverPadding*2
+	titleLines := wrapText(&font.Drawer{Face: titleFace}, level.Title, width, placeholderTitleLines)
+	artistsLines := wrapText(&font.Drawer{Face: artistsFace}, level.Artists, width, 2)
+	// 全体を上下の中央に置く
+	height := len(titleLines)*titleFace.Metrics().Height.Ceil() + placeholderArtistsSize + len(artistsLines)*artistsFace.Metrics().Height.Ceil()
+	top := drawCenteredLines(canvas, titleFace, titleLines, (placeholderCoverSize-height)/2)
+	drawCenteredLines(canvas, artistsFace, artistsLines, top+placeholderArtistsSize)
+	return canvas
+}
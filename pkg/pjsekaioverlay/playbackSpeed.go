@@ -0,0 +1,45 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+const (
+	minPlaybackSpeed = 0.1
+	maxPlaybackSpeed = 4
+)
+
+func ValidatePlaybackSpeed(speed float64) error {
+	if speed < minPlaybackSpeed || speed > maxPlaybackSpeed {
+		return fmt.Errorf("再生速度が不正です。(Invalid playback speed.) [%f]", speed)
+	}
+	return nil
+}
+
+// 再生速度を変えた録画に合わせて、譜面データのBPMとオフセットを変える（以降に求める時間は全て1/speed倍になる）
+//
+// 元の譜面データは変更しない
+func ScaleLevelData(levelData sonolus.LevelData, speed float64) sonolus.LevelData {
+	if speed == 1 {
+		return levelData
+	}
+	scaled := levelData
+	scaled.BgmOffset = levelData.BgmOffset / speed
+	scaled.Entities = make([]sonolus.LevelDataEntity, len(levelData.Entities))
+	for i, entity := range levelData.Entities {
+		if entity.Archetype == "#BPM_CHANGE" {
+			data := make([]sonolus.LevelDataEntityValue, len(entity.Data))
+			copy(data, entity.Data)
+			for j := range data {
+				if data[j].Name == "#BPM" {
+					data[j].Value *= speed
+				}
+			}
+			entity.Data = data
+		}
+		scaled.Entities[i] = entity
+	}
+	return scaled
+}
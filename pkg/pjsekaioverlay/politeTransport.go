@@ -0,0 +1,64 @@
+package pjsekaioverlay
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const UserAgent = "pjsekai-overlay-APPEND/" + Version + " (+https://github.com/TootieJin/pjsekai-overlay-APPEND)"
+
+// 既定のリクエストの間隔
+const DefaultRequestInterval = 200 * time.Millisecond
+
+// User-Agentを付け、サーバーごとにリクエストの間隔を空けて送る
+//
+// 複数の譜面を同時に生成する場合も、同じサーバーへのリクエストは間隔が空く
+type politeTransport struct {
+	base     http.RoundTripper
+	interval time.Duration
+
+	mutex sync.Mutex
+	next  map[string]time.Time
+}
+
+func (t *politeTransport) wait(host string) {
+	t.mutex.Lock()
+	now := time.Now()
+	start := t.next[host]
+	if start.Before(now) {
+		start = now
+	}
+	t.next[host] = start.Add(t.interval)
+	t.mutex.Unlock()
+	time.Sleep(time.Until(start))
+}
+
+func (t *politeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.interval > 0 {
+		t.wait(request.URL.Host)
+	}
+	// RoundTripperはリクエストを書き換えてはいけないので複製する
+	request = request.Clone(request.Context())
+	if request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", UserAgent)
+	}
+	return t.base.RoundTrip(request)
+}
+
+// リクエストの最小の間隔を指定する（0以下で制限しない）
+func WithRequestInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.requestInterval = interval
+	}
+}
+
+func (c *Client) wrapPoliteTransport() {
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *c.httpClient
+	wrapped.Transport = &politeTransport{base: base, interval: c.requestInterval, next: map[string]time.Time{}}
+	c.httpClient = &wrapped
+}
@@ -0,0 +1,311 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	DefaultPreviewStep  = 4
+	DefaultPreviewWidth = 480
+	// 最後のノーツの後に描画する長さ（秒）
+	previewTail = 1.0
+	// 判定文字を表示する長さ（秒）
+	previewJudgmentDuration = 0.3
+)
+
+// GIFの色（各色6段階）。色を探さずに添字を求められるようにする
+var previewPalette = func() color.Palette {
+	colors := make(color.Palette, 0, 216)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				colors = append(colors, color.RGBA{uint8(r * 51), uint8(g * 51), uint8(b * 51), 0xff})
+			}
+		}
+	}
+	return colors
+}()
+
+func quantizePreview(dst *image.Paletted, src *image.RGBA) {
+	for i := 0; i < len(src.Pix)/4; i++ {
+		pixel := src.Pix[i*4 : i*4+3]
+		dst.Pix[i] = uint8((int(pixel[0])+25)/51*36 + (int(pixel[1])+25)/51*6 + (int(pixel[2])+25)/51)
+	}
+}
+
+type PreviewFormat string
+
+const (
+	PreviewFormatGif PreviewFormat = "gif"
+	// ffmpegが必要
+	PreviewFormatMp4 PreviewFormat = "mp4"
+)
+
+// 拡張子から形式を決める
+func PreviewFormatFromPath(path string) (PreviewFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gif":
+		return PreviewFormatGif, nil
+	case ".mp4":
+		return PreviewFormatMp4, nil
+	}
+	return "", fmt.Errorf("プレビューの形式が不正です。(Invalid preview format.) [%s] (.gif, .mp4)", path)
+}
+
+type PreviewOptions struct {
+	// 何フレーム（60fps）ごとに描画するか
+	Step int
+	// 幅（高さは16:9に合わせる）
+	Width int
+	// 背景（nilの場合は黒）。Videoを指定した場合は使わない
+	Background image.Image
+	// 下に重ねる録画（mp4の場合のみ）。同期の確認用
+	Video string
+	// 譜面の0秒が動画の何秒目か
+	Offset float64
+}
+
+func DefaultPreviewOptions() PreviewOptions {
+	return PreviewOptions{Step: DefaultPreviewStep, Width: DefaultPreviewWidth, Offset: defaultWebEmbedOffset()}
+}
+
+func (options PreviewOptions) Validate(format PreviewFormat) error {
+	if options.Step < 1 {
+		return fmt.Errorf("フレームの間隔は1以上にして下さい。(The frame step must be at least 1.) [%d]", options.Step)
+	}
+	if options.Width < 16 || options.Width > 1920 {
+		return fmt.Errorf("幅は16から1920の間にして下さい。(The width must be between 16 and 1920.) [%d]", options.Width)
+	}
+	if options.Video != "" && format != PreviewFormatMp4 {
+		return fmt.Errorf("録画を重ねる場合はmp4で出力して下さい。(Use mp4 to preview over a recording.)")
+	}
+	return nil
+}
+
+// 1920x1080の座標で、テンプレートのスコア・コンボの位置に簡易的に描画する（アニメーションや画像の素材は使わない）
+type previewRenderer struct {
+	timeline   Timeline
+	options    PreviewOptions
+	width      int
+	height     int
+	scale      float64
+	background image.Image
+	faces      map[float64]font.Face
+	font       *opentype.Font
+	borders    RankBorders
+	combos     []int
+}
+
+func newPreviewRenderer(timeline Timeline, options PreviewOptions) (*previewRenderer, error) {
+	parsed, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("フォントの読み込みに失敗しました。(Failed to load the font.) [%s]", err)
+	}
+	renderer := &previewRenderer{
+		timeline: timeline,
+		options:  options,
+		width:    options.Width,
+		// yuv420pのため偶数にする
+		height:  (options.Width*9/16 + 1) / 2 * 2,
+		scale:   float64(options.Width) / 1920,
+		faces:   map[float64]font.Face{},
+		font:    parsed,
+		borders: getRankBorders(timeline.Level.Rating),
+		combos:  timeline.ComboCounter.Combos(timeline.Frames),
+	}
+	if options.Background != nil && options.Video == "" {
+		scaled := image.NewRGBA(image.Rect(0, 0, renderer.width, renderer.height))
+		draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), options.Background, options.Background.Bounds(), draw.Src, nil)
+		renderer.background = scaled
+	}
+	return renderer, nil
+}
+
+func (r *previewRenderer) close() {
+	for _, face := range r.faces {
+		face.Close()
+	}
+}
+
+func (r *previewRenderer) frameCount() int {
+	frames := r.timeline.Frames
+	end := r.options.Offset + previewTail
+	if len(frames) > 0 {
+		end += frames[len(frames)-1].Time
+	}
+	return max(int(math.Ceil(end*exoFrameRate/float64(r.options.Step))), 1)
+}
+
+func (r *previewRenderer) face(size float64) font.Face {
+	if face, ok := r.faces[size]; ok {
+		return face
+	}
+	face, _ := opentype.NewFace(r.font, &opentype.FaceOptions{Size: size * r.scale, DPI: 72, Hinting: font.HintingNone})
+	r.faces[size] = face
+	return face
+}
+
+func (r *previewRenderer) rect(canvas draw.Image, x1, y1, x2, y2 float64, c color.Color) {
+	bounds := image.Rect(int(x1*r.scale), int(y1*r.scale), int(math.Ceil(x2*r.scale)), int(math.Ceil(y2*r.scale)))
+	draw.Draw(canvas, bounds, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// xは揃える位置、alignは0で左揃え・0.5で中央揃え
+func (r *previewRenderer) text(canvas draw.Image, text string, size float64, x, y float64, align float64, c color.Color) {
+	drawer := font.Drawer{Dst: canvas, Src: image.NewUniform(c), Face: r.face(size)}
+	width := float64(drawer.MeasureString(text).Ceil())
+	drawer.Dot = fixed.P(int(x*r.scale-width*align), int(y*r.scale))
+	drawer.DrawString(text)
+}
+
+// index番目の描画するフレーム（動画上の時間はindex*Step/60秒）
+func (r *previewRenderer) render(canvas *image.RGBA, index int) {
+	videoTime := float64(index*r.options.Step) / exoFrameRate
+	now := videoTime - r.options.Offset
+	frames := r.timeline.Frames
+	current := sort.Search(len(frames), func(i int) bool { return frames[i].Time > now }) - 1
+
+	if r.background != nil {
+		draw.Draw(canvas, canvas.Bounds(), r.background, image.Point{}, draw.Src)
+	} else if r.options.Video != "" {
+		draw.Draw(canvas, canvas.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	} else {
+		draw.Draw(canvas, canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+	}
+
+	shadow := color.NRGBA{0, 0, 0, 0x99}
+	score := 0
+	if current >= 0 {
+		score = frames[current].Score
+	}
+	rank, _ := getRank(score, r.timeline.Level.Rating)
+	r.rect(canvas, 30, 25, 760, 145, shadow)
+	r.text(canvas, fmt.Sprint(score), 64, 50, 100, 0, color.White)
+	r.rect(canvas, 50, 115, 650, 130, color.NRGBA{0x40, 0x40, 0x40, 0xff})
+	r.rect(canvas, 50, 115, 50+600*math.Min(float64(score)/float64(r.borders.Border), 1), 130, color.NRGBA{0xff, 0xe0, 0x60, 0xff})
+	r.text(canvas, strings.ToUpper(rank), 40, 705, 135, 0.5, color.White)
+
+	if current > 0 {
+		combo := r.combos[current]
+		if combo > 0 {
+			r.text(canvas, "COMBO", 36, 1633.5, 400, 0.5, color.White)
+			r.text(canvas, fmt.Sprint(combo), 120, 1633.5, 520, 0.5, color.White)
+		}
+		if now-frames[current].Time < previewJudgmentDuration && frames[current].Judgment != JudgmentNone {
+			r.text(canvas, strings.ToUpper(string(frames[current].Judgment)), 48, 1633.5, 600, 0.5, color.NRGBA{0xff, 0x9c, 0xf0, 0xff})
+		}
+	}
+
+	// 同期の確認用に、動画上の時間を表示する
+	r.rect(canvas, 0, 1030, 300, 1080, shadow)
+	r.text(canvas, fmt.Sprintf("%.2fs", videoTime), 32, 20, 1068, 0, color.White)
+}
+
+// スコアとコンボの位置と同期を確認するための、低解像度のプレビューを書き出す
+func WritePreview(timeline Timeline, path string, options PreviewOptions) error {
+	format, err := PreviewFormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	if err := options.Validate(format); err != nil {
+		return err
+	}
+	renderer, err := newPreviewRenderer(timeline, options)
+	if err != nil {
+		return err
+	}
+	defer renderer.close()
+
+	if format == PreviewFormatGif {
+		return writePreviewGif(renderer, path)
+	}
+	return writePreviewMp4(renderer, path)
+}
+
+func writePreviewGif(renderer *previewRenderer, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+
+	count := renderer.frameCount()
+	animation := &gif.GIF{Image: make([]*image.Paletted, 0, count), Delay: make([]int, 0, count)}
+	canvas := image.NewRGBA(image.Rect(0, 0, renderer.width, renderer.height))
+	// 1/100秒単位なので、ずれがたまらないように合計から求める
+	centiseconds := func(index int) int {
+		return int(math.Round(float64(index*renderer.options.Step) / exoFrameRate * 100))
+	}
+	for i := 0; i < count; i++ {
+		renderer.render(canvas, i)
+		paletted := image.NewPaletted(canvas.Bounds(), previewPalette)
+		quantizePreview(paletted, canvas)
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, max(centiseconds(i+1)-centiseconds(i), 1))
+	}
+	writer := bufio.NewWriter(file)
+	if err := gif.EncodeAll(writer, animation); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
+
+// 描画したフレームをffmpegに渡してmp4にする（録画を指定した場合は、縮小した録画の上に重ねて音声も残す）
+func writePreviewMp4(renderer *previewRenderer, path string) error {
+	rate := fmt.Sprintf("%f", exoFrameRate/float64(renderer.options.Step))
+	size := fmt.Sprintf("%dx%d", renderer.width, renderer.height)
+	args := []string{"-y", "-v", "error"}
+	if renderer.options.Video != "" {
+		args = append(args, "-i", renderer.options.Video)
+	}
+	args = append(args, "-f", "rawvideo", "-pix_fmt", "rgba", "-s", size, "-framerate", rate, "-i", "-")
+	if renderer.options.Video != "" {
+		filter := fmt.Sprintf("[0:v]scale=%d:%d,fps=%s[base];[base][1:v]overlay=eof_action=endall:alpha=premultiplied[v]", renderer.width, renderer.height, rate)
+		args = append(args, "-filter_complex", filter, "-map", "[v]", "-map", "0:a?", "-c:a", "aac")
+	}
+	args = append(args, "-c:v", "libx264", "-preset", "ultrafast", "-pix_fmt", "yuv420p", path)
+
+	cmd := exec.Command("ffmpeg", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("ffmpegの起動に失敗しました。(Failed to start ffmpeg.) [%s]", err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpegの起動に失敗しました。ffmpegをインストールしてPATHに追加して下さい。(Failed to start ffmpeg. Install ffmpeg and add it to PATH.) [%s]", err)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, renderer.width, renderer.height))
+	// 録画が先に終わるとffmpegが読むのをやめるので、書き込みの失敗はffmpegの終了状態で判断する
+	for i := 0; i < renderer.frameCount(); i++ {
+		renderer.render(canvas, i)
+		if _, err := stdin.Write(canvas.Pix); err != nil {
+			break
+		}
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("プレビューの書き出しに失敗しました。(Failed to write the preview.) [%s: %s]", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --limit-rateの値（「500k」「2M」のような1秒あたりのバイト数。k・Mは1024倍）を読み込む
+func ParseRateLimit(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := 1.0
+	number := value
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier, number = 1024, value[:len(value)-1]
+	case "m":
+		multiplier, number = 1024*1024, value[:len(value)-1]
+	case "g":
+		multiplier, number = 1024*1024*1024, value[:len(value)-1]
+	}
+	rate, err := strconv.ParseFloat(number, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("通信速度の上限の形式が不正です。(Invalid rate limit.) [%s]", value)
+	}
+	return int64(rate * multiplier), nil
+}
+
+// 全ての通信で共有する、1秒あたりのバイト数の上限
+type rateLimiter struct {
+	rate int64
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// 1回に読み書きする量（約100ms分）
+func (l *rateLimiter) chunk() int {
+	return int(max(l.rate/10, 1))
+}
+
+// nバイト分の時間を予約し、その時間まで待つ
+func (l *rateLimiter) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	until := l.next
+	l.mutex.Unlock()
+	time.Sleep(time.Until(until))
+}
+
+type rateLimitedBody struct {
+	body    io.ReadCloser
+	limiter *rateLimiter
+}
+
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	if len(p) > b.limiter.chunk() {
+		p = p[:b.limiter.chunk()]
+	}
+	n, err := b.body.Read(p)
+	b.limiter.wait(n)
+	return n, err
+}
+
+func (b *rateLimitedBody) Close() error {
+	return b.body.Close()
+}
+
+// 送受信するボディの速度を制限する
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil && request.Body != http.NoBody {
+		request = request.Clone(request.Context())
+		request.Body = &rateLimitedBody{body: request.Body, limiter: t.limiter}
+	}
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body = &rateLimitedBody{body: response.Body, limiter: t.limiter}
+	return response, nil
+}
+
+// 全ての通信の速度の上限（1秒あたりのバイト数、0以下で制限しない）を指定する
+func WithRateLimit(bytesPerSecond int64) Option {
+	return func(c *Client) {
+		c.rateLimit = bytesPerSecond
+	}
+}
+
+func (c *Client) wrapRateLimitTransport() {
+	if c.rateLimit <= 0 {
+		return
+	}
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *c.httpClient
+	wrapped.Transport = &rateLimitTransport{base: base, limiter: &rateLimiter{rate: c.rateLimit}}
+	c.httpClient = &wrapped
+}
@@ -0,0 +1,167 @@
+package pjsekaioverlay
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 実機の録画から読み取った（OCRした）スコアとコンボ。読み取れなかった値は-1
+type ReferenceSample struct {
+	// 録画上の秒
+	Time  float64 `json:"time"`
+	Score int     `json:"score"`
+	Combo int     `json:"combo"`
+}
+
+// time,score,comboのCSV（見出しの行は省略可、空欄は読み取れなかった値）か、ReferenceSampleのJSONの配列を読み込む
+func LoadReferenceSamples(path string) ([]ReferenceSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("録画の読み取り結果の読み込みに失敗しました。(Loading the capture reference failed.) [%s]", err)
+	}
+	defer file.Close()
+
+	samples := []ReferenceSample{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.NewDecoder(file).Decode(&samples); err != nil {
+			return nil, fmt.Errorf("録画の読み取り結果の読み込みに失敗しました。(Loading the capture reference failed.) [%s]", err)
+		}
+	} else {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = 3
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("録画の読み取り結果の読み込みに失敗しました。(Loading the capture reference failed.) [%s]", err)
+			}
+			time, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+			if err != nil {
+				if line == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("録画の読み取り結果の時間が不正です。(Invalid time in the capture reference.) [%d: %s]", line, record[0])
+			}
+			sample := ReferenceSample{Time: time, Score: -1, Combo: -1}
+			for i, dest := range []*int{&sample.Score, &sample.Combo} {
+				value := strings.TrimSpace(record[i+1])
+				if value == "" {
+					continue
+				}
+				if *dest, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("録画の読み取り結果の値が不正です。(Invalid value in the capture reference.) [%d: %s]", line, value)
+				}
+			}
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("録画の読み取り結果が空です。(The capture reference is empty.) [%s]", path)
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time < samples[j].Time
+	})
+	return samples, nil
+}
+
+// 録画と計算結果の比較
+type ReferenceReport struct {
+	Samples int
+	// 譜面の0秒が録画の何秒目か
+	Offset float64
+	// スコアのずれが最大の点
+	MaxScoreDrift     int
+	MaxScoreDriftTime float64
+	ScoreMismatches   int
+	ComboMismatches   int
+	// コンボが増えた時間のずれ（秒、Offsetを差し引いたもの）
+	MaxTimeDrift     float64
+	MaxTimeDriftTime float64
+}
+
+// 録画でコンボが初めて表示された時間と、計算上のその時間の差
+type referenceComboChange struct {
+	Time  float64
+	Drift float64
+}
+
+func referenceComboChanges(frames []PedFrame, samples []ReferenceSample, counter ComboCounter) []referenceComboChange {
+	changes := []referenceComboChange{}
+	lastCombo := -1
+	for _, sample := range samples {
+		if sample.Combo < 0 || sample.Combo <= lastCombo {
+			continue
+		}
+		lastCombo = sample.Combo
+		for i := 1; i < len(frames); i++ {
+			if counter.Apply(i) == sample.Combo {
+				changes = append(changes, referenceComboChange{Time: sample.Time, Drift: sample.Time - frames[i].Time})
+				break
+			}
+		}
+	}
+	return changes
+}
+
+// コンボの増え方から、譜面の0秒が録画の何秒目かを推定する（録画の間隔の分だけ遅れるので中央値を使う）
+func EstimateReferenceOffset(frames []PedFrame, samples []ReferenceSample, counter ComboCounter) (float64, error) {
+	drifts := []float64{}
+	for _, change := range referenceComboChanges(frames, samples, counter) {
+		drifts = append(drifts, change.Drift)
+	}
+	if len(drifts) == 0 {
+		return 0, fmt.Errorf("録画からコンボの変化を読み取れませんでした。-offsetを指定して下さい。(No combo changes in the capture reference. Please specify -offset.)")
+	}
+	sort.Float64s(drifts)
+	return drifts[len(drifts)/2], nil
+}
+
+// 録画の各時点で、計算したタイムラインが表示するスコア・コンボと比べる
+//
+// ノーツの直後を録画した点はどちらのフレームにもなりうるので、前後tolerance秒以内のフレームのどれかと一致すればよい
+func CompareReference(frames []PedFrame, samples []ReferenceSample, offset float64, tolerance float64, counter ComboCounter) ReferenceReport {
+	report := ReferenceReport{Samples: len(samples), Offset: offset}
+	frameAt := func(time float64) int {
+		return sort.Search(len(frames), func(i int) bool { return frames[i].Time > time }) - 1
+	}
+	for _, sample := range samples {
+		time := sample.Time - offset
+		first, last := max(frameAt(time-tolerance), 0), frameAt(time+tolerance)
+		if last < 0 {
+			continue
+		}
+		if sample.Score >= 0 {
+			drift := math.MaxInt
+			for i := first; i <= last; i++ {
+				drift = min(drift, int(math.Abs(float64(sample.Score-frames[i].Score))))
+			}
+			if drift > 0 {
+				report.ScoreMismatches++
+			}
+			if drift > report.MaxScoreDrift {
+				report.MaxScoreDrift = drift
+				report.MaxScoreDriftTime = sample.Time
+			}
+		}
+		if sample.Combo >= 0 && (sample.Combo < counter.Apply(first) || sample.Combo > counter.Apply(last)) {
+			report.ComboMismatches++
+		}
+	}
+	for _, change := range referenceComboChanges(frames, samples, counter) {
+		if drift := math.Abs(change.Drift - offset); drift > report.MaxTimeDrift {
+			report.MaxTimeDrift = drift
+			report.MaxTimeDriftTime = change.Time
+		}
+	}
+	return report
+}
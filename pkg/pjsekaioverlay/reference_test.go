@@ -0,0 +1,128 @@
+package pjsekaioverlay
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 1秒ごとに10000点ずつ増える3ノーツのタイムライン
+var referenceTestFrames = []PedFrame{
+	{Time: 0, Score: 0, Judgment: JudgmentNone},
+	{Time: 1, Score: 10000, Judgment: JudgmentPerfect},
+	{Time: 2, Score: 20000, Judgment: JudgmentPerfect},
+	{Time: 3, Score: 30000, Judgment: JudgmentPerfect},
+}
+
+func writeReferenceFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReferenceSamples(t *testing.T) {
+	csvPath := writeReferenceFile(t, "reference.csv", "time,score,combo\n2.5,20000,\n1.5, 10000 ,1\n")
+	samples, err := LoadReferenceSamples(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 時間の順に並べ、空欄は-1にする
+	want := []ReferenceSample{{Time: 1.5, Score: 10000, Combo: 1}, {Time: 2.5, Score: 20000, Combo: -1}}
+	if len(samples) != len(want) || samples[0] != want[0] || samples[1] != want[1] {
+		t.Errorf("csv samples = %+v, want %+v", samples, want)
+	}
+
+	jsonPath := writeReferenceFile(t, "reference.json", `[{"time": 1.5, "score": 10000, "combo": 1}]`)
+	samples, err = LoadReferenceSamples(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0] != want[0] {
+		t.Errorf("json samples = %+v, want %+v", samples, want[:1])
+	}
+}
+
+func TestLoadReferenceSamplesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"invalid time", "reference.csv", "1.5,10000,1\nabc,20000,2\n"},
+		{"invalid value", "reference.csv", "1.5,10000,x\n"},
+		{"wrong field count", "reference.csv", "1.5,10000\n"},
+		{"header only", "reference.csv", "time,score,combo\n"},
+		{"invalid json", "reference.json", `{"time": 1.5}`},
+		{"empty json", "reference.json", `[]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LoadReferenceSamples(writeReferenceFile(t, test.file, test.content)); err == nil {
+				t.Error("LoadReferenceSamples accepted an invalid reference")
+			}
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadReferenceSamples(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+			t.Error("LoadReferenceSamples accepted a missing file")
+		}
+	})
+}
+
+func TestEstimateReferenceOffset(t *testing.T) {
+	// 録画は譜面より0.5秒遅れていて、2コンボ目だけ読み取りが0.1秒遅れた
+	samples := []ReferenceSample{
+		{Time: 0.5, Score: 0, Combo: -1},
+		{Time: 1.5, Score: 10000, Combo: 1},
+		{Time: 2.6, Score: 20000, Combo: 2},
+		{Time: 3.5, Score: 30000, Combo: 3},
+	}
+	offset, err := EstimateReferenceOffset(referenceTestFrames, samples, ComboCounter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(offset-0.5) > 1e-9 {
+		t.Errorf("offset = %f, want 0.5", offset)
+	}
+
+	// コンボの開始値を足した数で比べる
+	started := []ReferenceSample{{Time: 1.5, Score: 10000, Combo: 11}, {Time: 2.5, Score: 20000, Combo: 12}}
+	offset, err = EstimateReferenceOffset(referenceTestFrames, started, ComboCounter{Start: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(offset-0.5) > 1e-9 {
+		t.Errorf("offset with combo start = %f, want 0.5", offset)
+	}
+
+	if _, err := EstimateReferenceOffset(referenceTestFrames, []ReferenceSample{{Time: 1, Score: 10000, Combo: -1}}, ComboCounter{}); err == nil {
+		t.Error("EstimateReferenceOffset accepted a reference without combo")
+	}
+}
+
+func TestCompareReference(t *testing.T) {
+	samples := []ReferenceSample{
+		{Time: 1.5, Score: 10000, Combo: 1},
+		// ノーツの直後なので、前のフレームと一致すればよい
+		{Time: 2.52, Score: 10000, Combo: 1},
+		{Time: 2.6, Score: 20000, Combo: 2},
+		// 3ノーツ目の後なのに、スコアもコンボも足りない
+		{Time: 3.6, Score: 29000, Combo: 2},
+	}
+	report := CompareReference(referenceTestFrames, samples, 0.5, 0.05, ComboCounter{})
+	if report.Samples != 4 || report.Offset != 0.5 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.ScoreMismatches != 1 || report.MaxScoreDrift != 1000 || report.MaxScoreDriftTime != 3.6 {
+		t.Errorf("score drift = %d at %f (%d mismatches), want 1000 at 3.6 (1)", report.MaxScoreDrift, report.MaxScoreDriftTime, report.ScoreMismatches)
+	}
+	if report.ComboMismatches != 1 {
+		t.Errorf("combo mismatches = %d, want 1", report.ComboMismatches)
+	}
+	if math.Abs(report.MaxTimeDrift-0.1) > 1e-9 || report.MaxTimeDriftTime != 2.6 {
+		t.Errorf("time drift = %f at %f, want 0.1 at 2.6", report.MaxTimeDrift, report.MaxTimeDriftTime)
+	}
+}
@@ -0,0 +1,293 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 出力先のURLに応じたOutputを返す
+//
+//   - s3://bucket/prefix：S3互換のストレージ（認証情報はAWS_ACCESS_KEY_IDなどの環境変数、AWS_ENDPOINT_URLでS3以外のサービス）
+//   - webdav://host/path、webdavs://host/path：WebDAV（ディレクトリはMKCOLで作る）
+//   - http://、https://：それぞれのファイルのURLにPUTする
+//
+// URLでない場合はローカルのディレクトリとする
+func (c *Client) OpenOutput(target string) (Output, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || !strings.Contains(target, "://") {
+		return DirOutput(target), nil
+	}
+	switch parsed.Scheme {
+	case "s3":
+		return newS3Output(c.httpClient, parsed)
+	case "webdav", "webdavs":
+		scheme := "http"
+		if parsed.Scheme == "webdavs" {
+			scheme = "https"
+		}
+		base := *parsed
+		base.Scheme = scheme
+		return &httpOutput{client: c.httpClient, base: &base, webdav: true, collections: map[string]bool{}}, nil
+	case "http", "https":
+		return &httpOutput{client: c.httpClient, base: parsed}, nil
+	}
+	return nil, fmt.Errorf("対応していない出力先です。(Unsupported output destination.) [%s] (s3, webdav, webdavs, http, https)", parsed.Scheme)
+}
+
+// 出力先ディレクトリのファイルを全てoutputに書き込む（名前はdirからの相対パス）
+func UploadDir(dir string, output Output) error {
+	return filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("ファイルの読み込みに失敗しました。(Failed to read file.) [%s]", err)
+		}
+		defer file.Close()
+		if err := writeOutputFile(output, filepath.ToSlash(name), func(w io.Writer) error {
+			_, err := io.Copy(w, file)
+			return err
+		}); err != nil {
+			return fmt.Errorf("%s [%s]", err, filepath.ToSlash(name))
+		}
+		return nil
+	})
+}
+
+// 閉じたときに内容をまとめて送るファイル
+type remoteFile struct {
+	bytes.Buffer
+	upload func(data []byte) error
+}
+
+func (file *remoteFile) Close() error {
+	return file.upload(file.Bytes())
+}
+
+func checkUploadResponse(resp *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("アップロードに失敗しました。(Upload failed.) [%d: %s]", resp.StatusCode, resp.Request.URL.Redacted())
+	}
+	return nil
+}
+
+type httpOutput struct {
+	client *http.Client
+	base   *url.URL
+	webdav bool
+
+	mu          sync.Mutex
+	collections map[string]bool
+}
+
+func (output *httpOutput) fileUrl(name string) *url.URL {
+	fileUrl := *output.base
+	fileUrl.User = nil
+	fileUrl.Path = path.Join("/", output.base.Path, name)
+	if strings.HasSuffix(name, "/") {
+		fileUrl.Path += "/"
+	}
+	return &fileUrl
+}
+
+func (output *httpOutput) request(method string, name string, body []byte) error {
+	request, err := http.NewRequest(method, output.fileUrl(name).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if user := output.base.User; user != nil {
+		password, _ := user.Password()
+		request.SetBasicAuth(user.Username(), password)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" && method == http.MethodPut {
+		request.Header.Set("Content-Type", contentType)
+	}
+	resp, err := output.client.Do(request)
+	// 既にあるコレクションへのMKCOLは405が返る
+	if method == "MKCOL" && err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		return nil
+	}
+	return checkUploadResponse(resp, err)
+}
+
+// WebDAVでは親のコレクションがないとPUTできないので、上から順に作る
+func (output *httpOutput) makeCollections(name string) error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	// 出力先のコレクション自体もなければ作る
+	if !output.collections["/"] {
+		if err := output.request("MKCOL", "/", nil); err != nil {
+			return err
+		}
+		output.collections["/"] = true
+	}
+	dir := ""
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+		dir += part + "/"
+		if output.collections[dir] {
+			continue
+		}
+		if err := output.request("MKCOL", dir, nil); err != nil {
+			return err
+		}
+		output.collections[dir] = true
+	}
+	return nil
+}
+
+func (output *httpOutput) Create(name string) (io.WriteCloser, error) {
+	return &remoteFile{upload: func(data []byte) error {
+		if output.webdav {
+			if err := output.makeCollections(name); err != nil {
+				return err
+			}
+		}
+		return output.request(http.MethodPut, name, data)
+	}}, nil
+}
+
+// S3の署名（Signature Version 4）に使う認証情報
+type s3Output struct {
+	client   *http.Client
+	endpoint *url.URL
+	// パス形式（endpoint/bucket/key）の場合のバケット
+	pathBucket string
+	prefix     string
+
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func newS3Output(client *http.Client, target *url.URL) (*s3Output, error) {
+	output := &s3Output{
+		client:       client,
+		prefix:       strings.Trim(target.Path, "/"),
+		region:       os.Getenv("AWS_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if output.accessKey == "" || output.secretKey == "" {
+		return nil, fmt.Errorf("S3の認証情報がありません。AWS_ACCESS_KEY_IDとAWS_SECRET_ACCESS_KEYを設定して下さい。(No S3 credentials. Please set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.)")
+	}
+	if output.region == "" {
+		output.region = "us-east-1"
+	}
+	// S3以外のサービス（MinIO、R2など）はパス形式で送る
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	var err error
+	if endpoint != "" {
+		output.endpoint, err = url.Parse(endpoint)
+		output.pathBucket = target.Host
+	} else {
+		output.endpoint, err = url.Parse("https://" + target.Host + ".s3." + output.region + ".amazonaws.com")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+	return output, nil
+}
+
+// URIのパスの符号化（AWSの仕様では、英数字と-._~以外を全て符号化する）
+func awsEscapePath(value string) string {
+	builder := strings.Builder{}
+	for _, b := range []byte(value) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-._~/", b) >= 0 {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func (output *s3Output) put(name string, data []byte) error {
+	key := path.Join(output.prefix, name)
+	objectPath := "/" + key
+	if output.pathBucket != "" {
+		objectPath = "/" + output.pathBucket + objectPath
+	}
+	escapedPath := awsEscapePath(path.Join("/", output.endpoint.Path, objectPath))
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(data)
+	hash := hex.EncodeToString(payloadHash[:])
+
+	headers := [][2]string{{"host", output.endpoint.Host}, {"x-amz-content-sha256", hash}, {"x-amz-date", amzDate}}
+	if output.sessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", output.sessionToken})
+	}
+	canonicalHeaders, signedHeaders := "", []string{}
+	for _, header := range headers {
+		canonicalHeaders += header[0] + ":" + header[1] + "\n"
+		signedHeaders = append(signedHeaders, header[0])
+	}
+	canonicalRequest := strings.Join([]string{http.MethodPut, escapedPath, "", canonicalHeaders, strings.Join(signedHeaders, ";"), hash}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + output.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	signingKey := hmacSha256(hmacSha256(hmacSha256(hmacSha256([]byte("AWS4"+output.secretKey), date), output.region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	objectUrl := *output.endpoint
+	objectUrl.Path = ""
+	objectUrl.RawPath = ""
+	request, err := http.NewRequest(http.MethodPut, objectUrl.String()+escapedPath, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, header := range headers[1:] {
+		request.Header.Set(header[0], header[1])
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", output.accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return checkUploadResponse(output.client.Do(request))
+}
+
+func (output *s3Output) Create(name string) (io.WriteCloser, error) {
+	return &remoteFile{upload: func(data []byte) error {
+		return output.put(name, data)
+	}}, nil
+}
@@ -0,0 +1,64 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// スコアの数字が変わるときの動き
+type ScoreAnimationMode string
+
+const (
+	// ノーツごとにすぐ切り替える
+	ScoreAnimationInstant ScoreAnimationMode = "instant"
+	// Durationフレームかけて一定の速さで数え上げる
+	ScoreAnimationLinear ScoreAnimationMode = "linear"
+	// ゲームと同じく、最初に大きく増えて終わりにかけて減速する
+	ScoreAnimationGame ScoreAnimationMode = "game"
+)
+
+var ScoreAnimationModes = []string{string(ScoreAnimationInstant), string(ScoreAnimationLinear), string(ScoreAnimationGame)}
+
+// スコアの数え上げのアニメーション
+type ScoreAnimation struct {
+	Mode     ScoreAnimationMode
+	Duration float64 // フレーム数
+}
+
+var DefaultScoreAnimation = ScoreAnimation{
+	Mode:     ScoreAnimationInstant,
+	Duration: 10,
+}
+
+// 未指定（空）の場合はinstantとして扱う
+func (animation ScoreAnimation) Validate() error {
+	if animation.Mode == "" {
+		return nil
+	}
+	if !slices.Contains(ScoreAnimationModes, string(animation.Mode)) {
+		return fmt.Errorf("不明なスコアのアニメーションです。(Unknown score animation.) [%s] (%s)", animation.Mode, strings.Join(ScoreAnimationModes, ", "))
+	}
+	if animation.Animated() && animation.Duration <= 0 {
+		return fmt.Errorf("アニメーションの長さが不正です。(Invalid animation duration.) [%f]", animation.Duration)
+	}
+	return nil
+}
+
+// アニメーションするか（未指定の場合もすぐ切り替える）
+func (animation ScoreAnimation) Animated() bool {
+	return animation.Mode != "" && animation.Mode != ScoreAnimationInstant
+}
+
+// 切り替わってからframeフレーム後に表示するスコア（sekai.objのPED_SCORE_PROGRESSと同じ計算）
+func (animation ScoreAnimation) Value(from int, to int, frame float64) int {
+	if !animation.Animated() || frame >= animation.Duration {
+		return to
+	}
+	t := max(frame, 0) / animation.Duration
+	if animation.Mode == ScoreAnimationGame {
+		t = 1 - math.Pow(1-t, 3)
+	}
+	return from + int(math.Floor(float64(to-from)*t))
+}
@@ -0,0 +1,63 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// スコアの区切り文字
+type ScoreSeparator string
+
+const (
+	ScoreSeparatorNone       ScoreSeparator = "none"
+	ScoreSeparatorComma      ScoreSeparator = "comma"
+	ScoreSeparatorPeriod     ScoreSeparator = "period"
+	ScoreSeparatorSpace      ScoreSeparator = "space"
+	ScoreSeparatorApostrophe ScoreSeparator = "apostrophe"
+)
+
+// スコアの表示形式
+type ScoreFormat struct {
+	Separator ScoreSeparator
+	// 0埋めする桁数（0の場合は0埋めしない）
+	Digits int
+	// 区切り文字も数字と同じ幅で並べる
+	Monospace bool
+}
+
+var DefaultScoreFormat = ScoreFormat{Separator: ScoreSeparatorNone, Digits: 0, Monospace: true}
+
+// ロケール（none、en、de、frなど）から3桁ごとの区切り文字を求める
+func ParseScoreLocale(locale string) (ScoreSeparator, error) {
+	if locale == "" || locale == "none" {
+		return ScoreSeparatorNone, nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("不明なロケールです。(Unknown locale.) [%s]", locale)
+	}
+	formatted := message.NewPrinter(tag).Sprintf("%d", 1000)
+	separator := strings.TrimSuffix(strings.TrimPrefix(formatted, "1"), "000")
+	switch separator {
+	case "":
+		return ScoreSeparatorNone, nil
+	case ",", "،", "٬":
+		return ScoreSeparatorComma, nil
+	case ".":
+		return ScoreSeparatorPeriod, nil
+	case "'", "’":
+		return ScoreSeparatorApostrophe, nil
+	}
+	// 狭いノーブレークスペースなど
+	return ScoreSeparatorSpace, nil
+}
+
+func (format ScoreFormat) Validate() error {
+	if format.Digits < 0 || format.Digits > 12 {
+		return fmt.Errorf("スコアの桁数が不正です。(Invalid number of score digits.) [%d]", format.Digits)
+	}
+	return nil
+}
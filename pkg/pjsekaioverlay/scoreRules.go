@@ -0,0 +1,125 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ゲームのバージョンごとのスコア計算のルール
+type ScoreRules struct {
+	Name string `json:"name"`
+	// このルールが使われ始めた日（YYYY-MM-DD）
+	Since string `json:"since"`
+	// WEIGHT_MAPから変更するノーツの重み（ロングのtickなど）
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// comboFaxIntervalコンボごとにcomboFaxStepずつ、comboFaxMaxまで上がる
+	ComboFaxInterval int     `json:"comboFaxInterval"`
+	ComboFaxStep     float64 `json:"comboFaxStep"`
+	ComboFaxMax      float64 `json:"comboFaxMax"`
+}
+
+var DefaultScoreRules = ScoreRules{
+	Name:             "default",
+	Since:            "2020-09-30",
+	ComboFaxInterval: 100,
+	ComboFaxStep:     0.01,
+	ComboFaxMax:      1.1,
+}
+
+// 組み込みのルール（--score-rulesで追加できる）
+//
+// 日付で選ぶ場合、その日までに始まったルールのうち最も新しいものを使う
+var BuiltinScoreRules = []ScoreRules{DefaultScoreRules}
+
+func (rules ScoreRules) weight(archetype string) float64 {
+	if weight, ok := rules.Weights[archetype]; ok {
+		return weight
+	}
+	return WEIGHT_MAP[archetype]
+}
+
+// 省略された項目は現行のルールと同じにする
+func (rules ScoreRules) withDefaults() ScoreRules {
+	if rules.ComboFaxInterval == 0 {
+		rules.ComboFaxInterval = DefaultScoreRules.ComboFaxInterval
+		rules.ComboFaxStep = DefaultScoreRules.ComboFaxStep
+	}
+	if rules.ComboFaxMax == 0 {
+		rules.ComboFaxMax = DefaultScoreRules.ComboFaxMax
+	}
+	return rules
+}
+
+func (rules ScoreRules) Validate() error {
+	if _, err := time.Parse(time.DateOnly, rules.Since); err != nil {
+		return fmt.Errorf("ルールの日付が不正です。(Invalid rule date.) [%s: %s]", rules.Name, rules.Since)
+	}
+	if rules.ComboFaxInterval <= 0 || rules.ComboFaxMax < 1 {
+		return fmt.Errorf("ルールのコンボ補正が不正です。(Invalid combo bonus in rules.) [%s]", rules.Name)
+	}
+	for archetype, weight := range rules.Weights {
+		// 重みが0のアーキタイプは読み込み時に捨てるので、重みを付けられない
+		if base, ok := WEIGHT_MAP[archetype]; !ok || weight < 0 || (base == 0 && weight > 0) {
+			return fmt.Errorf("ルールのノーツの重みが不正です。(Invalid note weight in rules.) [%s: %s]", rules.Name, archetype)
+		}
+	}
+	return nil
+}
+
+// ルールの一覧（JSONの配列）を読み込む
+func LoadScoreRules(path string) ([]ScoreRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("スコアのルールの読み込みに失敗しました。(Loading score rules failed.) [%s]", err)
+	}
+	rulesets := []ScoreRules{}
+	if err := json.Unmarshal(data, &rulesets); err != nil {
+		return nil, fmt.Errorf("スコアのルールの読み込みに失敗しました。(Loading score rules failed.) [%s]", err)
+	}
+	for i := range rulesets {
+		rulesets[i] = rulesets[i].withDefaults()
+		if err := rulesets[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rulesets, nil
+}
+
+// ルール名、または日付（YYYY-MM-DD）の時点で使われていたルールを選ぶ
+func SelectScoreRules(rulesets []ScoreRules, value string) (ScoreRules, error) {
+	if value == "" {
+		return DefaultScoreRules, nil
+	}
+	for _, rules := range rulesets {
+		if rules.Name == value {
+			return rules, nil
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		names := []string{}
+		for _, rules := range rulesets {
+			names = append(names, rules.Name)
+		}
+		return ScoreRules{}, fmt.Errorf("不明なスコアのルールです。(Unknown score rules.) [%s] (%s)", value, strings.Join(names, ", "))
+	}
+	sorted := make([]ScoreRules, len(rulesets))
+	copy(sorted, rulesets)
+	// 日付の形式がそろっているので文字列で比べられる
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Since < sorted[j].Since
+	})
+	var selected *ScoreRules
+	for i := range sorted {
+		if sorted[i].Since <= value {
+			selected = &sorted[i]
+		}
+	}
+	if selected == nil {
+		return ScoreRules{}, fmt.Errorf("指定した日付のスコアのルールがありません。(No score rules for the specified date.) [%s]", value)
+	}
+	return *selected, nil
+}
@@ -0,0 +1,189 @@
+package pjsekaioverlay
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// Sonolusのコレクションパッケージ内で、ファイルを置く場所
+const scpRepositoryPath = "/sonolus/repository/"
+
+func ScpFileName(chartId string) string {
+	return SanitizeFileName(chartId) + ".scp"
+}
+
+// 譜面の詳細を、Sonolusの仕様にない項目も含めてそのまま取得する
+func (c *Client) fetchRawLevelDetails(source Source, chartId string) (map[string]any, error) {
+	if err := ValidateChartId(source, chartId); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Get("https://" + source.Host + "/sonolus/levels/" + chartId)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("譜面が見つかりませんでした。(Chart not found.) [%d]", resp.StatusCode)
+	}
+	var details map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return nil, fmt.Errorf("譜面情報の読み込みに失敗しました。(Loading chart info failed.) [%s]", err)
+	}
+	return details, nil
+}
+
+// 譜面情報に含まれるSRL（urlとhashを持つオブジェクト）を全て集める
+func collectSrls(value any, srls *[]map[string]any) {
+	switch value := value.(type) {
+	case map[string]any:
+		if url, ok := value["url"].(string); ok && url != "" {
+			if _, ok := value["hash"].(string); ok {
+				*srls = append(*srls, value)
+				return
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectSrls(value[key], srls)
+		}
+	case []any:
+		for _, item := range value {
+			collectSrls(item, srls)
+		}
+	}
+}
+
+func (c *Client) downloadSrl(source Source, url string) ([]byte, error) {
+	joined, err := sonolus.JoinUrl("https://"+source.Host, url)
+	if err != nil {
+		return nil, fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+	resp, err := c.httpClient.Get(joined)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("ファイルが見つかりませんでした。(File not found.) [%d: %s]", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ファイルのダウンロードに失敗しました。(Failed to download file.) [%s]", err)
+	}
+	return data, nil
+}
+
+// 譜面を、データ・ジャケット・曲・背景・エンジンのファイルごとSonolusのコレクションパッケージ（.scp）に書き出す
+//
+// 動画に使った版の譜面を残すためのもので、Sonolusで読み込むとそのまま遊べる
+func (c *Client) WriteLevelPackage(source Source, chartId string, path string) error {
+	details, err := c.fetchRawLevelDetails(source, chartId)
+	if err != nil {
+		return err
+	}
+	item, ok := details["item"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("譜面情報の読み込みに失敗しました。(Loading chart info failed.) [%s]", chartId)
+	}
+
+	srls := []map[string]any{}
+	collectSrls(item, &srls)
+	files := map[string][]byte{}
+	for _, srl := range srls {
+		url := srl["url"].(string)
+		hash := srl["hash"].(string)
+		if _, ok := files[hash]; ok && hash != "" {
+			srl["url"] = scpRepositoryPath + hash
+			continue
+		}
+		data, err := c.downloadSrl(source, url)
+		if err != nil {
+			return err
+		}
+		// ハッシュがない場合は内容から求める
+		if hash == "" {
+			sum := sha1.Sum(data)
+			hash = hex.EncodeToString(sum[:])
+			srl["hash"] = hash
+		}
+		files[hash] = data
+		srl["url"] = scpRepositoryPath + hash
+	}
+
+	name, _ := item["name"].(string)
+	title, _ := item["title"].(string)
+	description, _ := details["description"].(string)
+	entries := map[string]any{
+		"sonolus/info": map[string]any{
+			"title":         title,
+			"buttons":       []any{map[string]any{"type": "level"}},
+			"configuration": map[string]any{"options": []any{}},
+		},
+		"sonolus/levels/info": map[string]any{
+			"sections": []any{map[string]any{"title": "#LEVEL", "itemType": "level", "items": []any{item}}},
+		},
+		"sonolus/levels/list": map[string]any{
+			"pageCount": 1,
+			"items":     []any{item},
+		},
+		"sonolus/levels/" + name: map[string]any{
+			"item":         item,
+			"description":  description,
+			"actions":      []any{},
+			"hasCommunity": false,
+			"leaderboards": []any{},
+			"sections":     []any{},
+		},
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	names := make([]string, 0, len(entries))
+	for entryName := range entries {
+		names = append(names, entryName)
+	}
+	sort.Strings(names)
+	for _, entryName := range names {
+		writer, err := zipWriter.Create(entryName)
+		if err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+		if err := json.NewEncoder(writer).Encode(entries[entryName]); err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+	}
+	hashes := make([]string, 0, len(files))
+	for hash := range files {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		writer, err := zipWriter.Create("sonolus/repository/" + hash)
+		if err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+		if _, err := writer.Write(files[hash]); err != nil {
+			return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
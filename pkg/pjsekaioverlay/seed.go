@@ -0,0 +1,19 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+)
+
+// 乱数のシードを読み込む（空の場合はランダムに決める）
+func ParseSeed(value string) (uint64, error) {
+	if value == "" {
+		return rand.Uint64(), nil
+	}
+	seed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("シードが不正です。(Invalid seed.) [%s]", value)
+	}
+	return seed, nil
+}
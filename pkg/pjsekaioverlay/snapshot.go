@@ -0,0 +1,105 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// サーバーからの応答を保存し、オフライン時は保存したものを返す
+type snapshotTransport struct {
+	dir     string
+	offline bool
+	strict  bool
+	base    http.RoundTripper
+}
+
+func snapshotFileName(u *url.URL) string {
+	return SanitizeFileName(u.Host + u.Path)
+}
+
+func (t *snapshotTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return t.base.RoundTrip(request)
+	}
+	snapshotPath := filepath.Join(t.dir, snapshotFileName(request.URL))
+
+	if t.offline {
+		data, err := os.ReadFile(snapshotPath)
+		if err != nil {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Header:     http.Header{},
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Request:    request,
+			}, nil
+		}
+		body := io.NopCloser(bytes.NewReader(data))
+		if request.Method == http.MethodHead {
+			body = io.NopCloser(bytes.NewReader(nil))
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Status:        "200 OK",
+			Header:        http.Header{},
+			Body:          body,
+			ContentLength: int64(len(data)),
+			Request:       request,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(request)
+	if err != nil || request.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// 保存に失敗しても通信結果はそのまま使う（strictの場合を除く）
+	err = os.MkdirAll(t.dir, 0755)
+	if err == nil {
+		err = os.WriteFile(snapshotPath, data, 0644)
+	}
+	if err != nil && t.strict {
+		return nil, fmt.Errorf("譜面データの保存に失敗しました。(Failed to save the chart data.) [%s]", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// 譜面情報・譜面データ・画像をdirに保存する。offlineの場合は通信せず、保存したものだけを使う
+func WithSnapshotDir(dir string, offline bool) Option {
+	return func(c *Client) {
+		c.snapshotDir = dir
+		c.offline = offline
+	}
+}
+
+func (c *Client) wrapSnapshotTransport() {
+	if c.snapshotDir == "" {
+		return
+	}
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *c.httpClient
+	wrapped.Transport = &snapshotTransport{dir: c.snapshotDir, offline: c.offline, strict: c.strict, base: base}
+	c.httpClient = &wrapped
+}
+
+// オフラインで生成できるだけのデータが保存されているか
+func HasSnapshot(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return fmt.Errorf("保存された譜面データがありません。一度オンラインで生成して下さい。(No saved chart data. Please generate online once.) [%s]", dir)
+	}
+	return nil
+}
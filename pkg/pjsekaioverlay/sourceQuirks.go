@@ -0,0 +1,54 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// サーバーごとの、譜面情報の省略可能な項目の違いをそろえる（ソースのIDごと）
+var levelNormalizers = map[string]func(level *sonolus.LevelInfo){
+	// 背景を設定していない譜面でも、useDefaultとともにエンジンの既定の背景を返す（Sonolusの仕様ではuseDefaultならitemは使わない）
+	"chart_cyanvas": func(level *sonolus.LevelInfo) {
+		if level.UseBackground.UseDefault {
+			level.UseBackground.Item = sonolus.BackgroundInfo{}
+		}
+	},
+}
+
+func normalizeLevelInfo(source Source, level *sonolus.LevelInfo) {
+	level.Title = strings.TrimSpace(level.Title)
+	level.Artists = strings.TrimSpace(level.Artists)
+	level.Author = strings.TrimSpace(level.Author)
+	for i := range level.Tags {
+		level.Tags[i].Title = strings.TrimSpace(level.Tags[i].Title)
+	}
+	if normalize, ok := levelNormalizers[source.Id]; ok {
+		normalize(level)
+	}
+}
+
+// 動画の生成に必要な項目がない場合はエラーを返す（allowMissingCoverの場合、ジャケットはなくてもよい）
+func validateLevelInfo(source Source, level sonolus.LevelInfo, allowMissingCover bool) error {
+	missing := []string{}
+	if level.Name == "" {
+		missing = append(missing, "name")
+	}
+	if level.Title == "" {
+		missing = append(missing, "title")
+	}
+	if level.Data.Url == "" {
+		missing = append(missing, "data")
+	}
+	if level.Cover.Url == "" && !allowMissingCover {
+		missing = append(missing, "cover")
+	}
+	if level.Engine.Version == 0 {
+		missing = append(missing, "engine.version")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("譜面情報に必要な項目がありません。(Chart info is missing required fields.) [%s: %s]", source.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
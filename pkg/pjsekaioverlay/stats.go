@@ -0,0 +1,117 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+type NoteStats struct {
+	TotalNotes int            `json:"totalNotes"`
+	NoteTypes  map[string]int `json:"noteTypes"`
+	// 譜面の長さ（秒、最初のノーツから最後のノーツまで）
+	Length    float64 `json:"length"`
+	FirstNote float64 `json:"firstNote"`
+	LastNote  float64 `json:"lastNote"`
+	// 1秒ごとのノーツ数
+	Nps        []int   `json:"nps"`
+	PeakNps    int     `json:"peakNps"`
+	PeakTime   float64 `json:"peakTime"`
+	AverageNps float64 `json:"averageNps"`
+	// 推定の難易度
+	Difficulty DifficultyEstimate `json:"difficulty"`
+}
+
+// ノーツの時間を求める（判定のないエンティティは除く）
+func getNoteTimes(levelData sonolus.LevelData) ([]float64, map[string]int) {
+	bpmChanges := GetBpmChanges(levelData)
+	times := []float64{}
+	types := map[string]int{}
+	for _, entity := range levelData.Entities {
+		if WEIGHT_MAP[entity.Archetype] == 0 {
+			continue
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		times = append(times, getTimeFromBpmChanges(bpmChanges, beat)+levelData.BgmOffset)
+		types[entity.Archetype] += 1
+	}
+	sort.Float64s(times)
+	return times, types
+}
+
+func CalculateNoteStats(levelData sonolus.LevelData) NoteStats {
+	times, types := getNoteTimes(levelData)
+	stats := NoteStats{
+		TotalNotes: len(times),
+		NoteTypes:  types,
+		Nps:        []int{},
+		Difficulty: EstimateDifficulty(levelData),
+	}
+	if len(times) == 0 {
+		return stats
+	}
+	stats.FirstNote = times[0]
+	stats.LastNote = times[len(times)-1]
+	stats.Length = stats.LastNote - stats.FirstNote
+
+	stats.Nps = make([]int, int(math.Max(math.Floor(stats.LastNote), 0))+1)
+	for _, time := range times {
+		stats.Nps[int(math.Max(math.Floor(time), 0))] += 1
+	}
+	for second, count := range stats.Nps {
+		if count > stats.PeakNps {
+			stats.PeakNps = count
+			stats.PeakTime = float64(second)
+		}
+	}
+	if stats.Length > 0 {
+		stats.AverageNps = float64(stats.TotalNotes) / stats.Length
+	}
+	return stats
+}
+
+func formatStatsTime(seconds float64) string {
+	return fmt.Sprintf("%d:%02d", int(seconds)/60, int(seconds)%60)
+}
+
+// stats.jsonと、動画の概要欄に貼り付けられる形式のstats.mdを出力する
+func WriteNoteStats(stats NoteStats, title string, destDir string) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("統計の生成に失敗しました。(Failed to generate stats.) [%s]", err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "stats.json"), data, 0644); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+
+	types := make([]string, 0, len(stats.NoteTypes))
+	for name := range stats.NoteTypes {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n\n", title)
+	fmt.Fprintf(&builder, "- Notes: %d\n", stats.TotalNotes)
+	fmt.Fprintf(&builder, "- Length: %s\n", formatStatsTime(stats.Length))
+	fmt.Fprintf(&builder, "- Average NPS: %.2f\n", stats.AverageNps)
+	fmt.Fprintf(&builder, "- Peak NPS: %d (%s)\n", stats.PeakNps, formatStatsTime(stats.PeakTime))
+	fmt.Fprintf(&builder, "- Estimated difficulty: %.1f (jacks: %d, trills: %d, slide ticks: %.1f)\n", stats.Difficulty.Rating, stats.Difficulty.Jacks, stats.Difficulty.Trills, stats.Difficulty.SlideComplexity)
+	builder.WriteString("\n| Type | Count |\n| --- | ---: |\n")
+	for _, name := range types {
+		fmt.Fprintf(&builder, "| %s | %d |\n", name, stats.NoteTypes[name])
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "stats.md"), []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
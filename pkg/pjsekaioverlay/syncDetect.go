@@ -0,0 +1,178 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// 解析に使う音声のサンプリングレート
+	syncSampleRate = 8000
+	// 音量の変化を求める間隔（10ms）
+	syncHop = syncSampleRate / 100
+	// 照合に使う最初のノーツの数
+	syncNotes = 64
+	// ノーツの時間と音の立ち上がりのずれの許容範囲（±20ms）
+	syncTolerance = 2
+)
+
+// 録画の音声の、10msごとの音の立ち上がりの強さ
+type AudioOnsets []float64
+
+// 録画（またはクラップ音の音声）から音の立ち上がりを求める。WAV以外はffmpegで変換する
+func LoadAudioOnsets(path string) (AudioOnsets, error) {
+	samples, err := loadAudioSamples(path, syncSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("録画の音声の読み込みに失敗しました。(Loading recording audio failed.) [%s]", err)
+	}
+
+	onsets := make(AudioOnsets, len(samples)/syncHop)
+	previous := 0.0
+	for i := range onsets {
+		energy := 0.0
+		for _, sample := range samples[i*syncHop : (i+1)*syncHop] {
+			energy += float64(sample) * float64(sample)
+		}
+		// 音量が上がった分だけを立ち上がりとする
+		if energy > previous {
+			onsets[i] = energy - previous
+		}
+		previous = energy
+	}
+	return onsets, nil
+}
+
+// 音声をモノラル・指定したサンプリングレートで読み込む。WAV以外はffmpegで変換する
+func loadAudioSamples(path string, sampleRate int) ([]int16, error) {
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		return readWavSamples(path, sampleRate)
+	}
+	return readFfmpegSamples(path, sampleRate)
+}
+
+func readFfmpegSamples(path string, sampleRate int) ([]int16, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-v", "error", "-i", path, "-vn", "-ac", "1", "-ar", fmt.Sprint(sampleRate), "-f", "s16le", "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	samples := make([]int16, stdout.Len()/2)
+	binary.Read(&stdout, binary.LittleEndian, samples)
+	return samples, nil
+}
+
+// 16bitのPCMのWAVを読み込み、モノラル・指定したサンプリングレートに間引く
+func readWavSamples(path string, sampleRate int) ([]int16, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	var channels, bits int
+	var rate int
+	reader := bytes.NewReader(data[12:])
+	for {
+		var header struct {
+			Id   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+		body := make([]byte, header.Size)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, err
+		}
+		if header.Size%2 == 1 {
+			reader.ReadByte()
+		}
+		switch string(header.Id[:]) {
+		case "fmt ":
+			if len(body) < 16 || binary.LittleEndian.Uint16(body[0:]) != 1 {
+				return nil, errors.New("only PCM WAV files are supported")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			rate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			if bits != 16 || channels == 0 || rate < sampleRate {
+				return nil, fmt.Errorf("unsupported WAV format [%dbit, %dch, %dHz]", bits, channels, rate)
+			}
+			frames := len(body) / 2 / channels
+			samples := make([]int16, 0, frames*sampleRate/rate)
+			for i := 0; ; i++ {
+				frame := i * rate / sampleRate
+				if frame >= frames {
+					break
+				}
+				sum := 0
+				for c := 0; c < channels; c++ {
+					sum += int(int16(binary.LittleEndian.Uint16(body[(frame*channels+c)*2:])))
+				}
+				samples = append(samples, int16(sum/channels))
+			}
+			return samples, nil
+		}
+	}
+}
+
+// 録画の何秒目が譜面の0秒かを求める。confidenceは一致度の平均との比（大きいほど確か）
+func DetectSyncOffset(onsets AudioOnsets, frames []PedFrame) (offset float64, confidence float64, err error) {
+	// 同時押しのノーツは1つにまとめる
+	notes := []int{}
+	for _, frame := range frames {
+		bin := int(frame.Time * 100)
+		if len(notes) == 0 || bin > notes[len(notes)-1] {
+			notes = append(notes, bin)
+		}
+		if len(notes) == syncNotes {
+			break
+		}
+	}
+	if len(notes) < 4 {
+		return 0, 0, errors.New("同期に使うノーツが足りません。(Too few notes to sync.)")
+	}
+	span := notes[len(notes)-1] - notes[0]
+	if len(onsets) <= span {
+		return 0, 0, errors.New("録画が譜面より短いです。(The recording is shorter than the chart.)")
+	}
+
+	peak := func(bin int) float64 {
+		value := 0.0
+		for i := max(bin-syncTolerance, 0); i <= bin+syncTolerance && i < len(onsets); i++ {
+			value = max(value, onsets[i])
+		}
+		return value
+	}
+	best, bestShift, total := -1.0, 0, 0.0
+	// 最初のノーツが録画の範囲に入るずれを全て試す
+	shifts := len(onsets) - span
+	for shift := 0; shift < shifts; shift++ {
+		score := 0.0
+		for _, note := range notes {
+			score += peak(note - notes[0] + shift)
+		}
+		total += score
+		if score > best {
+			best, bestShift = score, shift
+		}
+	}
+	if best <= 0 {
+		return 0, 0, errors.New("録画に音が見つかりませんでした。(No sound was found in the recording.)")
+	}
+	return float64(bestShift-notes[0]) / 100, best / (total / float64(shifts)), nil
+}
@@ -0,0 +1,61 @@
+package pjsekaioverlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 譜面のタグに合わせて選ぶ表示の設定（空の項目は変えない）
+type TagPreset struct {
+	// 一致させるタグ（大文字と小文字は区別しない）
+	Tag string `json:"tag"`
+	// --tintと同じ形式の色
+	Tint         string `json:"tint"`
+	TintElements string `json:"tintElements"`
+	// 難易度の表示（既定は「APPEND」）
+	Difficulty string `json:"difficulty"`
+}
+
+var DefaultTagPresets = []TagPreset{
+	{Tag: "APPEND", Difficulty: "APPEND"},
+	{Tag: "April Fools", Tint: "ff77aa", TintElements: "combo,bar", Difficulty: "APRIL FOOLS"},
+}
+
+// --tag-presetsで指定するJSON（配列）を読み込み、既定のプリセットより優先して並べる
+//
+// 例：[{"tag": "Collab", "tint": "source", "difficulty": "COLLAB"}]
+func LoadTagPresets(path string) ([]TagPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("タグのプリセットの読み込みに失敗しました。(Loading tag presets failed.) [%s]", err)
+	}
+	presets := []TagPreset{}
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("タグのプリセットの読み込みに失敗しました。(Loading tag presets failed.) [%s]", err)
+	}
+	for _, preset := range presets {
+		if strings.TrimSpace(preset.Tag) == "" {
+			return nil, fmt.Errorf("タグが指定されていないプリセットがあります。(A preset has no tag.) [%+v]", preset)
+		}
+		if err := validateExoText("難易度 (Difficulty)", preset.Difficulty); err != nil {
+			return nil, err
+		}
+	}
+	return append(presets, DefaultTagPresets...), nil
+}
+
+// 譜面のタグに一致する最初のプリセットを返す
+func MatchTagPreset(presets []TagPreset, tags []sonolus.Tag) (TagPreset, bool) {
+	for _, preset := range presets {
+		for _, tag := range tags {
+			if strings.EqualFold(strings.TrimSpace(preset.Tag), tag.Title) {
+				return preset, true
+			}
+		}
+	}
+	return TagPreset{}, false
+}
@@ -0,0 +1,115 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 譜面上の時間を、動画編集ソフトのタイムライン上の時間に変換する
+type TimeMapper interface {
+	MapTime(time float64) float64
+}
+
+func MapTime(mapper TimeMapper, time float64) float64 {
+	if mapper == nil {
+		return time
+	}
+	return mapper.MapTime(time)
+}
+
+// 可変フレームレートの録画の各フレームの時刻
+//
+// 動画編集ソフトは録画のフレームを固定フレームレート（60fps）で並べるので、
+// 実際の時刻を「何フレーム目か」に変換してからタイムライン上の時間にする
+type TimeMap struct {
+	timestamps []float64
+}
+
+// フレームの時刻を1行に1つ書いたファイルを読み込む
+//
+// 「pts_time=1.234」「0,1.234」のような行は最後の値を使う（ffprobeの出力をそのまま渡せる）
+func LoadTimeMap(path string) (*TimeMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("タイムマップの読み込みに失敗しました。(Loading time map failed.) [%s]", err)
+	}
+	defer file.Close()
+
+	timestamps := []float64{}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == '=' || r == '\t' || r == ' '
+		})
+		timestamp, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			// ヘッダー行などは読み飛ばす
+			continue
+		}
+		if len(timestamps) > 0 && timestamp <= timestamps[len(timestamps)-1] {
+			return nil, fmt.Errorf("タイムマップの時刻が増加していません。(Time map timestamps are not increasing.) [%d]", line)
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("タイムマップの読み込みに失敗しました。(Loading time map failed.) [%s]", err)
+	}
+	if len(timestamps) < 2 {
+		return nil, fmt.Errorf("タイムマップのフレームが足りません。(Time map has too few frames.) [%d]", len(timestamps))
+	}
+
+	// 最初のフレームを0秒とする
+	start := timestamps[0]
+	for i := range timestamps {
+		timestamps[i] -= start
+	}
+	return &TimeMap{timestamps: timestamps}, nil
+}
+
+func (timeMap *TimeMap) MapTime(time float64) float64 {
+	timestamps := timeMap.timestamps
+	last := len(timestamps) - 1
+	// 範囲外は端のフレーム間隔で延長する
+	i := sort.SearchFloat64s(timestamps, time)
+	if i <= 0 {
+		i = 1
+	} else if i > last {
+		i = last
+	}
+	before, after := timestamps[i-1], timestamps[i]
+	frame := float64(i-1) + (time-before)/(after-before)
+	return frame / exoFrameRate
+}
+
+func MapFrames(frames []PedFrame, mapper TimeMapper) []PedFrame {
+	if mapper == nil {
+		return frames
+	}
+	mapped := make([]PedFrame, len(frames))
+	for i, frame := range frames {
+		frame.Time = mapper.MapTime(frame.Time)
+		mapped[i] = frame
+	}
+	return mapped
+}
+
+func MapMarkers(markers []Marker, mapper TimeMapper) []Marker {
+	if mapper == nil {
+		return markers
+	}
+	mapped := make([]Marker, len(markers))
+	for i, marker := range markers {
+		mapped[i] = newMarker(marker.Type, marker.Label, mapper.MapTime(marker.Time))
+	}
+	return mapped
+}
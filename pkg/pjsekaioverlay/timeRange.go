@@ -0,0 +1,112 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 生成する区間（秒）
+type TimeRange struct {
+	From float64
+	To   float64 // 0以下の場合は最後まで
+}
+
+func (timeRange TimeRange) IsFull() bool {
+	return timeRange.From <= 0 && timeRange.To <= 0
+}
+
+// --from/--toの値を秒に変換する
+//
+// 「90」「90.5」「1:30」は秒、「m12」は12小節目の頭（拍子の変化がない場合はbeatsPerMeasure拍子として数える）
+func ParseTimeRangePoint(value string, levelData sonolus.LevelData, beatsPerMeasure int) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if measureStr, ok := strings.CutPrefix(value, "m"); ok {
+		measure, err := strconv.Atoi(measureStr)
+		if err != nil || measure < 1 {
+			return 0, fmt.Errorf("小節番号が不正です。(Invalid measure number.) [%s]", value)
+		}
+		time, ok := getMeasureTime(levelData, measure, beatsPerMeasure)
+		if !ok {
+			return 0, fmt.Errorf("小節が見つかりませんでした。(Measure not found.) [%s]", value)
+		}
+		return time, nil
+	}
+
+	return parseSeconds(value)
+}
+
+// 「90」「90.5」「1:30」を秒に変換する
+func parseSeconds(value string) (float64, error) {
+	seconds := 0.0
+	for _, part := range strings.Split(value, ":") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("時間の形式が不正です。(Invalid time format.) [%s]", value)
+		}
+		seconds = seconds*60 + number
+	}
+	return seconds, nil
+}
+
+// measure小節目の頭の時間（CalculateBeatsと同様に、拍子の変化やBPM変化の位置で小節を数え直す）
+func getMeasureTime(levelData sonolus.LevelData, measure int, beatsPerMeasure int) (float64, bool) {
+	bpmChanges := GetBpmChanges(levelData)
+	if len(bpmChanges) == 0 {
+		return 0, false
+	}
+	lastBeat := 0.0
+	for _, entity := range levelData.Entities {
+		if beat, err := getValueFromData(entity.Data, "#BEAT"); err == nil && beat > lastBeat {
+			lastBeat = beat
+		}
+	}
+	endTime := getTimeFromBpmChanges(bpmChanges, lastBeat) + levelData.BgmOffset
+	for _, position := range calculateMeasurePositions(levelData, endTime, beatsPerMeasure) {
+		if position.Measure == measure && position.Beat == 1 {
+			return position.Time, true
+		}
+	}
+	return 0, false
+}
+
+func (timeRange TimeRange) Validate() error {
+	if timeRange.From < 0 || (timeRange.To > 0 && timeRange.To <= timeRange.From) {
+		return fmt.Errorf("生成する区間が不正です。(Invalid time range.) [%f-%f]", timeRange.From, timeRange.To)
+	}
+	return nil
+}
+
+// 区間の始まりが0秒になるように譜面データをずらす（以降に求める時間は全てずれる）
+func (timeRange TimeRange) ShiftLevelData(levelData *sonolus.LevelData) {
+	levelData.BgmOffset -= timeRange.From
+}
+
+// ShiftLevelDataでずらした譜面データのフレームから、区間外のものを取り除く
+//
+// 先頭には区間の始まりの時点のスコアを持つフレームを置く。
+// 取り除いたノーツ数を返すので、コンボ数の開始値に足して使う
+func TrimFrames(frames []PedFrame, timeRange TimeRange) ([]PedFrame, int) {
+	if timeRange.IsFull() || len(frames) == 0 {
+		return frames, 0
+	}
+	startScore := frames[0].Score
+	skipped := 0
+	trimmed := []PedFrame{{Time: 0, Score: startScore, Judgment: JudgmentNone}}
+	for _, frame := range frames[1:] {
+		if frame.Time < 0 {
+			trimmed[0].Score = frame.Score
+			skipped++
+			continue
+		}
+		if timeRange.To > 0 && frame.Time > timeRange.To-timeRange.From {
+			break
+		}
+		trimmed = append(trimmed, frame)
+	}
+	return trimmed, skipped
+}
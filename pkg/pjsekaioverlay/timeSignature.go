@@ -0,0 +1,133 @@
+package pjsekaioverlay
+
+import (
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// 拍子の変化を表すアーキタイプ（エンジンが出力する場合のみ）
+const timeSignatureArchetype = "#TIME_SIGNATURE"
+
+// 拍子の変化。#BEATの位置から新しい小節として数える
+type TimeSignature struct {
+	Beat        float64
+	Numerator   int
+	Denominator int
+}
+
+// 譜面の拍子の変化を拍順に並べて返す（分母がない場合は4分の拍子とする）
+func GetTimeSignatures(levelData sonolus.LevelData) []TimeSignature {
+	timeSignatures := []TimeSignature{}
+	for _, entity := range levelData.Entities {
+		if entity.Archetype != timeSignatureArchetype {
+			continue
+		}
+		beat, err := getValueFromData(entity.Data, "#BEAT")
+		if err != nil {
+			continue
+		}
+		numerator, err := getValueFromData(entity.Data, "#NUMERATOR")
+		if err != nil || numerator < 1 {
+			continue
+		}
+		denominator, err := getValueFromData(entity.Data, "#DENOMINATOR")
+		if err != nil {
+			denominator = 4
+		}
+		if denominator < 1 {
+			continue
+		}
+		timeSignatures = append(timeSignatures, TimeSignature{
+			Beat:        beat,
+			Numerator:   int(numerator),
+			Denominator: int(denominator),
+		})
+	}
+	sort.SliceStable(timeSignatures, func(i, j int) bool {
+		return timeSignatures[i].Beat < timeSignatures[j].Beat
+	})
+	return timeSignatures
+}
+
+// 拍の小節番号と、小節内で何拍目か（どちらも1から。小節の頭より前の拍は0小節目）
+type MeasurePosition struct {
+	Time    float64
+	Measure int
+	Beat    int
+}
+
+// endTimeまでの拍ごとの小節と拍の位置を求める（区間の始まりより前の拍も含む）
+//
+// 拍子の変化がない場合は、beatsPerMeasure拍子としてBPM変化の位置で小節を数え直す
+func calculateMeasurePositions(levelData sonolus.LevelData, endTime float64, beatsPerMeasure int) []MeasurePosition {
+	positions := []MeasurePosition{}
+	bpmChanges := GetBpmChanges(levelData)
+	if len(bpmChanges) == 0 || beatsPerMeasure <= 0 {
+		return positions
+	}
+	timeSignatures := GetTimeSignatures(levelData)
+	if len(timeSignatures) == 0 {
+		measure := 0
+		measureStart := 0.0
+		for i := 0.0; ; i++ {
+			beat := bpmChanges[0].Beat + i
+			for _, bpmChange := range bpmChanges[1:] {
+				if bpmChange.Beat <= beat && bpmChange.Beat > measureStart {
+					measureStart = bpmChange.Beat
+				}
+			}
+			time := getTimeFromBpmChanges(bpmChanges, beat) + levelData.BgmOffset
+			if time > endTime {
+				break
+			}
+			beatInMeasure := int(beat-measureStart) % beatsPerMeasure
+			if beatInMeasure == 0 {
+				measure++
+			}
+			positions = append(positions, MeasurePosition{Time: time, Measure: measure, Beat: beatInMeasure + 1})
+		}
+		return positions
+	}
+
+	// 最初の拍子の変化までは、beatsPerMeasure拍子の4分の拍子とする
+	if timeSignatures[0].Beat > bpmChanges[0].Beat {
+		timeSignatures = append([]TimeSignature{{Beat: bpmChanges[0].Beat, Numerator: beatsPerMeasure, Denominator: 4}}, timeSignatures...)
+	}
+	measure := 0
+	for i, timeSignature := range timeSignatures {
+		// 4分音符を1拍として、拍子の分母に合わせた間隔で数える
+		step := 4 / float64(timeSignature.Denominator)
+		for k := 0; ; k++ {
+			beat := timeSignature.Beat + float64(k)*step
+			if i+1 < len(timeSignatures) && beat >= timeSignatures[i+1].Beat {
+				break
+			}
+			time := getTimeFromBpmChanges(bpmChanges, beat) + levelData.BgmOffset
+			if time > endTime {
+				return positions
+			}
+			if k%timeSignature.Numerator == 0 {
+				measure++
+			}
+			positions = append(positions, MeasurePosition{Time: time, Measure: measure, Beat: k%timeSignature.Numerator + 1})
+		}
+	}
+	return positions
+}
+
+// 最後のノーツまでの、拍ごとの小節と拍の位置を求める（beatsPerMeasureが0以下の場合は無効）
+func CalculateMeasures(levelData sonolus.LevelData, frames []PedFrame, beatsPerMeasure int) []MeasurePosition {
+	measures := []MeasurePosition{}
+	if len(frames) == 0 {
+		return measures
+	}
+	for _, position := range calculateMeasurePositions(levelData, frames[len(frames)-1].Time, beatsPerMeasure) {
+		// 区間を指定した場合、始まりより前の拍は使わない
+		if position.Time < 0 {
+			continue
+		}
+		measures = append(measures, position)
+	}
+	return measures
+}
@@ -0,0 +1,65 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 1つの段階の処理時間
+type StageTiming struct {
+	Stage      string `json:"stage"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// 段階ごとの処理時間を集計する。同じ段階を何度計測しても合計される（ゼロ値で使える）
+type StageTimings struct {
+	// 最初に計測した順
+	stages    []string
+	durations map[string]time.Duration
+}
+
+func (t *StageTimings) Add(stage string, duration time.Duration) {
+	if t.durations == nil {
+		t.durations = map[string]time.Duration{}
+	}
+	if _, ok := t.durations[stage]; !ok {
+		t.stages = append(t.stages, stage)
+	}
+	t.durations[stage] += duration
+}
+
+// 計測を始め、返した関数を呼ぶまでの時間を加算する
+func (t *StageTimings) Start(stage string) func() {
+	start := time.Now()
+	return func() {
+		t.Add(stage, time.Since(start))
+	}
+}
+
+func (t *StageTimings) List() []StageTiming {
+	list := make([]StageTiming, 0, len(t.stages))
+	for _, stage := range t.stages {
+		list = append(list, StageTiming{Stage: stage, DurationMs: t.durations[stage].Milliseconds()})
+	}
+	return list
+}
+
+// 表示用に、段階ごとの時間と全体に対する割合を1行ずつ並べる
+func (t *StageTimings) Report() string {
+	total := time.Duration(0)
+	width := 0
+	for _, stage := range t.stages {
+		total += t.durations[stage]
+		width = max(width, len(stage))
+	}
+	var report strings.Builder
+	for _, stage := range t.stages {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(t.durations[stage]) / float64(total) * 100
+		}
+		fmt.Fprintf(&report, "  %-*s %8.3fs %5.1f%%\n", width, stage, t.durations[stage].Seconds(), percent)
+	}
+	return report.String()
+}
@@ -0,0 +1,111 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 色を変えられるUI要素
+type TintElement string
+
+const (
+	// コンボの数字と「COMBO」（AP中の色は変えない）
+	TintElementCombo TintElement = "combo"
+	// スコアのゲージ
+	TintElementBar TintElement = "bar"
+	// スコアの枠
+	TintElementFrame TintElement = "frame"
+)
+
+var TintElements = []TintElement{TintElementCombo, TintElementBar, TintElementFrame}
+
+// 要素ごとに色を変えるアセットの画像（assetsからの相対パス）
+var tintAssetPatterns = map[TintElement][]string{
+	TintElementCombo: {"combo/n*.png"},
+	TintElementBar:   {"score/bar.png"},
+	TintElementFrame: {"score/bg.png", "score/fg.png"},
+}
+
+type Tint struct {
+	Color    int
+	Elements []TintElement
+}
+
+// valueは「source」（譜面サーバーの色）か「88cb7f」のような16進数、elementsはカンマ区切りの要素
+func ParseTint(value string, source Source, elements string) (Tint, error) {
+	tint := Tint{}
+	if value == "source" {
+		if source.Color == 0 {
+			return Tint{}, fmt.Errorf("譜面サーバーに色が設定されていません。(The chart server has no color.) [%s]", source.Id)
+		}
+		tint.Color = source.Color
+	} else {
+		color, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 16, 24)
+		if err != nil {
+			return Tint{}, fmt.Errorf("色の形式が不正です。(Invalid color.) [%s]", value)
+		}
+		tint.Color = int(color)
+	}
+	for _, name := range strings.Split(elements, ",") {
+		element := TintElement(strings.TrimSpace(name))
+		if !slices.Contains(TintElements, element) {
+			return Tint{}, fmt.Errorf("不明な要素です。(Unknown element.) [%s]", name)
+		}
+		if !slices.Contains(tint.Elements, element) {
+			tint.Elements = append(tint.Elements, element)
+		}
+	}
+	return tint, nil
+}
+
+// 明るさを保ったまま色を付ける（AviUtlの単色化と同じ）
+func tintImage(src image.Image, tint int) *image.NRGBA {
+	r, g, b := float64(tint>>16&0xff), float64(tint>>8&0xff), float64(tint&0xff)
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			luminance := (0.299*float64(pixel.R) + 0.587*float64(pixel.G) + 0.114*float64(pixel.B)) / 255
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r * luminance), uint8(g * luminance), uint8(b * luminance), pixel.A})
+		}
+	}
+	return dst
+}
+
+// 色を変えたアセットの画像を出力先のtintに書き込み、要素ごとの読み込み先を返す
+func WriteTintedAssets(assets string, destPath string, tint Tint) ([]PedTint, error) {
+	tintDir := filepath.Join(destPath, "tint")
+	tints := make([]PedTint, 0, len(tint.Elements))
+	for _, element := range tint.Elements {
+		for _, pattern := range tintAssetPatterns[element] {
+			paths, err := filepath.Glob(filepath.Join(assets, filepath.FromSlash(pattern)))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				file, err := os.Open(path)
+				if err != nil {
+					return nil, fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [%s]", err)
+				}
+				img, _, err := image.Decode(file)
+				file.Close()
+				if err != nil {
+					return nil, fmt.Errorf("アセットの読み込みに失敗しました。(Loading assets failed.) [%s: %s]", filepath.Base(path), err)
+				}
+				rel, _ := filepath.Rel(assets, path)
+				if err := writeImage(DirOutput(filepath.Join(tintDir, filepath.Dir(rel))), filepath.Base(rel), tintImage(img, tint.Color), ImageFormatPng); err != nil {
+					return nil, err
+				}
+			}
+		}
+		tints = append(tints, PedTint{Element: element, Color: tint.Color, Path: tintDir})
+	}
+	return tints, nil
+}
@@ -0,0 +1,86 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+// スコアの計算が対応していないアーキタイプの扱い
+type UnknownArchetypeMode string
+
+const (
+	// コンボに含めない
+	UnknownArchetypeIgnore UnknownArchetypeMode = "ignore"
+	// 通常のタップノーツとして数える
+	UnknownArchetypeCombo UnknownArchetypeMode = "combo"
+)
+
+func ParseUnknownArchetypeMode(mode string) (UnknownArchetypeMode, error) {
+	switch UnknownArchetypeMode(mode) {
+	case UnknownArchetypeIgnore, UnknownArchetypeCombo:
+		return UnknownArchetypeMode(mode), nil
+	}
+	return "", fmt.Errorf("不明なアーキタイプの扱いです。(Unknown archetype mode.) [%s] (ignore, combo)", mode)
+}
+
+// 読み込み時に判別できるアーキタイプか（ルールの重みの指定は含まない）
+func isKnownArchetype(archetype string) bool {
+	if _, ok := WEIGHT_MAP[archetype]; ok {
+		return true
+	}
+	if _, ok := markerArchetypes[archetype]; ok {
+		return true
+	}
+	return archetype == timeSignatureArchetype
+}
+
+// 未対応のアーキタイプとエンティティ数
+type ArchetypeCount struct {
+	Archetype string
+	Count     int
+}
+
+// 譜面データのうち、スコアの計算が対応していないアーキタイプを数の多い順に返す（ルールで重みを指定したものは除く）
+func FindUnknownArchetypes(levelData sonolus.LevelData, rules ScoreRules) []ArchetypeCount {
+	counts := map[string]int{}
+	for _, entity := range levelData.Entities {
+		if isKnownArchetype(entity.Archetype) {
+			continue
+		}
+		if _, ok := rules.Weights[entity.Archetype]; ok {
+			continue
+		}
+		counts[entity.Archetype]++
+	}
+	unknown := make([]ArchetypeCount, 0, len(counts))
+	for archetype, count := range counts {
+		unknown = append(unknown, ArchetypeCount{Archetype: archetype, Count: count})
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		if unknown[i].Count == unknown[j].Count {
+			return unknown[i].Archetype < unknown[j].Archetype
+		}
+		return unknown[i].Count > unknown[j].Count
+	})
+	return unknown
+}
+
+// comboの場合、未対応のアーキタイプのうち#BEATを持つものを通常のタップノーツとして読み替える（元の譜面データは変更しない）
+func (mode UnknownArchetypeMode) Apply(levelData sonolus.LevelData, rules ScoreRules) sonolus.LevelData {
+	if mode != UnknownArchetypeCombo {
+		return levelData
+	}
+	applied := levelData
+	applied.Entities = make([]sonolus.LevelDataEntity, len(levelData.Entities))
+	for i, entity := range levelData.Entities {
+		if _, ok := rules.Weights[entity.Archetype]; !ok && !isKnownArchetype(entity.Archetype) {
+			if _, err := getValueFromData(entity.Data, "#BEAT"); err == nil {
+				entity.Archetype = "NormalTapNote"
+			}
+		}
+		applied.Entities[i] = entity
+	}
+	return applied
+}
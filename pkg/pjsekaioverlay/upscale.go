@@ -0,0 +1,68 @@
+package pjsekaioverlay
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// 低解像度のジャケットを、外部のコマンド（Real-ESRGANなど）で拡大する
+type CoverUpscaler struct {
+	// 実行するコマンド。{input}と{output}はPNGファイルのパスに置き換えられる
+	Command string
+	// 幅と高さの短い方がこれより小さいジャケットだけを拡大する
+	MinSize int
+}
+
+func (upscaler CoverUpscaler) Validate() error {
+	if !strings.Contains(upscaler.Command, "{input}") || !strings.Contains(upscaler.Command, "{output}") {
+		return fmt.Errorf("拡大に使うコマンドには{input}と{output}を含めて下さい。(The upscale command must contain {input} and {output}.) [%s]", upscaler.Command)
+	}
+	return nil
+}
+
+func (upscaler CoverUpscaler) needsUpscale(img image.Image) bool {
+	return min(img.Bounds().Dx(), img.Bounds().Dy()) < upscaler.MinSize
+}
+
+// 一時ファイルを介してコマンドを実行し、拡大した画像を返す
+func (upscaler CoverUpscaler) Upscale(img image.Image) (image.Image, error) {
+	workDir, err := os.MkdirTemp("", "pjsekai-overlay-upscale")
+	if err != nil {
+		return nil, fmt.Errorf("ジャケットの拡大に失敗しました。(Failed to upscale jacket.) [%s]", err)
+	}
+	defer os.RemoveAll(workDir)
+	input := filepath.Join(workDir, "input.png")
+	output := filepath.Join(workDir, "output.png")
+	if err := writeImageFile(input, img, ImageFormatPng); err != nil {
+		return nil, err
+	}
+
+	// パスに空白が含まれても分かれないよう、区切ってから置き換える
+	args := strings.Fields(upscaler.Command)
+	for i, arg := range args {
+		args[i] = strings.NewReplacer("{input}", input, "{output}", output).Replace(arg)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ジャケットの拡大に失敗しました。(Failed to upscale jacket.) [%s %s]", err, strings.TrimSpace(stderr.String()))
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return nil, fmt.Errorf("ジャケットの拡大に失敗しました。(Failed to upscale jacket.) [%s]", err)
+	}
+	defer file.Close()
+	upscaled, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("拡大したジャケットの読み込みに失敗しました。(Loading upscaled jacket failed.) [%s]", err)
+	}
+	return upscaled, nil
+}
@@ -0,0 +1,257 @@
+package pjsekaioverlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/cmplx"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"golang.org/x/image/draw"
+)
+
+// 曲に合わせて動くビジュアライザーの種類
+type WaveformMode string
+
+const (
+	WaveformModeNone WaveformMode = "none"
+	// 音声の波形（オシロスコープ）
+	WaveformModeWave WaveformMode = "wave"
+	// 周波数ごとの棒グラフ
+	WaveformModeSpectrum WaveformMode = "spectrum"
+)
+
+func ParseWaveformMode(mode string) (WaveformMode, error) {
+	switch WaveformMode(mode) {
+	case WaveformModeNone, WaveformModeWave, WaveformModeSpectrum:
+		return WaveformMode(mode), nil
+	}
+	return "", fmt.Errorf("不明なビジュアライザーの種類です。(Unknown visualizer mode.) [%s]", mode)
+}
+
+const (
+	waveformWidth      = 1920
+	waveformHeight     = 240
+	waveformFrameRate  = 30
+	waveformSampleRate = 22050
+	// 1フレームに表示する波形のサンプル数（約46ms）
+	waveformWindow = 1024
+	// スペクトラムの解析に使うサンプル数
+	spectrumSize  = 2048
+	spectrumBands = 64
+	// 棒が下がる速さ（1フレームあたり）
+	spectrumDecay = 0.06
+)
+
+// ビジュアライザーの連番画像（オーバーレイとして表示する場合のみ）
+type Waveform struct {
+	// 連番画像（00000.png～）のディレクトリ
+	Path      string
+	FrameRate float64
+	Count     int
+	// 曲の先頭のpedでの時間（秒）
+	Start float64
+}
+
+// 曲の長さ（秒）。ビジュアライザーがない場合は0
+func (waveform Waveform) Duration() float64 {
+	if waveform.FrameRate <= 0 {
+		return 0
+	}
+	return float64(waveform.Count) / waveform.FrameRate
+}
+
+func BgmFileName(level sonolus.LevelInfo) string {
+	ext := path.Ext(level.Bgm.Url)
+	if ext == "" {
+		ext = ".mp3"
+	}
+	return "bgm" + ext
+}
+
+// 曲をダウンロードし、出力先に保存したパスを返す
+func (c *Client) DownloadBgm(source Source, level sonolus.LevelInfo, destPath string) (string, error) {
+	if level.Bgm.Url == "" {
+		return "", fmt.Errorf("譜面に曲が設定されていません。(The chart has no BGM.) [%s]", level.Name)
+	}
+	bgmUrl, err := sonolus.JoinUrl("https://"+source.Host, level.Bgm.Url)
+	if err != nil {
+		return "", fmt.Errorf("URLの解析に失敗しました。(URL parsing failed.) [%s]", err)
+	}
+	resp, err := c.httpClient.Get(bgmUrl)
+	if err != nil {
+		return "", fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("曲が見つかりませんでした。(BGM not found.) [%d]", resp.StatusCode)
+	}
+
+	name := BgmFileName(level)
+	err = writeOutputFile(DirOutput(destPath), name, func(w io.Writer) error {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(destPath, name), nil
+}
+
+// 2のべき乗の長さのデータを高速フーリエ変換する
+func fft(values []complex128) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := values[start+k], values[start+k+size/2]*w
+				values[start+k], values[start+k+size/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+// 各フレームの周波数帯ごとの大きさ（0～1）。低い音から高い音まで対数で分ける
+func spectrumLevels(samples []int16, count int) [][]float64 {
+	edges := make([]int, spectrumBands+1)
+	for i := range edges {
+		frequency := 40 * math.Pow(11000.0/40, float64(i)/spectrumBands)
+		edges[i] = max(int(frequency*spectrumSize/waveformSampleRate), 1)
+	}
+	levels := make([][]float64, count)
+	values := make([]complex128, spectrumSize)
+	previous := make([]float64, spectrumBands)
+	for i := range levels {
+		center := i * waveformSampleRate / waveformFrameRate
+		for k := range values {
+			sample := 0.0
+			if at := center - spectrumSize/2 + k; at >= 0 && at < len(samples) {
+				sample = float64(samples[at]) / 32768
+			}
+			// ハン窓
+			values[k] = complex(sample*(0.5-0.5*math.Cos(2*math.Pi*float64(k)/spectrumSize)), 0)
+		}
+		fft(values)
+		levels[i] = make([]float64, spectrumBands)
+		for band := range levels[i] {
+			peak := 0.0
+			for bin := edges[band]; bin <= max(edges[band+1]-1, edges[band]); bin++ {
+				peak = max(peak, cmplx.Abs(values[bin]))
+			}
+			// -60dB～0dBを0～1にする
+			level := (20*math.Log10(peak/(spectrumSize/4)+1e-9) + 60) / 60
+			levels[i][band] = max(min(level, 1), previous[band]-spectrumDecay, 0)
+		}
+		previous = levels[i]
+	}
+	return levels
+}
+
+func renderWave(samples []int16, frame int, peak float64) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, waveformWidth, waveformHeight))
+	center := frame * waveformSampleRate / waveformFrameRate
+	lineColor := image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0xe0})
+	for x := 0; x < waveformWidth; x++ {
+		low, high := 0.0, 0.0
+		from := center - waveformWindow/2 + x*waveformWindow/waveformWidth
+		to := max(center-waveformWindow/2+(x+1)*waveformWindow/waveformWidth, from+1)
+		for at := from; at < to; at++ {
+			if at >= 0 && at < len(samples) {
+				low, high = min(low, float64(samples[at])/peak), max(high, float64(samples[at])/peak)
+			}
+		}
+		top := int(float64(waveformHeight) / 2 * (1 - high))
+		bottom := max(int(float64(waveformHeight)/2*(1-low)), top+2)
+		draw.Draw(canvas, image.Rect(x, top, x+1, bottom), lineColor, image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+func renderSpectrum(levels []float64) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, waveformWidth, waveformHeight))
+	barWidth := waveformWidth / spectrumBands
+	barColor := image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0xe0})
+	for band, level := range levels {
+		height := max(int(level*waveformHeight), 2)
+		rect := image.Rect(band*barWidth+barWidth/5, waveformHeight-height, (band+1)*barWidth-barWidth/5, waveformHeight)
+		draw.Draw(canvas, rect, barColor, image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+// 曲を解析し、ビジュアライザーの連番画像をwaveformに出力する
+func WriteWaveform(audioPath string, destDir string, mode WaveformMode) (Waveform, error) {
+	samples, err := loadAudioSamples(audioPath, waveformSampleRate)
+	if err != nil {
+		return Waveform{}, fmt.Errorf("曲の読み込みに失敗しました。(Loading BGM failed.) [%s]", err)
+	}
+	count := int(math.Ceil(float64(len(samples)) * waveformFrameRate / waveformSampleRate))
+	if count == 0 {
+		return Waveform{}, fmt.Errorf("曲が空です。(The BGM is empty.) [%s]", filepath.Base(audioPath))
+	}
+
+	var levels [][]float64
+	peak := 1.0
+	if mode == WaveformModeSpectrum {
+		// 棒が下がる動きは前のフレームに依存するので、先にまとめて求める
+		levels = spectrumLevels(samples, count)
+	} else {
+		for _, sample := range samples {
+			peak = max(peak, math.Abs(float64(sample)))
+		}
+	}
+
+	waveformDir := filepath.Join(destDir, "waveform")
+	output := DirOutput(waveformDir)
+	frames := make(chan int)
+	errs := make([]error, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for worker := range errs {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for frame := range frames {
+				if errs[worker] != nil {
+					continue
+				}
+				var img *image.NRGBA
+				if mode == WaveformModeSpectrum {
+					img = renderSpectrum(levels[frame])
+				} else {
+					img = renderWave(samples, frame, peak)
+				}
+				errs[worker] = writeImage(output, fmt.Sprintf("%05d.png", frame), img, ImageFormatPng)
+			}
+		}(worker)
+	}
+	for frame := 0; frame < count; frame++ {
+		frames <- frame
+	}
+	close(frames)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return Waveform{}, err
+		}
+	}
+	return Waveform{Path: waveformDir, FrameRate: waveformFrameRate, Count: count}, nil
+}
@@ -0,0 +1,174 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const webEmbedFileName = "overlay.html"
+
+// 埋め込む動画のファイル名またはURL（既定）
+const defaultWebEmbedVideo = "video.mp4"
+
+// ノーツごとのキーフレーム（時間は動画上の秒）
+type webKeyframe struct {
+	Time  float64 `json:"t"`
+	Score int     `json:"s"`
+	Combo int     `json:"c"`
+}
+
+type webEmbedData struct {
+	Keyframes []webKeyframe `json:"keyframes"`
+	// 数え上げの方式と長さ（秒）
+	ScoreAnimation string  `json:"scoreAnimation"`
+	ScoreDuration  float64 `json:"scoreDuration"`
+}
+
+type WebEmbedOptions struct {
+	Video string
+	// 譜面の0秒が動画の何秒目か（exoから書き出した動画の場合は既定のまま）
+	Offset float64
+}
+
+// exoから書き出した動画での、譜面の0秒の位置
+func defaultWebEmbedOffset() float64 {
+	return float64(noteFrame(0)) / exoFrameRate
+}
+
+const webEmbedTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+.pjsekai-overlay { position: relative; display: inline-block; }
+.pjsekai-overlay video { display: block; width: 100%%; }
+.pjsekai-overlay-score, .pjsekai-overlay-combo { position: absolute; color: #fff; font-family: sans-serif; font-weight: bold; text-shadow: 0 0 0.2em #000; pointer-events: none; }
+.pjsekai-overlay-score { left: 3%%; top: 4%%; font-size: 4vw; }
+.pjsekai-overlay-combo { right: 8%%; top: 42%%; font-size: 6vw; text-align: center; }
+.pjsekai-overlay-combo:empty { display: none; }
+</style>
+</head>
+<body>
+<div class="pjsekai-overlay">
+<video src="%s" controls></video>
+<div class="pjsekai-overlay-score"></div>
+<div class="pjsekai-overlay-combo"></div>
+</div>
+<script>
+(() => {
+  const data = %s;
+  const root = document.currentScript.previousElementSibling;
+  const video = root.querySelector("video");
+  const scoreElement = root.querySelector(".pjsekai-overlay-score");
+  const comboElement = root.querySelector(".pjsekai-overlay-combo");
+  const frames = data.keyframes;
+  // time以前の最後のキーフレーム
+  const find = (time) => {
+    let low = 0, high = frames.length - 1;
+    while (low < high) {
+      const mid = (low + high + 1) >> 1;
+      if (frames[mid].t <= time) low = mid; else high = mid - 1;
+    }
+    return low;
+  };
+  const scoreAt = (i, time) => {
+    if (i === 0 || data.scoreAnimation === "instant" || data.scoreAnimation === "") return frames[i].s;
+    const t = Math.min((time - frames[i].t) / data.scoreDuration, 1);
+    const progress = data.scoreAnimation === "game" ? 1 - Math.pow(1 - t, 3) : t;
+    return Math.round(frames[i - 1].s + (frames[i].s - frames[i - 1].s) * progress);
+  };
+  let lastCombo = -1;
+  const update = () => {
+    const time = video.currentTime;
+    const i = find(time);
+    scoreElement.textContent = String(scoreAt(i, time)).padStart(8, "0");
+    const combo = frames[i].c;
+    if (combo !== lastCombo) {
+      comboElement.textContent = combo > 0 ? combo : "";
+      if (combo > lastCombo && combo > 0 && !video.paused) {
+        comboElement.animate([{ transform: "scale(1.15)" }, { transform: "scale(1)" }], { duration: 120, easing: "ease-out" });
+      }
+      lastCombo = combo;
+    }
+    requestAnimationFrame(update);
+  };
+  requestAnimationFrame(update);
+})();
+</script>
+</body>
+</html>
+`
+
+// 動画に合わせてスコアとコンボを表示するHTML（CSSとJSを含む）を書き出す。譜面のページなどに埋め込む用
+func WriteWebEmbed(timeline Timeline, destDir string, options WebEmbedOptions) error {
+	if len(timeline.Frames) == 0 {
+		return fmt.Errorf("ノーツがありません。(No notes.)")
+	}
+	data := webEmbedData{
+		Keyframes:      make([]webKeyframe, 0, len(timeline.Frames)),
+		ScoreAnimation: string(timeline.ScoreAnimation.Mode),
+		ScoreDuration:  timeline.ScoreAnimation.Duration / exoFrameRate,
+	}
+	combos := timeline.ComboCounter.Combos(timeline.Frames)
+	for i, frame := range timeline.Frames {
+		data.Keyframes = append(data.Keyframes, webKeyframe{
+			Time:  options.Offset + frame.Time,
+			Score: frame.Score,
+			Combo: combos[i],
+		})
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("HTMLの生成に失敗しました。(Failed to generate HTML.) [%s]", err)
+	}
+
+	file, err := os.Create(filepath.Join(destDir, webEmbedFileName))
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました。(Failed to create file.) [%s]", err)
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, webEmbedTemplate, html.EscapeString(timeline.Level.Title), html.EscapeString(options.Video), encoded)
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました。(Failed to write file.) [%s]", err)
+	}
+	return nil
+}
+
+type webBackend struct{}
+
+func (webBackend) Name() string { return "web" }
+func (webBackend) Description() string {
+	return "Webページに埋め込むHTML (HTML for web embeds)"
+}
+func (webBackend) Options() []BackendOption {
+	return []BackendOption{
+		{Name: "video", Description: "埋め込む動画のファイル名またはURL（既定はvideo.mp4）(Video file name or URL, video.mp4 by default)"},
+		{Name: "offset", Description: "譜面の0秒が動画の何秒目か（既定はexoから書き出した動画の位置）(Video time of the chart's 0s, defaults to the exo render)"},
+	}
+}
+func (webBackend) FileNames() []string { return []string{webEmbedFileName} }
+func (webBackend) Generate(timeline Timeline, assets string, destDir string, options map[string]string) error {
+	embedOptions := WebEmbedOptions{Video: defaultWebEmbedVideo, Offset: defaultWebEmbedOffset()}
+	if video, ok := options["video"]; ok {
+		embedOptions.Video = video
+	}
+	if value, ok := options["offset"]; ok {
+		offset, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("オプションの値が不正です。(Invalid option value.) [offset=%s]", value)
+		}
+		embedOptions.Offset = offset
+	}
+	return WriteWebEmbed(timeline, destDir, embedOptions)
+}
+
+func init() {
+	RegisterBackend(webBackend{})
+}
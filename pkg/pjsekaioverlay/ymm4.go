@@ -0,0 +1,192 @@
+package pjsekaioverlay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// YMM4（ゆっくりMovieMaker4）のプロジェクトファイル（.ymmp）の一部
+type ymm4Project struct {
+	FilePath  string         `json:"FilePath"`
+	Timelines []ymm4Timeline `json:"Timelines"`
+	Version   string         `json:"Version"`
+}
+
+type ymm4Timeline struct {
+	VideoInfo ymm4VideoInfo `json:"VideoInfo"`
+	Items     []ymm4Item    `json:"Items"`
+	Length    int           `json:"Length"`
+}
+
+type ymm4VideoInfo struct {
+	FPS    int `json:"FPS"`
+	Hz     int `json:"Hz"`
+	Width  int `json:"Width"`
+	Height int `json:"Height"`
+}
+
+type ymm4Values struct {
+	Values []ymm4Value `json:"Values"`
+}
+
+type ymm4Value struct {
+	Value float64 `json:"Value"`
+}
+
+type ymm4Item struct {
+	Type     string      `json:"$type"`
+	FilePath string      `json:"FilePath,omitempty"`
+	Text     string      `json:"Text,omitempty"`
+	FontSize *ymm4Values `json:"FontSize,omitempty"`
+	X        ymm4Values  `json:"X"`
+	Y        ymm4Values  `json:"Y"`
+	Zoom     ymm4Values  `json:"Zoom"`
+	Layer    int         `json:"Layer"`
+	Frame    int         `json:"Frame"`
+	Length   int         `json:"Length"`
+	IsLooped bool        `json:"IsLooped,omitempty"`
+}
+
+const (
+	ymm4ImageItem = "YukkuriMovieMaker.Project.Items.ImageItem, YukkuriMovieMaker"
+	ymm4TextItem  = "YukkuriMovieMaker.Project.Items.TextItem, YukkuriMovieMaker"
+	ymm4VideoItem = "YukkuriMovieMaker.Project.Items.VideoItem, YukkuriMovieMaker"
+)
+
+func newYmm4Values(value float64) ymm4Values {
+	return ymm4Values{Values: []ymm4Value{{Value: value}}}
+}
+
+func newYmm4Image(filePath string, layer int, frame int, length int, x float64, y float64, zoom float64) ymm4Item {
+	return ymm4Item{
+		Type:     ymm4ImageItem,
+		FilePath: filePath,
+		X:        newYmm4Values(x),
+		Y:        newYmm4Values(y),
+		Zoom:     newYmm4Values(zoom),
+		Layer:    layer,
+		Frame:    frame,
+		Length:   length,
+	}
+}
+
+func newYmm4Text(text string, layer int, frame int, length int, x float64, y float64, size float64) ymm4Item {
+	fontSize := newYmm4Values(size)
+	return ymm4Item{
+		Type:     ymm4TextItem,
+		Text:     text,
+		FontSize: &fontSize,
+		X:        newYmm4Values(x),
+		Y:        newYmm4Values(y),
+		Zoom:     newYmm4Values(100),
+		Layer:    layer,
+		Frame:    frame,
+		Length:   length,
+	}
+}
+
+// ノーツの時間を、exoと同じタイムライン上のフレームに変換する
+func noteFrame(time float64) int {
+	return exoPlayStart - 1 + exoRootOffset + int(math.Round(time*exoFrameRate))
+}
+
+type Ymm4Options struct {
+	ComboCounter   ComboCounter
+	ScoreAnimation ScoreAnimation
+	// 事前に組み立てたコンボ数の画像のディレクトリ（空の場合はテキストで表示する）
+	ComboImages string
+	Lyrics      []LyricLine
+	// 背景の画像の代わりにループ再生する、出力先の動画のファイル名
+	BackgroundVideo string
+	// プロジェクトが参照する出力先（空の場合は書き込み先と同じ）
+	DistDir string
+}
+
+// スコアとコンボをテキストアイテムのキーフレームとして並べたYMM4のプロジェクトを出力する
+func WriteYmm4Project(frames []PedFrame, destDir string, coverFormat ImageFormat, options Ymm4Options) error {
+	length := noteFrame(frames[len(frames)-1].Time) + exoFinaleDelay + exoFrameRate*6
+	distDir := destDir
+	if options.DistDir != "" {
+		distDir = options.DistDir
+	}
+
+	items := make([]ymm4Item, 0, 2+len(frames)*2+len(options.Lyrics))
+	background := newYmm4Image(filepath.Join(distDir, "background.png"), 0, 0, length, 0, 0, 150)
+	if options.BackgroundVideo != "" {
+		background.Type = ymm4VideoItem
+		background.FilePath = filepath.Join(distDir, options.BackgroundVideo)
+		background.IsLooped = true
+	}
+	items = append(items,
+		background,
+		newYmm4Image(filepath.Join(distDir, coverFormat.FileName("cover")), 1, 0, exoPlayStart-1, -618, 245, 78.75),
+	)
+
+	combos := options.ComboCounter.Combos(frames)
+	for i, frame := range frames {
+		start := noteFrame(frame.Time)
+		end := length
+		if i+1 < len(frames) {
+			end = noteFrame(frames[i+1].Time)
+		}
+		if end <= start {
+			continue
+		}
+		// 数え上げの途中は1フレームごとのアイテムにする
+		scoreStart := start
+		if i > 0 && options.ScoreAnimation.Animated() {
+			for ; scoreStart < end && float64(scoreStart-start) < options.ScoreAnimation.Duration; scoreStart++ {
+				score := options.ScoreAnimation.Value(frames[i-1].Score, frame.Score, float64(scoreStart-start))
+				items = append(items, newYmm4Text(fmt.Sprintf("%08d", score), 2, scoreStart, 1, -583.5, -469, 48))
+			}
+		}
+		if scoreStart < end {
+			items = append(items, newYmm4Text(fmt.Sprintf("%08d", frame.Score), 2, scoreStart, end-scoreStart, -583.5, -469, 48))
+		}
+		if combo := combos[i]; combo > 0 && options.ComboImages != "" {
+			items = append(items, newYmm4Image(filepath.Join(options.ComboImages, "n", strconv.Itoa(combo)+".png"), 3, start, end-start, 673.5, -62.5, 70))
+		} else if combo > 0 {
+			items = append(items, newYmm4Text(strconv.Itoa(combo), 3, start, end-start, 673.5, -62.5, 120))
+		}
+	}
+
+	for _, line := range options.Lyrics {
+		start, end := noteFrame(line.Start), noteFrame(line.End)
+		if end <= start {
+			continue
+		}
+		items = append(items, newYmm4Text(line.Text, 4, start, end-start, 0, 420, 54))
+	}
+
+	projectPath := filepath.Join(destDir, "main.ymmp")
+	project := ymm4Project{
+		FilePath: filepath.Join(distDir, "main.ymmp"),
+		Version:  "4.0.0.0",
+		Timelines: []ymm4Timeline{{
+			VideoInfo: ymm4VideoInfo{FPS: exoFrameRate, Hz: 44100, Width: 1920, Height: 1080},
+			Items:     items,
+			Length:    length,
+		}},
+	}
+
+	file, err := os.Create(projectPath)
+	if err != nil {
+		return fmt.Errorf("ファイルの作成に失敗しました (Failed to create file) [%w]", err)
+	}
+	defer file.Close()
+
+	// 長い譜面ではアイテムが数万個になるので、インデントせずに書き出す
+	writer := bufio.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(project); err != nil {
+		return fmt.Errorf("プロジェクトの生成に失敗しました (Failed to generate project) [%w]", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("ファイルの書き込みに失敗しました (Failed to write file) [%w]", err)
+	}
+	return nil
+}
@@ -13,13 +13,22 @@ type LevelInfo struct {
 	Version       int                     `json:"version"`
 	Rating        int                     `json:"rating"`
 	Cover         SRL                     `json:"cover"`
+	Bgm           SRL                     `json:"bgm"`
 	Data          SRL                     `json:"data"`
 	UseBackground UseItem[BackgroundInfo] `json:"useBackground"`
 	Engine        EngineInfo              `json:"engine"`
+	Tags          []Tag                   `json:"tags"`
+}
+
+type Tag struct {
+	Title string `json:"title"`
+	Icon  string `json:"icon"`
 }
 
 type BackgroundInfo struct {
 	Image SRL `json:"image"`
+	// 一部のサーバーが独自に提供する背景動画（Sonolusの仕様にはない）
+	Video SRL `json:"video"`
 }
 
 type EngineInfo struct {
@@ -32,7 +41,18 @@ type SRL struct {
 }
 
 type InfoResponse[T any] struct {
-	Item T `json:"item"`
+	Item     T                `json:"item"`
+	Sections []ItemSection[T] `json:"sections"`
+}
+
+type ItemList[T any] struct {
+	PageCount int `json:"pageCount"`
+	Items     []T `json:"items"`
+}
+
+type ItemSection[T any] struct {
+	Title string `json:"title"`
+	Items []T    `json:"items"`
 }
 
 type UseItem[T any] struct {
@@ -0,0 +1,71 @@
+package sonolus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// LevelDataを少しずつ読み込む。keepがfalseを返したエンティティは保持しないため、
+// エンティティ数の多い譜面でもメモリを節約できる。keepで書き換えたエンティティはそのまま保持する。
+func DecodeLevelDataStream(r io.Reader, keep func(entity *LevelDataEntity) bool) (LevelData, error) {
+	decoder := json.NewDecoder(r)
+	data := LevelData{Entities: []LevelDataEntity{}}
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return LevelData{}, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return LevelData{}, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return LevelData{}, fmt.Errorf("unexpected token: %v", token)
+		}
+		switch key {
+		case "bgmOffset":
+			if err := decoder.Decode(&data.BgmOffset); err != nil {
+				return LevelData{}, err
+			}
+		case "entities":
+			if err := expectDelim(decoder, '['); err != nil {
+				return LevelData{}, err
+			}
+			for decoder.More() {
+				var entity LevelDataEntity
+				if err := decoder.Decode(&entity); err != nil {
+					return LevelData{}, err
+				}
+				if keep == nil || keep(&entity) {
+					data.Entities = append(data.Entities, entity)
+				}
+			}
+			if err := expectDelim(decoder, ']'); err != nil {
+				return LevelData{}, err
+			}
+		default:
+			// 使わない値は読み飛ばす
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return LevelData{}, err
+			}
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return LevelData{}, err
+	}
+	return data, nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected token: %v (expected %v)", token, delim)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package sonolus
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeLevelDataStream(t *testing.T) {
+	file, err := os.Open("testdata/level_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := DecodeLevelDataStream(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LevelData{
+		BgmOffset: 0.25,
+		Entities: []LevelDataEntity{
+			{Archetype: "Initialization", Data: []LevelDataEntityValue{}},
+			{Archetype: "#BPM_CHANGE", Data: []LevelDataEntityValue{{Name: "#BEAT", Value: 0}, {Name: "#BPM", Value: 120}}},
+			{Archetype: "NormalTapNote", Data: []LevelDataEntityValue{{Name: "lane", Value: -2}, {Name: "#BEAT", Value: 4.5}}},
+			{Archetype: "NormalSlideConnector", Data: []LevelDataEntityValue{{Name: "head", Ref: "start"}, {Name: "tail", Ref: "end"}}},
+		},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %+v, want %+v", data, want)
+	}
+
+	// 一度に読み込んだ場合と同じになる
+	raw, err := os.ReadFile("testdata/level_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshaled LevelData
+	if err := json.Unmarshal(raw, &unmarshaled); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, unmarshaled) {
+		t.Errorf("data = %+v, json.Unmarshal = %+v", data, unmarshaled)
+	}
+}
+
+func TestDecodeLevelDataStreamKeep(t *testing.T) {
+	file, err := os.Open("testdata/level_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := DecodeLevelDataStream(file, func(entity *LevelDataEntity) bool {
+		return strings.HasSuffix(entity.Archetype, "Note")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.BgmOffset != 0.25 || len(data.Entities) != 1 || data.Entities[0].Archetype != "NormalTapNote" {
+		t.Errorf("data = %+v", data)
+	}
+}
+
+func TestDecodeLevelDataStreamInvalid(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`[]`,
+		`{"entities": {}}`,
+		`{"entities": [{"archetype": 1}]}`,
+		`{"bgmOffset": "0", "entities": []}`,
+		`{"entities": []`,
+	} {
+		if _, err := DecodeLevelDataStream(strings.NewReader(input), nil); err == nil {
+			t.Errorf("DecodeLevelDataStream(%q) returned no error", input)
+		}
+	}
+}
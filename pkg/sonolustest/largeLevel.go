@@ -0,0 +1,34 @@
+package sonolustest
+
+import (
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+var largeLevelArchetypes = []string{
+	"NormalTapNote",
+	"CriticalTapNote",
+	"NormalFlickNote",
+	"NormalSlideStartNote",
+	"NormalSlideTickNote",
+	"NormalSlideEndNote",
+}
+
+// 長い譜面の処理時間を測るための、指定したノーツ数の譜面データ（16分で並べる）
+func LargeLevelData(notes int) sonolus.LevelData {
+	entities := []sonolus.LevelDataEntity{
+		{Archetype: "Initialization", Data: []sonolus.LevelDataEntityValue{}},
+		{Archetype: "Stage", Data: []sonolus.LevelDataEntityValue{}},
+		{Archetype: "#BPM_CHANGE", Data: []sonolus.LevelDataEntityValue{{Name: "#BEAT", Value: 0}, {Name: "#BPM", Value: 160}}},
+	}
+	for i := 0; i < notes; i++ {
+		entities = append(entities, sonolus.LevelDataEntity{
+			Archetype: largeLevelArchetypes[i%len(largeLevelArchetypes)],
+			Data: []sonolus.LevelDataEntityValue{
+				{Name: "#BEAT", Value: 4 + float64(i)/4},
+				{Name: "lane", Value: float64(i%12 - 6)},
+				{Name: "size", Value: 1.5},
+			},
+		})
+	}
+	return sonolus.LevelData{BgmOffset: 0, Entities: entities}
+}
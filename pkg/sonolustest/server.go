@@ -0,0 +1,117 @@
+// sonolustestは、pjsekaioverlayを使うコードのテスト用に、Sonolusサーバーの代わりになる
+// httptestのサーバーと譜面データを提供する。
+package sonolustest
+
+import (
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// テスト用の譜面ID
+const LevelId = "chcy-fixture"
+
+// テスト用の譜面情報
+func LevelInfo() sonolus.LevelInfo {
+	var response sonolus.InfoResponse[sonolus.LevelInfo]
+	mustDecodeFixture("fixtures/level.json", &response)
+	return response.Item
+}
+
+// テスト用の譜面データ
+func LevelData() sonolus.LevelData {
+	var data sonolus.LevelData
+	mustDecodeFixture("fixtures/level_data.json", &data)
+	return data
+}
+
+// 最後のノーツの後に、判定のない終点まで続くロングがあるテスト用の譜面データ
+func HoldTailLevelData() sonolus.LevelData {
+	data := LevelData()
+	data.Entities = append(data.Entities, sonolus.LevelDataEntity{
+		Archetype: "HiddenSlideTickNote",
+		Data: []sonolus.LevelDataEntityValue{
+			{Name: "#BEAT", Value: 39.5},
+			{Name: "lane", Value: 0},
+			{Name: "size", Value: 1.5},
+		},
+	})
+	return data
+}
+
+func mustDecodeFixture(name string, v any) {
+	file, err := fixtures.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(v); err != nil {
+		panic(err)
+	}
+}
+
+// gzipで圧縮されていない譜面データのURL（読み込みの失敗のテスト用）
+const BrokenLevelDataUrl = "/sonolus/repository/data/broken"
+
+type Server struct {
+	*httptest.Server
+	// Source.Hostに指定するホスト名
+	Host string
+}
+
+// 譜面情報・譜面データ・ジャケットを返すHTTPSサーバーを起動する
+//
+// pjsekaioverlay.New(pjsekaioverlay.WithHTTPClient(server.Client())) のように
+// サーバーの証明書を信頼するクライアントを指定して使う。
+func NewServer() *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sonolus/levels/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/sonolus/levels/") != LevelId {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := fixtures.ReadFile("fixtures/level.json")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	// 譜面IDの候補を探すための一覧（テスト用の譜面だけを返す）
+	mux.HandleFunc("/sonolus/levels/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sonolus.ItemList[sonolus.LevelInfo]{PageCount: 1, Items: []sonolus.LevelInfo{LevelInfo()}})
+	})
+	mux.HandleFunc("/sonolus/repository/data/"+LevelId, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := fixtures.ReadFile("fixtures/level_data.json")
+		gzipWriter := gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		gzipWriter.Write(data)
+	})
+	mux.HandleFunc(BrokenLevelDataUrl, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/sonolus/repository/cover/"+LevelId, func(w http.ResponseWriter, r *http.Request) {
+		cover := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				cover.Set(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), 0xc0, 0xff})
+			}
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, cover)
+	})
+
+	server := httptest.NewTLSServer(mux)
+	serverUrl, _ := url.Parse(server.URL)
+	return &Server{Server: server, Host: serverUrl.Host}
+}
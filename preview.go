@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+// 数フレームごとに縮小して描画したプレビューを書き出す（生成やAviUtlへの読み込みの前に、配置と同期を確認する用）
+func previewMain(args []string) {
+	flags := flag.NewFlagSet("preview", flag.ExitOnError)
+	defaults := pjsekaioverlay.DefaultPreviewOptions()
+	var out string
+	flags.StringVar(&out, "o", "preview.gif", "書き出すファイルを指定します。拡張子で形式を決めます。mp4はffmpegが必要です。(.gif, .mp4)\nEnter the file to write. The extension decides the format. mp4 requires ffmpeg. (.gif, .mp4)")
+	var step int
+	flags.IntVar(&step, "step", defaults.Step, "何フレーム（60fps）ごとに描画するかを指定します。\nEnter how many frames (60fps) to advance between drawn frames.")
+	var width int
+	flags.IntVar(&width, "width", defaults.Width, "プレビューの幅を指定します。（高さは16:9に合わせます）\nEnter the width of the preview. (The height follows 16:9)")
+	var video string
+	flags.StringVar(&video, "video", "", "下に重ねる録画を指定します。同期の確認用です。（mp4の場合のみ）\nEnter a recording to draw the preview over, for checking sync. (mp4 only)")
+	var offset float64
+	flags.Float64Var(&offset, "offset", defaults.Offset, "譜面の0秒が動画の何秒目かを指定します。（既定はexoから書き出した動画の位置）\nEnter the video time of the chart's 0s. (Defaults to the exo render)")
+	var teamPower int
+	flags.IntVar(&teamPower, "team-power", pjsekaioverlay.DefaultGenerateOptions.TeamPower, "総合力を指定します。\nEnter the team power.")
+	var cacheDir string
+	flags.StringVar(&cacheDir, "cache-dir", "", "譜面とジャケットのキャッシュを置くディレクトリを指定します。（空の場合はキャッシュしない）\nEnter the directory to cache charts and jackets in. (No cache if empty)")
+	flags.Usage = func() {
+		fmt.Println("Usage: pjsekai-overlay preview [options] [譜面ID (Chart ID)]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fail := func(err error) {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	options := pjsekaioverlay.PreviewOptions{Step: step, Width: width, Video: video, Offset: offset}
+	format, err := pjsekaioverlay.PreviewFormatFromPath(out)
+	if err != nil {
+		fail(err)
+	}
+	if err := options.Validate(format); err != nil {
+		fail(err)
+	}
+
+	start := time.Now()
+	client := pjsekaioverlay.New(pjsekaioverlay.WithCacheDir(cacheDir))
+	generateOptions := pjsekaioverlay.DefaultGenerateOptions
+	generateOptions.ChartId = flags.Arg(0)
+	generateOptions.TeamPower = teamPower
+	// ジャケットと背景はファイルに書き出さない
+	output := pjsekaioverlay.NewMemoryOutput()
+	timeline, err := client.GenerateTimeline(generateOptions, output, func(step string) {
+		fmt.Printf("- %s\n", step)
+	})
+	if err != nil {
+		fail(err)
+	}
+	if background, _, err := image.Decode(bytes.NewReader(output.File("background.png"))); err == nil {
+		options.Background = background
+	}
+
+	fmt.Print("- プレビューを描画中 (Rendering preview)... ")
+	if err := pjsekaioverlay.WritePreview(timeline, out, options); err != nil {
+		fail(err)
+	}
+	fmt.Println(color.GreenString(fmt.Sprintf("OK (%s, %.1fs)", out, time.Since(start).Seconds())))
+}
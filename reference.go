@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/sonolus"
+	"github.com/fatih/color"
+)
+
+// 実機の録画から読み取ったスコアとコンボを計算結果と比べ、ずれを報告する（スコアのルールを変えたときの確認用）
+func referenceMain(args []string) {
+	flags := flag.NewFlagSet("reference", flag.ExitOnError)
+	var levelFile string
+	flags.StringVar(&levelFile, "level-file", "", "サーバーから取得する代わりに使う譜面データのファイルを指定します。\nEnter a level data file to use instead of fetching the chart.")
+	var rating int
+	flags.IntVar(&rating, "rating", 30, "-level-fileの譜面のレベルを指定します。\nEnter the level of the -level-file chart.")
+	var power int
+	flags.IntVar(&power, "power", 250000, "録画したときの総合力を指定します。\nEnter the team power used in the capture.")
+	var scoreRulesFile string
+	flags.StringVar(&scoreRulesFile, "score-rules", "", "スコアのルール（JSONの配列）を追加するファイルを指定します。\nEnter a file (JSON array) that adds score rules.")
+	var gameVersion string
+	flags.StringVar(&gameVersion, "game-version", "", "比べるスコアのルールの名前か日付（YYYY-MM-DD）を指定します。\nEnter the name or date (YYYY-MM-DD) of the score rules to compare.")
+	var offsetValue string
+	flags.StringVar(&offsetValue, "offset", "auto", "譜面の0秒が録画の何秒目かを指定します。autoの場合はコンボの変化から推定します。\nEnter the capture time of the chart's 0s. auto estimates it from the combo changes.")
+	var tolerance float64
+	flags.Float64Var(&tolerance, "tolerance", 1, "許容する時間のずれ（フレーム、60fps）を指定します。\nEnter the allowed time drift in frames. (60fps)")
+	flags.Usage = func() {
+		fmt.Println("Usage: pjsekai-overlay reference [options] [録画の読み取り結果 (Capture reference: time,score,combo CSV or JSON)] [譜面ID (Chart ID)]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || (levelFile == "" && flags.NArg() < 2) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fail := func(err error) {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	samples, err := pjsekaioverlay.LoadReferenceSamples(flags.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+
+	levelInfo := sonolus.LevelInfo{Rating: rating}
+	var levelData sonolus.LevelData
+	if levelFile != "" {
+		levelData, err = pjsekaioverlay.LoadLevelFile(levelFile, nil)
+		if err != nil {
+			fail(err)
+		}
+	} else {
+		client := pjsekaioverlay.New()
+		matches, err := client.FindChart(flags.Arg(1))
+		if err != nil {
+			fail(err)
+		}
+		levelInfo = matches[0].Level
+		levelData, err = client.FetchLevelData(matches[0].Source, levelInfo)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	scoreRulesets := pjsekaioverlay.BuiltinScoreRules
+	if scoreRulesFile != "" {
+		loadedRules, err := pjsekaioverlay.LoadScoreRules(scoreRulesFile)
+		if err != nil {
+			fail(err)
+		}
+		scoreRulesets = append(scoreRulesets, loadedRules...)
+	}
+	scoreRules, err := pjsekaioverlay.SelectScoreRules(scoreRulesets, gameVersion)
+	if err != nil {
+		fail(err)
+	}
+	frames := pjsekaioverlay.CalculateScoreWithRules(levelInfo, levelData, power, scoreRules)
+
+	counter := pjsekaioverlay.ComboCounter{}
+	var offset float64
+	if offsetValue == "auto" {
+		offset, err = pjsekaioverlay.EstimateReferenceOffset(frames, samples, counter)
+	} else {
+		offset, err = strconv.ParseFloat(offsetValue, 64)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	report := pjsekaioverlay.CompareReference(frames, samples, offset, tolerance/60, counter)
+	fmt.Printf("- 比べた点 (Samples): %d\n", report.Samples)
+	fmt.Printf("- 譜面の0秒の位置 (Chart start): %.3fs\n", report.Offset)
+	fmt.Printf("- スコアの最大のずれ (Max score drift): %d (%.3fs)\n", report.MaxScoreDrift, report.MaxScoreDriftTime)
+	fmt.Printf("- スコアの不一致 (Score mismatches): %d\n", report.ScoreMismatches)
+	fmt.Printf("- コンボの不一致 (Combo mismatches): %d\n", report.ComboMismatches)
+	// 録画の時間の丸めの分は誤差として、0.1フレーム単位で比べる
+	timeDrift := math.Round(report.MaxTimeDrift*600) / 10
+	fmt.Printf("- 時間の最大のずれ (Max time drift): %.1f frames (%.3fs)\n", timeDrift, report.MaxTimeDriftTime)
+
+	if report.MaxScoreDrift > 0 || timeDrift > tolerance {
+		fmt.Println(color.RedString("録画と計算結果が一致しませんでした。(The simulated timeline does not match the capture.)"))
+		os.Exit(1)
+	}
+	fmt.Println(color.GreenString("録画と計算結果が一致しました。(The simulated timeline matches the capture.)"))
+}
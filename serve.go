@@ -0,0 +1,262 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+//go:embed web/index.html
+var webIndex []byte
+
+type serveRequest struct {
+	ChartId         string `json:"chartId"`
+	TeamPower       int    `json:"teamPower"`
+	BackgroundStyle string `json:"backgroundStyle"`
+	ApCombo         bool   `json:"apCombo"`
+	ComboMilestone  bool   `json:"comboMilestone"`
+	RankEffect      bool   `json:"rankEffect"`
+}
+
+type serveJob struct {
+	Id     string `json:"id"`
+	Status string `json:"status"` // queued, running, done, failed
+	Step   string `json:"step"`
+	Error  string `json:"error,omitempty"`
+	// 段階ごとの処理時間
+	Timings []pjsekaioverlay.StageTiming `json:"timings,omitempty"`
+	outDir  string
+}
+
+type queuedJob struct {
+	id      string
+	options pjsekaioverlay.GenerateOptions
+}
+
+type server struct {
+	client  *pjsekaioverlay.Client
+	assets  string
+	workDir string
+
+	mutex sync.Mutex
+	jobs  map[string]*serveJob
+	queue chan queuedJob
+}
+
+// キューに入ったジョブを順番に処理する
+func (s *server) worker() {
+	for queued := range s.queue {
+		id := queued.id
+		s.updateJob(id, func(job *serveJob) { job.Status = "running" })
+		options := queued.options
+		// 出力先に別のファイルが残っていても混ざらないように、空にしてから生成する
+		var pipeline *pjsekaioverlay.Pipeline
+		err := os.RemoveAll(options.OutDir)
+		if err == nil {
+			err = os.MkdirAll(options.OutDir, 0755)
+		}
+		if err == nil {
+			pipeline, err = s.client.NewPipeline(options, pjsekaioverlay.DirOutput(options.OutDir), filepath.Join(options.OutDir, pjsekaioverlay.PipelineStateFileName))
+		}
+		if err == nil {
+			err = pipeline.Run(func(step string) {
+				s.updateJob(id, func(job *serveJob) { job.Step = step })
+			})
+		}
+		s.updateJob(id, func(job *serveJob) {
+			if pipeline != nil {
+				job.Timings = pipeline.Timings.List()
+			}
+			if err != nil {
+				job.Status = "failed"
+				job.Error = err.Error()
+			} else {
+				job.Status = "done"
+			}
+		})
+	}
+}
+
+func (s *server) updateJob(id string, update func(job *serveJob)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	update(s.jobs[id])
+}
+
+// 指定されなかった項目はCLIの初期値を使う
+func defaultServeRequest() serveRequest {
+	return serveRequest{
+		TeamPower:       pjsekaioverlay.DefaultGenerateOptions.TeamPower,
+		BackgroundStyle: pjsekaioverlay.DefaultGenerateOptions.BackgroundStyle,
+		ApCombo:         pjsekaioverlay.DefaultGenerateOptions.ApCombo,
+		ComboMilestone:  pjsekaioverlay.DefaultGenerateOptions.ComboMilestone,
+		RankEffect:      pjsekaioverlay.DefaultGenerateOptions.RankEffect,
+	}
+}
+
+func (request serveRequest) Validate() error {
+	if request.ChartId == "" {
+		return errors.New("譜面IDを指定して下さい。(Please specify the chart ID.)")
+	}
+	_, err := pjsekaioverlay.GetBackgroundComposer(request.BackgroundStyle)
+	return err
+}
+
+func (request serveRequest) generateOptions(assets string, outDir string) pjsekaioverlay.GenerateOptions {
+	options := pjsekaioverlay.DefaultGenerateOptions
+	options.ChartId = request.ChartId
+	options.OutDir = outDir
+	options.Assets = assets
+	options.TeamPower = request.TeamPower
+	options.ApCombo = request.ApCombo
+	options.BackgroundStyle = request.BackgroundStyle
+	options.ComboMilestone = request.ComboMilestone
+	options.RankEffect = request.RankEffect
+	return options
+}
+
+func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	request := defaultServeRequest()
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ChartId == "" {
+		http.Error(w, "譜面IDを指定して下さい。(Please specify the chart ID.)", http.StatusBadRequest)
+		return
+	}
+	if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 再起動しても前回のジョブと重ならないように、IDは作成したディレクトリの名前にする
+	if err := os.MkdirAll(s.workDir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	outDir, err := os.MkdirTemp(s.workDir, "job-")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := filepath.Base(outDir)
+	job := &serveJob{Id: id, Status: "queued", outDir: outDir}
+
+	s.mutex.Lock()
+
+	options := request.generateOptions(s.assets, job.outDir)
+	select {
+	case s.queue <- queuedJob{id: id, options: options}:
+	default:
+		s.mutex.Unlock()
+		os.Remove(outDir)
+		http.Error(w, "キューが一杯です。しばらくしてから再度お試し下さい。(The queue is full. Please try again later.)", http.StatusServiceUnavailable)
+		return
+	}
+	s.jobs[id] = job
+	response := *job
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *server) getJob(id string) (serveJob, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return serveJob{}, false
+	}
+	return *job, true
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.getJob(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// 出力先ディレクトリをzipにまとめて返す
+func (s *server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.getJob(r.PathValue("id"))
+	if !ok || job.Status != "done" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"pjsekai-overlay-%s.zip\"", job.Id))
+
+	pjsekaioverlay.ArchiveDir(w, job.outDir)
+}
+
+// ブラウザから譜面IDを入力して生成できるWebサーバーを起動する
+func serveMain(args []string) {
+	Title()
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	var addr string
+	flags.StringVar(&addr, "addr", "127.0.0.1:8080", "待ち受けるアドレスを指定します。(Enter the address to listen on.)")
+	var workDir string
+	flags.StringVar(&workDir, "work-dir", "./dist/serve", "生成したファイルの保存先を指定します。(Enter the directory to store generated files.)")
+	var cacheDir string
+	flags.StringVar(&cacheDir, "cache-dir", "./dist/cache", "譜面データやジャケットをキャッシュするディレクトリを指定します。（空で無効）\nEnter the directory to cache chart data and images. (empty to disable)")
+	var workers int
+	flags.IntVar(&workers, "workers", 1, "同時に処理するジョブの数を指定します。(Enter the number of jobs processed at the same time.)")
+	var queueSize int
+	flags.IntVar(&queueSize, "queue-size", 64, "待機できるジョブの数を指定します。(Enter the maximum number of queued jobs.)")
+	var assetsDir string
+	flags.StringVar(&assetsDir, "assets-dir", "", "アセットのディレクトリを指定します。（空で実行ファイルの隣のassets）\nEnter the assets directory. (empty for assets next to the executable)")
+	flags.Parse(args)
+
+	if workers <= 0 || queueSize <= 0 {
+		fmt.Println(color.RedString("FAIL:--workersと--queue-sizeには1以上を指定して下さい。(--workers and --queue-size must be at least 1.)"))
+		return
+	}
+
+	assets, err := resolveAssetsDir(assetsDir)
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		return
+	}
+
+	s := &server{
+		client:  pjsekaioverlay.New(pjsekaioverlay.WithCacheDir(cacheDir)),
+		assets:  assets,
+		workDir: workDir,
+		jobs:    map[string]*serveJob{},
+		queue:   make(chan queuedJob, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webIndex)
+	})
+	mux.HandleFunc("POST /api/generate", s.handleGenerate)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /api/jobs/{id}/download", s.handleDownload)
+	// 自動化用のREST API
+	mux.HandleFunc("POST /generate", s.handleGenerate)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /jobs/{id}/download", s.handleDownload)
+
+	fmt.Printf("- Webサーバーを起動しました (Web server started): %s\n", color.CyanString("http://"+addr))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+	"github.com/google/go-github/v57/github"
+)
+
+// リリースに添付されるアセットパックのファイル名
+const (
+	assetPackFileName          = "assets.zip"
+	assetPackChecksumFileName  = assetPackFileName + ".sha256"
+	assetPackSignatureFileName = assetPackFileName + ".sig"
+)
+
+// 最新のリリースからアセットパックを取得し、assetsディレクトリを更新する
+func updateAssetsMain(args []string) {
+	Title()
+
+	flags := flag.NewFlagSet("update-assets", flag.ExitOnError)
+	var publicKeyHex string
+	flags.StringVar(&publicKeyHex, "public-key", "", "署名の確認に使うEd25519の公開鍵を16進数で指定します。（省略した場合はリリースの公開鍵）\nEnter the Ed25519 public key in hex to verify the signature with. (defaults to the release key)")
+	var force bool
+	flags.BoolVar(&force, "force", false, "同じバージョンでもインストールし直します。(Reinstall even if the version is the same.)")
+	flags.Parse(args)
+
+	if err := updateAssets(publicKeyHex, force); err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+}
+
+func updateAssets(publicKeyHex string, force bool) error {
+	var publicKey ed25519.PublicKey
+	if publicKeyHex != "" {
+		key, err := hex.DecodeString(publicKeyHex)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("公開鍵が不正です。(Invalid public key.) [%s]", publicKeyHex)
+		}
+		publicKey = key
+	}
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	assets := filepath.Join(filepath.Dir(executablePath), "assets")
+
+	fmt.Print("- リリースを確認中 (Checking releases)... ")
+	githubClient := github.NewClient(nil)
+	release, _, err := githubClient.Repositories.GetLatestRelease(context.Background(), "TootieJin", "pjsekai-overlay-APPEND")
+	if err != nil {
+		return fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	urls := map[string]string{}
+	for _, asset := range release.Assets {
+		urls[asset.GetName()] = asset.GetBrowserDownloadURL()
+	}
+	// 署名のないアセットパックはインストールしない
+	if urls[assetPackFileName] == "" || urls[assetPackChecksumFileName] == "" || urls[assetPackSignatureFileName] == "" {
+		return fmt.Errorf("リリースにアセットパックがありません。(The release has no asset pack.) [%s]", release.GetTagName())
+	}
+	fmt.Println(color.GreenString("OK"))
+
+	version := strings.TrimPrefix(release.GetTagName(), "v")
+	if !force && pjsekaioverlay.InstalledAssetPackVersion(assets) == version {
+		fmt.Println(color.GreenString(fmt.Sprintf("アセットは最新です。(Assets are up to date.) [%s]", version)))
+		return nil
+	}
+
+	checksum, err := fetchReleaseFile(urls[assetPackChecksumFileName])
+	if err != nil {
+		return err
+	}
+	pack := pjsekaioverlay.AssetPack{Version: version, Url: urls[assetPackFileName]}
+	pack.Sha256, err = pjsekaioverlay.ParseAssetPackChecksum(string(checksum))
+	if err != nil {
+		return err
+	}
+	pack.Signature, err = fetchReleaseFile(urls[assetPackSignatureFileName])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("- アセットパックをインストール中 (Installing asset pack): %s... ", color.GreenString(version))
+	if err := pjsekaioverlay.New().InstallAssetPack(pack, publicKey, assets); err != nil {
+		return err
+	}
+	fmt.Println(color.GreenString("OK"))
+	return nil
+}
+
+func fetchReleaseFile(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("サーバーに接続できませんでした。(Could not connect to server.) [%s]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("ファイルが見つかりませんでした。(File not found.) [%d]", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
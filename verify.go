@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	"github.com/fatih/color"
+)
+
+// 出力先ディレクトリのファイルがmanifest.jsonと一致するか確認する
+func verifyMain(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: pjsekai-overlay verify [出力先ディレクトリ (Output directory)]")
+		os.Exit(2)
+	}
+	problems, err := pjsekaioverlay.VerifyManifest(args[0])
+	if err != nil {
+		fmt.Println(color.RedString(fmt.Sprintf("FAIL:%s", err.Error())))
+		os.Exit(1)
+	}
+	for _, problem := range problems {
+		fmt.Println(color.RedString(problem))
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println(color.GreenString("全てのファイルがmanifest.jsonと一致しました。(All files match manifest.json.)"))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TootieJin/pjsekai-overlay-APPEND/pkg/pjsekaioverlay"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ウィザードを途中で閉じた
+var errWizardCancelled = errors.New("cancelled")
+
+type wizardStep int
+
+const (
+	wizardSource wizardStep = iota
+	wizardChartId
+	wizardTeamPower
+	wizardOptions
+	wizardSummary
+)
+
+// ウィザードで切り替えられるオプション
+type wizardToggle struct {
+	flag  string
+	label string
+	value bool
+}
+
+type wizardModel struct {
+	step wizardStep
+	// サーバーの絞り込みに入力した文字列
+	filter  string
+	cursor  int
+	source  *pjsekaioverlay.Source
+	chartId string
+	power   string
+	toggles []wizardToggle
+	message string
+	done    bool
+}
+
+func newWizardModel() wizardModel {
+	return wizardModel{
+		power: "250000",
+		toggles: []wizardToggle{
+			{"ap-combo", "コンボのAP表示 (AP indicator for combo)", true},
+			{"combo-milestone", "100コンボごとの演出 (Effect at every 100 combo)", true},
+			{"rank-effect", "ランクが上がった時の演出 (Effect when the rank goes up)", true},
+			{"judgment-counter", "判定数の表示 (Judgment counts)", false},
+			{"density-overlay", "ノーツ密度のグラフ (Note density graph)", false},
+			{"ymm4", "YMM4用のプロジェクト (YMM4 project)", false},
+			{"aviutl2", "AviUtl2用のファイル (AviUtl2 files)", false},
+			{"archive", "zipファイルにまとめる (Pack into a zip file)", false},
+		},
+	}
+}
+
+// 絞り込みに一致するサーバー（先頭のnilは譜面IDの接頭辞から判別する）
+func (m wizardModel) filteredSources() []*pjsekaioverlay.Source {
+	sources := []*pjsekaioverlay.Source{nil}
+	for i := range pjsekaioverlay.DefaultSources {
+		source := &pjsekaioverlay.DefaultSources[i]
+		text := strings.ToLower(source.Name + " " + source.Id + " " + source.Host + " " + source.Prefix)
+		if strings.Contains(text, strings.ToLower(m.filter)) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+func (m wizardModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if key.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	m.message = ""
+	if key.Type == tea.KeyEsc {
+		if m.step > wizardSource {
+			m.step--
+			m.cursor = 0
+		}
+		return m, nil
+	}
+
+	switch m.step {
+	case wizardSource:
+		sources := m.filteredSources()
+		switch key.Type {
+		case tea.KeyUp:
+			m.cursor = max(m.cursor-1, 0)
+		case tea.KeyDown:
+			m.cursor = min(m.cursor+1, len(sources)-1)
+		case tea.KeyBackspace:
+			m.filter = trimLastRune(m.filter)
+			m.cursor = 0
+		case tea.KeyRunes:
+			m.filter += string(key.Runes)
+			m.cursor = 0
+		case tea.KeyEnter:
+			m.source = sources[m.cursor]
+			if m.source != nil && !strings.HasPrefix(m.chartId, m.source.Prefix) {
+				m.chartId = m.source.Prefix
+			}
+			m.step = wizardChartId
+		}
+	case wizardChartId:
+		switch key.Type {
+		case tea.KeyBackspace:
+			m.chartId = trimLastRune(m.chartId)
+		case tea.KeyRunes:
+			m.chartId += string(key.Runes)
+		case tea.KeyEnter:
+			if m.source == nil && !hasKnownPrefix(m.chartId) {
+				m.message = "譜面IDをプレフィックス込みで入力して下さい。(Enter the chart ID including the prefix.)"
+				break
+			}
+			m.step = wizardTeamPower
+		}
+	case wizardTeamPower:
+		switch key.Type {
+		case tea.KeyBackspace:
+			m.power = trimLastRune(m.power)
+		case tea.KeyRunes:
+			m.power += string(key.Runes)
+		case tea.KeyEnter:
+			if power, err := strconv.Atoi(m.power); err != nil || power <= 0 {
+				m.message = "総合力が不正です。(Invalid team power.)"
+				break
+			}
+			m.step = wizardOptions
+			m.cursor = 0
+		}
+	case wizardOptions:
+		switch key.Type {
+		case tea.KeyUp:
+			m.cursor = max(m.cursor-1, 0)
+		case tea.KeyDown:
+			m.cursor = min(m.cursor+1, len(m.toggles)-1)
+		case tea.KeySpace:
+			m.toggles[m.cursor].value = !m.toggles[m.cursor].value
+		case tea.KeyEnter:
+			m.step = wizardSummary
+		}
+	case wizardSummary:
+		if key.Type == tea.KeyEnter {
+			m.done = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m wizardModel) View() string {
+	var builder strings.Builder
+	builder.WriteString("\n")
+	switch m.step {
+	case wizardSource:
+		builder.WriteString("サーバーを選んで下さい。文字を入力すると絞り込めます。\nSelect the source. Type to filter.\n\n")
+		fmt.Fprintf(&builder, "  > %s\n\n", m.filter)
+		for i, source := range m.filteredSources() {
+			cursor := "  "
+			if i == m.cursor {
+				cursor = "> "
+			}
+			if source == nil {
+				fmt.Fprintf(&builder, "%s自動（譜面IDから判別） (Auto-detect from the chart ID)\n", cursor)
+				continue
+			}
+			fmt.Fprintf(&builder, "%s%s%s%s (%s, '%s')\n", cursor, RgbColorEscape(source.Color), source.Name, ResetEscape(), source.Host, source.Prefix)
+		}
+	case wizardChartId:
+		builder.WriteString("譜面IDをプレフィックス込みで入力して下さい。\nEnter the chart ID including the prefix.\n\n")
+		fmt.Fprintf(&builder, "  > %s\n", m.chartId)
+	case wizardTeamPower:
+		builder.WriteString("総合力を指定してください。\nInput your team's power.\n\n")
+		fmt.Fprintf(&builder, "  > %s\n", m.power)
+	case wizardOptions:
+		builder.WriteString("オプションを選んで下さい。（スペースで切り替え）\nSelect the options. (Space to toggle)\n\n")
+		for i, toggle := range m.toggles {
+			cursor := "  "
+			if i == m.cursor {
+				cursor = "> "
+			}
+			check := "[ ]"
+			if toggle.value {
+				check = "[x]"
+			}
+			fmt.Fprintf(&builder, "%s%s %s\n", cursor, check, toggle.label)
+		}
+	case wizardSummary:
+		builder.WriteString("以下の内容で生成します。(The overlay will be generated with these settings.)\n\n")
+		source := "自動 (Auto)"
+		if m.source != nil {
+			source = RgbColorEscape(m.source.Color) + m.source.Name + ResetEscape()
+		}
+		fmt.Fprintf(&builder, "  サーバー (Source): %s\n", source)
+		fmt.Fprintf(&builder, "  譜面ID (Chart ID): %s\n", m.chartId)
+		fmt.Fprintf(&builder, "  総合力 (Team power): %s\n", m.power)
+		for _, toggle := range m.toggles {
+			if toggle.value {
+				fmt.Fprintf(&builder, "  %s\n", toggle.label)
+			}
+		}
+		builder.WriteString("\nEnterで開始します。(Press Enter to start.)\n")
+	}
+	if m.message != "" {
+		fmt.Fprintf(&builder, "\n%s\n", m.message)
+	}
+	builder.WriteString("\nEnter: 次へ (Next)  Esc: 戻る (Back)  Ctrl+C: 終了 (Quit)\n")
+	return builder.String()
+}
+
+// ウィザードの選択内容をコマンドライン引数にする
+func (m wizardModel) args() []string {
+	args := []string{"-team-power=" + m.power}
+	if m.source != nil {
+		args = append(args, "-source="+m.source.Id)
+	}
+	for _, toggle := range m.toggles {
+		args = append(args, fmt.Sprintf("-%s=%t", toggle.flag, toggle.value))
+	}
+	return append(args, m.chartId)
+}
+
+func hasKnownPrefix(chartId string) bool {
+	for _, source := range pjsekaioverlay.DefaultSources {
+		if strings.HasPrefix(chartId, source.Prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimLastRune(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
+	}
+	return string(runes[:len(runes)-1])
+}
+
+// 対話形式で設定を選んでもらい、コマンドライン引数として返す
+func runWizard() ([]string, error) {
+	result, err := tea.NewProgram(newWizardModel()).Run()
+	if err != nil {
+		return nil, err
+	}
+	model := result.(wizardModel)
+	if !model.done {
+		return nil, errWizardCancelled
+	}
+	return model.args(), nil
+}